@@ -30,7 +30,7 @@ func main() {
 	h := bootstrap.InitHandlers(s)
 
 	// ========== inisialisasi cron job =========
-	cronManager := cron.NewCronManager(s.PaymentService, s.TemplateService, s.NotificationService, s.BookingService)
+	cronManager := cron.NewCronManager(s.PaymentService, s.TemplateService, s.NotificationService, s.BookingService, s.ScheduleService, s.SubscriptionService, s.UserPackageService, s.ReconcileService, s.FreezeService)
 	cronManager.RegisterJobs()
 	cronManager.Start()
 
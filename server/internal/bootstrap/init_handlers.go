@@ -22,6 +22,18 @@ type Handlers struct {
 	UserPackageHandler  *handlers.UserPackageHandler
 	SubcategoryHandler  *handlers.SubcategoryHandler
 	NotificationHandler *handlers.NotificationHandler
+	AvailabilityHandler *handlers.AvailabilityHandler
+	CreditHandler       *handlers.CreditHandler
+	SubscriptionHandler *handlers.SubscriptionHandler
+	FreezeHandler       *handlers.FreezeHandler
+	MemberHandler       *handlers.PackageMemberHandler
+	GiftHandler         *handlers.GiftHandler
+	RefundHandler       *handlers.RefundHandler
+	WebhookHandler      *handlers.WebhookHandler
+	TaxHandler          *handlers.TaxHandler
+	InvoiceHandler      *handlers.InvoiceHandler
+	ReconcileHandler    *handlers.ReconciliationHandler
+	WalletHandler       *handlers.WalletHandler
 }
 
 func InitHandlers(s *Services) *Handlers {
@@ -45,5 +57,17 @@ func InitHandlers(s *Services) *Handlers {
 		UserPackageHandler:  handlers.NewUserPackageHandler(s.UserPackageService),
 		SubcategoryHandler:  handlers.NewSubcategoryHandler(s.SubcategoryService),
 		NotificationHandler: handlers.NewNotificationHandler(s.NotificationService),
+		AvailabilityHandler: handlers.NewAvailabilityHandler(s.AvailabilityService),
+		SubscriptionHandler: handlers.NewSubscriptionHandler(s.SubscriptionService),
+		CreditHandler:       handlers.NewCreditHandler(s.CreditService),
+		FreezeHandler:       handlers.NewFreezeHandler(s.FreezeService),
+		MemberHandler:       handlers.NewPackageMemberHandler(s.MemberService),
+		GiftHandler:         handlers.NewGiftHandler(s.GiftService),
+		RefundHandler:       handlers.NewRefundHandler(s.RefundService),
+		WebhookHandler:      handlers.NewWebhookHandler(s.WebhookService),
+		TaxHandler:          handlers.NewTaxHandler(s.TaxService),
+		InvoiceHandler:      handlers.NewInvoiceHandler(s.InvoiceService),
+		ReconcileHandler:    handlers.NewReconciliationHandler(s.ReconcileService),
+		WalletHandler:       handlers.NewWalletHandler(s.WalletService),
 	}
 }
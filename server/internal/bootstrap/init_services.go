@@ -2,6 +2,8 @@ package bootstrap
 
 import (
 	"server/internal/services"
+	"server/pkg/gateway"
+	"server/pkg/meeting"
 
 	"gorm.io/gorm"
 )
@@ -26,14 +28,41 @@ type Services struct {
 	SubcategoryService  services.SubcategoryService
 	TemplateService     services.ScheduleTemplateService
 	NotificationService services.NotificationService
+	AvailabilityService services.AvailabilityService
+	CreditService       services.CreditService
+	SubscriptionService services.SubscriptionService
+	FreezeService       services.FreezeService
+	MemberService       services.PackageMemberService
+	GiftService         services.GiftService
+	RefundService       services.RefundService
+	WebhookService      services.WebhookService
+	TaxService          services.TaxService
+	InvoiceService      services.InvoiceService
+	ReconcileService    services.ReconciliationService
+	WalletService       services.WalletService
 }
 
 func InitServices(r *Repositories, db *gorm.DB) *Services {
 	notificationService := services.NewNotificationService(r.NotificationRepository)
 	voucherService := services.NewVoucherService(r.VoucherRepository)
+	meetingProvider := meeting.NewProvider()
+	gateways := gateway.NewRegistryFromEnv()
+	taxService := services.NewTaxService(r.TaxRuleRepository, r.LocationRepository)
+	subscriptionService := services.NewSubscriptionService(r.SubscriptionRepository, r.PaymentRepository, r.UserPackageRepository, r.UserRepository, r.PackageRepository, taxService, notificationService, gateways)
 	templateService := services.NewScheduleTemplateService(
-		r.TemplateRepository, r.ClassRepository, r.InstructorRepository, r.ScheduleRepository,
+		r.TemplateRepository, r.ClassRepository, r.InstructorRepository, r.ScheduleRepository, r.AvailabilityRepository,
 	)
+	scheduleService := services.NewClassScheduleService(r.ScheduleRepository, templateService, r.ClassRepository, r.InstructorRepository, r.BookingRepository, r.PackageRepository, meetingProvider, notificationService)
+	scheduleService.OnScheduleCompleted(services.NewReviewRequestHook(notificationService))
+	walletService := services.NewWalletService(r.WalletRepository, r.UserRepository, notificationService)
+	paymentService := services.NewPaymentService(r.PaymentRepository, r.PackageRepository, r.UserRepository, voucherService, notificationService, r.UserPackageRepository, subscriptionService, gateways, taxService, r.LocationRepository, walletService, r.DiscrepancyRepository, r.RefundRepository)
+	freezeService := services.NewFreezeService(r.FreezeRepository, r.UserPackageRepository, r.PackageRepository, r.BookingRepository, paymentService, notificationService)
+	paymentService.OnPaymentSucceeded("freeze_fee", freezeService.HandleFeePaid)
+	paymentService.OnPaymentFailed("freeze_fee", freezeService.HandleFeeFailed)
+	giftService := services.NewGiftService(r.GiftRepository, r.PaymentRepository, r.UserRepository, r.AuthRepository, paymentService, notificationService)
+	paymentService.OnPaymentSucceeded("gift", giftService.HandleGiftPaid)
+	invoiceService := services.NewInvoiceService(paymentService, r.LocationRepository)
+	paymentService.OnPaymentSettled(invoiceService.SendReceipt)
 
 	return &Services{
 		UserService:         services.NewUserService(r.UserRepository),
@@ -42,18 +71,30 @@ func InitServices(r *Repositories, db *gorm.DB) *Services {
 		ClassService:        services.NewClassService(r.ClassRepository),
 		LevelService:        services.NewLevelService(r.LevelRepository),
 		ReviewService:       services.NewReviewService(r.ReviewRepository, r.BookingRepository, r.InstructorRepository),
-		PaymentService:      services.NewPaymentService(r.PaymentRepository, r.PackageRepository, r.UserRepository, voucherService, notificationService, r.UserPackageRepository),
-		BookingService:      services.NewBookingService(db, r.BookingRepository, r.PackageRepository, notificationService, r.UserPackageRepository, r.ScheduleRepository),
+		PaymentService:      paymentService,
+		BookingService:      services.NewBookingService(r.BookingRepository, r.PackageRepository, notificationService, r.UserPackageRepository, r.ScheduleRepository, meetingProvider, r.FreezeRepository, r.PackageMemberRepository),
 		VoucherService:      voucherService,
-		PackageService:      services.NewPackageService(r.PackageRepository),
+		PackageService:      services.NewPackageService(r.PackageRepository, r.PaymentRepository, r.UserPackageRepository),
 		CategoryService:     services.NewCategoryService(r.CategoryRepository),
 		LocationService:     services.NewLocationService(r.LocationRepository),
 		DashboardService:    services.NewDashboardService(r.DashboardRepository),
 		InstructorService:   services.NewInstructorService(r.InstructorRepository, r.UserRepository),
-		ScheduleService:     services.NewClassScheduleService(r.ScheduleRepository, templateService, r.ClassRepository, r.InstructorRepository, r.BookingRepository, r.PackageRepository),
-		UserPackageService:  services.NewUserPackageService(r.UserPackageRepository),
+		ScheduleService:     scheduleService,
+		UserPackageService:  services.NewUserPackageService(r.UserPackageRepository, r.CreditLedgerRepository, notificationService),
 		SubcategoryService:  services.NewSubcategoryService(r.SubcategoryRepository),
 		TemplateService:     templateService,
 		NotificationService: notificationService,
+		AvailabilityService: services.NewAvailabilityService(r.AvailabilityRepository, r.InstructorRepository, r.ScheduleRepository, notificationService),
+		SubscriptionService: subscriptionService,
+		CreditService:       services.NewCreditService(r.CreditLedgerRepository, r.UserPackageRepository, r.PackageRepository, r.AuthRepository, notificationService),
+		FreezeService:       freezeService,
+		GiftService:         giftService,
+		RefundService:       services.NewRefundService(r.RefundRepository, r.PaymentRepository, r.UserPackageRepository, r.CreditLedgerRepository, gateways, notificationService),
+		WebhookService:      services.NewWebhookService(r.WebhookEventRepository, paymentService, gateways),
+		TaxService:          taxService,
+		InvoiceService:      invoiceService,
+		ReconcileService:    services.NewReconciliationService(r.DiscrepancyRepository, r.PaymentRepository, r.PackageRepository, paymentService, gateways),
+		WalletService:       walletService,
+		MemberService:       services.NewPackageMemberService(r.PackageMemberRepository, r.UserPackageRepository, r.PackageRepository, r.AuthRepository, notificationService),
 	}
 }
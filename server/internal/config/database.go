@@ -2,13 +2,16 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"time"
 
 	"server/internal/models"
+	"server/pkg/money"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var DB *gorm.DB
@@ -71,9 +74,27 @@ func InitDatabase() {
 		&models.Notification{},
 		&models.NotificationType{},
 		&models.NotificationSetting{},
+		&models.InstructorAvailability{},
+		&models.InstructorTimeOff{},
+		&models.CreditLedgerEntry{},
+		&models.Subscription{},
+		&models.UserPackageFreeze{},
+		&models.UserPackageMember{},
+		&models.PackageGift{},
+		&models.PaymentRefund{},
+		&models.WebhookEvent{},
+		&models.VoucherReservation{},
+		&models.TaxRule{},
+		&models.PaymentTax{},
+		&models.InvoiceSequence{},
+		&models.PaymentDiscrepancy{},
+		&models.WalletTransaction{},
 	); err != nil {
 		panic("Migration failed: " + err.Error())
 	}
+	if err := migrateMinorUnits(DB); err != nil {
+		panic("Money migration failed: " + err.Error())
+	}
 
 	sqlDB, err := DB.DB()
 	if err != nil {
@@ -85,3 +106,56 @@ func InitDatabase() {
 
 	fmt.Println("Database connection established successfully.")
 }
+
+// decimalMoneyColumns are the decimal columns that were replaced by integer minor unit columns
+var decimalMoneyColumns = []struct {
+	model    any
+	from, to string
+}{
+	{&models.Package{}, "price", "price_minor"},
+	{&models.Package{}, "freeze_fee", "freeze_fee_minor"},
+	{&models.Payment{}, "base_price", "base_price_minor"},
+	{&models.Payment{}, "tax", "tax_minor"},
+	{&models.Payment{}, "total", "total_minor"},
+	{&models.Payment{}, "voucher_discount", "voucher_discount_minor"},
+	{&models.Payment{}, "refunded_amount", "refunded_amount_minor"},
+	{&models.PaymentRefund{}, "amount", "amount_minor"},
+	{&models.UserPackageFreeze{}, "fee", "fee_minor"},
+	{&models.Voucher{}, "max_discount", "max_discount_minor"},
+}
+
+// migrateMinorUnits copies amounts stored in major units into the minor unit columns and drops the old columns,
+// rows written before the change were all charged in the default currency
+func migrateMinorUnits(db *gorm.DB) error {
+	currency := money.DefaultCurrency()
+	scale := math.Pow10(money.Exponent(currency))
+	migrator := db.Migrator()
+
+	for _, col := range decimalMoneyColumns {
+		if !migrator.HasColumn(col.model, col.from) {
+			continue
+		}
+		if err := db.Unscoped().Model(col.model).
+			Where("? IS NOT NULL", clause.Column{Name: col.from}).
+			UpdateColumn(col.to, gorm.Expr("ROUND(? * ?)", clause.Column{Name: col.from}, scale)).Error; err != nil {
+			return fmt.Errorf("copy %s to %s: %w", col.from, col.to, err)
+		}
+		if migrator.HasColumn(col.model, "currency") {
+			if err := db.Unscoped().Model(col.model).Where("1 = 1").UpdateColumn("currency", currency).Error; err != nil {
+				return fmt.Errorf("set currency: %w", err)
+			}
+		}
+		if err := migrator.DropColumn(col.model, col.from); err != nil {
+			return fmt.Errorf("drop %s: %w", col.from, err)
+		}
+		fmt.Printf("Migrated %s to minor units.\n", col.from)
+	}
+
+	// the discount of a fixed voucher was an amount, it moved to its own column while percentages stay
+	return db.Unscoped().Model(&models.Voucher{}).
+		Where("discount_type = ? AND discount > 0 AND discount_amount_minor = 0", "fixed").
+		UpdateColumns(map[string]any{
+			"discount_amount_minor": gorm.Expr("ROUND(discount * ?)", scale),
+			"discount":              0,
+		}).Error
+}
@@ -14,6 +14,11 @@ type CronManager struct {
 	scheduleService     services.ScheduleTemplateService
 	notificationService services.NotificationService
 	bookingService      services.BookingService
+	classService        services.ClassScheduleService
+	subscriptionService services.SubscriptionService
+	userPackageService  services.UserPackageService
+	reconcileService    services.ReconciliationService
+	freezeService       services.FreezeService
 }
 
 func NewCronManager(
@@ -21,6 +26,11 @@ func NewCronManager(
 	schedule services.ScheduleTemplateService,
 	notification services.NotificationService,
 	attendance services.BookingService,
+	class services.ClassScheduleService,
+	subscription services.SubscriptionService,
+	userPackage services.UserPackageService,
+	reconcile services.ReconciliationService,
+	freeze services.FreezeService,
 ) *CronManager {
 	return &CronManager{
 		c:                   cron.New(cron.WithSeconds()),
@@ -28,6 +38,11 @@ func NewCronManager(
 		scheduleService:     schedule,
 		notificationService: notification,
 		bookingService:      attendance,
+		classService:        class,
+		subscriptionService: subscription,
+		userPackageService:  userPackage,
+		reconcileService:    reconcile,
+		freezeService:       freeze,
 	}
 }
 
@@ -40,6 +55,49 @@ func (cm *CronManager) RegisterJobs() {
 		} else {
 			log.Println("Payment status updated (pending → failed)")
 		}
+
+		if reopened, err := cm.freezeService.ReopenUnpaidFreezes(); err != nil {
+			log.Println("Error reopening unpaid freezes:", err)
+		} else {
+			log.Printf("%d freezes with an unpaid fee reopened\n", reopened)
+		}
+	})
+
+	// Ask the gateways about checkouts left pending by a lost webhook every 10 minutes
+	cm.c.AddFunc("0 */10 * * * *", func() {
+		log.Println("Cron: Reconciling pending payments...")
+		if result, err := cm.reconcileService.ReconcilePendingPayments(); err != nil {
+			log.Println("Payment reconciliation failed:", err)
+		} else {
+			log.Printf("Payments reconciled: %d checked, %d fulfilled, %d failed, %d discrepancies\n", result.Checked, result.Fulfilled, result.Failed, result.Discrepancies)
+		}
+	})
+
+	// Close subscriptions canceled at period end (everyday @00:45)
+	cm.c.AddFunc("0 45 0 * * *", func() {
+		log.Println("Cron: Closing ended subscriptions...")
+		if err := cm.subscriptionService.ExpireEndedSubscriptions(); err != nil {
+			log.Println("Closing subscriptions failed:", err)
+		} else {
+			log.Println("Ended subscriptions closed")
+		}
+	})
+
+	// Forfeit credits of expired packages, then remind members of upcoming expiry (everyday @01:00)
+	cm.c.AddFunc("0 0 1 * * *", func() {
+		log.Println("Cron: Closing expired packages...")
+		if err := cm.userPackageService.CloseExpiredPackages(); err != nil {
+			log.Println("Closing expired packages failed:", err)
+		} else {
+			log.Println("Expired packages closed")
+		}
+
+		log.Println("Cron: Sending package expiry reminders...")
+		if err := cm.userPackageService.SendExpiryReminders(); err != nil {
+			log.Println("Expiry reminders failed:", err)
+		} else {
+			log.Println("Package expiry reminders sent")
+		}
 	})
 
 	// Generate recurring class schedule (everyday @12:00)
@@ -69,6 +127,16 @@ func (cm *CronManager) RegisterJobs() {
 		}
 	})
 
+	// Move schedules through in_progress → completed every 5 minutes
+	cm.c.AddFunc("@every 5m", func() {
+		log.Println("Cron: Advancing class schedule lifecycle...")
+		if err := cm.classService.AdvanceScheduleLifecycle(); err != nil {
+			log.Println("Advancing schedules failed:", err)
+		} else {
+			log.Println("Class schedule statuses updated")
+		}
+	})
+
 }
 
 func (cm *CronManager) Start() {
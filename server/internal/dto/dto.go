@@ -2,6 +2,8 @@ package dto
 
 import (
 	"mime/multipart"
+
+	"server/pkg/money"
 )
 
 // USER & AUTHENTICATION MODULE MANAGEMENT =============
@@ -36,6 +38,8 @@ type AuthMeResponse struct {
 	Fullname string `json:"fullname"`
 	Avatar   string `json:"avatar"`
 	Role     string `json:"role"`
+
+	WalletBalance float64 `json:"walletBalance"`
 }
 
 type UpdateAvatarRequest struct {
@@ -81,6 +85,8 @@ type UserDetailResponse struct {
 	Bio       string `json:"bio"`
 	LastLogin string `json:"lastLogin"`
 	JoinedAt  string `json:"joinedAt"`
+
+	WalletBalance float64 `json:"walletBalance"`
 }
 
 type UserStatsResponse struct {
@@ -116,6 +122,7 @@ type CreateClassRequest struct {
 	CategoryID    string                  `form:"categoryId" binding:"required"`
 	SubcategoryID string                  `form:"subcategoryId" binding:"required"`
 	Duration      int                     `form:"duration" binding:"required,min=15"`
+	DeliveryMode  string                  `form:"deliveryMode" binding:"omitempty,oneof=in_person online hybrid"`
 	Additional    []string                `form:"additional"`
 	IsActive      bool                    `form:"isActive"`
 	Image         *multipart.FileHeader   `form:"image" binding:"required"`
@@ -127,6 +134,7 @@ type CreateClassRequest struct {
 type UpdateClassRequest struct {
 	Title         string                `form:"title" binding:"required"`
 	Duration      int                   `form:"duration" binding:"required,min=15"`
+	DeliveryMode  string                `form:"deliveryMode" binding:"omitempty,oneof=in_person online hybrid"`
 	Description   string                `form:"description" binding:"required"`
 	IsActive      bool                  `form:"isActive"`
 	Additional    []string              `form:"additional"`
@@ -154,6 +162,8 @@ type ClassDetailResponse struct {
 	Subcategory string   `json:"subcategory"`
 	Galleries   []string `json:"galleries"`
 	CreatedAt   string   `json:"createdAt"`
+
+	DeliveryMode string `json:"deliveryMode"`
 }
 
 type UpdateGalleryRequest struct {
@@ -173,6 +183,7 @@ type ClassResponse struct {
 	Image         string   `json:"image"`
 	IsActive      bool     `json:"isActive"`
 	Duration      int      `json:"duration"`
+	DeliveryMode  string   `json:"deliveryMode"`
 	Description   string   `json:"description"`
 	Additional    []string `json:"additional"`
 	TypeID        string   `json:"typeId"`
@@ -243,12 +254,14 @@ type CreateLocationRequest struct {
 	Name        string `json:"name" binding:"required,min=2"`
 	Address     string `json:"address" binding:"required"`
 	GeoLocation string `json:"geoLocation" binding:"required"`
+	Code        string `json:"code" binding:"omitempty,alphanum,max=10"`
 }
 
 type UpdateLocationRequest struct {
 	Name        string `json:"name" binding:"required,min=2"`
 	Address     string `json:"address" binding:"required"`
 	GeoLocation string `json:"geoLocation" binding:"required"`
+	Code        string `json:"code" binding:"omitempty,alphanum,max=10"`
 }
 
 type LocationResponse struct {
@@ -256,6 +269,7 @@ type LocationResponse struct {
 	Name        string `json:"name"`
 	Address     string `json:"address"`
 	GeoLocation string `json:"geoLocation"`
+	Code        string `json:"code"`
 }
 
 type CreateInstructorRequest struct {
@@ -288,18 +302,26 @@ type InstructorResponse struct {
 // PACKAGE MODULE MANAGEMENT =============================
 
 type PackageQueryParam struct {
-	Q      string `form:"q"`
-	Status string `form:"status"`
-	Sort   string `form:"sort"`
-	Page   int    `form:"page"`
-	Limit  int    `form:"limit"`
+	Q            string `form:"q"`
+	Status       string `form:"status"`
+	Sort         string `form:"sort"`
+	Page         int    `form:"page"`
+	Limit        int    `form:"limit"`
+	EligibleOnly bool   `form:"eligibleOnly"`
 }
 
 type CreatePackageRequest struct {
 	Name        string                `form:"name" binding:"required,min=6"`
 	Description string                `form:"description" binding:"required"`
 	Price       float64               `form:"price" binding:"required,gt=0"`
-	Credit      int                   `form:"credit" binding:"required,gt=0"`
+	Type        string                `form:"type" binding:"omitempty,oneof=credit_pack unlimited weekly_limit"`
+	Credit      int                   `form:"credit" binding:"omitempty,min=0"`
+	WeeklyLimit int                   `form:"weeklyLimit" binding:"omitempty,min=0"`
+	AutoRenew   bool                  `form:"autoRenew"`
+	MaxFreeze   int                   `form:"maxFreezeDays" binding:"omitempty,min=0"`
+	FreezeFee   float64               `form:"freezeFee" binding:"omitempty,min=0"`
+	MaxMembers  int                   `form:"maxMembers" binding:"omitempty,min=0"`
+	MaxTransfer int                   `form:"maxTransferCredits" binding:"omitempty,min=0"`
 	Expired     int                   `form:"expired" binding:"required,gt=0"`
 	Discount    float64               `form:"discount"`
 	Additional  []string              `form:"additional"`
@@ -307,13 +329,40 @@ type CreatePackageRequest struct {
 	ClassIDs    []string              `form:"classIds" binding:"required"`
 	Image       *multipart.FileHeader `form:"image" binding:"required"`
 	ImageURL    string                `form:"-"`
+
+	PackageEligibilityRequest
+	PackageTaxRequest
+}
+
+// PackageEligibilityRequest limits who can buy a package, dates are YYYY-MM-DD and inclusive
+type PackageEligibilityRequest struct {
+	FirstPurchaseOnly bool     `form:"firstPurchaseOnly"`
+	MaxPerUser        int      `form:"maxPerUser" binding:"omitempty,min=0"`
+	Segment           string   `form:"segment" binding:"omitempty,oneof=all members lapsed"`
+	AvailableFrom     string   `form:"availableFrom"`
+	AvailableUntil    string   `form:"availableUntil"`
+	LocationIDs       []string `form:"locationIds"`
+}
+
+// PackageTaxRequest picks the tax rules of a package, an inclusive price already contains the tax
+type PackageTaxRequest struct {
+	TaxCategory  string `form:"taxCategory" binding:"omitempty,max=50"`
+	TaxInclusive bool   `form:"taxInclusive"`
+	TaxExempt    bool   `form:"taxExempt"`
 }
 
 type UpdatePackageRequest struct {
 	Name        string                `form:"name" binding:"required,min=6"`
 	Description string                `form:"description" binding:"required"`
 	Price       float64               `form:"price" binding:"required,gt=0"`
-	Credit      int                   `form:"credit" binding:"required,gt=0"`
+	Type        string                `form:"type" binding:"omitempty,oneof=credit_pack unlimited weekly_limit"`
+	Credit      int                   `form:"credit" binding:"omitempty,min=0"`
+	WeeklyLimit int                   `form:"weeklyLimit" binding:"omitempty,min=0"`
+	AutoRenew   bool                  `form:"autoRenew"`
+	MaxFreeze   int                   `form:"maxFreezeDays" binding:"omitempty,min=0"`
+	FreezeFee   float64               `form:"freezeFee" binding:"omitempty,min=0"`
+	MaxMembers  int                   `form:"maxMembers" binding:"omitempty,min=0"`
+	MaxTransfer int                   `form:"maxTransferCredits" binding:"omitempty,min=0"`
 	Expired     int                   `form:"expired" binding:"required,gt=0"`
 	Discount    float64               `form:"discount"`
 	Additional  []string              `form:"additional"`
@@ -321,34 +370,79 @@ type UpdatePackageRequest struct {
 	ClassIDs    []string              `form:"classIds" binding:"required"`
 	Image       *multipart.FileHeader `form:"image" binding:"required"`
 	ImageURL    string                `form:"-"`
+
+	PackageEligibilityRequest
+	PackageTaxRequest
 }
 
 type PackageListResponse struct {
 	ID          string                 `json:"id"`
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
+	Currency    string                 `json:"currency"`
 	Price       float64                `json:"price"`
+	Type        string                 `json:"type"`
 	Credit      int                    `json:"credit"`
+	WeeklyLimit int                    `json:"weeklyLimit"`
+	AutoRenew   bool                   `json:"autoRenew"`
+	MaxFreeze   int                    `json:"maxFreezeDays"`
+	FreezeFee   float64                `json:"freezeFee"`
+	MaxMembers  int                    `json:"maxMembers"`
+	MaxTransfer int                    `json:"maxTransferCredits"`
 	Expired     int                    `json:"expired"`
 	Image       string                 `json:"image"`
 	Discount    float64                `json:"discount"`
 	IsActive    bool                   `json:"isActive"`
 	Additional  []string               `json:"additional"`
 	Classes     []ClassSummaryResponse `json:"classes"`
+
+	TaxCategory  string `json:"taxCategory"`
+	TaxInclusive bool   `json:"taxInclusive"`
+	TaxExempt    bool   `json:"taxExempt"`
+
+	FirstPurchaseOnly bool     `json:"firstPurchaseOnly"`
+	MaxPerUser        int      `json:"maxPerUser"`
+	Segment           string   `json:"segment"`
+	AvailableFrom     string   `json:"availableFrom,omitempty"`
+	AvailableUntil    string   `json:"availableUntil,omitempty"`
+	LocationIDs       []string `json:"locationIds"`
+	Eligible          *bool    `json:"eligible,omitempty"`
+	IneligibleReason  string   `json:"ineligibleReason,omitempty"`
 }
 
 type PackageDetailResponse struct {
 	ID          string                 `json:"id"`
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
+	Currency    string                 `json:"currency"`
 	Price       float64                `json:"price"`
+	Type        string                 `json:"type"`
 	Credit      int                    `json:"credit"`
+	WeeklyLimit int                    `json:"weeklyLimit"`
+	AutoRenew   bool                   `json:"autoRenew"`
+	MaxFreeze   int                    `json:"maxFreezeDays"`
+	FreezeFee   float64                `json:"freezeFee"`
+	MaxMembers  int                    `json:"maxMembers"`
+	MaxTransfer int                    `json:"maxTransferCredits"`
 	Expired     int                    `json:"expired"`
 	Discount    float64                `form:"discount"`
 	Image       string                 `json:"image"`
 	IsActive    bool                   `json:"isActive"`
 	Additional  []string               `json:"additional"`
 	Classes     []ClassSummaryResponse `json:"classes"`
+
+	TaxCategory  string `json:"taxCategory"`
+	TaxInclusive bool   `json:"taxInclusive"`
+	TaxExempt    bool   `json:"taxExempt"`
+
+	FirstPurchaseOnly bool     `json:"firstPurchaseOnly"`
+	MaxPerUser        int      `json:"maxPerUser"`
+	Segment           string   `json:"segment"`
+	AvailableFrom     string   `json:"availableFrom,omitempty"`
+	AvailableUntil    string   `json:"availableUntil,omitempty"`
+	LocationIDs       []string `json:"locationIds"`
+	Eligible          *bool    `json:"eligible,omitempty"`
+	IneligibleReason  string   `json:"ineligibleReason,omitempty"`
 }
 
 type ClassSummaryResponse struct {
@@ -364,12 +458,89 @@ type ClassSummaryResponse struct {
 type CreatePaymentRequest struct {
 	PackageID   string  `json:"packageId" binding:"required"`
 	VoucherCode *string `json:"voucherCode"`
+	GiftEmail   *string `json:"giftEmail" binding:"omitempty,email"`
+	GiftMessage string  `json:"giftMessage" binding:"omitempty,max=500"`
+	LocationID  *string `json:"locationId" binding:"omitempty,uuid"`
+
+	PaymentMethod string          `json:"paymentMethod" binding:"omitempty,oneof=card qris virtual_account ewallet"`
+	Billing       *BillingDetails `json:"billing"`
+
+	// UseWallet pays from the wallet first, up to WalletAmount when it is given, and the gateway charges the rest
+	UseWallet    bool     `json:"useWallet"`
+	WalletAmount *float64 `json:"walletAmount" binding:"omitempty,gt=0"`
+}
+
+// BillingDetails are printed on the invoice for customers who claim the purchase, e.g. from their employer
+type BillingDetails struct {
+	Company string `json:"company" binding:"omitempty,max=255"`
+	TaxID   string `json:"taxId" binding:"omitempty,max=50"`
+	Address string `json:"address" binding:"omitempty,max=500"`
+}
+
+// OfflinePaymentRequest records a purchase paid at the front desk, Reference is the transfer or EDC slip number
+type OfflinePaymentRequest struct {
+	UserID      string  `json:"userId" binding:"required,uuid"`
+	PackageID   string  `json:"packageId" binding:"required,uuid"`
+	VoucherCode *string `json:"voucherCode"`
+	LocationID  *string `json:"locationId" binding:"omitempty,uuid"`
+
+	Method    string          `json:"method" binding:"required,oneof=cash bank_transfer edc"`
+	Reference string          `json:"reference" binding:"required_unless=Method cash,max=100"`
+	Billing   *BillingDetails `json:"billing"`
+}
+
+type UpgradePackageRequest struct {
+	UserPackageID string `json:"userPackageId" binding:"required,uuid"`
+	PackageID     string `json:"packageId" binding:"required,uuid"`
+	PaymentMethod string `json:"paymentMethod" binding:"omitempty,oneof=card qris virtual_account ewallet"`
+}
+
+type UpgradeQuoteResponse struct {
+	UserPackageID   string  `json:"userPackageId"`
+	CurrentPackage  string  `json:"currentPackage"`
+	TargetPackageID string  `json:"targetPackageId"`
+	TargetPackage   string  `json:"targetPackage"`
+	RemainingCredit int     `json:"remainingCredit"`
+	RemainingDays   int     `json:"remainingDays"`
+	UnusedValue     float64 `json:"unusedValue"`
+	TargetPrice     float64 `json:"targetPrice"`
+	BasePrice       float64 `json:"basePrice"`
+	Tax             float64 `json:"tax"`
+	Total           float64 `json:"total"`
+	NewCredit       int     `json:"newCredit"`
+	NewExpiredAt    string  `json:"newExpiredAt"`
+
+	Taxes []PaymentTaxResponse `json:"taxes"`
+}
+
+type RedeemGiftRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type GiftResponse struct {
+	ID             string `json:"id"`
+	Code           string `json:"code"`
+	PackageID      string `json:"packageId"`
+	PackageName    string `json:"packageName"`
+	From           string `json:"from"`
+	RecipientEmail string `json:"recipientEmail"`
+	Message        string `json:"message"`
+	Status         string `json:"status"`
+	UserPackageID  string `json:"userPackageId,omitempty"`
+	RedeemedAt     string `json:"redeemedAt,omitempty"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+type MyGiftsResponse struct {
+	Sent     []GiftResponse `json:"sent"`
+	Received []GiftResponse `json:"received"`
 }
 
 type CreatePaymentResponse struct {
 	PaymentID string `json:"paymentId"`
 	SessionID string `json:"sessionId"`
 	SnapURL   string `json:"snapUrl"`
+	Status    string `json:"status,omitempty"`
 }
 
 type NotificationEvent struct {
@@ -409,6 +580,7 @@ type PaymentListResponse struct {
 	PackageID     string  `json:"packageId"`
 	PackageName   string  `json:"packageName"`
 	Total         float64 `json:"total"`
+	Refunded      float64 `json:"refundedAmount"`
 	PaymentMethod string  `json:"paymentMethod"`
 	Status        string  `json:"status"`
 	PaidAt        string  `json:"paidAt"`
@@ -422,14 +594,189 @@ type PaymentDetailResponse struct {
 	Fullname        string  `json:"fullname"`
 	PackageID       string  `json:"packageId"`
 	PackageName     string  `json:"packageName"`
+	Currency        string  `json:"currency"`
 	BasePrice       float64 `json:"basePrice"`
 	Tax             float64 `json:"tax"`
 	VoucherCode     string  `json:"voucherCode"`
 	VoucherDiscount float64 `json:"voucherDiscount"`
 	Total           float64 `json:"total"`
+	WalletAmount    float64 `json:"walletAmount"`
+	Refunded        float64 `json:"refundedAmount"`
 	PaymentMethod   string  `json:"paymentMethod"`
+	Gateway         string  `json:"gateway"`
 	Status          string  `json:"status"`
 	PaidAt          string  `json:"paidAt"`
+	Purpose         string  `json:"purpose"`
+	GiftEmail       string  `json:"giftEmail,omitempty"`
+	LocationID      string  `json:"locationId,omitempty"`
+	ReceivedBy      string  `json:"receivedBy,omitempty"`
+	Reference       string  `json:"reference,omitempty"`
+
+	Billing *BillingDetails      `json:"billing,omitempty"`
+	Taxes   []PaymentTaxResponse `json:"taxes"`
+}
+
+type CashDrawerQueryParam struct {
+	Date       string `form:"date"`
+	StaffID    string `form:"staffId"`
+	LocationID string `form:"locationId"`
+}
+
+// CashDrawerReport totals the front-desk payments of one day per staff member, Cash is what should be in the drawer
+type CashDrawerReport struct {
+	Date  string            `json:"date"`
+	Staff []CashDrawerStaff `json:"staff"`
+}
+
+type CashDrawerStaff struct {
+	StaffID   string              `json:"staffId"`
+	StaffName string              `json:"staffName"`
+	Cash      float64             `json:"cash"`
+	Methods   []CashDrawerMethod  `json:"methods"`
+	Payments  []CashDrawerPayment `json:"payments"`
+}
+
+type CashDrawerMethod struct {
+	Method   string  `json:"method"`
+	Count    int     `json:"count"`
+	Currency string  `json:"currency"`
+	Total    float64 `json:"total"`
+}
+
+type CashDrawerPayment struct {
+	ID            string  `json:"id"`
+	InvoiceNumber string  `json:"invoiceNumber"`
+	Fullname      string  `json:"fullname"`
+	PackageName   string  `json:"packageName"`
+	Method        string  `json:"method"`
+	Reference     string  `json:"reference,omitempty"`
+	Status        string  `json:"status"`
+	Total         float64 `json:"total"`
+	PaidAt        string  `json:"paidAt"`
+}
+
+// PaymentTaxResponse is one line of a payment's tax breakdown, Rate is a percentage
+type PaymentTaxResponse struct {
+	Name      string  `json:"name"`
+	Rate      float64 `json:"rate"`
+	Inclusive bool    `json:"inclusive"`
+	Taxable   float64 `json:"taxable"`
+	Amount    float64 `json:"amount"`
+}
+
+// RefundPaymentRequest refunds Amount, or everything not refunded yet when it is omitted
+// RefundPaymentRequest returns money the way it was paid, ToWallet returns all of it as store credit instead
+type RefundPaymentRequest struct {
+	Amount   *float64 `json:"amount" binding:"omitempty,gt=0"`
+	Reason   string   `json:"reason" binding:"required,min=3"`
+	ToWallet bool     `json:"toWallet"`
+}
+
+type RefundResponse struct {
+	ID             string  `json:"id"`
+	PaymentID      string  `json:"paymentId"`
+	Amount         float64 `json:"amount"`
+	Reason         string  `json:"reason"`
+	Status         string  `json:"status"`
+	Provider       string  `json:"provider"`
+	WalletAmount   float64 `json:"walletAmount"`
+	RevokedCredits int     `json:"revokedCredits"`
+	RefundedBy     string  `json:"refundedBy"`
+	CreatedAt      string  `json:"createdAt"`
+}
+
+type WalletQueryParam struct {
+	Type  string `form:"type" binding:"omitempty,oneof=top_up payment release refund"`
+	Page  int    `form:"page" binding:"omitempty,min=1"`
+	Limit int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+type WalletTopUpRequest struct {
+	Amount float64 `json:"amount" binding:"required,gt=0"`
+	Reason string  `json:"reason" binding:"required,min=3,max=500"`
+}
+
+type WalletResponse struct {
+	UserID       string                      `json:"userId"`
+	Balance      float64                     `json:"balance"`
+	Currency     string                      `json:"currency"`
+	Transactions []WalletTransactionResponse `json:"transactions"`
+}
+
+type WalletTransactionResponse struct {
+	ID           string  `json:"id"`
+	Type         string  `json:"type"`
+	Amount       float64 `json:"amount"`
+	BalanceAfter float64 `json:"balanceAfter"`
+	Currency     string  `json:"currency"`
+	PaymentID    string  `json:"paymentId,omitempty"`
+	Reason       string  `json:"reason"`
+	CreatedAt    string  `json:"createdAt"`
+}
+
+type WebhookEventQueryParam struct {
+	Gateway   string `form:"gateway"`
+	Status    string `form:"status" binding:"omitempty,oneof=received processed failed ignored"`
+	Type      string `form:"type"`
+	Reference string `form:"reference"`
+	Page      int    `form:"page" binding:"omitempty,min=1"`
+	Limit     int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// WebhookEventResponse carries the payload only on the detail endpoint
+type WebhookEventResponse struct {
+	ID          string `json:"id"`
+	Gateway     string `json:"gateway"`
+	EventID     string `json:"eventId"`
+	Type        string `json:"type"`
+	Reference   string `json:"reference"`
+	Status      string `json:"status"`
+	Attempts    int    `json:"attempts"`
+	LastError   string `json:"lastError,omitempty"`
+	ReceivedAt  string `json:"receivedAt"`
+	ProcessedAt string `json:"processedAt,omitempty"`
+	Payload     string `json:"payload,omitempty"`
+}
+
+type PaymentDiscrepancyQueryParam struct {
+	Status    string `form:"status" binding:"omitempty,oneof=open resolved"`
+	Kind      string `form:"kind" binding:"omitempty,oneof=status_mismatch amount_mismatch unknown_at_provider wallet_shortfall"`
+	Gateway   string `form:"gateway"`
+	PaymentID string `form:"paymentId"`
+	Page      int    `form:"page" binding:"omitempty,min=1"`
+	Limit     int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// PaymentDiscrepancyResponse compares a payment with its gateway, Action is what reconciliation did about it
+type PaymentDiscrepancyResponse struct {
+	ID             string  `json:"id"`
+	PaymentID      string  `json:"paymentId"`
+	Gateway        string  `json:"gateway"`
+	Kind           string  `json:"kind"`
+	OurStatus      string  `json:"ourStatus"`
+	ProviderStatus string  `json:"providerStatus"`
+	Currency       string  `json:"currency"`
+	ExpectedAmount float64 `json:"expectedAmount"`
+	ProviderAmount float64 `json:"providerAmount"`
+	Action         string  `json:"action"`
+	Detail         string  `json:"detail,omitempty"`
+	Status         string  `json:"status"`
+	Resolution     string  `json:"resolution,omitempty"`
+	ResolvedAt     string  `json:"resolvedAt,omitempty"`
+	FirstSeenAt    string  `json:"firstSeenAt"`
+	LastSeenAt     string  `json:"lastSeenAt"`
+}
+
+type ResolveDiscrepancyRequest struct {
+	Resolution string `json:"resolution" binding:"required,max=1000"`
+}
+
+// ReconciliationResult counts what one run of the reconciliation did
+type ReconciliationResult struct {
+	Checked       int `json:"checked"`
+	Fulfilled     int `json:"fulfilled"`
+	Failed        int `json:"failed"`
+	Discrepancies int `json:"discrepancies"`
 }
 
 // NOTIFICATIONS
@@ -480,6 +827,9 @@ type CreateScheduleRequest struct {
 	StartMinute  int    `json:"startMinute" validate:"required,oneof=0 15 30 45"`
 	Capacity     int    `json:"capacity" validate:"required,min=1"`
 	Color        string `json:"color"`
+
+	DeliveryMode   string `json:"deliveryMode" binding:"omitempty,oneof=in_person online hybrid"`
+	OnlineCapacity int    `json:"onlineCapacity" binding:"omitempty,min=0"`
 }
 
 type CreateRecurringScheduleRequest struct {
@@ -492,6 +842,9 @@ type CreateRecurringScheduleRequest struct {
 	StartMinute  int    `json:"startMinute" validate:"required,oneof=0 15 30 45"`
 	DayOfWeeks   []int  `json:"dayOfWeeks" binding:"required,dive,min=0,max=6"`
 	EndDate      string `json:"endDate,omitempty"`
+
+	DeliveryMode   string `json:"deliveryMode" binding:"omitempty,oneof=in_person online hybrid"`
+	OnlineCapacity int    `json:"onlineCapacity" binding:"omitempty,min=0"`
 }
 
 type UpdateClassScheduleRequest struct {
@@ -502,6 +855,9 @@ type UpdateClassScheduleRequest struct {
 	StartMinute  int    `json:"startMinute" validate:"required,oneof=0 15 30 45"`
 	Capacity     int    `json:"capacity" validate:"required,min=1"`
 	Color        string `json:"color"`
+
+	DeliveryMode   string `json:"deliveryMode" binding:"omitempty,oneof=in_person online hybrid"`
+	OnlineCapacity int    `json:"onlineCapacity" binding:"omitempty,min=0"`
 }
 
 type ScheduleTemplateResponse struct {
@@ -511,10 +867,13 @@ type ScheduleTemplateResponse struct {
 	InstructorID   string `json:"instructorId"`
 	InstructorName string `json:"instructorName"`
 	Instructor     string `json:"instructor"`
+	Room           string `json:"room"`
 	DayOfWeeks     []int  `json:"dayOfWeeks"`
 	StartHour      int    `json:"startHour"`
 	StartMinute    int    `json:"startMinute"`
+	DeliveryMode   string `json:"deliveryMode"`
 	Capacity       int    `json:"capacity"`
+	OnlineCapacity int    `json:"onlineCapacity"`
 	IsActive       bool   `json:"isActive"`
 	Frequency      string `json:"frequency"`
 	EndDate        string `json:"endDate"`
@@ -531,6 +890,9 @@ type CreateScheduleTemplateRequest struct {
 	Capacity     int    `json:"capacity" binding:"required,gt=0"`
 	Color        string `json:"color" binding:"required"`
 	EndDate      string `json:"endDate" binding:"required"`
+
+	DeliveryMode   string `json:"deliveryMode" binding:"omitempty,oneof=in_person online hybrid"`
+	OnlineCapacity int    `json:"onlineCapacity" binding:"omitempty,min=0"`
 }
 
 type UpdateScheduleTemplateRequest struct {
@@ -541,6 +903,72 @@ type UpdateScheduleTemplateRequest struct {
 	StartMinute  int    `json:"startMinute" validate:"required,oneof=0 15 30 45"`
 	Capacity     int    `json:"capacity" binding:"required,gt=0"`
 	EndDate      string `json:"endDate" binding:"required"`
+
+	DeliveryMode   string `json:"deliveryMode" binding:"omitempty,oneof=in_person online hybrid"`
+	OnlineCapacity int    `json:"onlineCapacity" binding:"omitempty,min=0"`
+}
+
+type TimetableClassRequest struct {
+	ClassID         string `json:"classId" binding:"required"`
+	SessionsPerWeek int    `json:"sessionsPerWeek" binding:"required,min=1,max=14"`
+	Capacity        int    `json:"capacity" binding:"required,gt=0"`
+	OnlineCapacity  int    `json:"onlineCapacity" binding:"omitempty,min=0"`
+	Color           string `json:"color"`
+}
+
+type TimetableRoomRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Capacity int    `json:"capacity" binding:"required,gt=0"`
+}
+
+type PlanTimetableRequest struct {
+	LocationID       string                  `json:"locationId" binding:"required"`
+	Classes          []TimetableClassRequest `json:"classes" binding:"required,min=1,dive"`
+	Rooms            []TimetableRoomRequest  `json:"rooms" binding:"required,min=1,dive"`
+	InstructorIDs    []string                `json:"instructorIds"`
+	DayOfWeeks       []int                   `json:"dayOfWeeks" binding:"omitempty,dive,min=0,max=6"`
+	OpenHour         int                     `json:"openHour" binding:"omitempty,min=0,max=23"`
+	CloseHour        int                     `json:"closeHour" binding:"omitempty,min=1,max=24"`
+	MaxClassesPerDay int                     `json:"maxClassesPerDay" binding:"omitempty,min=1"`
+	EndDate          string                  `json:"endDate" binding:"required"`
+}
+
+type TimetableSlot struct {
+	ClassID        string `json:"classId" binding:"required"`
+	ClassName      string `json:"className"`
+	InstructorID   string `json:"instructorId" binding:"required"`
+	InstructorName string `json:"instructorName"`
+	Room           string `json:"room"`
+	DayOfWeeks     []int  `json:"dayOfWeeks" binding:"required,min=1,dive,min=0,max=6"`
+	StartHour      int    `json:"startHour" binding:"min=0,max=23"`
+	StartMinute    int    `json:"startMinute" binding:"oneof=0 15 30 45"`
+	Capacity       int    `json:"capacity" binding:"required,gt=0"`
+	OnlineCapacity int    `json:"onlineCapacity" binding:"omitempty,min=0"`
+	Color          string `json:"color"`
+}
+
+type TimetableUnplaced struct {
+	ClassID   string `json:"classId"`
+	ClassName string `json:"className"`
+	Sessions  int    `json:"sessions"`
+	Reason    string `json:"reason"`
+}
+
+type PlanTimetableResponse struct {
+	EndDate          string                 `json:"endDate"`
+	Rooms            []TimetableRoomRequest `json:"rooms"`
+	MaxClassesPerDay int                    `json:"maxClassesPerDay"`
+	TotalSessions    int                    `json:"totalSessions"`
+	PlacedSessions   int                    `json:"placedSessions"`
+	Slots            []TimetableSlot        `json:"slots"`
+	Unplaced         []TimetableUnplaced    `json:"unplaced"`
+}
+
+type AcceptTimetableRequest struct {
+	EndDate          string                 `json:"endDate" binding:"required"`
+	Rooms            []TimetableRoomRequest `json:"rooms" binding:"required,min=1,dive"`
+	MaxClassesPerDay int                    `json:"maxClassesPerDay" binding:"omitempty,min=1"`
+	Slots            []TimetableSlot        `json:"slots" binding:"required,min=1,dive"`
 }
 
 type ScheduleTemplateToggleRequest struct {
@@ -558,10 +986,14 @@ type ClassScheduleResponse struct {
 	Date           string `json:"date"`
 	StartHour      int    `json:"startHour"`
 	StartMinute    int    `json:"startMinute"`
+	DeliveryMode   string `json:"deliveryMode"`
 	Capacity       int    `json:"capacity"`
+	OnlineCapacity int    `json:"onlineCapacity"`
 	BookedCount    int    `json:"bookedCount"`
+	OnlineBooked   int    `json:"onlineBookedCount"`
 	Duration       int    `json:"duration"`
 	Color          string `json:"color"`
+	Status         string `json:"status"`
 	IsBooked       bool   `json:"isBooked"`
 }
 
@@ -592,6 +1024,45 @@ type ClassScheduleQueryParam struct {
 	EndDate   string `form:"endDate"`
 }
 
+type ImportScheduleRequest struct {
+	File *multipart.FileHeader `form:"file" binding:"required"`
+}
+
+// ScheduleImportRow is one parsed line of a CSV or one VEVENT of an .ics file
+type ScheduleImportRow struct {
+	Row        int
+	Class      string
+	Instructor string
+	Date       string
+	Time       string
+	Capacity   int
+	Color      string
+	Error      string
+}
+
+type ScheduleImportRowResponse struct {
+	Row            int    `json:"row"`
+	ClassID        string `json:"classId,omitempty"`
+	ClassName      string `json:"className"`
+	InstructorID   string `json:"instructorId,omitempty"`
+	InstructorName string `json:"instructorName"`
+	Date           string `json:"date"`
+	StartHour      int    `json:"startHour"`
+	StartMinute    int    `json:"startMinute"`
+	Capacity       int    `json:"capacity"`
+	Color          string `json:"color"`
+	IsValid        bool   `json:"isValid"`
+	Error          string `json:"error,omitempty"`
+}
+
+type ScheduleImportResponse struct {
+	TotalRows   int                         `json:"totalRows"`
+	ValidRows   int                         `json:"validRows"`
+	InvalidRows int                         `json:"invalidRows"`
+	Committed   bool                        `json:"committed"`
+	Rows        []ScheduleImportRowResponse `json:"rows"`
+}
+
 // CLASS-SCHEDULE =====================
 
 // BOOKINGS & ATTENDANCE ===========================
@@ -606,6 +1077,7 @@ type BookingQueryParam struct {
 type CreateBookingRequest struct {
 	PackageID       string `json:"packageId" binding:"required,uuid"`
 	ClassScheduleID string `json:"scheduleId" binding:"required,uuid"`
+	Mode            string `json:"mode" binding:"omitempty,oneof=in_person online"`
 }
 
 type BookingResponse struct {
@@ -620,6 +1092,8 @@ type BookingResponse struct {
 	StartHour      int    `json:"startHour"`
 	StartMinute    int    `json:"startMinute"`
 	Location       string `json:"location"`
+	DeliveryMode   string `json:"deliveryMode"`
+	Mode           string `json:"mode"`
 	BookedAt       string `json:"bookedAt"`
 	IsOpened       bool   `json:"isOpen"`
 }
@@ -637,10 +1111,14 @@ type BookingDetailResponse struct {
 	Duration         int    `json:"duration"`
 	CheckedIn        bool   `json:"checkedIn"`
 	CheckedOut       bool   `json:"checkedOut"`
+	DeliveryMode     string `json:"deliveryMode"`
+	Mode             string `json:"mode"`
 	ZoomLink         string `json:"zoomLink"`
+	LinkAvailableAt  string `json:"linkAvailableAt,omitempty"`
 	AttendanceStatus string `json:"attendanceStatus"`
 	IsReviewed       bool   `json:"isReviewed"`
 	IsOpened         bool   `json:"isOpen"`
+	ScheduleStatus   string `json:"scheduleStatus"`
 	CheckedAt        string `json:"checkedAt"`
 	VerifiedAt       string `json:"verifiedAt,omitempty"`
 }
@@ -701,10 +1179,193 @@ type UserPackageResponse struct {
 	ID              string `json:"id"`
 	PackageID       string `json:"packageId"`
 	PackageName     string `json:"packageName"`
+	Type            string `json:"type"`
+	WeeklyLimit     int    `json:"weeklyLimit,omitempty"`
 	RemainingCredit int    `json:"remainingCredit"`
 	ExpiredAt       string `json:"expiredAt,omitempty"`
 	ExpiredInDays   int    `json:"expiredInDays,omitempty"`
 	PurchasedAt     string `json:"purchasedAt"`
+	Shared          bool   `json:"shared,omitempty"`
+}
+
+type InviteMemberRequest struct {
+	Email     string `json:"email" binding:"required,email"`
+	CreditCap *int   `json:"creditCap" binding:"omitempty,min=0"`
+}
+
+type UpdateMemberCapRequest struct {
+	CreditCap *int `json:"creditCap" binding:"omitempty,min=0"`
+}
+
+type MemberCreditUsage struct {
+	UserID string
+	Used   int
+}
+
+type PackageMemberResponse struct {
+	ID          string `json:"id"`
+	UserID      string `json:"userId"`
+	Fullname    string `json:"fullname"`
+	Email       string `json:"email"`
+	Status      string `json:"status"`
+	CreditCap   *int   `json:"creditCap"`
+	CreditsUsed int    `json:"creditsUsed"`
+	JoinedAt    string `json:"joinedAt,omitempty"`
+}
+
+type PackageUsageEntry struct {
+	BookingID string `json:"bookingId"`
+	UserID    string `json:"userId"`
+	Fullname  string `json:"fullname"`
+	ClassName string `json:"className"`
+	Date      string `json:"date"`
+	Status    string `json:"status"`
+}
+
+type SharedPackageUsageResponse struct {
+	UserPackageID    string                  `json:"userPackageId"`
+	PackageName      string                  `json:"packageName"`
+	RemainingCredit  int                     `json:"remainingCredit"`
+	MaxMembers       int                     `json:"maxMembers"`
+	OwnerCreditsUsed int                     `json:"ownerCreditsUsed"`
+	Members          []PackageMemberResponse `json:"members"`
+	Bookings         []PackageUsageEntry     `json:"bookings"`
+}
+
+type PackageInvitationResponse struct {
+	ID            string `json:"id"`
+	UserPackageID string `json:"userPackageId"`
+	PackageName   string `json:"packageName"`
+	InvitedBy     string `json:"invitedBy"`
+	CreditCap     *int   `json:"creditCap"`
+	ExpiredAt     string `json:"expiredAt,omitempty"`
+	CreatedAt     string `json:"createdAt"`
+}
+
+type ExtendUserPackageRequest struct {
+	Days   int    `json:"days" binding:"required,min=1,max=365"`
+	Reason string `json:"reason" binding:"required,min=5"`
+}
+
+type ForfeitedCreditStat struct {
+	Month    string `json:"month"`
+	Credits  int    `json:"credits"`
+	Restored int    `json:"restored"`
+	Packages int    `json:"packages"`
+}
+
+type ForfeitedCreditsResponse struct {
+	TotalCredits  int                   `json:"totalCredits"`
+	TotalRestored int                   `json:"totalRestored"`
+	Series        []ForfeitedCreditStat `json:"series"`
+}
+
+type SubscriptionResponse struct {
+	ID                 string `json:"id"`
+	PackageID          string `json:"packageId"`
+	PackageName        string `json:"packageName"`
+	Status             string `json:"status"`
+	CurrentPeriodStart string `json:"currentPeriodStart"`
+	CurrentPeriodEnd   string `json:"currentPeriodEnd"`
+	CancelAtPeriodEnd  bool   `json:"cancelAtPeriodEnd"`
+	FailedAttempts     int    `json:"failedAttempts"`
+	NextRetryAt        string `json:"nextRetryAt,omitempty"`
+	CanceledAt         string `json:"canceledAt,omitempty"`
+}
+
+type CreateFreezeRequest struct {
+	UserPackageID string `json:"userPackageId" binding:"required,uuid"`
+	StartDate     string `json:"startDate" binding:"required"`
+	EndDate       string `json:"endDate" binding:"required"`
+	Reason        string `json:"reason" binding:"required,min=5"`
+}
+
+type FreezeQueryParam struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending awaiting_payment approved rejected canceled"`
+}
+
+type ApproveFreezeRequest struct {
+	Note     string `json:"note"`
+	WaiveFee bool   `json:"waiveFee"`
+}
+
+type RejectFreezeRequest struct {
+	Note string `json:"note" binding:"required"`
+}
+
+type FreezeResponse struct {
+	ID            string  `json:"id"`
+	UserPackageID string  `json:"userPackageId"`
+	PackageName   string  `json:"packageName"`
+	UserID        string  `json:"userId"`
+	Fullname      string  `json:"fullname,omitempty"`
+	StartDate     string  `json:"startDate"`
+	EndDate       string  `json:"endDate"`
+	Days          int     `json:"days"`
+	Reason        string  `json:"reason"`
+	Status        string  `json:"status"`
+	Fee           float64 `json:"fee"`
+	PaymentID     string  `json:"paymentId,omitempty"`
+	PaymentLink   string  `json:"paymentLink,omitempty"`
+	ReviewNote    string  `json:"reviewNote"`
+	ReviewedAt    string  `json:"reviewedAt,omitempty"`
+	CreatedAt     string  `json:"createdAt"`
+}
+
+// FeeCharge is a one-off payment that is not a package purchase, Purpose routes the fulfilment
+// and UserPackageID points at the package the charge applies to
+type FeeCharge struct {
+	PackageID     string
+	UserPackageID string
+	Description   string
+	Amount        money.Money
+	Purpose       string
+	Method        string
+}
+
+type TransferCreditRequest struct {
+	UserPackageID string `json:"userPackageId" binding:"required,uuid"`
+	Email         string `json:"email" binding:"required,email"`
+	Credits       int    `json:"credits" binding:"required,min=1"`
+}
+
+type CreditHistoryQueryParam struct {
+	UserPackageID string `form:"userPackageId" binding:"omitempty,uuid"`
+	Type          string `form:"type" binding:"omitempty,oneof=purchase booking refund penalty adjustment expiry expiry_restore transfer"`
+	Page          int    `form:"page" binding:"omitempty,min=1"`
+	Limit         int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+type CreditLedgerEntryResponse struct {
+	ID            string `json:"id"`
+	UserPackageID string `json:"userPackageId"`
+	PackageName   string `json:"packageName"`
+	Type          string `json:"type"`
+	Amount        int    `json:"amount"`
+	BalanceAfter  int    `json:"balanceAfter"`
+	PaymentID     string `json:"paymentId,omitempty"`
+	BookingID     string `json:"bookingId,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	CreatedAt     string `json:"createdAt"`
+}
+
+type AdjustCreditRequest struct {
+	Amount int    `json:"amount" binding:"required,ne=0"`
+	Type   string `json:"type" binding:"omitempty,oneof=adjustment penalty"`
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+type ReconcileCreditRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+type CreditReconciliationResponse struct {
+	UserPackageID string                      `json:"userPackageId"`
+	StoredBalance int                         `json:"storedBalance"`
+	LedgerBalance int                         `json:"ledgerBalance"`
+	Difference    int                         `json:"difference"`
+	Reconciled    bool                        `json:"reconciled"`
+	Entries       []CreditLedgerEntryResponse `json:"entries"`
 }
 
 type BookingListResponse struct {
@@ -764,6 +1425,39 @@ type ApplyVoucherResponse struct {
 	FinalTotal    float64  `json:"finalTotal"`
 }
 
+// TAX
+
+// TaxRuleRequest dates are YYYY-MM-DD, a rule that already took effect can only be given an end date
+type TaxRuleRequest struct {
+	Name           string  `json:"name" binding:"required,max=100"`
+	Rate           float64 `json:"rate" binding:"min=0,max=100"`
+	LocationID     *string `json:"locationId" binding:"omitempty,uuid"`
+	TaxCategory    string  `json:"taxCategory" binding:"omitempty,max=50"`
+	EffectiveFrom  string  `json:"effectiveFrom" binding:"required"`
+	EffectiveUntil *string `json:"effectiveUntil"`
+}
+
+type TaxRuleQueryParam struct {
+	LocationID  string `form:"locationId"`
+	TaxCategory string `form:"taxCategory"`
+	Name        string `form:"name"`
+	ActiveOn    string `form:"activeOn"`
+	Page        int    `form:"page"`
+	Limit       int    `form:"limit"`
+}
+
+type TaxRuleResponse struct {
+	ID             string  `json:"id"`
+	Name           string  `json:"name"`
+	Rate           float64 `json:"rate"`
+	LocationID     string  `json:"locationId,omitempty"`
+	LocationName   string  `json:"locationName,omitempty"`
+	TaxCategory    string  `json:"taxCategory"`
+	EffectiveFrom  string  `json:"effectiveFrom"`
+	EffectiveUntil string  `json:"effectiveUntil,omitempty"`
+	CreatedAt      string  `json:"createdAt"`
+}
+
 type GoogleSignInRequest struct {
 	IDToken string `json:"idToken" binding:"required"`
 }
@@ -797,14 +1491,17 @@ type RevenueStatRequest struct {
 	Range string `form:"range" binding:"omitempty,oneof=daily monthly yearly"`
 }
 
+// RevenueStat is the revenue net of refunds, Refunded is what was returned on payments of the period
 type RevenueStat struct {
-	Date  string  `json:"date"`
-	Total float64 `json:"total"`
+	Date     string  `json:"date"`
+	Total    float64 `json:"total"`
+	Refunded float64 `json:"refunded"`
 }
 
 type RevenueStatsResponse struct {
 	Range         string        `json:"range"`
 	TotalRevenue  float64       `json:"totalRevenue"`
+	TotalRefunded float64       `json:"totalRefunded"`
 	RevenueSeries []RevenueStat `json:"revenueSeries"`
 }
 
@@ -829,6 +1526,10 @@ type OpenClassScheduleRequest struct {
 	ZoomLink         string `json:"zoomLink" binding:"omitempty"`
 }
 
+type CancelClassScheduleRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
 type InstructorScheduleResponse struct {
 	ID               string `json:"id"`
 	ClassID          string `json:"classId"`
@@ -840,10 +1541,64 @@ type InstructorScheduleResponse struct {
 	Date             string `json:"date"`
 	StartHour        int    `json:"startHour"`
 	StartMinute      int    `json:"startMinute"`
+	DeliveryMode     string `json:"deliveryMode"`
 	Capacity         int    `json:"capacity"`
+	OnlineCapacity   int    `json:"onlineCapacity"`
 	BookedCount      int    `json:"bookedCount"`
+	OnlineBooked     int    `json:"onlineBookedCount"`
 	Duration         int    `json:"duration"`
 	IsOpened         bool   `json:"isOpen"`
+	Status           string `json:"status"`
 	VerificationCode string `json:"verificationCode"`
 	ZoomLink         string `json:"zoomLink"`
 }
+
+// INSTRUCTOR AVAILABILITY & TIME-OFF
+
+type AvailabilityWindowRequest struct {
+	DayOfWeek   int `json:"dayOfWeek" binding:"min=0,max=6"`
+	StartHour   int `json:"startHour" binding:"min=0,max=23"`
+	StartMinute int `json:"startMinute" binding:"min=0,max=59"`
+	EndHour     int `json:"endHour" binding:"min=0,max=24"`
+	EndMinute   int `json:"endMinute" binding:"min=0,max=59"`
+}
+
+type UpdateAvailabilityRequest struct {
+	Windows []AvailabilityWindowRequest `json:"windows" binding:"dive"`
+}
+
+type AvailabilityWindowResponse struct {
+	ID          string `json:"id"`
+	DayOfWeek   int    `json:"dayOfWeek"`
+	StartHour   int    `json:"startHour"`
+	StartMinute int    `json:"startMinute"`
+	EndHour     int    `json:"endHour"`
+	EndMinute   int    `json:"endMinute"`
+}
+
+type CreateTimeOffRequest struct {
+	StartDate string `json:"startDate" binding:"required"`
+	EndDate   string `json:"endDate" binding:"required"`
+	Reason    string `json:"reason" binding:"required,min=5"`
+}
+
+type TimeOffQueryParam struct {
+	Status string `form:"status"`
+}
+
+type ReviewTimeOffRequest struct {
+	Note string `json:"note"`
+}
+
+type TimeOffResponse struct {
+	ID             string `json:"id"`
+	InstructorID   string `json:"instructorId"`
+	InstructorName string `json:"instructorName"`
+	StartDate      string `json:"startDate"`
+	EndDate        string `json:"endDate"`
+	Reason         string `json:"reason"`
+	Status         string `json:"status"`
+	ReviewNote     string `json:"reviewNote"`
+	ReviewedAt     string `json:"reviewedAt,omitempty"`
+	CreatedAt      string `json:"createdAt"`
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"server/internal/dto"
+	"server/internal/services"
+	"server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AvailabilityHandler struct {
+	service services.AvailabilityService
+}
+
+func NewAvailabilityHandler(service services.AvailabilityService) *AvailabilityHandler {
+	return &AvailabilityHandler{service}
+}
+
+func (h *AvailabilityHandler) GetMyAvailability(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+
+	windows, err := h.service.GetMyAvailability(userID)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": windows})
+}
+
+func (h *AvailabilityHandler) UpdateMyAvailability(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+	var req dto.UpdateAvailabilityRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	if err := h.service.UpdateMyAvailability(userID, req); err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Availability updated successfully"})
+}
+
+func (h *AvailabilityHandler) GetMyTimeOffs(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+
+	timeOffs, err := h.service.GetMyTimeOffs(userID)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": timeOffs})
+}
+
+func (h *AvailabilityHandler) RequestTimeOff(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+	var req dto.CreateTimeOffRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	if err := h.service.RequestTimeOff(userID, req); err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Time off requested successfully"})
+}
+
+func (h *AvailabilityHandler) CancelTimeOff(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+	id := c.Param("id")
+
+	if err := h.service.CancelTimeOff(userID, id); err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Time off request canceled successfully"})
+}
+
+func (h *AvailabilityHandler) GetTimeOffs(c *gin.Context) {
+	var param dto.TimeOffQueryParam
+	if !utils.BindAndValidateForm(c, &param) {
+		return
+	}
+
+	timeOffs, err := h.service.GetTimeOffs(param)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": timeOffs})
+}
+
+func (h *AvailabilityHandler) ApproveTimeOff(c *gin.Context) {
+	adminID := utils.MustGetUserID(c)
+	id := c.Param("id")
+	var req dto.ReviewTimeOffRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	affected, err := h.service.ApproveTimeOff(adminID, id, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Time off approved successfully", "affectedSchedules": affected})
+}
+
+func (h *AvailabilityHandler) RejectTimeOff(c *gin.Context) {
+	adminID := utils.MustGetUserID(c)
+	id := c.Param("id")
+	var req dto.ReviewTimeOffRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	if err := h.service.RejectTimeOff(adminID, id, req); err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Time off rejected successfully"})
+}
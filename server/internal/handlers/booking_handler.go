@@ -25,7 +25,7 @@ func (h *BookingHandler) CreateBooking(c *gin.Context) {
 		return
 	}
 
-	err := h.bookingService.CreateBooking(userID, req.PackageID, req.ClassScheduleID)
+	err := h.bookingService.CreateBooking(userID, req.PackageID, req.ClassScheduleID, req.Mode)
 	if err != nil {
 		utils.HandleServiceError(c, err, err.Error())
 		return
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"server/internal/dto"
+	"server/internal/services"
+	"server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreditHandler struct {
+	service services.CreditService
+}
+
+func NewCreditHandler(service services.CreditService) *CreditHandler {
+	return &CreditHandler{service}
+}
+
+func (h *CreditHandler) GetMyCreditHistory(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+	var params dto.CreditHistoryQueryParam
+	if !utils.BindAndValidateForm(c, &params) {
+		return
+	}
+
+	entries, pagination, err := h.service.GetMyCreditHistory(userID, params)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       entries,
+		"pagination": pagination,
+	})
+}
+
+func (h *CreditHandler) TransferCredit(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+	var req dto.TransferCreditRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	entry, err := h.service.TransferCredit(userID, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Credits transferred successfully",
+		"data":    entry,
+	})
+}
+
+func (h *CreditHandler) GetReconciliation(c *gin.Context) {
+	id := c.Param("id")
+
+	result, err := h.service.GetReconciliation(id)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+func (h *CreditHandler) AdjustCredit(c *gin.Context) {
+	id := c.Param("id")
+	adminID := utils.MustGetUserID(c)
+	var req dto.AdjustCreditRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	entry, err := h.service.AdjustCredit(adminID, id, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Credit adjusted successfully",
+		"data":    entry,
+	})
+}
+
+func (h *CreditHandler) ReconcileLedger(c *gin.Context) {
+	id := c.Param("id")
+	adminID := utils.MustGetUserID(c)
+	var req dto.ReconcileCreditRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	result, err := h.service.ReconcileLedger(adminID, id, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Credit ledger reconciled successfully",
+		"data":    result,
+	})
+}
@@ -35,3 +35,13 @@ func (h *DashboardHandler) GetRevenueStats(c *gin.Context) {
 
 	c.JSON(http.StatusOK, result)
 }
+
+func (h *DashboardHandler) GetForfeitedCredits(c *gin.Context) {
+	result, err := h.dashboardService.GetForfeitedCredits()
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+	"server/internal/dto"
+	"server/internal/services"
+	"server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FreezeHandler struct {
+	service services.FreezeService
+}
+
+func NewFreezeHandler(service services.FreezeService) *FreezeHandler {
+	return &FreezeHandler{service}
+}
+
+func (h *FreezeHandler) RequestFreeze(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+	var req dto.CreateFreezeRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	freeze, err := h.service.RequestFreeze(userID, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Freeze request submitted successfully",
+		"data":    freeze,
+	})
+}
+
+func (h *FreezeHandler) GetMyFreezes(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+
+	freezes, err := h.service.GetMyFreezes(userID)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": freezes})
+}
+
+func (h *FreezeHandler) CancelFreeze(c *gin.Context) {
+	id := c.Param("id")
+	userID := utils.MustGetUserID(c)
+
+	if err := h.service.CancelFreeze(userID, id); err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Freeze request canceled successfully"})
+}
+
+func (h *FreezeHandler) GetFreezes(c *gin.Context) {
+	var params dto.FreezeQueryParam
+	if !utils.BindAndValidateForm(c, &params) {
+		return
+	}
+
+	freezes, err := h.service.GetFreezes(params)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": freezes})
+}
+
+func (h *FreezeHandler) ApproveFreeze(c *gin.Context) {
+	id := c.Param("id")
+	adminID := utils.MustGetUserID(c)
+	var req dto.ApproveFreezeRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	freeze, err := h.service.ApproveFreeze(adminID, id, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Freeze approved successfully",
+		"data":    freeze,
+	})
+}
+
+func (h *FreezeHandler) RejectFreeze(c *gin.Context) {
+	id := c.Param("id")
+	adminID := utils.MustGetUserID(c)
+	var req dto.RejectFreezeRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	freeze, err := h.service.RejectFreeze(adminID, id, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Freeze rejected successfully",
+		"data":    freeze,
+	})
+}
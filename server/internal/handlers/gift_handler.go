@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"server/internal/dto"
+	"server/internal/services"
+	"server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GiftHandler struct {
+	service services.GiftService
+}
+
+func NewGiftHandler(service services.GiftService) *GiftHandler {
+	return &GiftHandler{service}
+}
+
+func (h *GiftHandler) GetMyGifts(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+
+	gifts, err := h.service.GetMyGifts(userID)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gifts})
+}
+
+func (h *GiftHandler) RedeemGift(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+	var req dto.RedeemGiftRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	gift, err := h.service.RedeemGift(userID, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Gift redeemed successfully",
+		"data":    gift,
+	})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"server/internal/services"
+	"server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InvoiceHandler struct {
+	service services.InvoiceService
+}
+
+func NewInvoiceHandler(service services.InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{service}
+}
+
+func (h *InvoiceHandler) GetMyInvoice(c *gin.Context) {
+	doc, err := h.service.GetInvoice(c.Param("id"), utils.MustGetUserID(c))
+	sendDocument(c, doc, err)
+}
+
+func (h *InvoiceHandler) GetMyReceipt(c *gin.Context) {
+	doc, err := h.service.GetReceipt(c.Param("id"), utils.MustGetUserID(c))
+	sendDocument(c, doc, err)
+}
+
+func (h *InvoiceHandler) GetInvoice(c *gin.Context) {
+	doc, err := h.service.GetInvoice(c.Param("id"), "")
+	sendDocument(c, doc, err)
+}
+
+func (h *InvoiceHandler) GetReceipt(c *gin.Context) {
+	doc, err := h.service.GetReceipt(c.Param("id"), "")
+	sendDocument(c, doc, err)
+}
+
+func sendDocument(c *gin.Context, doc *services.PaymentDocument, err error) {
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", doc.Filename))
+	c.Data(http.StatusOK, "application/pdf", doc.Content)
+}
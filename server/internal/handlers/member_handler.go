@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"server/internal/dto"
+	"server/internal/services"
+	"server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PackageMemberHandler struct {
+	service services.PackageMemberService
+}
+
+func NewPackageMemberHandler(service services.PackageMemberService) *PackageMemberHandler {
+	return &PackageMemberHandler{service}
+}
+
+func (h *PackageMemberHandler) InviteMember(c *gin.Context) {
+	id := c.Param("id")
+	userID := utils.MustGetUserID(c)
+	var req dto.InviteMemberRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	member, err := h.service.InviteMember(userID, id, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Member invited successfully",
+		"data":    member,
+	})
+}
+
+func (h *PackageMemberHandler) GetPackageUsage(c *gin.Context) {
+	id := c.Param("id")
+	userID := utils.MustGetUserID(c)
+
+	usage, err := h.service.GetPackageUsage(userID, id)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": usage})
+}
+
+func (h *PackageMemberHandler) UpdateMemberCap(c *gin.Context) {
+	id := c.Param("id")
+	memberID := c.Param("memberId")
+	userID := utils.MustGetUserID(c)
+	var req dto.UpdateMemberCapRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	member, err := h.service.UpdateMemberCap(userID, id, memberID, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member credit cap updated successfully",
+		"data":    member,
+	})
+}
+
+func (h *PackageMemberHandler) RemoveMember(c *gin.Context) {
+	id := c.Param("id")
+	memberID := c.Param("memberId")
+	userID := utils.MustGetUserID(c)
+
+	if err := h.service.RemoveMember(userID, id, memberID); err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+func (h *PackageMemberHandler) GetMyInvitations(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+
+	invitations, err := h.service.GetMyInvitations(userID)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invitations})
+}
+
+func (h *PackageMemberHandler) AcceptInvitation(c *gin.Context) {
+	id := c.Param("id")
+	userID := utils.MustGetUserID(c)
+
+	if err := h.service.RespondInvitation(userID, id, true); err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted successfully"})
+}
+
+func (h *PackageMemberHandler) DeclineInvitation(c *gin.Context) {
+	id := c.Param("id")
+	userID := utils.MustGetUserID(c)
+
+	if err := h.service.RespondInvitation(userID, id, false); err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation declined successfully"})
+}
@@ -74,7 +74,13 @@ func (h *PackageHandler) GetAllPackages(c *gin.Context) {
 		return
 	}
 
-	packages, pagination, err := h.service.GetAllPackages(params)
+	// signed-in customers see which packages they can buy
+	userID := ""
+	if role, ok := c.Get("role"); ok && role == "customer" {
+		userID = utils.MustGetUserID(c)
+	}
+
+	packages, pagination, err := h.service.GetAllPackages(userID, params)
 	if err != nil {
 		utils.HandleServiceError(c, err, err.Error())
 		return
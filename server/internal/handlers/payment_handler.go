@@ -2,13 +2,11 @@ package handlers
 
 import (
 	"net/http"
-	"os"
 	"server/internal/dto"
 	"server/internal/services"
 	"server/pkg/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/stripe/stripe-go/v75/webhook"
 )
 
 type PaymentHandler struct {
@@ -36,35 +34,6 @@ func (h *PaymentHandler) CreatePayment(c *gin.Context) {
 	c.JSON(http.StatusCreated, response)
 }
 
-func (h *PaymentHandler) HandlePaymentNotifications(c *gin.Context) {
-	const MaxBodyBytes = int64(65536)
-
-	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxBodyBytes)
-
-	body, err := c.GetRawData()
-	if err != nil {
-		utils.HandleServiceError(c, err, "Failed to read request body")
-		return
-	}
-
-	sigHeader := c.GetHeader("Stripe-Signature")
-
-	event, err := webhook.ConstructEventWithOptions(body, sigHeader, os.Getenv("STRIPE_WEBHOOK_SECRET"), webhook.ConstructEventOptions{
-		IgnoreAPIVersionMismatch: true,
-	})
-	if err != nil {
-		utils.HandleServiceError(c, err, "Invalid Signatures")
-		return
-	}
-
-	if err := h.paymentService.StripeWebhookNotification(event); err != nil {
-		utils.HandleServiceError(c, err, "Failed to send notifications")
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Payment received successfully"})
-}
-
 func (h *PaymentHandler) GetAllUserPayments(c *gin.Context) {
 	var params dto.PaymentQueryParam
 	if !utils.BindAndValidateForm(c, &params) {
@@ -114,3 +83,69 @@ func (h *PaymentHandler) GetMyTransactions(c *gin.Context) {
 	})
 
 }
+
+func (h *PaymentHandler) QuoteUpgrade(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+
+	var req dto.UpgradePackageRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	quote, err := h.paymentService.QuoteUpgrade(userID, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Upgrade quote calculated successfully", "data": quote})
+}
+
+func (h *PaymentHandler) UpgradePackage(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+
+	var req dto.UpgradePackageRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	response, err := h.paymentService.UpgradePackage(userID, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+func (h *PaymentHandler) RecordOfflinePayment(c *gin.Context) {
+	staffID := utils.MustGetUserID(c)
+
+	var req dto.OfflinePaymentRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	payment, err := h.paymentService.RecordOfflinePayment(staffID, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, "Failed to record payment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Payment recorded successfully", "data": payment})
+}
+
+func (h *PaymentHandler) GetCashDrawerReport(c *gin.Context) {
+	var params dto.CashDrawerQueryParam
+	if !utils.BindAndValidateForm(c, &params) {
+		return
+	}
+
+	report, err := h.paymentService.GetCashDrawerReport(params)
+	if err != nil {
+		utils.HandleServiceError(c, err, "Failed to fetch cash drawer report")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"server/internal/dto"
+	"server/internal/services"
+	"server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReconciliationHandler struct {
+	service services.ReconciliationService
+}
+
+func NewReconciliationHandler(service services.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{service}
+}
+
+func (h *ReconciliationHandler) Run(c *gin.Context) {
+	result, err := h.service.ReconcilePendingPayments()
+	if err != nil {
+		utils.HandleServiceError(c, err, "Failed to reconcile payments")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pending payments reconciled successfully", "data": result})
+}
+
+func (h *ReconciliationHandler) GetDiscrepancies(c *gin.Context) {
+	var params dto.PaymentDiscrepancyQueryParam
+	if !utils.BindAndValidateForm(c, &params) {
+		return
+	}
+
+	discrepancies, pagination, err := h.service.GetDiscrepancies(params)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       discrepancies,
+		"pagination": pagination,
+	})
+}
+
+func (h *ReconciliationHandler) ResolveDiscrepancy(c *gin.Context) {
+	adminID := utils.MustGetUserID(c)
+
+	var req dto.ResolveDiscrepancyRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	discrepancy, err := h.service.ResolveDiscrepancy(c.Param("id"), adminID, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payment discrepancy resolved successfully", "data": discrepancy})
+}
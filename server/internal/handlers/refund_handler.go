@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"server/internal/dto"
+	"server/internal/services"
+	"server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RefundHandler struct {
+	service services.RefundService
+}
+
+func NewRefundHandler(service services.RefundService) *RefundHandler {
+	return &RefundHandler{service}
+}
+
+func (h *RefundHandler) RefundPayment(c *gin.Context) {
+	adminID := utils.MustGetUserID(c)
+	var req dto.RefundPaymentRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	refund, err := h.service.RefundPayment(adminID, c.Param("id"), req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Payment refunded successfully", "data": refund})
+}
+
+func (h *RefundHandler) GetRefunds(c *gin.Context) {
+	refunds, err := h.service.GetRefunds(c.Param("id"))
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": refunds})
+}
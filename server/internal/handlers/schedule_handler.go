@@ -76,6 +76,59 @@ func (h *ClassScheduleHandler) DeleteClassSchedule(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Class schedule deleted successfully"})
 }
 
+func (h *ClassScheduleHandler) PreviewScheduleImport(c *gin.Context) {
+	var req dto.ImportScheduleRequest
+	if !utils.BindAndValidateForm(c, &req) {
+		return
+	}
+
+	rows, err := utils.ParseScheduleImportFile(req.File)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	result, err := h.service.ImportSchedules(rows, false)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *ClassScheduleHandler) ImportSchedules(c *gin.Context) {
+	var req dto.ImportScheduleRequest
+	if !utils.BindAndValidateForm(c, &req) {
+		return
+	}
+
+	rows, err := utils.ParseScheduleImportFile(req.File)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	result, err := h.service.ImportSchedules(rows, true)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	if !result.Committed {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Import rejected, no schedule was created. Fix the invalid rows and try again",
+			"data":    result,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Class schedules imported successfully",
+		"data":    result,
+	})
+}
+
 // public
 func (h *ClassScheduleHandler) GetAllClassSchedules(c *gin.Context) {
 	var param dto.ClassScheduleQueryParam
@@ -165,3 +218,43 @@ func (h *ClassScheduleHandler) OpenClassSchedule(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Class schedule opened successfully"})
 }
+
+func (h *ClassScheduleHandler) StartClassSchedule(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+	id := c.Param("id")
+
+	if err := h.service.StartClassSchedule(userID, id); err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Class schedule started successfully"})
+}
+
+func (h *ClassScheduleHandler) CompleteClassSchedule(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+	id := c.Param("id")
+
+	if err := h.service.CompleteClassSchedule(userID, id); err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Class schedule completed successfully"})
+}
+
+func (h *ClassScheduleHandler) CancelClassSchedule(c *gin.Context) {
+	id := c.Param("id")
+
+	var req dto.CancelClassScheduleRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	if err := h.service.CancelClassSchedule(id, req); err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Class schedule canceled successfully"})
+}
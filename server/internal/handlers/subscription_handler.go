@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"server/internal/services"
+	"server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SubscriptionHandler struct {
+	service services.SubscriptionService
+}
+
+func NewSubscriptionHandler(service services.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{service}
+}
+
+func (h *SubscriptionHandler) GetMySubscriptions(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+
+	subscriptions, err := h.service.GetMySubscriptions(userID)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": subscriptions})
+}
+
+func (h *SubscriptionHandler) CancelMySubscription(c *gin.Context) {
+	userID := utils.MustGetUserID(c)
+	id := c.Param("id")
+
+	subscription, err := h.service.CancelMySubscription(userID, id)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Subscription will be canceled at the end of the current period",
+		"data":    subscription,
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"server/internal/dto"
+	"server/internal/services"
+	"server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TaxHandler struct {
+	service services.TaxService
+}
+
+func NewTaxHandler(service services.TaxService) *TaxHandler {
+	return &TaxHandler{service}
+}
+
+func (h *TaxHandler) GetRules(c *gin.Context) {
+	var params dto.TaxRuleQueryParam
+	if !utils.BindAndValidateForm(c, &params) {
+		return
+	}
+
+	rules, pagination, err := h.service.GetRules(params)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       rules,
+		"pagination": pagination,
+	})
+}
+
+func (h *TaxHandler) CreateRule(c *gin.Context) {
+	var req dto.TaxRuleRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	rule, err := h.service.CreateRule(req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Tax rule created successfully", "data": rule})
+}
+
+func (h *TaxHandler) UpdateRule(c *gin.Context) {
+	var req dto.TaxRuleRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	rule, err := h.service.UpdateRule(c.Param("id"), req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tax rule updated successfully", "data": rule})
+}
+
+func (h *TaxHandler) DeleteRule(c *gin.Context) {
+	if err := h.service.DeleteRule(c.Param("id")); err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tax rule deleted successfully"})
+}
@@ -69,3 +69,33 @@ func (h *ScheduleTemplateHandler) StopScheduleTemplate(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Template deactivated successfully"})
 }
+
+func (h *ScheduleTemplateHandler) PlanTimetable(c *gin.Context) {
+	var req dto.PlanTimetableRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	plan, err := h.service.PlanTimetable(req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": plan})
+}
+
+func (h *ScheduleTemplateHandler) AcceptTimetable(c *gin.Context) {
+	var req dto.AcceptTimetableRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	ids, err := h.service.AcceptTimetable(req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Timetable templates created successfully", "templateIds": ids})
+}
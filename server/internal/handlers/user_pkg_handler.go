@@ -48,3 +48,23 @@ func (h *UserPackageHandler) GetUserPackagesByClassID(c *gin.Context) {
 
 	c.JSON(http.StatusOK, userPackages)
 }
+
+func (h *UserPackageHandler) ExtendUserPackage(c *gin.Context) {
+	id := c.Param("id")
+	adminID := utils.MustGetUserID(c)
+	var req dto.ExtendUserPackageRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	userPackage, err := h.service.ExtendUserPackage(adminID, id, req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Package extended successfully",
+		"data":    userPackage,
+	})
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"server/internal/dto"
+	"server/internal/services"
+	"server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WalletHandler struct {
+	service services.WalletService
+}
+
+func NewWalletHandler(service services.WalletService) *WalletHandler {
+	return &WalletHandler{service}
+}
+
+func (h *WalletHandler) GetMyWallet(c *gin.Context) {
+	h.getWallet(c, utils.MustGetUserID(c))
+}
+
+func (h *WalletHandler) GetUserWallet(c *gin.Context) {
+	h.getWallet(c, c.Param("id"))
+}
+
+func (h *WalletHandler) getWallet(c *gin.Context, userID string) {
+	var params dto.WalletQueryParam
+	if !utils.BindAndValidateForm(c, &params) {
+		return
+	}
+
+	wallet, pagination, err := h.service.GetWallet(userID, params)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       wallet,
+		"pagination": pagination,
+	})
+}
+
+func (h *WalletHandler) TopUp(c *gin.Context) {
+	adminID := utils.MustGetUserID(c)
+
+	var req dto.WalletTopUpRequest
+	if !utils.BindAndValidateJSON(c, &req) {
+		return
+	}
+
+	txn, err := h.service.TopUp(adminID, c.Param("id"), req)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Wallet topped up successfully", "data": txn})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"server/internal/dto"
+	"server/internal/services"
+	"server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	service services.WebhookService
+}
+
+func NewWebhookHandler(service services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service}
+}
+
+func (h *WebhookHandler) ReceiveNotification(c *gin.Context) {
+	const MaxBodyBytes = int64(65536)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxBodyBytes)
+
+	body, err := c.GetRawData()
+	if err != nil {
+		utils.HandleServiceError(c, err, "Failed to read request body")
+		return
+	}
+
+	if err := h.service.Receive(c.Param("gateway"), body, c.Request.Header); err != nil {
+		utils.HandleServiceError(c, err, "Failed to process notification")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payment received successfully"})
+}
+
+func (h *WebhookHandler) GetEvents(c *gin.Context) {
+	var params dto.WebhookEventQueryParam
+	if !utils.BindAndValidateForm(c, &params) {
+		return
+	}
+
+	events, pagination, err := h.service.GetEvents(params)
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       events,
+		"pagination": pagination,
+	})
+}
+
+func (h *WebhookHandler) GetEvent(c *gin.Context) {
+	event, err := h.service.GetEvent(c.Param("id"))
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": event})
+}
+
+func (h *WebhookHandler) Replay(c *gin.Context) {
+	event, err := h.service.Replay(c.Param("id"))
+	if err != nil {
+		utils.HandleServiceError(c, err, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook event replayed successfully", "data": event})
+}
@@ -23,6 +23,9 @@ type User struct {
 	CreatedAt time.Time  `gorm:"autoCreateTime"`
 	UpdatedAt time.Time  `gorm:"autoUpdateTime"`
 
+	// WalletBalance is the store credit in minor units of the default currency, it only moves with a WalletTransaction
+	WalletBalance int64 `gorm:"column:wallet_balance_minor;not null;default:0" json:"walletBalance"`
+
 	Tokens []Token `gorm:"foreignKey:UserID" json:"-"`
 }
 
@@ -40,6 +43,7 @@ type Class struct {
 	Image          string         `gorm:"type:varchar(255);not null" json:"image"`
 	IsActive       bool           `gorm:"not null;default:true" json:"isActive"`
 	Duration       int            `gorm:"not null" json:"duration"`
+	DeliveryMode   string         `gorm:"type:varchar(20);not null;default:'in_person';check:delivery_mode IN ('in_person','online','hybrid')" json:"deliveryMode"`
 	Description    string         `gorm:"type:text" json:"description"`
 	Additional     string         `gorm:"type:longtext" json:"-"`
 	AdditionalList []string       `gorm:"-" json:"additional"`
@@ -78,86 +82,363 @@ type PackageClass struct {
 }
 
 type UserPackage struct {
-	ID              uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
-	UserID          uuid.UUID      `gorm:"type:char(36);not null" json:"userId"`
-	PackageID       uuid.UUID      `gorm:"type:char(36);not null" json:"packageId"`
-	PackageName     string         `gorm:"type:varchar(255);not null" json:"packageName"`
-	RemainingCredit int            `gorm:"not null;default:0" json:"remainingCredit"`
-	ExpiredAt       *time.Time     `json:"expiredAt"`
-	PurchasedAt     time.Time      `gorm:"autoCreateTime" json:"purchasedAt"`
-	CreatedAt       time.Time      `gorm:"autoCreateTime" json:"createdAt"`
-	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID             uuid.UUID      `gorm:"type:char(36);not null" json:"userId"`
+	PackageID          uuid.UUID      `gorm:"type:char(36);not null" json:"packageId"`
+	PackageName        string         `gorm:"type:varchar(255);not null" json:"packageName"`
+	Type               string         `gorm:"type:varchar(20);not null;default:'credit_pack'" json:"type"`
+	WeeklyLimit        int            `gorm:"not null;default:0" json:"weeklyLimit"`
+	RemainingCredit    int            `gorm:"not null;default:0" json:"remainingCredit"`
+	SubscriptionID     *uuid.UUID     `gorm:"type:char(36)" json:"subscriptionId"`
+	ExpiredAt          *time.Time     `json:"expiredAt"`
+	ExpiryRemindedDays int            `gorm:"not null;default:0" json:"-"`
+	ClosedAt           *time.Time     `json:"-"`
+	PurchasedAt        time.Time      `gorm:"autoCreateTime" json:"purchasedAt"`
+	CreatedAt          time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
 
 	Package Package `gorm:"foreignKey:PackageID" json:"package"`
 }
 
+// CreditLedgerEntry is an append-only record of every credit movement on a UserPackage,
+// RemainingCredit must always equal the sum of its entries
+type CreditLedgerEntry struct {
+	ID            uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserPackageID uuid.UUID  `gorm:"type:char(36);not null;index" json:"userPackageId"`
+	UserID        uuid.UUID  `gorm:"type:char(36);not null;index" json:"userId"`
+	Type          string     `gorm:"type:varchar(20);not null;check:type IN ('purchase','booking','refund','penalty','adjustment','expiry','expiry_restore','transfer')" json:"type"`
+	Amount        int        `gorm:"not null" json:"amount"`
+	BalanceAfter  int        `gorm:"not null" json:"balanceAfter"`
+	PaymentID     *uuid.UUID `gorm:"type:char(36);index" json:"paymentId"`
+	BookingID     *uuid.UUID `gorm:"type:char(36);index" json:"bookingId"`
+	Reason        string     `gorm:"type:text" json:"reason"`
+	CreatedBy     *uuid.UUID `gorm:"type:char(36)" json:"createdBy"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime;index" json:"createdAt"`
+
+	UserPackage UserPackage `gorm:"foreignKey:UserPackageID" json:"-"`
+}
+
 type Package struct {
-	ID             uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
-	Name           string         `gorm:"type:varchar(255);not null" json:"name"`
-	Description    string         `gorm:"type:text;not null" json:"description"`
-	IsActive       bool           `gorm:"not null;default:true" json:"isActive"`
-	Image          string         `gorm:"type:varchar(255)" json:"image"`
-	Price          float64        `gorm:"type:decimal(10,2);not null" json:"price"`
-	Credit         int            `gorm:"not null" json:"credit"`
-	Discount       float64        `gorm:"not null;default:0" json:"discount"`
-	Expired        int            `json:"expired"`
-	Additional     string         `gorm:"type:longtext" json:"-"`
-	AdditionalList []string       `gorm:"-" json:"additional"`
-	CreatedAt      time.Time      `gorm:"autoCreateTime"`
-	UpdatedAt      time.Time      `gorm:"autoUpdateTime"`
-	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	Name           string    `gorm:"type:varchar(255);not null" json:"name"`
+	Description    string    `gorm:"type:text;not null" json:"description"`
+	IsActive       bool      `gorm:"not null;default:true" json:"isActive"`
+	Image          string    `gorm:"type:varchar(255)" json:"image"`
+	Price          int64     `gorm:"column:price_minor;not null;default:0" json:"price"`
+	Currency       string    `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	Type           string    `gorm:"type:varchar(20);not null;default:'credit_pack';check:type IN ('credit_pack','unlimited','weekly_limit')" json:"type"`
+	Credit         int       `gorm:"not null" json:"credit"`
+	WeeklyLimit    int       `gorm:"not null;default:0" json:"weeklyLimit"`
+	AutoRenew      bool      `gorm:"not null;default:false" json:"autoRenew"`
+	MaxFreezeDays  int       `gorm:"not null;default:0" json:"maxFreezeDays"`
+	FreezeFee      int64     `gorm:"column:freeze_fee_minor;not null;default:0" json:"freezeFee"`
+	MaxMembers     int       `gorm:"not null;default:0" json:"maxMembers"`
+	MaxTransfer    int       `gorm:"not null;default:0" json:"maxTransferCredits"`
+	Discount       float64   `gorm:"not null;default:0" json:"discount"`
+	TaxCategory    string    `gorm:"type:varchar(50);not null;default:''" json:"taxCategory"`
+	TaxInclusive   bool      `gorm:"not null;default:false" json:"taxInclusive"`
+	TaxExempt      bool      `gorm:"not null;default:false" json:"taxExempt"`
+	Expired        int       `json:"expired"`
+	Additional     string    `gorm:"type:longtext" json:"-"`
+	AdditionalList []string  `gorm:"-" json:"additional"`
+
+	// purchase eligibility, zero values leave the package open to every customer
+	FirstPurchaseOnly bool       `gorm:"not null;default:false" json:"firstPurchaseOnly"`
+	MaxPerUser        int        `gorm:"not null;default:0" json:"maxPerUser"`
+	Segment           string     `gorm:"type:varchar(20);not null;default:'all';check:segment IN ('all','members','lapsed')" json:"segment"`
+	AvailableFrom     *time.Time `json:"availableFrom"`
+	AvailableUntil    *time.Time `json:"availableUntil"`
+	Locations         string     `gorm:"type:longtext" json:"-"`
+	LocationList      []string   `gorm:"-" json:"locationIds"`
+
+	CreatedAt time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
 	Classes []Class `gorm:"many2many:package_classes;" json:"classes,omitempty"`
 }
 
+// Payment amounts are stored in minor units of Currency, see pkg/money
 type Payment struct {
 	ID              uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
 	PackageID       uuid.UUID `gorm:"type:char(36);not null" json:"packageId"`
-	InvoiceNumber   string    `gorm:"type:varchar(100);uniqueIndex;not null"`
+	InvoiceNumber   *string   `gorm:"type:varchar(100);uniqueIndex" json:"invoiceNumber"`
 	PackageName     string    `gorm:"type:varchar(255);not null" json:"packageName"`
 	UserID          uuid.UUID `gorm:"type:char(36);not null" json:"userId"`
 	Fullname        string    `gorm:"type:varchar(255);not null" json:"fullname"`
 	Email           string    `gorm:"type:varchar(255);not null" json:"email"`
 	PaymentMethod   string    `gorm:"type:varchar(50);not null" json:"paymentMethod"`
 	PaymentLink     string    `gorm:"type:text;not null" json:"paymentLink"`
-	Status          string    `gorm:"type:varchar(20);default:'pending';check:status IN ('success', 'pending', 'failed')" json:"status"`
+	Status          string    `gorm:"type:varchar(20);default:'pending';check:status IN ('success', 'pending', 'failed', 'refunded', 'partially_refunded', 'disputed')" json:"status"`
 	PaidAt          time.Time `gorm:"autoCreateTime" json:"paidAt"`
-	BasePrice       float64   `gorm:"type:decimal(10,2);not null"`
-	Tax             float64   `gorm:"type:decimal(10,2);not null"`
-	Total           float64   `gorm:"type:decimal(10,2);not null"`
+	Currency        string    `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	BasePrice       int64     `gorm:"column:base_price_minor;not null;default:0" json:"basePrice"`
+	Tax             int64     `gorm:"column:tax_minor;not null;default:0" json:"tax"`
+	Total           int64     `gorm:"column:total_minor;not null;default:0" json:"total"`
 	VoucherCode     *string   `gorm:"type:varchar(100)" json:"voucherCode,omitempty"`
-	VoucherDiscount float64   `gorm:"default:0" json:"voucherDiscount"`
+	VoucherDiscount int64     `gorm:"column:voucher_discount_minor;not null;default:0" json:"voucherDiscount"`
+
+	Purpose           string     `gorm:"type:varchar(20);not null;default:'package'" json:"purpose"`
+	UserPackageID     *uuid.UUID `gorm:"type:char(36);index" json:"userPackageId,omitempty"`
+	SubscriptionID    *uuid.UUID `gorm:"type:char(36);index" json:"subscriptionId,omitempty"`
+	ProviderInvoiceID *string    `gorm:"type:varchar(255);uniqueIndex" json:"-"`
+	ProviderPaymentID *string    `gorm:"type:varchar(255);index" json:"-"`
+	ProviderSessionID *string    `gorm:"type:varchar(255);index" json:"-"`
+	Gateway           string     `gorm:"type:varchar(30);not null;default:'stripe'" json:"gateway"`
+	LocationID        *uuid.UUID `gorm:"type:char(36);index" json:"locationId,omitempty"`
+	RefundedAmount    int64      `gorm:"column:refunded_amount_minor;not null;default:0" json:"refundedAmount"`
+	GiftEmail         *string    `gorm:"type:varchar(255)" json:"giftEmail,omitempty"`
+	GiftMessage       string     `gorm:"type:text" json:"giftMessage,omitempty"`
+	BillingCompany    string     `gorm:"type:varchar(255)" json:"billingCompany,omitempty"`
+	BillingTaxID      string     `gorm:"type:varchar(50)" json:"billingTaxId,omitempty"`
+	BillingAddress    string     `gorm:"type:text" json:"billingAddress,omitempty"`
+	WalletAmount      int64      `gorm:"column:wallet_amount_minor;not null;default:0" json:"walletAmount"`
+	ReceivedBy        *uuid.UUID `gorm:"type:char(36);index" json:"receivedBy,omitempty"`
+	OfflineReference  string     `gorm:"type:varchar(100)" json:"offlineReference,omitempty"`
+	LastReconciledAt  *time.Time `gorm:"index" json:"-"`
+
+	Taxes []PaymentTax `gorm:"foreignKey:PaymentID" json:"taxes,omitempty"`
+}
+
+// WalletTransaction is one movement of a member's store credit, rows are only appended and BalanceAfter is the
+// wallet balance right after the movement
+type WalletTransaction struct {
+	ID           uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:char(36);not null;index" json:"userId"`
+	Type         string     `gorm:"type:varchar(20);not null;check:type IN ('top_up','payment','release','refund')" json:"type"`
+	Amount       int64      `gorm:"column:amount_minor;not null" json:"amount"`
+	BalanceAfter int64      `gorm:"column:balance_after_minor;not null" json:"balanceAfter"`
+	Currency     string     `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	PaymentID    *uuid.UUID `gorm:"type:char(36);index" json:"paymentId"`
+	Reason       string     `gorm:"type:text" json:"reason"`
+	CreatedBy    *uuid.UUID `gorm:"type:char(36)" json:"createdBy"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime;index" json:"createdAt"`
+}
+
+// PaymentRefund is money returned on a payment, RevokedCredits are the unused credits taken back with it and
+// WalletAmount is the part of Amount returned as store credit
+type PaymentRefund struct {
+	ID               uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	PaymentID        uuid.UUID `gorm:"type:char(36);not null;index" json:"paymentId"`
+	Amount           int64     `gorm:"column:amount_minor;not null;default:0" json:"amount"`
+	Currency         string    `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	Reason           string    `gorm:"type:text;not null" json:"reason"`
+	Status           string    `gorm:"type:varchar(20);not null;default:'pending';check:status IN ('pending','succeeded','failed')" json:"status"`
+	Provider         string    `gorm:"type:varchar(50);not null" json:"provider"`
+	ProviderRefundID *string   `gorm:"type:varchar(255);uniqueIndex" json:"-"`
+	RevokedCredits   int       `gorm:"not null;default:0" json:"revokedCredits"`
+	WalletAmount     int64     `gorm:"column:wallet_amount_minor;not null;default:0" json:"walletAmount"`
+	RefundedBy       uuid.UUID `gorm:"type:char(36);not null" json:"refundedBy"`
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"createdAt"`
+
+	Payment Payment `gorm:"foreignKey:PaymentID" json:"-"`
+	Admin   User    `gorm:"foreignKey:RefundedBy" json:"-"`
+}
+
+// TaxRule charges Rate percent on payments from EffectiveFrom until EffectiveUntil, a rule without a location or
+// tax category applies everywhere, of the rules sharing a Name the most specific one is used
+type TaxRule struct {
+	ID             uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
+	Name           string         `gorm:"type:varchar(100);not null;index" json:"name"`
+	Rate           float64        `gorm:"type:decimal(6,3);not null" json:"rate"`
+	LocationID     *uuid.UUID     `gorm:"type:char(36);index" json:"locationId"`
+	TaxCategory    string         `gorm:"type:varchar(50);not null;default:''" json:"taxCategory"`
+	EffectiveFrom  time.Time      `gorm:"not null" json:"effectiveFrom"`
+	EffectiveUntil *time.Time     `json:"effectiveUntil"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updatedAt"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Location *Location `gorm:"foreignKey:LocationID" json:"-"`
+}
+
+// PaymentTax is one tax charged on a payment, copied from its rule so the invoice stays as issued after the rule changes
+type PaymentTax struct {
+	ID        uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	PaymentID uuid.UUID  `gorm:"type:char(36);not null;index" json:"paymentId"`
+	TaxRuleID *uuid.UUID `gorm:"type:char(36)" json:"taxRuleId"`
+	Name      string     `gorm:"type:varchar(100);not null" json:"name"`
+	Rate      float64    `gorm:"type:decimal(6,3);not null" json:"rate"`
+	Inclusive bool       `gorm:"not null;default:false" json:"inclusive"`
+	Taxable   int64      `gorm:"column:taxable_minor;not null;default:0" json:"taxable"`
+	Amount    int64      `gorm:"column:amount_minor;not null;default:0" json:"amount"`
+	Currency  string     `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// InvoiceSequence is the last invoice number issued in a series, e.g. "INV/2026/10/{SEQ:5}", the row is locked
+// while a payment settles so numbers are handed out one after the other without gaps
+type InvoiceSequence struct {
+	ID         uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	Series     string    `gorm:"type:varchar(150);uniqueIndex;not null" json:"series"`
+	LastNumber int64     `gorm:"not null;default:0" json:"lastNumber"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// WebhookEvent is a notification received from a payment gateway, the provider's event ID makes redeliveries
+// land on the same row so each event is fulfilled once, failed events keep their payload for a replay
+type WebhookEvent struct {
+	ID          uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	Gateway     string     `gorm:"type:varchar(30);not null;uniqueIndex:idx_webhook_event" json:"gateway"`
+	EventID     string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_webhook_event" json:"eventId"`
+	Type        string     `gorm:"type:varchar(100);not null;index" json:"type"`
+	Reference   string     `gorm:"type:varchar(255);index" json:"reference"`
+	Payload     string     `gorm:"type:longtext;not null" json:"-"`
+	Status      string     `gorm:"type:varchar(20);not null;default:'received';index;check:status IN ('received','processed','failed','ignored')" json:"status"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	LastError   string     `gorm:"type:text" json:"lastError"`
+	ProcessedAt *time.Time `json:"processedAt"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// PaymentDiscrepancy is a difference between a payment and what its gateway reports, found by the reconciliation
+// job, a finding that keeps showing up stays one open row and only LastSeenAt moves
+type PaymentDiscrepancy struct {
+	ID             uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	PaymentID      uuid.UUID  `gorm:"type:char(36);not null;index" json:"paymentId"`
+	Gateway        string     `gorm:"type:varchar(30);not null" json:"gateway"`
+	Kind           string     `gorm:"type:varchar(30);not null;index;check:kind IN ('status_mismatch','amount_mismatch','unknown_at_provider','wallet_shortfall')" json:"kind"`
+	OurStatus      string     `gorm:"type:varchar(20);not null" json:"ourStatus"`
+	ProviderStatus string     `gorm:"type:varchar(20)" json:"providerStatus"`
+	ExpectedAmount int64      `gorm:"column:expected_amount_minor;not null;default:0" json:"expectedAmount"`
+	ProviderAmount int64      `gorm:"column:provider_amount_minor;not null;default:0" json:"providerAmount"`
+	Currency       string     `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	Action         string     `gorm:"type:varchar(20);not null;default:'none';check:action IN ('none','fulfilled','failed','refunded')" json:"action"`
+	Detail         string     `gorm:"type:text" json:"detail"`
+	Status         string     `gorm:"type:varchar(20);not null;default:'open';index;check:status IN ('open','resolved')" json:"status"`
+	ResolvedBy     *uuid.UUID `gorm:"type:char(36)" json:"resolvedBy,omitempty"`
+	ResolvedAt     *time.Time `json:"resolvedAt,omitempty"`
+	Resolution     string     `gorm:"type:text" json:"resolution,omitempty"`
+	LastSeenAt     time.Time  `json:"lastSeenAt"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// PackageGift is a paid package waiting to be redeemed by whoever holds the code,
+// redemption grants the package to the redeeming user
+type PackageGift struct {
+	ID             uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	Code           string     `gorm:"type:varchar(20);uniqueIndex;not null" json:"code"`
+	PaymentID      uuid.UUID  `gorm:"type:char(36);uniqueIndex;not null" json:"paymentId"`
+	PackageID      uuid.UUID  `gorm:"type:char(36);not null" json:"packageId"`
+	PackageName    string     `gorm:"type:varchar(255);not null" json:"packageName"`
+	PurchaserID    uuid.UUID  `gorm:"type:char(36);not null;index" json:"purchaserId"`
+	RecipientEmail string     `gorm:"type:varchar(255);not null;index" json:"recipientEmail"`
+	Message        string     `gorm:"type:text" json:"message"`
+	Status         string     `gorm:"type:varchar(20);not null;default:'issued';check:status IN ('issued','redeemed')" json:"status"`
+	RedeemedBy     *uuid.UUID `gorm:"type:char(36)" json:"redeemedBy"`
+	RedeemedAt     *time.Time `json:"redeemedAt"`
+	UserPackageID  *uuid.UUID `gorm:"type:char(36)" json:"userPackageId"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+
+	Purchaser User `gorm:"foreignKey:PurchaserID" json:"-"`
+}
+
+// UserPackageFreeze pauses a package, once approved the package expiry is pushed back by Days
+type UserPackageFreeze struct {
+	ID            uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
+	UserPackageID uuid.UUID      `gorm:"type:char(36);not null;index" json:"userPackageId"`
+	UserID        uuid.UUID      `gorm:"type:char(36);not null;index" json:"userId"`
+	StartDate     time.Time      `gorm:"not null" json:"startDate"`
+	EndDate       time.Time      `gorm:"not null" json:"endDate"`
+	Days          int            `gorm:"not null" json:"days"`
+	Reason        string         `gorm:"type:text;not null" json:"reason"`
+	Status        string         `gorm:"type:varchar(20);not null;default:'pending';check:status IN ('pending','awaiting_payment','approved','rejected','canceled')" json:"status"`
+	Fee           int64          `gorm:"column:fee_minor;not null;default:0" json:"fee"`
+	Currency      string         `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	PaymentID     *uuid.UUID     `gorm:"type:char(36);index" json:"paymentId"`
+	ReviewNote    string         `gorm:"type:text" json:"reviewNote"`
+	ReviewedBy    *uuid.UUID     `gorm:"type:char(36)" json:"reviewedBy"`
+	ReviewedAt    *time.Time     `json:"reviewedAt"`
+	CreatedAt     time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+
+	UserPackage UserPackage `gorm:"foreignKey:UserPackageID" json:"userPackage"`
+	User        User        `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// UserPackageMember shares the credit pool of a UserPackage with another user, CreditCap limits
+// how many credits the member may spend from the pool, nil means no cap
+type UserPackageMember struct {
+	ID            uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserPackageID uuid.UUID  `gorm:"type:char(36);not null;uniqueIndex:idx_package_member" json:"userPackageId"`
+	UserID        uuid.UUID  `gorm:"type:char(36);not null;uniqueIndex:idx_package_member" json:"userId"`
+	InvitedBy     uuid.UUID  `gorm:"type:char(36);not null" json:"invitedBy"`
+	Status        string     `gorm:"type:varchar(20);not null;default:'invited';check:status IN ('invited','active','declined','removed')" json:"status"`
+	CreditCap     *int       `json:"creditCap"`
+	JoinedAt      *time.Time `json:"joinedAt"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+
+	UserPackage UserPackage `gorm:"foreignKey:UserPackageID" json:"userPackage"`
+	User        User        `gorm:"foreignKey:UserID" json:"user"`
+	Inviter     User        `gorm:"foreignKey:InvitedBy" json:"-"`
+}
+
+// Subscription tracks recurring billing of an auto-renewing membership, every paid renewal
+// extends the linked UserPackage by one period
+type Subscription struct {
+	ID                     uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID                 uuid.UUID  `gorm:"type:char(36);not null;index" json:"userId"`
+	PackageID              uuid.UUID  `gorm:"type:char(36);not null" json:"packageId"`
+	UserPackageID          uuid.UUID  `gorm:"type:char(36);not null" json:"userPackageId"`
+	PackageName            string     `gorm:"type:varchar(255);not null" json:"packageName"`
+	Provider               string     `gorm:"type:varchar(50);not null" json:"provider"`
+	ProviderSubscriptionID string     `gorm:"type:varchar(255);not null;uniqueIndex" json:"-"`
+	Status                 string     `gorm:"type:varchar(20);not null;default:'active';check:status IN ('active','past_due','canceled')" json:"status"`
+	CurrentPeriodStart     time.Time  `json:"currentPeriodStart"`
+	CurrentPeriodEnd       time.Time  `json:"currentPeriodEnd"`
+	CancelAtPeriodEnd      bool       `gorm:"not null;default:false" json:"cancelAtPeriodEnd"`
+	FailedAttempts         int        `gorm:"not null;default:0" json:"failedAttempts"`
+	NextRetryAt            *time.Time `json:"nextRetryAt"`
+	CanceledAt             *time.Time `json:"canceledAt"`
+	CreatedAt              time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt              time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+
+	Package Package `gorm:"foreignKey:PackageID" json:"-"`
 }
 
 type ClassSchedule struct {
-	ID               uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
-	ClassID          uuid.UUID `gorm:"type:char(36);not null" json:"classId"`
-	ClassImage       string    `gorm:"type:varchar(255);not null" json:"classImage"`
-	ClassName        string    `gorm:"type:varchar(255);not null" json:"className"`
-	Location         string    `gorm:"type:varchar(255);not null" json:"location"`
-	InstructorID     uuid.UUID `gorm:"type:char(36);not null" json:"instructorId"`
-	InstructorName   string    `gorm:"type:varchar(255);not null" json:"instructorName"`
-	Capacity         int       `gorm:"not null" json:"capacity"`
-	Color            string    `gorm:"type:varchar(20)" json:"color"`
-	Date             time.Time `gorm:"not null" json:"date"`
-	Booked           int       `gorm:"not null;default:0" json:"booked"`
-	StartHour        int       `gorm:"not null" json:"startHour"`
-	StartMinute      int       `gorm:"not null" json:"startMinute"`
-	Duration         int       `gorm:"not null" json:"duration"`
-	ZoomLink         *string   `gorm:"type:varchar(255)" json:"zoomLink,omitempty"`
-	IsOpened         bool      `gorm:"default:false" json:"isOpened"`
-	VerificationCode *string   `gorm:"type:varchar(10)" json:"verificationCode,omitempty"`
+	ID               uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	ClassID          uuid.UUID  `gorm:"type:char(36);not null" json:"classId"`
+	ClassImage       string     `gorm:"type:varchar(255);not null" json:"classImage"`
+	ClassName        string     `gorm:"type:varchar(255);not null" json:"className"`
+	Location         string     `gorm:"type:varchar(255);not null" json:"location"`
+	InstructorID     uuid.UUID  `gorm:"type:char(36);not null" json:"instructorId"`
+	InstructorName   string     `gorm:"type:varchar(255);not null" json:"instructorName"`
+	DeliveryMode     string     `gorm:"type:varchar(20);not null;default:'in_person';check:delivery_mode IN ('in_person','online','hybrid')" json:"deliveryMode"`
+	Capacity         int        `gorm:"not null" json:"capacity"`
+	OnlineCapacity   int        `gorm:"not null;default:0" json:"onlineCapacity"`
+	Color            string     `gorm:"type:varchar(20)" json:"color"`
+	Date             time.Time  `gorm:"not null" json:"date"`
+	Booked           int        `gorm:"not null;default:0" json:"booked"`
+	OnlineBooked     int        `gorm:"not null;default:0" json:"onlineBooked"`
+	StartHour        int        `gorm:"not null" json:"startHour"`
+	StartMinute      int        `gorm:"not null" json:"startMinute"`
+	Duration         int        `gorm:"not null" json:"duration"`
+	ZoomLink         *string    `gorm:"type:varchar(255)" json:"zoomLink,omitempty"`
+	MeetingProvider  string     `gorm:"type:varchar(30)" json:"meetingProvider,omitempty"`
+	MeetingID        string     `gorm:"type:varchar(100)" json:"meetingId,omitempty"`
+	IsOpened         bool       `gorm:"default:false" json:"isOpened"`
+	VerificationCode *string    `gorm:"type:varchar(10)" json:"verificationCode,omitempty"`
+	Status           string     `gorm:"type:varchar(20);not null;default:'scheduled';index;check:status IN ('scheduled','open','in_progress','completed','canceled')" json:"status"`
+	CancelReason     string     `gorm:"type:varchar(255)" json:"cancelReason,omitempty"`
+	CompletedAt      *time.Time `json:"completedAt,omitempty"`
+	CanceledAt       *time.Time `json:"canceledAt,omitempty"`
 
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 	Bookings  []Booking      `gorm:"foreignKey:ClassScheduleID" json:"bookings"`
 }
 
 type Booking struct {
-	ID              uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
-	UserID          uuid.UUID `gorm:"type:char(36);not null;uniqueIndex:idx_user_schedule" json:"userId"`
-	ClassScheduleID uuid.UUID `gorm:"type:char(36);not null;uniqueIndex:idx_user_schedule" json:"classScheduleId"`
-	Status          string    `gorm:"type:varchar(20);not null;default:'booked';check:status IN ('booked','canceled')" json:"status"`
-	CreatedAt       time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	ID              uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID          uuid.UUID  `gorm:"type:char(36);not null;uniqueIndex:idx_user_schedule" json:"userId"`
+	ClassScheduleID uuid.UUID  `gorm:"type:char(36);not null;uniqueIndex:idx_user_schedule" json:"classScheduleId"`
+	Status          string     `gorm:"type:varchar(20);not null;default:'booked';check:status IN ('booked','canceled')" json:"status"`
+	Mode            string     `gorm:"type:varchar(20);not null;default:'in_person';check:mode IN ('in_person','online')" json:"mode"`
+	UserPackageID   *uuid.UUID `gorm:"type:char(36)" json:"userPackageId,omitempty"`
+	PackageMemberID *uuid.UUID `gorm:"type:char(36);index" json:"packageMemberId,omitempty"`
+	CreatedAt       time.Time  `gorm:"autoCreateTime" json:"createdAt"`
 
 	User          User          `gorm:"foreignKey:UserID" json:"user"`
 	ClassSchedule ClassSchedule `gorm:"foreignKey:ClassScheduleID" json:"classSchedule"`
@@ -183,10 +464,13 @@ type ScheduleTemplate struct {
 	Location        string         `gorm:"type:varchar(255);not null" json:"location"`
 	InstructorID    uuid.UUID      `gorm:"type:char(36);not null" json:"instructorId"`
 	InstructorName  string         `gorm:"type:varchar(255);not null" json:"instructorName"`
+	Room            string         `gorm:"type:varchar(100)" json:"room"`
 	DayOfWeeks      datatypes.JSON `gorm:"type:json" json:"dayOfWeeks"`
 	StartHour       int            `gorm:"not null" json:"startHour"`
 	StartMinute     int            `gorm:"not null" json:"startMinute"`
+	DeliveryMode    string         `gorm:"type:varchar(20);not null;default:'in_person'" json:"deliveryMode"`
 	Capacity        int            `gorm:"not null" json:"capacity"`
+	OnlineCapacity  int            `gorm:"not null;default:0" json:"onlineCapacity"`
 	IsActive        bool           `gorm:"default:true" json:"isActive"`
 	Color           string         `gorm:"type:varchar(20)" json:"color"`
 	LastGeneratedAt *time.Time     `gorm:"column:last_generated_at" json:"lastGeneratedAt"`
@@ -203,6 +487,7 @@ type Location struct {
 	Name        string         `gorm:"type:varchar(255);not null" json:"name"`
 	Address     string         `gorm:"type:varchar(255);not null" json:"address"`
 	GeoLocation string         `gorm:"type:varchar(255);not null" json:"geoLocation"`
+	Code        string         `gorm:"type:varchar(10);not null;default:''" json:"code"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
@@ -233,13 +518,16 @@ type Level struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// Voucher takes Discount percent off, or Amount in minor units of Currency off for fixed vouchers
 type Voucher struct {
 	ID           uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
 	Code         string         `gorm:"type:varchar(100);uniqueIndex;not null" json:"code"`
 	Description  string         `gorm:"type:text" json:"description"`
 	DiscountType string         `gorm:"type:varchar(20);not null" json:"discountType"`
 	Discount     float64        `gorm:"not null" json:"discount"`
-	MaxDiscount  *float64       `gorm:"maxDiscount,omitempty"`
+	Amount       int64          `gorm:"column:discount_amount_minor;not null;default:0" json:"amount"`
+	MaxDiscount  *int64         `gorm:"column:max_discount_minor" json:"maxDiscount,omitempty"`
+	Currency     string         `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
 	Quota        int            `gorm:"not null" json:"quota"`
 	IsReusable   bool           `gorm:"default:false" json:"isReusable"`
 	ExpiredAt    time.Time      `gorm:"not null" json:"expiredAt"`
@@ -255,6 +543,19 @@ type UsedVoucher struct {
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
+// VoucherReservation holds one use of a voucher for a checkout, the use is committed once the payment succeeds
+// and released back to the quota when the payment fails or expires
+type VoucherReservation struct {
+	ID        uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	VoucherID uuid.UUID `gorm:"type:char(36);not null;index" json:"voucherId"`
+	UserID    uuid.UUID `gorm:"type:char(36);not null;index" json:"userId"`
+	PaymentID uuid.UUID `gorm:"type:char(36);not null;uniqueIndex" json:"paymentId"`
+	Status    string    `gorm:"type:varchar(20);not null;default:'held';index;check:status IN ('held','committed','released')" json:"status"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expiresAt"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
 type Review struct {
 	ID        uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
 	UserID    uuid.UUID      `gorm:"type:char(36);not null" json:"userId"`
@@ -283,6 +584,33 @@ type Instructor struct {
 	User User `gorm:"foreignKey:UserID"`
 }
 
+type InstructorAvailability struct {
+	ID           uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	InstructorID uuid.UUID `gorm:"type:char(36);not null;index" json:"instructorId"`
+	DayOfWeek    int       `gorm:"not null" json:"dayOfWeek"`
+	StartHour    int       `gorm:"not null" json:"startHour"`
+	StartMinute  int       `gorm:"not null" json:"startMinute"`
+	EndHour      int       `gorm:"not null" json:"endHour"`
+	EndMinute    int       `gorm:"not null" json:"endMinute"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+type InstructorTimeOff struct {
+	ID           uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
+	InstructorID uuid.UUID      `gorm:"type:char(36);not null;index" json:"instructorId"`
+	StartDate    time.Time      `gorm:"not null" json:"startDate"`
+	EndDate      time.Time      `gorm:"not null" json:"endDate"`
+	Reason       string         `gorm:"type:text;not null" json:"reason"`
+	Status       string         `gorm:"type:varchar(20);not null;default:'pending';check:status IN ('pending','approved','rejected','canceled')" json:"status"`
+	ReviewNote   string         `gorm:"type:text" json:"reviewNote"`
+	ReviewedBy   *uuid.UUID     `gorm:"type:char(36)" json:"reviewedBy"`
+	ReviewedAt   *time.Time     `json:"reviewedAt"`
+	CreatedAt    time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Instructor Instructor `gorm:"foreignKey:InstructorID" json:"instructor"`
+}
+
 type NotificationType struct {
 	ID             uuid.UUID      `gorm:"type:char(36);primaryKey"`
 	Code           string         `gorm:"unique;not null"`
@@ -326,6 +654,41 @@ func (up *UserPackage) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 	return
 }
+func (e *CreditLedgerEntry) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return
+}
+
+func (f *UserPackageFreeze) BeforeCreate(tx *gorm.DB) (err error) {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	return
+}
+
+func (g *PackageGift) BeforeCreate(tx *gorm.DB) (err error) {
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+	return
+}
+
+func (m *UserPackageMember) BeforeCreate(tx *gorm.DB) (err error) {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return
+}
+
+func (s *Subscription) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return
+}
+
 func (p *Payment) BeforeCreate(tx *gorm.DB) (err error) {
 	if p.ID == uuid.Nil {
 		p.ID = uuid.New()
@@ -386,6 +749,20 @@ func (r *Review) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+func (a *InstructorAvailability) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return
+}
+
+func (t *InstructorTimeOff) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return
+}
+
 func (n *Notification) BeforeCreate(tx *gorm.DB) (err error) {
 	if n.ID == uuid.Nil {
 		n.ID = uuid.New()
@@ -447,6 +824,62 @@ func (c *Voucher) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 	return
 }
+func (r *PaymentRefund) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
+
+func (t *TaxRule) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return
+}
+
+func (t *PaymentTax) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return
+}
+
+func (q *InvoiceSequence) BeforeCreate(tx *gorm.DB) (err error) {
+	if q.ID == uuid.Nil {
+		q.ID = uuid.New()
+	}
+	return
+}
+
+func (e *WebhookEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return
+}
+
+func (w *WalletTransaction) BeforeCreate(tx *gorm.DB) (err error) {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	return
+}
+
+func (d *PaymentDiscrepancy) BeforeCreate(tx *gorm.DB) (err error) {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return
+}
+
+func (v *VoucherReservation) BeforeCreate(tx *gorm.DB) (err error) {
+	if v.ID == uuid.Nil {
+		v.ID = uuid.New()
+	}
+	return
+}
+
 func (pc *PackageClass) BeforeCreate(tx *gorm.DB) (err error) {
 	if pc.ID == uuid.Nil {
 		pc.ID = uuid.New()
@@ -469,6 +902,13 @@ func (p *Package) BeforeSave(tx *gorm.DB) error {
 		}
 		p.Additional = string(data)
 	}
+	if p.LocationList != nil {
+		data, err := json.Marshal(p.LocationList)
+		if err != nil {
+			return err
+		}
+		p.Locations = string(data)
+	}
 	return nil
 }
 
@@ -502,5 +942,12 @@ func (p *Package) AfterFind(tx *gorm.DB) error {
 		}
 		p.AdditionalList = data
 	}
+	if p.Locations != "" {
+		var data []string
+		if err := json.Unmarshal([]byte(p.Locations), &data); err != nil {
+			return err
+		}
+		p.LocationList = data
+	}
 	return nil
 }
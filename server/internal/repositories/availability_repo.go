@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"errors"
+	"server/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AvailabilityRepository interface {
+	GetAvailabilityByInstructorID(instructorID uuid.UUID) ([]models.InstructorAvailability, error)
+	ReplaceAvailability(instructorID uuid.UUID, windows []models.InstructorAvailability) error
+
+	// time-off
+	CreateTimeOff(timeOff *models.InstructorTimeOff) error
+	UpdateTimeOff(timeOff *models.InstructorTimeOff) error
+	GetTimeOffByID(id string) (*models.InstructorTimeOff, error)
+	GetTimeOffs(status string) ([]models.InstructorTimeOff, error)
+	GetTimeOffsByInstructorID(instructorID uuid.UUID) ([]models.InstructorTimeOff, error)
+	GetApprovedTimeOffs(instructorID uuid.UUID, from time.Time) ([]models.InstructorTimeOff, error)
+}
+
+type availabilityRepository struct {
+	db *gorm.DB
+}
+
+func NewAvailabilityRepository(db *gorm.DB) AvailabilityRepository {
+	return &availabilityRepository{db}
+}
+
+func (r *availabilityRepository) GetAvailabilityByInstructorID(instructorID uuid.UUID) ([]models.InstructorAvailability, error) {
+	var windows []models.InstructorAvailability
+	err := r.db.
+		Where("instructor_id = ?", instructorID).
+		Order("day_of_week asc").
+		Order("start_hour asc").
+		Order("start_minute asc").
+		Find(&windows).Error
+	return windows, err
+}
+
+func (r *availabilityRepository) ReplaceAvailability(instructorID uuid.UUID, windows []models.InstructorAvailability) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("instructor_id = ?", instructorID).Delete(&models.InstructorAvailability{}).Error; err != nil {
+			return err
+		}
+		if len(windows) == 0 {
+			return nil
+		}
+		return tx.Create(&windows).Error
+	})
+}
+
+func (r *availabilityRepository) CreateTimeOff(timeOff *models.InstructorTimeOff) error {
+	return r.db.Create(timeOff).Error
+}
+
+func (r *availabilityRepository) UpdateTimeOff(timeOff *models.InstructorTimeOff) error {
+	return r.db.Omit("Instructor").Save(timeOff).Error
+}
+
+func (r *availabilityRepository) GetTimeOffByID(id string) (*models.InstructorTimeOff, error) {
+	var timeOff models.InstructorTimeOff
+	err := r.db.Preload("Instructor.User").First(&timeOff, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &timeOff, err
+}
+
+func (r *availabilityRepository) GetTimeOffs(status string) ([]models.InstructorTimeOff, error) {
+	var timeOffs []models.InstructorTimeOff
+	db := r.db.Preload("Instructor.User").Order("start_date asc")
+	if status != "" && status != "all" {
+		db = db.Where("status = ?", status)
+	}
+	err := db.Find(&timeOffs).Error
+	return timeOffs, err
+}
+
+func (r *availabilityRepository) GetTimeOffsByInstructorID(instructorID uuid.UUID) ([]models.InstructorTimeOff, error) {
+	var timeOffs []models.InstructorTimeOff
+	err := r.db.
+		Where("instructor_id = ?", instructorID).
+		Order("start_date desc").
+		Find(&timeOffs).Error
+	return timeOffs, err
+}
+
+func (r *availabilityRepository) GetApprovedTimeOffs(instructorID uuid.UUID, from time.Time) ([]models.InstructorTimeOff, error) {
+	var timeOffs []models.InstructorTimeOff
+	err := r.db.
+		Where("instructor_id = ? AND status = ? AND end_date >= ?", instructorID, "approved", from).
+		Find(&timeOffs).Error
+	return timeOffs, err
+}
@@ -4,14 +4,29 @@ import (
 	"errors"
 	"server/internal/dto"
 	"server/internal/models"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrWeeklyLimitReached = errors.New("weekly booking limit reached")
+
+// BookingLimits are what a new booking has to stay within, a zero WeeklyLimit and a nil MemberCap are not checked
+type BookingLimits struct {
+	WeeklyLimit int
+	WeekStart   time.Time
+	WeekEnd     time.Time
+	MemberCap   *int
+}
+
 type BookingRepository interface {
 	CreateBooking(booking *models.Booking) error
+	BookSchedule(booking *models.Booking, attendance *models.Attendance, entry *models.CreditLedgerEntry, limits BookingLimits) error
 	CountBookingBySchedule(scheduleID string) (int64, error)
+	CountBookingByScheduleAndMode(scheduleID, mode string) (int64, error)
+	CountPackageBookingsInRange(userPackageID uuid.UUID, start, end time.Time) (int64, error)
 	CheckAttendanceExists(bookingID uuid.UUID) (bool, error)
 	IsUserBookedSchedule(userID, scheduleID string) (bool, error)
 	UpdateBookingStatus(bookingID uuid.UUID, status string) error
@@ -93,6 +108,52 @@ func (r *bookingRepository) CreateBooking(booking *models.Booking) error {
 	return r.db.Create(booking).Error
 }
 
+// BookSchedule stores a booking with its attendance and takes its seat in one transaction, a credit pack pays with
+// entry. ErrWeeklyLimitReached and ErrMemberCapReached are returned when the booking goes past its limits
+func (r *bookingRepository) BookSchedule(booking *models.Booking, attendance *models.Attendance, entry *models.CreditLedgerEntry, limits BookingLimits) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if limits.WeeklyLimit > 0 {
+			if err := CheckWeeklyLimit(tx, *booking.UserPackageID, limits.WeekStart, limits.WeekEnd, limits.WeeklyLimit); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(booking).Error; err != nil {
+			return err
+		}
+		attendance.BookingID = booking.ID
+		if err := tx.Create(attendance).Error; err != nil {
+			return err
+		}
+
+		if entry != nil {
+			entry.BookingID = &booking.ID
+			if err := ApplyCreditEntry(tx, entry); err != nil {
+				return err
+			}
+
+			// the package row is locked by the ledger entry, so the cap is checked against a stable count
+			if limits.MemberCap != nil {
+				used, err := CountCreditsUsedByUser(tx, entry.UserPackageID, booking.UserID)
+				if err != nil {
+					return err
+				}
+				if int(used) > *limits.MemberCap {
+					return ErrMemberCapReached
+				}
+			}
+		}
+
+		updates := map[string]any{"booked": gorm.Expr("booked + 1")}
+		if booking.Mode == "online" {
+			updates["online_booked"] = gorm.Expr("online_booked + 1")
+		}
+		return tx.Model(&models.ClassSchedule{}).
+			Where("id = ?", booking.ClassScheduleID).
+			Updates(updates).Error
+	})
+}
+
 func (r *bookingRepository) GetBookingByID(userID, bookingID string) (*models.Booking, error) {
 	var booking models.Booking
 	err := r.db.Preload("ClassSchedule").Preload("Attendance").Where("user_id = ?", userID).First(&booking, "id = ?", bookingID).Error
@@ -108,6 +169,48 @@ func (r *bookingRepository) CountBookingBySchedule(scheduleID string) (int64, er
 	return count, err
 }
 
+func (r *bookingRepository) CountBookingByScheduleAndMode(scheduleID, mode string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Booking{}).Where("class_schedule_id = ? AND mode = ?", scheduleID, mode).Count(&count).Error
+	return count, err
+}
+
+// counts active bookings made with the package for classes held between start (inclusive) and end (exclusive)
+func (r *bookingRepository) CountPackageBookingsInRange(userPackageID uuid.UUID, start, end time.Time) (int64, error) {
+	return countPackageBookingsInRange(r.db, userPackageID, start, end)
+}
+
+// CheckWeeklyLimit counts the bookings of a weekly plan using the caller's transaction, the package row is locked
+// first so concurrent bookings of the same package wait for each other and cannot both take the last slot
+func CheckWeeklyLimit(tx *gorm.DB, userPackageID uuid.UUID, weekStart, weekEnd time.Time, limit int) error {
+	var userPackage models.UserPackage
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		Where("id = ?", userPackageID).
+		First(&userPackage).Error; err != nil {
+		return err
+	}
+
+	count, err := countPackageBookingsInRange(tx, userPackageID, weekStart, weekEnd)
+	if err != nil {
+		return err
+	}
+	if int(count) >= limit {
+		return ErrWeeklyLimitReached
+	}
+	return nil
+}
+
+func countPackageBookingsInRange(db *gorm.DB, userPackageID uuid.UUID, start, end time.Time) (int64, error) {
+	var count int64
+	err := db.Model(&models.Booking{}).
+		Joins("JOIN class_schedules cs ON cs.id = bookings.class_schedule_id").
+		Where("bookings.user_package_id = ? AND bookings.status = ?", userPackageID, "booked").
+		Where("cs.date >= ? AND cs.date < ?", start, end).
+		Count(&count).Error
+	return count, err
+}
+
 func (r *bookingRepository) IsUserBookedSchedule(userID, scheduleID string) (bool, error) {
 	var count int64
 	err := r.db.Model(&models.Booking{}).
@@ -15,6 +15,7 @@ type ClassRepository interface {
 	CreateClass(class *models.Class) error
 	DeleteClassGalleryByID(id string) error
 	GetClassByID(id string) (*models.Class, error)
+	GetClassByTitle(title string) (*models.Class, error)
 	SaveClassGalleries(galleries []models.ClassGallery) error
 	GetAllClasses(params dto.ClassQueryParam) ([]models.Class, int64, error)
 	FindGalleriesByClassID(classID uuid.UUID) ([]models.ClassGallery, error)
@@ -60,6 +61,19 @@ func (r *classRepository) GetClassByID(id string) (*models.Class, error) {
 	return &class, err
 }
 
+func (r *classRepository) GetClassByTitle(title string) (*models.Class, error) {
+	var class models.Class
+	err := r.db.
+		Preload("Location").
+		Where("title = ?", title).
+		First(&class).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &class, err
+}
+
 func (r *classRepository) SaveClassGalleries(galleries []models.ClassGallery) error {
 	return r.db.Create(&galleries).Error
 }
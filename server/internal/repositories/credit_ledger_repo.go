@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"errors"
+	"server/internal/dto"
+	"server/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInsufficientCredit = errors.New("not enough credit")
+
+var ErrTransferLimitReached = errors.New("credit transfer limit reached")
+
+type CreditLedgerRepository interface {
+	ApplyEntry(entry *models.CreditLedgerEntry) error
+	RecordReconciliation(entry *models.CreditLedgerEntry) error
+	GetEntriesByUserID(userID string, params dto.CreditHistoryQueryParam) ([]models.CreditLedgerEntry, int64, error)
+	GetEntriesByUserPackageID(userPackageID string) ([]models.CreditLedgerEntry, error)
+	GetLedgerBalance(userPackageID string) (int, error)
+	GetLatestEntryByType(userPackageID string, entryTypes ...string) (*models.CreditLedgerEntry, error)
+	GetTransferredOut(userPackageID string) (int, error)
+	GetPurchaseEntryByPaymentID(paymentID string) (*models.CreditLedgerEntry, error)
+}
+
+type creditLedgerRepository struct {
+	db *gorm.DB
+}
+
+func NewCreditLedgerRepository(db *gorm.DB) CreditLedgerRepository {
+	return &creditLedgerRepository{db}
+}
+
+// ApplyCreditEntry moves the package balance and appends the matching ledger entry using the caller's transaction,
+// the package row is locked so BalanceAfter stays consistent under concurrent bookings
+func ApplyCreditEntry(tx *gorm.DB, entry *models.CreditLedgerEntry) error {
+	var userPackage models.UserPackage
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", entry.UserPackageID).
+		First(&userPackage).Error; err != nil {
+		return err
+	}
+
+	balance := userPackage.RemainingCredit + entry.Amount
+	if entry.Amount < 0 && balance < 0 {
+		return ErrInsufficientCredit
+	}
+
+	if err := tx.Model(&models.UserPackage{}).
+		Where("id = ?", userPackage.ID).
+		Update("remaining_credit", balance).Error; err != nil {
+		return err
+	}
+
+	entry.UserID = userPackage.UserID
+	entry.BalanceAfter = balance
+	return tx.Create(entry).Error
+}
+
+func (r *creditLedgerRepository) ApplyEntry(entry *models.CreditLedgerEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return ApplyCreditEntry(tx, entry)
+	})
+}
+
+// RecordReconciliation appends an entry without touching RemainingCredit, used to bring the ledger of
+// packages that predate it in line with the stored balance
+func (r *creditLedgerRepository) RecordReconciliation(entry *models.CreditLedgerEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var userPackage models.UserPackage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", entry.UserPackageID).
+			First(&userPackage).Error; err != nil {
+			return err
+		}
+
+		entry.UserID = userPackage.UserID
+		entry.BalanceAfter = userPackage.RemainingCredit
+		return tx.Create(entry).Error
+	})
+}
+
+// GetLatestEntryByType is the most recent entry of the package with any of the given types
+func (r *creditLedgerRepository) GetLatestEntryByType(userPackageID string, entryTypes ...string) (*models.CreditLedgerEntry, error) {
+	var entry models.CreditLedgerEntry
+	err := r.db.
+		Where("user_package_id = ? AND type IN ?", userPackageID, entryTypes).
+		Order("created_at desc").
+		First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &entry, err
+}
+
+// credits moved out of the package by transfers so far
+func (r *creditLedgerRepository) GetTransferredOut(userPackageID string) (int, error) {
+	return transferredOut(r.db, userPackageID)
+}
+
+func transferredOut(db *gorm.DB, userPackageID string) (int, error) {
+	var total int
+	err := db.Model(&models.CreditLedgerEntry{}).
+		Select("COALESCE(-SUM(amount), 0)").
+		Where("user_package_id = ? AND type = ? AND amount < 0", userPackageID, "transfer").
+		Scan(&total).Error
+	return total, err
+}
+
+func (r *creditLedgerRepository) GetEntriesByUserID(userID string, params dto.CreditHistoryQueryParam) ([]models.CreditLedgerEntry, int64, error) {
+	var entries []models.CreditLedgerEntry
+	var count int64
+
+	db := r.db.Model(&models.CreditLedgerEntry{}).
+		Where("user_id = ?", userID).
+		Preload("UserPackage")
+
+	if params.UserPackageID != "" {
+		db = db.Where("user_package_id = ?", params.UserPackageID)
+	}
+	if params.Type != "" {
+		db = db.Where("type = ?", params.Type)
+	}
+
+	if err := db.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (params.Page - 1) * params.Limit
+	if err := db.Order("created_at desc").Limit(params.Limit).Offset(offset).Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return entries, count, nil
+}
+
+func (r *creditLedgerRepository) GetEntriesByUserPackageID(userPackageID string) ([]models.CreditLedgerEntry, error) {
+	var entries []models.CreditLedgerEntry
+	err := r.db.
+		Where("user_package_id = ?", userPackageID).
+		Order("created_at asc").
+		Find(&entries).Error
+	return entries, err
+}
+
+func (r *creditLedgerRepository) GetLedgerBalance(userPackageID string) (int, error) {
+	var balance int
+	err := r.db.Model(&models.CreditLedgerEntry{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("user_package_id = ?", userPackageID).
+		Scan(&balance).Error
+	return balance, err
+}
+
+// the credits granted by a payment, gifts record the redeemer's package on the purchaser's payment
+func (r *creditLedgerRepository) GetPurchaseEntryByPaymentID(paymentID string) (*models.CreditLedgerEntry, error) {
+	var entry models.CreditLedgerEntry
+	err := r.db.
+		Where("payment_id = ? AND type = ?", paymentID, "purchase").
+		Order("created_at desc").
+		First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &entry, err
+}
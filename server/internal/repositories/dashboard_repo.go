@@ -3,6 +3,7 @@ package repositories
 import (
 	"server/internal/dto"
 	"server/internal/models"
+	"server/pkg/money"
 
 	"gorm.io/gorm"
 )
@@ -18,6 +19,7 @@ type DashboardRepository interface {
 
 	GetRevenueStatsByRange(rangeType string) ([]dto.RevenueStat, float64, error)
 	CountAttendanceByStatus(status string) (int, error)
+	GetForfeitedCreditsByMonth() ([]dto.ForfeitedCreditStat, error)
 }
 
 type dashboardRepository struct {
@@ -52,16 +54,20 @@ func (r *dashboardRepository) CountBookings() (int, error) {
 	return int(count), err
 }
 
+// payments that were paid, refunds included, pending and failed checkouts are left out
+var paidStatuses = []string{"success", "partially_refunded", "refunded"}
+
 func (r *dashboardRepository) CountPayments() (int, error) {
 	var count int64
-	err := r.db.Model(&models.Payment{}).Where("status = ?", "success").Count(&count).Error
+	err := r.db.Model(&models.Payment{}).Where("status IN ?", paidStatuses).Count(&count).Error
 	return int(count), err
 }
 
+// SumRevenue adds up payments in minor units, amounts are reported in the default currency
 func (r *dashboardRepository) SumRevenue() (float64, error) {
-	var total float64
-	err := r.db.Model(&models.Payment{}).Where("status = ?", "success").Select("SUM(total)").Scan(&total).Error
-	return total, err
+	var total int64
+	err := r.db.Model(&models.Payment{}).Where("status IN ?", paidStatuses).Select("COALESCE(SUM(total_minor - refunded_amount_minor), 0)").Scan(&total).Error
+	return toMajor(total), err
 }
 
 func (r *dashboardRepository) CountActivePackages() (int, error) {
@@ -77,10 +83,14 @@ func (r *dashboardRepository) CountAttendanceByStatus(status string) (int, error
 }
 
 func (r *dashboardRepository) GetRevenueStatsByRange(status string) ([]dto.RevenueStat, float64, error) {
-	var stats []dto.RevenueStat
-	var total float64
+	var rows []struct {
+		Date     string
+		Total    int64
+		Refunded int64
+	}
+	var total int64
 
-	query := r.db.Model(&models.Payment{}).Where("status = ?", "success")
+	query := r.db.Model(&models.Payment{}).Where("status IN ?", paidStatuses)
 
 	selectClause := ""
 	groupClause := ""
@@ -106,14 +116,38 @@ func (r *dashboardRepository) GetRevenueStatsByRange(status string) ([]dto.Reven
 	}
 
 	err := query.
-		Select(selectClause + ", SUM(total) as total").
+		Select(selectClause + ", SUM(total_minor - refunded_amount_minor) as total, SUM(refunded_amount_minor) as refunded").
 		Group(groupClause).
 		Order(orderClause + " ASC").
-		Scan(&stats).Error
+		Scan(&rows).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
-	err = query.Select("SUM(total)").Scan(&total).Error
-	return stats, total, err
+	stats := make([]dto.RevenueStat, 0, len(rows))
+	for _, row := range rows {
+		stats = append(stats, dto.RevenueStat{Date: row.Date, Total: toMajor(row.Total), Refunded: toMajor(row.Refunded)})
+	}
+
+	err = query.Select("COALESCE(SUM(total_minor - refunded_amount_minor), 0)").Scan(&total).Error
+	return stats, toMajor(total), err
+}
+
+func toMajor(amount int64) float64 {
+	return money.New(amount, money.DefaultCurrency()).Major()
+}
+
+// credits lost to expiry per month, credits given back by grace extensions are reported apart as restored
+func (r *dashboardRepository) GetForfeitedCreditsByMonth() ([]dto.ForfeitedCreditStat, error) {
+	var stats []dto.ForfeitedCreditStat
+	err := r.db.Model(&models.CreditLedgerEntry{}).
+		Select(`DATE_FORMAT(created_at, '%Y-%m') as month,
+			-SUM(CASE WHEN type = 'expiry' THEN amount ELSE 0 END) as credits,
+			SUM(CASE WHEN type = 'expiry_restore' THEN amount ELSE 0 END) as restored,
+			COUNT(DISTINCT CASE WHEN type = 'expiry' THEN user_package_id END) as packages`).
+		Where("type IN ?", []string{"expiry", "expiry_restore"}).
+		Group("DATE_FORMAT(created_at, '%Y-%m')").
+		Order("month ASC").
+		Scan(&stats).Error
+	return stats, err
 }
@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"errors"
+	"server/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type FreezeRepository interface {
+	CreateFreeze(freeze *models.UserPackageFreeze) error
+	UpdateFreeze(freeze *models.UserPackageFreeze) error
+	ApplyFreeze(freeze *models.UserPackageFreeze) error
+	GetFreezeByID(id string) (*models.UserPackageFreeze, error)
+	GetFreezeByPaymentID(paymentID uuid.UUID) (*models.UserPackageFreeze, error)
+	GetFreezesByUserID(userID string) ([]models.UserPackageFreeze, error)
+	GetFreezes(status string) ([]models.UserPackageFreeze, error)
+	GetOpenFreezesByUserPackage(userPackageID uuid.UUID) ([]models.UserPackageFreeze, error)
+	GetUnpaidFreezes() ([]models.UserPackageFreeze, error)
+	IsFrozenOn(userPackageID uuid.UUID, date time.Time) (bool, error)
+}
+
+type freezeRepository struct {
+	db *gorm.DB
+}
+
+func NewFreezeRepository(db *gorm.DB) FreezeRepository {
+	return &freezeRepository{db}
+}
+
+func (r *freezeRepository) CreateFreeze(freeze *models.UserPackageFreeze) error {
+	return r.db.Create(freeze).Error
+}
+
+func (r *freezeRepository) UpdateFreeze(freeze *models.UserPackageFreeze) error {
+	return r.db.Omit("UserPackage", "User").Save(freeze).Error
+}
+
+// ApplyFreeze approves the freeze and pushes back the package expiry by the frozen days in one transaction
+func (r *freezeRepository) ApplyFreeze(freeze *models.UserPackageFreeze) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		freeze.Status = "approved"
+		if err := tx.Omit("UserPackage", "User").Save(freeze).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.UserPackage{}).
+			Where("id = ? AND expired_at IS NOT NULL", freeze.UserPackageID).
+			Update("expired_at", gorm.Expr("DATE_ADD(expired_at, INTERVAL ? DAY)", freeze.Days)).Error
+	})
+}
+
+func (r *freezeRepository) GetFreezeByID(id string) (*models.UserPackageFreeze, error) {
+	var freeze models.UserPackageFreeze
+	err := r.db.Preload("UserPackage").Preload("User").First(&freeze, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &freeze, err
+}
+
+func (r *freezeRepository) GetFreezeByPaymentID(paymentID uuid.UUID) (*models.UserPackageFreeze, error) {
+	var freeze models.UserPackageFreeze
+	err := r.db.Preload("UserPackage").First(&freeze, "payment_id = ?", paymentID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &freeze, err
+}
+
+func (r *freezeRepository) GetFreezesByUserID(userID string) ([]models.UserPackageFreeze, error) {
+	var freezes []models.UserPackageFreeze
+	err := r.db.
+		Preload("UserPackage").
+		Where("user_id = ?", userID).
+		Order("start_date desc").
+		Find(&freezes).Error
+	return freezes, err
+}
+
+func (r *freezeRepository) GetFreezes(status string) ([]models.UserPackageFreeze, error) {
+	var freezes []models.UserPackageFreeze
+	db := r.db.Preload("UserPackage").Preload("User")
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	err := db.Order("created_at desc").Find(&freezes).Error
+	return freezes, err
+}
+
+// freezes that still count towards the package allowance
+func (r *freezeRepository) GetOpenFreezesByUserPackage(userPackageID uuid.UUID) ([]models.UserPackageFreeze, error) {
+	var freezes []models.UserPackageFreeze
+	err := r.db.
+		Where("user_package_id = ? AND status IN ?", userPackageID, []string{"pending", "awaiting_payment", "approved"}).
+		Find(&freezes).Error
+	return freezes, err
+}
+
+// freezes still waiting on a fee payment that has failed, like the checkouts expired in bulk by the cron job
+func (r *freezeRepository) GetUnpaidFreezes() ([]models.UserPackageFreeze, error) {
+	var freezes []models.UserPackageFreeze
+	err := r.db.
+		Preload("UserPackage").
+		Joins("JOIN payments p ON p.id = user_package_freezes.payment_id").
+		Where("user_package_freezes.status = ? AND p.status = ?", "awaiting_payment", "failed").
+		Find(&freezes).Error
+	return freezes, err
+}
+
+func (r *freezeRepository) IsFrozenOn(userPackageID uuid.UUID, date time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.UserPackageFreeze{}).
+		Where("user_package_id = ? AND status = ?", userPackageID, "approved").
+		Where("DATE(start_date) <= DATE(?) AND DATE(end_date) >= DATE(?)", date, date).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"errors"
+	"server/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type GiftRepository interface {
+	CreateGift(gift *models.PackageGift) error
+	GetGiftByCode(code string) (*models.PackageGift, error)
+	GetGiftByPaymentID(paymentID uuid.UUID) (*models.PackageGift, error)
+	GetGiftsByPurchaserID(userID string) ([]models.PackageGift, error)
+	GetGiftsByRecipientEmail(email string) ([]models.PackageGift, error)
+	ClaimGift(gift *models.PackageGift, userID uuid.UUID, redeemedAt time.Time) (bool, error)
+	ReleaseGift(gift *models.PackageGift) error
+	SetGiftUserPackage(gift *models.PackageGift, userPackageID uuid.UUID) error
+}
+
+type giftRepository struct {
+	db *gorm.DB
+}
+
+func NewGiftRepository(db *gorm.DB) GiftRepository {
+	return &giftRepository{db}
+}
+
+func (r *giftRepository) CreateGift(gift *models.PackageGift) error {
+	return r.db.Create(gift).Error
+}
+
+func (r *giftRepository) GetGiftByCode(code string) (*models.PackageGift, error) {
+	var gift models.PackageGift
+	err := r.db.Preload("Purchaser").First(&gift, "code = ?", code).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &gift, err
+}
+
+func (r *giftRepository) GetGiftByPaymentID(paymentID uuid.UUID) (*models.PackageGift, error) {
+	var gift models.PackageGift
+	err := r.db.First(&gift, "payment_id = ?", paymentID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &gift, err
+}
+
+func (r *giftRepository) GetGiftsByPurchaserID(userID string) ([]models.PackageGift, error) {
+	var gifts []models.PackageGift
+	err := r.db.
+		Preload("Purchaser").
+		Where("purchaser_id = ?", userID).
+		Order("created_at desc").
+		Find(&gifts).Error
+	return gifts, err
+}
+
+func (r *giftRepository) GetGiftsByRecipientEmail(email string) ([]models.PackageGift, error) {
+	var gifts []models.PackageGift
+	err := r.db.
+		Preload("Purchaser").
+		Where("recipient_email = ?", email).
+		Order("created_at desc").
+		Find(&gifts).Error
+	return gifts, err
+}
+
+// ClaimGift marks an issued gift as redeemed, false means someone else redeemed it first
+func (r *giftRepository) ClaimGift(gift *models.PackageGift, userID uuid.UUID, redeemedAt time.Time) (bool, error) {
+	result := r.db.Model(&models.PackageGift{}).
+		Where("id = ? AND status = ?", gift.ID, "issued").
+		Updates(map[string]interface{}{
+			"status":      "redeemed",
+			"redeemed_by": userID,
+			"redeemed_at": redeemedAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	gift.Status = "redeemed"
+	gift.RedeemedBy = &userID
+	gift.RedeemedAt = &redeemedAt
+	return true, nil
+}
+
+// ReleaseGift puts a claimed gift back when the package could not be granted
+func (r *giftRepository) ReleaseGift(gift *models.PackageGift) error {
+	return r.db.Model(&models.PackageGift{}).
+		Where("id = ?", gift.ID).
+		Updates(map[string]interface{}{
+			"status":      "issued",
+			"redeemed_by": nil,
+			"redeemed_at": nil,
+		}).Error
+}
+
+func (r *giftRepository) SetGiftUserPackage(gift *models.PackageGift, userPackageID uuid.UUID) error {
+	gift.UserPackageID = &userPackageID
+	return r.db.Model(&models.PackageGift{}).
+		Where("id = ?", gift.ID).
+		Update("user_package_id", userPackageID).Error
+}
@@ -16,6 +16,7 @@ type InstructorRepository interface {
 	GetInstructorByID(id string) (*models.Instructor, error)
 	UpdateRating(instructorID uuid.UUID, rating float64) error
 	GetInstructorByUserID(userID string) (*models.Instructor, error)
+	GetInstructorByName(fullname string) (*models.Instructor, error)
 }
 
 type instructorRepository struct {
@@ -67,3 +68,15 @@ func (r *instructorRepository) GetInstructorByUserID(userID string) (*models.Ins
 	}
 	return &instructor, err
 }
+
+func (r *instructorRepository) GetInstructorByName(fullname string) (*models.Instructor, error) {
+	var instructor models.Instructor
+	err := r.db.Preload("User").
+		Joins("JOIN users ON users.id = instructors.user_id").
+		Where("users.fullname = ?", fullname).
+		First(&instructor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &instructor, err
+}
@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"errors"
+	"server/internal/dto"
+	"server/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrMemberCapReached = errors.New("member credit cap reached")
+
+type PackageMemberRepository interface {
+	CreateMember(member *models.UserPackageMember) error
+	UpdateMember(member *models.UserPackageMember) error
+	GetMemberByID(id string) (*models.UserPackageMember, error)
+	GetMember(userPackageID, userID uuid.UUID) (*models.UserPackageMember, error)
+	GetMembersByUserPackageID(userPackageID uuid.UUID) ([]models.UserPackageMember, error)
+	GetInvitationsByUserID(userID string) ([]models.UserPackageMember, error)
+	CountOpenMembers(userPackageID uuid.UUID) (int64, error)
+	GetActiveMembership(userID, packageID string) (*models.UserPackageMember, error)
+	GetCreditsUsedByUser(userPackageID uuid.UUID) ([]dto.MemberCreditUsage, error)
+	GetUsage(userPackageID uuid.UUID) ([]models.Booking, error)
+}
+
+type packageMemberRepository struct {
+	db *gorm.DB
+}
+
+func NewPackageMemberRepository(db *gorm.DB) PackageMemberRepository {
+	return &packageMemberRepository{db}
+}
+
+func (r *packageMemberRepository) CreateMember(member *models.UserPackageMember) error {
+	return r.db.Create(member).Error
+}
+
+func (r *packageMemberRepository) UpdateMember(member *models.UserPackageMember) error {
+	return r.db.Omit("UserPackage", "User", "Inviter").Save(member).Error
+}
+
+func (r *packageMemberRepository) GetMemberByID(id string) (*models.UserPackageMember, error) {
+	var member models.UserPackageMember
+	err := r.db.Preload("UserPackage").Preload("User").First(&member, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &member, err
+}
+
+func (r *packageMemberRepository) GetMember(userPackageID, userID uuid.UUID) (*models.UserPackageMember, error) {
+	var member models.UserPackageMember
+	err := r.db.Where("user_package_id = ? AND user_id = ?", userPackageID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &member, err
+}
+
+func (r *packageMemberRepository) GetMembersByUserPackageID(userPackageID uuid.UUID) ([]models.UserPackageMember, error) {
+	var members []models.UserPackageMember
+	err := r.db.
+		Preload("User").
+		Where("user_package_id = ? AND status IN ?", userPackageID, []string{"invited", "active"}).
+		Order("created_at asc").
+		Find(&members).Error
+	return members, err
+}
+
+func (r *packageMemberRepository) GetInvitationsByUserID(userID string) ([]models.UserPackageMember, error) {
+	var members []models.UserPackageMember
+	err := r.db.
+		Preload("UserPackage").
+		Preload("Inviter").
+		Where("user_id = ? AND status = ?", userID, "invited").
+		Order("created_at desc").
+		Find(&members).Error
+	return members, err
+}
+
+// invited and active members both take a seat on the package
+func (r *packageMemberRepository) CountOpenMembers(userPackageID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.UserPackageMember{}).
+		Where("user_package_id = ? AND status IN ?", userPackageID, []string{"invited", "active"}).
+		Count(&count).Error
+	return count, err
+}
+
+// GetActiveMembership finds the newest unexpired package of packageID shared with the user
+func (r *packageMemberRepository) GetActiveMembership(userID, packageID string) (*models.UserPackageMember, error) {
+	var member models.UserPackageMember
+	err := r.db.
+		Joins("JOIN user_packages up ON up.id = user_package_members.user_package_id AND up.deleted_at IS NULL").
+		Where("user_package_members.user_id = ? AND user_package_members.status = ?", userID, "active").
+		Where("up.package_id = ? AND up.expired_at > ?", packageID, time.Now()).
+		Order("up.purchased_at desc").
+		Preload("UserPackage").
+		Preload("User").
+		First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &member, err
+}
+
+// credits still spent by each user of the package, read from the ledger so a booking counts until its credit
+// is refunded, whatever the booking's status
+func (r *packageMemberRepository) GetCreditsUsedByUser(userPackageID uuid.UUID) ([]dto.MemberCreditUsage, error) {
+	var usage []dto.MemberCreditUsage
+	err := creditsUsed(r.db, userPackageID).
+		Select("bookings.user_id AS user_id, COALESCE(-SUM(credit_ledger_entries.amount), 0) AS used").
+		Group("bookings.user_id").
+		Scan(&usage).Error
+	return usage, err
+}
+
+// CountCreditsUsedByUser is what one user spent of a shared package using the caller's transaction
+func CountCreditsUsedByUser(tx *gorm.DB, userPackageID, userID uuid.UUID) (int64, error) {
+	var used int64
+	err := creditsUsed(tx, userPackageID).
+		Where("bookings.user_id = ?", userID).
+		Select("COALESCE(-SUM(credit_ledger_entries.amount), 0)").
+		Scan(&used).Error
+	return used, err
+}
+
+// creditsUsed selects the booking entries of the package and the refunds of those bookings
+func creditsUsed(db *gorm.DB, userPackageID uuid.UUID) *gorm.DB {
+	return db.Model(&models.CreditLedgerEntry{}).
+		Joins("JOIN bookings ON bookings.id = credit_ledger_entries.booking_id").
+		Where("credit_ledger_entries.user_package_id = ? AND credit_ledger_entries.type IN ?", userPackageID, []string{"booking", "refund"})
+}
+
+func (r *packageMemberRepository) GetUsage(userPackageID uuid.UUID) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.db.
+		Preload("User").
+		Preload("ClassSchedule").
+		Joins("JOIN class_schedules cs ON cs.id = bookings.class_schedule_id").
+		Where("bookings.user_package_id = ?", userPackageID).
+		Order("cs.date desc").
+		Find(&bookings).Error
+	return bookings, err
+}
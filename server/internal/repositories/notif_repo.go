@@ -17,6 +17,7 @@ type NotificationRepository interface {
 	UpdateNotificationSetting(setting *models.NotificationSetting) error
 	CreateNotificationSetting(setting *models.NotificationSetting) error
 	GetTypeByCode(code string) (*models.NotificationType, error)
+	GetUserIDsByRole(role string) ([]uuid.UUID, error)
 	GetAllBrowserNotifications(userID uuid.UUID) ([]models.Notification, error)
 	GetBookingsForClassReminder(reminderTime time.Time) ([]models.Booking, error)
 	GetNotificationSettingsByUser(userID uuid.UUID) ([]models.NotificationSetting, error)
@@ -122,3 +123,9 @@ func (r *notificationRepository) GetBookingsForClassReminder(reminderTime time.T
 		Find(&bookings).Error
 	return bookings, err
 }
+
+func (r *notificationRepository) GetUserIDsByRole(role string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&models.User{}).Where("role = ?", role).Pluck("id", &ids).Error
+	return ids, err
+}
@@ -84,9 +84,9 @@ func (r *packageRepository) GetAllPackages(params dto.PackageQueryParam) ([]mode
 	case "name_desc":
 		db = db.Order("packages.name desc")
 	case "price_asc":
-		db = db.Order("packages.price asc")
+		db = db.Order("packages.price_minor asc")
 	case "price_desc":
-		db = db.Order("packages.price desc")
+		db = db.Order("packages.price_minor desc")
 	default:
 		db = db.Order("packages.created_at desc")
 	}
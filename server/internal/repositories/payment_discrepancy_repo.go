@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"errors"
+	"server/internal/dto"
+	"server/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PaymentDiscrepancyRepository interface {
+	RecordDiscrepancy(discrepancy *models.PaymentDiscrepancy) error
+	UpdateDiscrepancy(discrepancy *models.PaymentDiscrepancy) error
+	GetDiscrepancyByID(id string) (*models.PaymentDiscrepancy, error)
+	GetDiscrepancies(params dto.PaymentDiscrepancyQueryParam) ([]models.PaymentDiscrepancy, int64, error)
+}
+
+type paymentDiscrepancyRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentDiscrepancyRepository(db *gorm.DB) PaymentDiscrepancyRepository {
+	return &paymentDiscrepancyRepository{db}
+}
+
+// RecordDiscrepancy stores a finding, an open finding of the same kind on the payment is refreshed instead so every
+// run of the job does not add a row
+func (r *paymentDiscrepancyRepository) RecordDiscrepancy(discrepancy *models.PaymentDiscrepancy) error {
+	discrepancy.LastSeenAt = time.Now().UTC()
+
+	var open models.PaymentDiscrepancy
+	err := r.db.
+		Where("payment_id = ? AND kind = ? AND status = ?", discrepancy.PaymentID, discrepancy.Kind, "open").
+		First(&open).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return r.db.Create(discrepancy).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	discrepancy.ID = open.ID
+	discrepancy.CreatedAt = open.CreatedAt
+	discrepancy.Status = open.Status
+	return r.db.Save(discrepancy).Error
+}
+
+func (r *paymentDiscrepancyRepository) UpdateDiscrepancy(discrepancy *models.PaymentDiscrepancy) error {
+	return r.db.Save(discrepancy).Error
+}
+
+func (r *paymentDiscrepancyRepository) GetDiscrepancyByID(id string) (*models.PaymentDiscrepancy, error) {
+	var discrepancy models.PaymentDiscrepancy
+	err := r.db.First(&discrepancy, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &discrepancy, err
+}
+
+func (r *paymentDiscrepancyRepository) GetDiscrepancies(params dto.PaymentDiscrepancyQueryParam) ([]models.PaymentDiscrepancy, int64, error) {
+	var discrepancies []models.PaymentDiscrepancy
+	var count int64
+
+	db := r.db.Model(&models.PaymentDiscrepancy{})
+	if params.Status != "" {
+		db = db.Where("status = ?", params.Status)
+	}
+	if params.Kind != "" {
+		db = db.Where("kind = ?", params.Kind)
+	}
+	if params.Gateway != "" {
+		db = db.Where("gateway = ?", params.Gateway)
+	}
+	if params.PaymentID != "" {
+		db = db.Where("payment_id = ?", params.PaymentID)
+	}
+
+	if err := db.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (params.Page - 1) * params.Limit
+	if err := db.Order("last_seen_at desc").Limit(params.Limit).Offset(offset).Find(&discrepancies).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return discrepancies, count, nil
+}
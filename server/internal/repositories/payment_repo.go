@@ -4,19 +4,36 @@ import (
 	"errors"
 	"server/internal/dto"
 	"server/internal/models"
+	"server/pkg/utils"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrPaymentSettled = errors.New("payment is already settled")
+
 type PaymentRepository interface {
 	ExpireOldPendingPayments() (int64, error)
 	CreatePayment(payment *models.Payment) error
 	UpdatePayment(payment *models.Payment) error
+	MarkPaymentFailed(id uuid.UUID) (bool, error)
+	RecordGatewayRefund(payment *models.Payment, credit *models.WalletTransaction) error
+	SettlePayment(payment *models.Payment, userPackage *models.UserPackage, entry *models.CreditLedgerEntry) error
+	LinkPackage(payment *models.Payment, userPackage *models.UserPackage, entry *models.CreditLedgerEntry) error
 	GetPaymentByID(id string) (*models.Payment, error)
+	GetPaymentTaxes(paymentID string) ([]models.PaymentTax, error)
 	GetPaymentByOrderID(orderID string) (*models.Payment, error)
+	GetPaymentByProviderInvoiceID(invoiceID string) (*models.Payment, error)
+	GetPaymentByProviderPaymentID(paymentRef string) (*models.Payment, error)
+	GetLatestPaidPackagePayment(userID, packageID string) (*models.Payment, error)
+	CountPackagePurchases(userID string) (map[string]int64, error)
 	GetAllUserPayments(params dto.PaymentQueryParam) ([]models.Payment, int64, error)
 	GetPaymentsByUserID(userID string, params dto.PaymentQueryParam) ([]models.Payment, int64, error)
+	GetOfflinePayments(from, to time.Time, staffID, locationID string) ([]models.Payment, error)
+	GetStalePendingPayments(before time.Time, limit int) ([]models.Payment, error)
+	MarkPaymentsReconciled(ids []uuid.UUID, at time.Time) error
 }
 
 type paymentRepository struct {
@@ -27,8 +44,136 @@ func NewPaymentRepository(db *gorm.DB) PaymentRepository {
 	return &paymentRepository{db}
 }
 
+// CreatePayment stores a new payment, one that is paid already, like a renewal, gets its invoice number with it and
+// the share paid from the wallet is taken from the balance in the same transaction
 func (r *paymentRepository) CreatePayment(payment *models.Payment) error {
-	return r.db.Create(payment).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := AssignInvoiceNumber(tx, payment); err != nil {
+			return err
+		}
+		if err := tx.Create(payment).Error; err != nil {
+			return err
+		}
+		if payment.WalletAmount <= 0 {
+			return nil
+		}
+		return ApplyWalletTransaction(tx, &models.WalletTransaction{
+			UserID:    payment.UserID,
+			Type:      "payment",
+			Amount:    -payment.WalletAmount,
+			Currency:  payment.Currency,
+			PaymentID: &payment.ID,
+			Reason:    "Paid towards " + payment.PackageName,
+		})
+	})
+}
+
+// SettlePayment stores a paid payment together with the package it pays for and the voucher use it held in one
+// transaction, the payment row is locked and ErrPaymentSettled is returned when another delivery of the same
+// payment got there first
+func (r *paymentRepository) SettlePayment(payment *models.Payment, userPackage *models.UserPackage, entry *models.CreditLedgerEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", payment.ID).
+			First(&current).Error; err != nil {
+			return err
+		}
+		// expired checkouts are marked failed, a late payment still settles them
+		if current.Status != "pending" && current.Status != "failed" {
+			return ErrPaymentSettled
+		}
+		if current.Status == "failed" {
+			if err := reclaimPaymentHold(tx, &current); err != nil {
+				return err
+			}
+		}
+		if payment.VoucherCode != nil {
+			if err := commitReservation(tx, payment.ID); err != nil {
+				return err
+			}
+		}
+		if err := AssignInvoiceNumber(tx, payment); err != nil {
+			return err
+		}
+		return savePaymentWithPackage(tx, payment, userPackage, entry)
+	})
+}
+
+// LinkPackage grants the package of an already paid payment, used when a gift is redeemed
+func (r *paymentRepository) LinkPackage(payment *models.Payment, userPackage *models.UserPackage, entry *models.CreditLedgerEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return savePaymentWithPackage(tx, payment, userPackage, entry)
+	})
+}
+
+// savePaymentWithPackage creates or updates the package, applies its ledger entry and links the payment to it,
+// credits only move through the ledger so the stored balance is left alone
+func savePaymentWithPackage(tx *gorm.DB, payment *models.Payment, userPackage *models.UserPackage, entry *models.CreditLedgerEntry) error {
+	if userPackage != nil {
+		if userPackage.ID == uuid.Nil {
+			userPackage.RemainingCredit = 0
+			if err := tx.Create(userPackage).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Omit("remaining_credit").Save(userPackage).Error; err != nil {
+			return err
+		}
+
+		if entry != nil {
+			entry.UserPackageID = userPackage.ID
+			if err := ApplyCreditEntry(tx, entry); err != nil {
+				return err
+			}
+			userPackage.RemainingCredit = entry.BalanceAfter
+		}
+		payment.UserPackageID = &userPackage.ID
+	}
+	return tx.Save(payment).Error
+}
+
+// defaultInvoiceLocation is the {LOC} of payments made without a location or at a location without a code
+const defaultInvoiceLocation = "HQ"
+
+// AssignInvoiceNumber gives a paid payment the next number of its series using the caller's transaction, the
+// series row stays locked until the transaction ends and a rollback hands the number back, so numbers are only
+// used by settled payments and never skipped. A payment that has a number keeps it
+func AssignInvoiceNumber(tx *gorm.DB, payment *models.Payment) error {
+	if payment.Status != "success" || payment.InvoiceNumber != nil {
+		return nil
+	}
+
+	code := defaultInvoiceLocation
+	if payment.LocationID != nil {
+		var location models.Location
+		if err := tx.Unscoped().Select("code").First(&location, "id = ?", *payment.LocationID).Error; err == nil && location.Code != "" {
+			code = location.Code
+		}
+	}
+	paidAt := payment.PaidAt
+	if paidAt.IsZero() {
+		paidAt = time.Now()
+	}
+	series := utils.InvoiceSeries(utils.InvoiceNumberFormat(), paidAt, code)
+
+	// the first payment of a series creates its row, a concurrent one waits on the unique index and finds it
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.InvoiceSequence{Series: series}).Error; err != nil {
+		return err
+	}
+	var sequence models.InvoiceSequence
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("series = ?", series).
+		First(&sequence).Error; err != nil {
+		return err
+	}
+	sequence.LastNumber++
+	if err := tx.Model(&sequence).Update("last_number", sequence.LastNumber).Error; err != nil {
+		return err
+	}
+
+	number := utils.FormatInvoiceNumber(series, sequence.LastNumber)
+	payment.InvoiceNumber = &number
+	return nil
 }
 
 func (r *paymentRepository) GetPaymentByID(id string) (*models.Payment, error) {
@@ -40,6 +185,12 @@ func (r *paymentRepository) GetPaymentByID(id string) (*models.Payment, error) {
 	return &payment, err
 }
 
+func (r *paymentRepository) GetPaymentTaxes(paymentID string) ([]models.PaymentTax, error) {
+	var taxes []models.PaymentTax
+	err := r.db.Where("payment_id = ?", paymentID).Order("name asc").Find(&taxes).Error
+	return taxes, err
+}
+
 func (r *paymentRepository) GetPaymentByOrderID(orderID string) (*models.Payment, error) {
 	var payment models.Payment
 	err := r.db.First(&payment, "id = ?", orderID).Error
@@ -49,10 +200,53 @@ func (r *paymentRepository) GetPaymentByOrderID(orderID string) (*models.Payment
 	return &payment, err
 }
 
+func (r *paymentRepository) GetPaymentByProviderPaymentID(paymentRef string) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.First(&payment, "provider_payment_id = ?", paymentRef).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &payment, err
+}
+
+func (r *paymentRepository) GetPaymentByProviderInvoiceID(invoiceID string) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.First(&payment, "provider_invoice_id = ?", invoiceID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &payment, err
+}
+
 func (r *paymentRepository) UpdatePayment(payment *models.Payment) error {
 	return r.db.Save(payment).Error
 }
 
+// MarkPaymentFailed fails the payment only while it is pending, it reports whether this call changed it
+func (r *paymentRepository) MarkPaymentFailed(id uuid.UUID) (bool, error) {
+	result := r.db.Model(&models.Payment{}).
+		Where("id = ? AND status = ?", id, "pending").
+		Update("status", "failed")
+	return result.RowsAffected == 1, result.Error
+}
+
+// RecordGatewayRefund stores a refund made at the gateway together with the wallet credit that goes back with it
+func (r *paymentRepository) RecordGatewayRefund(payment *models.Payment, credit *models.WalletTransaction) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if credit != nil {
+			if err := ApplyWalletTransaction(tx, credit); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Payment{}).
+			Where("id = ?", payment.ID).
+			Updates(map[string]interface{}{
+				"refunded_amount_minor": payment.RefundedAmount,
+				"status":                payment.Status,
+			}).Error
+	})
+}
+
 func applyPaymentFilters(db *gorm.DB, params dto.PaymentQueryParam) *gorm.DB {
 	if params.Search != "" {
 		like := "%" + params.Search + "%"
@@ -135,3 +329,94 @@ func (r *paymentRepository) ExpireOldPendingPayments() (int64, error) {
 
 	return result.RowsAffected, result.Error
 }
+
+// the most recent successful purchase of the package by the user, used to value what is left of it
+func (r *paymentRepository) GetLatestPaidPackagePayment(userID, packageID string) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.
+		Where("user_id = ? AND package_id = ? AND status = ? AND purpose = ?", userID, packageID, "success", "package").
+		Order("paid_at desc").
+		First(&payment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &payment, err
+}
+
+// purchases per package made by the user, open checkouts count so an offer cannot be checked out twice and refunded
+// or disputed ones count so a refund does not free the offer again, gifts the user redeemed count as their purchases
+func (r *paymentRepository) CountPackagePurchases(userID string) (map[string]int64, error) {
+	var rows []struct {
+		PackageID string
+		Total     int64
+	}
+	err := r.db.Model(&models.Payment{}).
+		Select("package_id, COUNT(*) as total").
+		Where("user_id = ? AND purpose = ? AND status <> ?", userID, "package", "failed").
+		Group("package_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var gifts []struct {
+		PackageID string
+		Total     int64
+	}
+	err = r.db.Model(&models.PackageGift{}).
+		Select("package_id, COUNT(*) as total").
+		Where("redeemed_by = ? AND status = ?", userID, "redeemed").
+		Group("package_id").
+		Scan(&gifts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows)+len(gifts))
+	for _, row := range rows {
+		counts[row.PackageID] += row.Total
+	}
+	for _, row := range gifts {
+		counts[row.PackageID] += row.Total
+	}
+	return counts, nil
+}
+
+// GetOfflinePayments lists the paid front-desk payments taken between from and to, oldest first
+func (r *paymentRepository) GetOfflinePayments(from, to time.Time, staffID, locationID string) ([]models.Payment, error) {
+	var payments []models.Payment
+	db := r.db.
+		Where("gateway = ? AND received_by IS NOT NULL", "offline").
+		Where("status IN ?", []string{"success", "partially_refunded", "refunded"}).
+		Where("paid_at >= ? AND paid_at < ?", from, to)
+	if staffID != "" {
+		db = db.Where("received_by = ?", staffID)
+	}
+	if locationID != "" {
+		db = db.Where("location_id = ?", locationID)
+	}
+	err := db.Order("paid_at asc").Find(&payments).Error
+	return payments, err
+}
+
+// GetStalePendingPayments lists checkouts still pending that were opened before the given time, the ones never
+// reconciled come first and then the ones reconciled longest ago, so a backlog larger than limit is worked through
+func (r *paymentRepository) GetStalePendingPayments(before time.Time, limit int) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.
+		Where("status = ? AND paid_at <= ? AND provider_session_id IS NOT NULL", "pending", before).
+		Order("last_reconciled_at IS NOT NULL, last_reconciled_at asc, paid_at asc").
+		Limit(limit).
+		Find(&payments).Error
+	return payments, err
+}
+
+// MarkPaymentsReconciled moves the payments to the back of the reconciliation queue
+func (r *paymentRepository) MarkPaymentsReconciled(ids []uuid.UUID, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.Payment{}).
+		Where("id IN ?", ids).
+		UpdateColumn("last_reconciled_at", at).Error
+}
@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"testing"
+
+	"server/internal/models"
+)
+
+// a payment is numbered once, when it is paid, neither case reaches the sequence table
+func TestInvoiceNumberIsOnlyAssignedToAPaidPaymentWithoutOne(t *testing.T) {
+	issued := "INV/2026/10/00001"
+	tests := []struct {
+		name    string
+		payment models.Payment
+		want    *string
+	}{
+		{name: "pending payment", payment: models.Payment{Status: "pending"}},
+		{name: "failed payment", payment: models.Payment{Status: "failed"}},
+		{name: "already numbered", payment: models.Payment{Status: "success", InvoiceNumber: &issued}, want: &issued},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := AssignInvoiceNumber(nil, &tt.payment); err != nil {
+				t.Fatalf("assign: %v", err)
+			}
+			if tt.payment.InvoiceNumber != tt.want {
+				t.Fatalf("invoice number = %v, want %v", tt.payment.InvoiceNumber, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"errors"
+	"server/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrRefundExceedsPayment = errors.New("refund exceeds the refundable amount")
+
+type RefundRepository interface {
+	CreateRefund(refund *models.PaymentRefund) error
+	UpdateRefund(refund *models.PaymentRefund) error
+	CompleteRefund(refund *models.PaymentRefund, payment *models.Payment, revoke *models.CreditLedgerEntry, endUserPackageID *uuid.UUID, credit *models.WalletTransaction) error
+	GetRefundsByPaymentID(paymentID string) ([]models.PaymentRefund, error)
+	GetWalletRefunded(paymentID string) (int64, error)
+}
+
+type refundRepository struct {
+	db *gorm.DB
+}
+
+func NewRefundRepository(db *gorm.DB) RefundRepository {
+	return &refundRepository{db}
+}
+
+// CreateRefund records a pending refund, the payment row is locked so refunds still in flight
+// count against what is left to refund
+func (r *refundRepository) CreateRefund(refund *models.PaymentRefund) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var payment models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", refund.PaymentID).
+			First(&payment).Error; err != nil {
+			return err
+		}
+
+		var inFlight int64
+		if err := tx.Model(&models.PaymentRefund{}).
+			Select("COALESCE(SUM(amount_minor), 0)").
+			Where("payment_id = ? AND status = ? AND provider_refund_id IS NULL", refund.PaymentID, "pending").
+			Scan(&inFlight).Error; err != nil {
+			return err
+		}
+
+		if refund.Amount > payment.Total-payment.RefundedAmount-inFlight {
+			return ErrRefundExceedsPayment
+		}
+		return tx.Create(refund).Error
+	})
+}
+
+func (r *refundRepository) UpdateRefund(refund *models.PaymentRefund) error {
+	return r.db.Omit("Payment", "Admin").Save(refund).Error
+}
+
+// CompleteRefund stores the provider's answer together with the payment totals, the revoked credits, the wallet
+// credit and, for a fully refunded membership, the end of the membership
+func (r *refundRepository) CompleteRefund(refund *models.PaymentRefund, payment *models.Payment, revoke *models.CreditLedgerEntry, endUserPackageID *uuid.UUID, credit *models.WalletTransaction) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if revoke != nil {
+			if err := ApplyCreditEntry(tx, revoke); err != nil {
+				return err
+			}
+		}
+		if credit != nil {
+			if err := ApplyWalletTransaction(tx, credit); err != nil {
+				return err
+			}
+		}
+
+		if endUserPackageID != nil {
+			now := time.Now()
+			if err := tx.Model(&models.UserPackage{}).
+				Where("id = ?", *endUserPackageID).
+				Updates(map[string]interface{}{"expired_at": now, "closed_at": now}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Omit("Payment", "Admin").Save(refund).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Payment{}).
+			Where("id = ?", payment.ID).
+			Updates(map[string]interface{}{
+				"refunded_amount_minor": payment.RefundedAmount,
+				"status":                payment.Status,
+			}).Error
+	})
+}
+
+func (r *refundRepository) GetRefundsByPaymentID(paymentID string) ([]models.PaymentRefund, error) {
+	var refunds []models.PaymentRefund
+	err := r.db.
+		Preload("Admin").
+		Where("payment_id = ?", paymentID).
+		Order("created_at desc").
+		Find(&refunds).Error
+	return refunds, err
+}
+
+// GetWalletRefunded is how much of the payment's completed refunds went to the wallet rather than the gateway
+func (r *refundRepository) GetWalletRefunded(paymentID string) (int64, error) {
+	var total int64
+	err := r.db.Model(&models.PaymentRefund{}).
+		Select("COALESCE(SUM(wallet_amount_minor), 0)").
+		Where("payment_id = ? AND status <> ? AND provider_refund_id IS NOT NULL", paymentID, "failed").
+		Scan(&total).Error
+	return total, err
+}
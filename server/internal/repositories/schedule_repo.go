@@ -1,8 +1,10 @@
 package repositories
 
 import (
+	"errors"
 	"server/internal/dto"
 	"server/internal/models"
+	"server/pkg/utils"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,18 +17,27 @@ type ClassScheduleRepository interface {
 	GetClassSchedules() ([]models.ClassSchedule, error)
 	HasActiveBooking(scheduleID uuid.UUID) (bool, error)
 	CreateClassSchedule(schedule *models.ClassSchedule) error
+	CreateClassSchedules(schedules []models.ClassSchedule) error
 	UpdateClassSchedule(schedule *models.ClassSchedule) error
 	GetClassScheduleByID(id string) (*models.ClassSchedule, error)
+	GetScheduleLocationID(schedule *models.ClassSchedule) (uuid.UUID, error)
 	GetClassSchedulesWithFilter(filter dto.ClassScheduleQueryParam) ([]models.ClassSchedule, error)
 
 	// instructor
 
-	CloseScheduleWithCode(scheduleID uuid.UUID, code string) error
+	UpdateMeetingLink(scheduleID uuid.UUID, provider, meetingID, link string) error
 	OpenSchedule(scheduleID uuid.UUID, schedule *models.ClassSchedule) error
+	UpdateScheduleStatus(scheduleID uuid.UUID, from []string, to string) error
+	CompleteSchedule(schedule *models.ClassSchedule, completedAt time.Time) error
+	CancelSchedule(schedule *models.ClassSchedule, reason string, canceledAt time.Time) ([]models.Booking, map[uuid.UUID]string, error)
+	GetSchedulesPendingTransition(until time.Time) ([]models.ClassSchedule, error)
 	GetAttendancesByScheduleID(scheduleID string) ([]models.Booking, error)
+	GetSchedulesByInstructorInRange(instructorID uuid.UUID, start, end time.Time) ([]models.ClassSchedule, error)
 	GetSchedulesByInstructorID(instructorID uuid.UUID, params dto.InstructorScheduleQueryParam) ([]models.ClassSchedule, int64, error)
 }
 
+var ErrScheduleStatusChanged = errors.New("schedule status has already changed")
+
 type classScheduleRepository struct {
 	db *gorm.DB
 }
@@ -39,6 +50,13 @@ func (r *classScheduleRepository) CreateClassSchedule(schedule *models.ClassSche
 	return r.db.Create(schedule).Error
 }
 
+// all schedules are inserted in one transaction, either every row is saved or none
+func (r *classScheduleRepository) CreateClassSchedules(schedules []models.ClassSchedule) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&schedules, 100).Error
+	})
+}
+
 func (r *classScheduleRepository) UpdateClassSchedule(schedule *models.ClassSchedule) error {
 	return r.db.Save(schedule).Error
 }
@@ -53,6 +71,13 @@ func (r *classScheduleRepository) GetClassScheduleByID(id string) (*models.Class
 	return &schedule, err
 }
 
+// schedules only keep the location name, the location itself belongs to the class
+func (r *classScheduleRepository) GetScheduleLocationID(schedule *models.ClassSchedule) (uuid.UUID, error) {
+	var class models.Class
+	err := r.db.Select("location_id").First(&class, "id = ?", schedule.ClassID).Error
+	return class.LocationID, err
+}
+
 func (r *classScheduleRepository) GetClassSchedules() ([]models.ClassSchedule, error) {
 	var schedules []models.ClassSchedule
 	err := r.db.
@@ -152,15 +177,148 @@ func (r *classScheduleRepository) OpenSchedule(scheduleID uuid.UUID, schedule *m
 		Where("id = ?", scheduleID).
 		Updates(map[string]any{
 			"zoom_link":         schedule.ZoomLink,
+			"meeting_provider":  schedule.MeetingProvider,
+			"meeting_id":        schedule.MeetingID,
 			"verification_code": schedule.VerificationCode,
 			"is_opened":         true,
+			"status":            "open",
 		}).Error
 }
 
-func (r *classScheduleRepository) CloseScheduleWithCode(scheduleID uuid.UUID, code string) error {
+// UpdateScheduleStatus only moves schedules that are still in one of the expected states
+func (r *classScheduleRepository) UpdateScheduleStatus(scheduleID uuid.UUID, from []string, to string) error {
+	res := r.db.Model(&models.ClassSchedule{}).
+		Where("id = ? AND status IN ?", scheduleID, from).
+		Update("status", to)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrScheduleStatusChanged
+	}
+	return nil
+}
+
+// CompleteSchedule finalizes attendance in the same transaction, checked-in members are marked
+// attended and everyone else absent, and the class counts towards the instructor total
+func (r *classScheduleRepository) CompleteSchedule(schedule *models.ClassSchedule, completedAt time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.ClassSchedule{}).
+			Where("id = ? AND status IN ?", schedule.ID, []string{"scheduled", "open", "in_progress"}).
+			Updates(map[string]any{
+				"status":       "completed",
+				"is_opened":    false,
+				"completed_at": completedAt,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrScheduleStatusChanged
+		}
+
+		bookings := func() *gorm.DB {
+			return tx.Model(&models.Booking{}).Select("id").
+				Where("class_schedule_id = ? AND status = ?", schedule.ID, "booked")
+		}
+
+		if err := tx.Model(&models.Attendance{}).
+			Where("booking_id IN (?) AND checked_in = ?", bookings(), true).
+			Update("status", "attended").Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Attendance{}).
+			Where("booking_id IN (?) AND checked_in = ?", bookings(), false).
+			Update("status", "absent").Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Instructor{}).
+			Where("id = ?", schedule.InstructorID).
+			Update("total_class", gorm.Expr("total_class + 1")).Error
+	})
+}
+
+// CancelSchedule cancels every active booking and gives the credit back to the package it came from,
+// the type of the package each booking used is returned with the bookings keyed by booking ID
+func (r *classScheduleRepository) CancelSchedule(schedule *models.ClassSchedule, reason string, canceledAt time.Time) ([]models.Booking, map[uuid.UUID]string, error) {
+	var bookings []models.Booking
+	packageTypes := map[uuid.UUID]string{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.ClassSchedule{}).
+			Where("id = ? AND status IN ?", schedule.ID, []string{"scheduled", "open"}).
+			Updates(map[string]any{
+				"status":        "canceled",
+				"is_opened":     false,
+				"cancel_reason": reason,
+				"canceled_at":   canceledAt,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrScheduleStatusChanged
+		}
+
+		if err := tx.Where("class_schedule_id = ? AND status = ?", schedule.ID, "booked").
+			Find(&bookings).Error; err != nil {
+			return err
+		}
+
+		for _, b := range bookings {
+			if err := tx.Model(&models.Booking{}).
+				Where("id = ?", b.ID).
+				Update("status", "canceled").Error; err != nil {
+				return err
+			}
+			if b.UserPackageID == nil {
+				continue
+			}
+
+			// memberships never spent a credit on the booking
+			var packageType string
+			if err := tx.Model(&models.UserPackage{}).
+				Select("type").
+				Where("id = ?", *b.UserPackageID).
+				Scan(&packageType).Error; err != nil {
+				return err
+			}
+			packageTypes[b.ID] = packageType
+			if packageType != "credit_pack" {
+				continue
+			}
+			if err := ApplyCreditEntry(tx, &models.CreditLedgerEntry{
+				UserPackageID: *b.UserPackageID,
+				Type:          "refund",
+				Amount:        1,
+				BookingID:     &b.ID,
+				Reason:        "Class canceled: " + reason,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return bookings, packageTypes, err
+}
+
+// schedules up to the given date that have not reached a final state yet
+func (r *classScheduleRepository) GetSchedulesPendingTransition(until time.Time) ([]models.ClassSchedule, error) {
+	var schedules []models.ClassSchedule
+	err := r.db.
+		Where("status IN ? AND DATE(date) <= DATE(?)", []string{"scheduled", "open", "in_progress"}, until).
+		Find(&schedules).Error
+	return schedules, err
+}
+
+func (r *classScheduleRepository) UpdateMeetingLink(scheduleID uuid.UUID, provider, meetingID, link string) error {
 	return r.db.Model(&models.ClassSchedule{}).
 		Where("id = ?", scheduleID).
-		Update("verification_code", code).Error
+		Updates(map[string]any{
+			"zoom_link":        link,
+			"meeting_provider": provider,
+			"meeting_id":       meetingID,
+		}).Error
 }
 
 func (r *classScheduleRepository) GetAttendancesByScheduleID(scheduleID string) ([]models.Booking, error) {
@@ -175,3 +333,18 @@ func (r *classScheduleRepository) GetAttendancesByScheduleID(scheduleID string)
 	}
 	return bookings, nil
 }
+
+// GetSchedulesByInstructorInRange returns the instructor's schedules between start and end that are still to come,
+// classes that already started, finished or were canceled don't need anyone to cover them
+func (r *classScheduleRepository) GetSchedulesByInstructorInRange(instructorID uuid.UUID, start, end time.Time) ([]models.ClassSchedule, error) {
+	var schedules []models.ClassSchedule
+	now := time.Now().In(utils.GetStudioLocation())
+	err := r.db.
+		Where("instructor_id = ? AND DATE(date) BETWEEN ? AND ?", instructorID, start.Format("2006-01-02"), end.Format("2006-01-02")).
+		Where("status IN ?", []string{"scheduled", "open"}).
+		Where("TIMESTAMP(date, MAKETIME(start_hour, start_minute, 0)) > ?", now.Format("2006-01-02 15:04:05")).
+		Order("date asc").
+		Order("start_hour asc").
+		Find(&schedules).Error
+	return schedules, err
+}
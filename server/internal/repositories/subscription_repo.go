@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"errors"
+	"server/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SubscriptionRepository interface {
+	CreateSubscription(subscription *models.Subscription) error
+	UpdateSubscription(subscription *models.Subscription) error
+	RecordRenewal(subscription *models.Subscription, payment *models.Payment, userPackage *models.UserPackage) error
+	GetSubscriptionByID(id string) (*models.Subscription, error)
+	GetSubscriptionByProviderID(providerSubscriptionID string) (*models.Subscription, error)
+	GetSubscriptionsByUserID(userID string) ([]models.Subscription, error)
+	GetEndedSubscriptions(now time.Time) ([]models.Subscription, error)
+}
+
+type subscriptionRepository struct {
+	db *gorm.DB
+}
+
+func NewSubscriptionRepository(db *gorm.DB) SubscriptionRepository {
+	return &subscriptionRepository{db}
+}
+
+func (r *subscriptionRepository) CreateSubscription(subscription *models.Subscription) error {
+	return r.db.Create(subscription).Error
+}
+
+func (r *subscriptionRepository) UpdateSubscription(subscription *models.Subscription) error {
+	return r.db.Omit("Package").Save(subscription).Error
+}
+
+// RecordRenewal stores the renewal payment, extends the membership and moves the subscription to its new period
+// in one transaction, so a failed write leaves nothing behind and the retried webhook renews it again
+func (r *subscriptionRepository) RecordRenewal(subscription *models.Subscription, payment *models.Payment, userPackage *models.UserPackage) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := AssignInvoiceNumber(tx, payment); err != nil {
+			return err
+		}
+		if err := tx.Create(payment).Error; err != nil {
+			return err
+		}
+		// credits only move through the ledger, so the stored balance is never overwritten here
+		if err := tx.Omit("remaining_credit").Save(userPackage).Error; err != nil {
+			return err
+		}
+		return tx.Omit("Package").Save(subscription).Error
+	})
+}
+
+func (r *subscriptionRepository) GetSubscriptionByID(id string) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := r.db.Preload("Package").First(&subscription, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &subscription, err
+}
+
+func (r *subscriptionRepository) GetSubscriptionByProviderID(providerSubscriptionID string) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := r.db.Preload("Package").First(&subscription, "provider_subscription_id = ?", providerSubscriptionID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &subscription, err
+}
+
+func (r *subscriptionRepository) GetSubscriptionsByUserID(userID string) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	err := r.db.
+		Where("user_id = ?", userID).
+		Order("created_at desc").
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// subscriptions set to cancel at period end whose period is already over
+func (r *subscriptionRepository) GetEndedSubscriptions(now time.Time) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	err := r.db.
+		Where("status <> ? AND cancel_at_period_end = ? AND current_period_end <= ?", "canceled", true, now).
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
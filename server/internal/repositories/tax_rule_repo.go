@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"errors"
+	"server/internal/dto"
+	"server/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TaxRuleRepository interface {
+	CreateRule(rule *models.TaxRule) error
+	UpdateRule(rule *models.TaxRule) error
+	DeleteRule(id string) error
+	GetRuleByID(id string) (*models.TaxRule, error)
+	GetRules(params dto.TaxRuleQueryParam, activeOn *time.Time) ([]models.TaxRule, int64, error)
+	GetEffectiveRules(at time.Time, locationID *uuid.UUID, category string) ([]models.TaxRule, error)
+}
+
+type taxRuleRepository struct {
+	db *gorm.DB
+}
+
+func NewTaxRuleRepository(db *gorm.DB) TaxRuleRepository {
+	return &taxRuleRepository{db}
+}
+
+func (r *taxRuleRepository) CreateRule(rule *models.TaxRule) error {
+	return r.db.Omit("Location").Create(rule).Error
+}
+
+func (r *taxRuleRepository) UpdateRule(rule *models.TaxRule) error {
+	return r.db.Omit("Location").Save(rule).Error
+}
+
+func (r *taxRuleRepository) DeleteRule(id string) error {
+	return r.db.Delete(&models.TaxRule{}, "id = ?", id).Error
+}
+
+func (r *taxRuleRepository) GetRuleByID(id string) (*models.TaxRule, error) {
+	var rule models.TaxRule
+	err := r.db.Preload("Location").First(&rule, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &rule, err
+}
+
+func (r *taxRuleRepository) GetRules(params dto.TaxRuleQueryParam, activeOn *time.Time) ([]models.TaxRule, int64, error) {
+	var rules []models.TaxRule
+	var count int64
+
+	db := r.db.Model(&models.TaxRule{})
+	if params.LocationID != "" {
+		db = db.Where("location_id = ?", params.LocationID)
+	}
+	if params.TaxCategory != "" {
+		db = db.Where("tax_category = ?", params.TaxCategory)
+	}
+	if params.Name != "" {
+		db = db.Where("name = ?", params.Name)
+	}
+	if activeOn != nil {
+		db = effectiveAt(db, *activeOn)
+	}
+
+	if err := db.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (params.Page - 1) * params.Limit
+	if err := db.Preload("Location").Order("name asc, effective_from desc").Limit(params.Limit).Offset(offset).Find(&rules).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return rules, count, nil
+}
+
+// GetEffectiveRules returns every rule in effect at the time that covers the location and tax category,
+// rules without a location or category are included as they apply everywhere
+func (r *taxRuleRepository) GetEffectiveRules(at time.Time, locationID *uuid.UUID, category string) ([]models.TaxRule, error) {
+	var rules []models.TaxRule
+
+	db := effectiveAt(r.db, at).Where("tax_category = '' OR tax_category = ?", category)
+	if locationID != nil {
+		db = db.Where("location_id IS NULL OR location_id = ?", *locationID)
+	} else {
+		db = db.Where("location_id IS NULL")
+	}
+
+	err := db.Order("effective_from desc").Find(&rules).Error
+	return rules, err
+}
+
+func effectiveAt(db *gorm.DB, at time.Time) *gorm.DB {
+	return db.Where("effective_from <= ? AND (effective_until IS NULL OR effective_until > ?)", at, at)
+}
@@ -3,6 +3,7 @@ package repositories
 import (
 	"errors"
 	"server/internal/models"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -11,7 +12,9 @@ type ScheduleTemplateRepository interface {
 	DeleteTemplate(id string) error
 	GetAllTemplates() ([]models.ScheduleTemplate, error)
 	CreateTemplate(template *models.ScheduleTemplate) error
+	CreateTemplates(templates []models.ScheduleTemplate) error
 	GetActiveTemplates() ([]models.ScheduleTemplate, error)
+	GetUnexpiredTemplates(date time.Time) ([]models.ScheduleTemplate, error)
 	UpdateTemplate(template *models.ScheduleTemplate) error
 	GetTemplateByID(id string) (*models.ScheduleTemplate, error)
 }
@@ -39,6 +42,13 @@ func (r *scheduleTemplateRepository) GetActiveTemplates() ([]models.ScheduleTemp
 	return templates, err
 }
 
+// GetUnexpiredTemplates returns the templates that still generate classes on or after date, stopped or not
+func (r *scheduleTemplateRepository) GetUnexpiredTemplates(date time.Time) ([]models.ScheduleTemplate, error) {
+	var templates []models.ScheduleTemplate
+	err := r.db.Where("end_date >= ?", date).Find(&templates).Error
+	return templates, err
+}
+
 func (r *scheduleTemplateRepository) UpdateTemplate(template *models.ScheduleTemplate) error {
 	return r.db.Save(template).Error
 }
@@ -47,6 +57,12 @@ func (r *scheduleTemplateRepository) CreateTemplate(template *models.ScheduleTem
 	return r.db.Create(template).Error
 }
 
+func (r *scheduleTemplateRepository) CreateTemplates(templates []models.ScheduleTemplate) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&templates).Error
+	})
+}
+
 func (r *scheduleTemplateRepository) GetTemplateByID(id string) (*models.ScheduleTemplate, error) {
 	var template models.ScheduleTemplate
 	err := r.db.First(&template, "id = ?", id).Error
@@ -2,22 +2,33 @@ package repositories
 
 import (
 	"errors"
+	"fmt"
 	"server/internal/dto"
 	"server/internal/models"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserPackageRepository interface {
 	CreateUserPackage(userPackage *models.UserPackage) error
 	UpdateUserPackage(userPackage *models.UserPackage) error
+	SaveWithCredit(userPackage *models.UserPackage, entry *models.CreditLedgerEntry) error
+	GetUserPackageByID(id string) (*models.UserPackage, error)
 	GetUserPackagesByUserID(userID string) ([]models.UserPackage, error)
 	GetUserPackagesByClassID(userID, classID string) ([]models.UserPackage, error)
 	GetUserPackagesByPackageIDs(packageIDs []uuid.UUID) ([]models.UserPackage, error)
 	GetActiveUserPackages(userID, packageID string, result *models.UserPackage) error
 	GetUserPackages(userID string, params dto.PackageQueryParam) ([]models.UserPackage, int64, error)
+	GetUnprocessedExpiredPackages(now time.Time) ([]models.UserPackage, error)
+	GetPackagesExpiringBetween(from, until time.Time) ([]models.UserPackage, error)
+	ExpireUserPackage(userPackage *models.UserPackage, now time.Time) (int, error)
+	ExtendExpiry(userPackage *models.UserPackage, expiredAt time.Time, restore *models.CreditLedgerEntry) error
+	MarkExpiryReminded(id uuid.UUID, days int) error
+	TransferCredits(target *models.UserPackage, debit, credit *models.CreditLedgerEntry, maxTransfer int) error
+	HasActivePackage(userID string) (bool, error)
 }
 
 type userPackageRepository struct {
@@ -32,8 +43,166 @@ func (r *userPackageRepository) CreateUserPackage(userPackage *models.UserPackag
 	return r.db.Create(userPackage).Error
 }
 
+// credits only move through the ledger, so the stored balance is never overwritten here
 func (r *userPackageRepository) UpdateUserPackage(userPackage *models.UserPackage) error {
-	return r.db.Save(userPackage).Error
+	return r.db.Omit("remaining_credit").Save(userPackage).Error
+}
+
+// SaveWithCredit creates or updates the package and applies the ledger entry in one transaction,
+// a package without an ID is created with an empty balance so the entry is its opening credit
+func (r *userPackageRepository) SaveWithCredit(userPackage *models.UserPackage, entry *models.CreditLedgerEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if userPackage.ID == uuid.Nil {
+			userPackage.RemainingCredit = 0
+			if err := tx.Create(userPackage).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Omit("remaining_credit").Save(userPackage).Error; err != nil {
+			return err
+		}
+
+		entry.UserPackageID = userPackage.ID
+		if err := ApplyCreditEntry(tx, entry); err != nil {
+			return err
+		}
+		userPackage.RemainingCredit = entry.BalanceAfter
+		return nil
+	})
+}
+
+// packages past their expiry date that the expiry job has not closed yet
+func (r *userPackageRepository) GetUnprocessedExpiredPackages(now time.Time) ([]models.UserPackage, error) {
+	var userPackages []models.UserPackage
+	err := r.db.
+		Where("expired_at <= ? AND closed_at IS NULL", now).
+		Find(&userPackages).Error
+	return userPackages, err
+}
+
+// auto-renewing packages are extended by their subscription, so they are left out of reminders
+func (r *userPackageRepository) GetPackagesExpiringBetween(from, until time.Time) ([]models.UserPackage, error) {
+	var userPackages []models.UserPackage
+	err := r.db.
+		Where("expired_at > ? AND expired_at <= ? AND subscription_id IS NULL", from, until).
+		Find(&userPackages).Error
+	return userPackages, err
+}
+
+// ExpireUserPackage forfeits the remaining credit through the ledger and marks the package as processed,
+// it returns the number of credits forfeited
+func (r *userPackageRepository) ExpireUserPackage(userPackage *models.UserPackage, now time.Time) (int, error) {
+	forfeited := 0
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var current models.UserPackage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", userPackage.ID).
+			First(&current).Error; err != nil {
+			return err
+		}
+		if current.ClosedAt != nil {
+			return nil
+		}
+
+		if current.Type == "credit_pack" && current.RemainingCredit > 0 {
+			forfeited = current.RemainingCredit
+			entry := models.CreditLedgerEntry{
+				UserPackageID: current.ID,
+				Type:          "expiry",
+				Amount:        -forfeited,
+				Reason:        fmt.Sprintf("Package expired on %s", current.ExpiredAt.Format("2006-01-02")),
+			}
+			if err := ApplyCreditEntry(tx, &entry); err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.UserPackage{}).
+			Where("id = ?", current.ID).
+			Update("closed_at", now).Error
+	})
+	return forfeited, err
+}
+
+// ExtendExpiry moves the expiry date and reopens the package, restore gives back credits forfeited at expiry
+func (r *userPackageRepository) ExtendExpiry(userPackage *models.UserPackage, expiredAt time.Time, restore *models.CreditLedgerEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserPackage{}).
+			Where("id = ?", userPackage.ID).
+			Updates(map[string]interface{}{
+				"expired_at":           expiredAt,
+				"closed_at":            nil,
+				"expiry_reminded_days": 0,
+			}).Error; err != nil {
+			return err
+		}
+
+		userPackage.ExpiredAt = &expiredAt
+		userPackage.ClosedAt = nil
+		userPackage.ExpiryRemindedDays = 0
+		if restore == nil {
+			return nil
+		}
+
+		restore.UserPackageID = userPackage.ID
+		if err := ApplyCreditEntry(tx, restore); err != nil {
+			return err
+		}
+		userPackage.RemainingCredit = restore.BalanceAfter
+		return nil
+	})
+}
+
+// TransferCredits moves credits between two packages in one transaction, a target without an ID is created first.
+// The source row is locked before the credits already transferred out are counted, so concurrent transfers
+// cannot together go over maxTransfer
+func (r *userPackageRepository) TransferCredits(target *models.UserPackage, debit, credit *models.CreditLedgerEntry, maxTransfer int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var source models.UserPackage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			Where("id = ?", debit.UserPackageID).
+			First(&source).Error; err != nil {
+			return err
+		}
+		transferred, err := transferredOut(tx, debit.UserPackageID.String())
+		if err != nil {
+			return err
+		}
+		if transferred-debit.Amount > maxTransfer {
+			return ErrTransferLimitReached
+		}
+
+		if err := ApplyCreditEntry(tx, debit); err != nil {
+			return err
+		}
+
+		if target.ID == uuid.Nil {
+			target.RemainingCredit = 0
+			if err := tx.Create(target).Error; err != nil {
+				return err
+			}
+		}
+
+		credit.UserPackageID = target.ID
+		if err := ApplyCreditEntry(tx, credit); err != nil {
+			return err
+		}
+		target.RemainingCredit = credit.BalanceAfter
+		return nil
+	})
+}
+
+func (r *userPackageRepository) MarkExpiryReminded(id uuid.UUID, days int) error {
+	return r.db.Model(&models.UserPackage{}).Where("id = ?", id).Update("expiry_reminded_days", days).Error
+}
+
+func (r *userPackageRepository) GetUserPackageByID(id string) (*models.UserPackage, error) {
+	var userPackage models.UserPackage
+	err := r.db.Where("id = ?", id).First(&userPackage).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &userPackage, err
 }
 
 func (r *userPackageRepository) GetUserPackagesByUserID(userID string) ([]models.UserPackage, error) {
@@ -111,9 +280,19 @@ func (r *userPackageRepository) GetUserPackagesByClassID(userID, classID string)
 
 	err := r.db.
 		Joins("JOIN package_classes pc ON pc.package_id = user_packages.package_id").
-		Where("user_packages.user_id = ? AND pc.class_id = ?", userID, classID).
+		Where("pc.class_id = ?", classID).
+		Where("user_packages.user_id = ? OR user_packages.id IN (?)", userID,
+			r.db.Model(&models.UserPackageMember{}).Select("user_package_id").Where("user_id = ? AND status = ?", userID, "active")).
 		Preload("Package.Classes").
 		Find(&userPackages).Error
 
 	return userPackages, err
 }
+
+func (r *userPackageRepository) HasActivePackage(userID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.UserPackage{}).
+		Where("user_id = ? AND expired_at > ? AND closed_at IS NULL", userID, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -1,13 +1,17 @@
 package repositories
 
 import (
+	"errors"
 	"server/internal/models"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrVoucherUnavailable = errors.New("voucher is no longer available")
+
 type VoucherRepository interface {
 	CreateNewVoucher(v *models.Voucher) error
 	GetAllVouchers() ([]models.Voucher, error)
@@ -16,6 +20,9 @@ type VoucherRepository interface {
 	GetByCode(code string) (*models.Voucher, error)
 	GetVoucherByID(id string) (*models.Voucher, error)
 	InsertUsedVoucher(userID, voucherID uuid.UUID) error
+	HoldVoucher(reservation *models.VoucherReservation, oneTime bool) error
+	ReleaseReservation(paymentID uuid.UUID) (bool, error)
+	GetStaleReservations(now time.Time) ([]models.VoucherReservation, error)
 	CheckVoucherUsed(userID, voucherID uuid.UUID) (bool, error)
 	GetValidVoucherByCode(code string) (*models.Voucher, error)
 }
@@ -60,12 +67,22 @@ func (r *voucherRepository) GetValidVoucherByCode(code string) (*models.Voucher,
 	return &voucher, err
 }
 
+// CheckVoucherUsed also counts a use the user still holds for an open checkout
 func (r *voucherRepository) CheckVoucherUsed(userID, voucherID uuid.UUID) (bool, error) {
-	var count int64
-	err := r.db.Model(&models.UsedVoucher{}).
+	return voucherTaken(r.db, userID, voucherID)
+}
+
+func voucherTaken(db *gorm.DB, userID, voucherID uuid.UUID) (bool, error) {
+	var used, held int64
+	if err := db.Model(&models.UsedVoucher{}).
 		Where("user_id = ? AND voucher_id = ?", userID, voucherID).
-		Count(&count).Error
-	return count > 0, err
+		Count(&used).Error; err != nil {
+		return false, err
+	}
+	err := db.Model(&models.VoucherReservation{}).
+		Where("user_id = ? AND voucher_id = ? AND status = ?", userID, voucherID, "held").
+		Count(&held).Error
+	return used+held > 0, err
 }
 
 func (r *voucherRepository) InsertUsedVoucher(userID, voucherID uuid.UUID) error {
@@ -77,6 +94,114 @@ func (r *voucherRepository) InsertUsedVoucher(userID, voucherID uuid.UUID) error
 	}).Error
 }
 
+// HoldVoucher takes one use of the voucher for the reservation, the quota is decremented by a guarded update
+// so concurrent checkouts cannot oversubscribe it, a one-time voucher the user holds or used is unavailable
+func (r *voucherRepository) HoldVoucher(reservation *models.VoucherReservation, oneTime bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if oneTime {
+			taken, err := voucherTaken(tx, reservation.UserID, reservation.VoucherID)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return ErrVoucherUnavailable
+			}
+		}
+
+		result := tx.Model(&models.Voucher{}).
+			Where("id = ? AND quota > 0 AND expired_at > ?", reservation.VoucherID, time.Now()).
+			Update("quota", gorm.Expr("quota - 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVoucherUnavailable
+		}
+		return tx.Create(reservation).Error
+	})
+}
+
+// commitReservation turns the held use of a paid payment into a used voucher, a hold released before the
+// payment arrived takes its use back from the quota, payments without a reservation are left alone.
+// It runs in the transaction settling the payment so the voucher is only used up by a payment that committed
+func commitReservation(tx *gorm.DB, paymentID uuid.UUID) error {
+	var reservation models.VoucherReservation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("payment_id = ?", paymentID).
+		First(&reservation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil || reservation.Status == "committed" {
+		return err
+	}
+
+	if reservation.Status == "released" {
+		if err := tx.Unscoped().Model(&models.Voucher{}).
+			Where("id = ?", reservation.VoucherID).
+			Update("quota", gorm.Expr("GREATEST(quota - 1, 0)")).Error; err != nil {
+			return err
+		}
+	}
+
+	var voucher models.Voucher
+	if err := tx.Unscoped().First(&voucher, "id = ?", reservation.VoucherID).Error; err != nil {
+		return err
+	}
+	if !voucher.IsReusable {
+		if err := tx.Create(&models.UsedVoucher{
+			ID:        uuid.New(),
+			UserID:    reservation.UserID,
+			VoucherID: reservation.VoucherID,
+			UsedAt:    time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&reservation).Update("status", "committed").Error
+}
+
+// ReleaseReservation gives a held use back to the quota, it reports whether there was a hold to release
+func (r *voucherRepository) ReleaseReservation(paymentID uuid.UUID) (bool, error) {
+	released := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var reservation models.VoucherReservation
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("payment_id = ? AND status = ?", paymentID, "held").
+			First(&reservation).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&reservation).Update("status", "released").Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Voucher{}).
+			Where("id = ?", reservation.VoucherID).
+			Update("quota", gorm.Expr("quota + 1")).Error; err != nil {
+			return err
+		}
+		released = true
+		return nil
+	})
+	return released, err
+}
+
+// holds past their expiry or whose payment failed without the webhook releasing them
+func (r *voucherRepository) GetStaleReservations(now time.Time) ([]models.VoucherReservation, error) {
+	var reservations []models.VoucherReservation
+	err := r.db.
+		Where("status = ?", "held").
+		Where("expires_at <= ? OR payment_id IN (?)", now,
+			r.db.Model(&models.Payment{}).Select("id").Where("status = ?", "failed")).
+		Find(&reservations).Error
+	return reservations, err
+}
+
 func (r *voucherRepository) GetVoucherByID(id string) (*models.Voucher, error) {
 	var voucher models.Voucher
 	err := r.db.First(&voucher, "id = ?", id).Error
@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"errors"
+	"server/internal/dto"
+	"server/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInsufficientWallet = errors.New("not enough wallet balance")
+
+type WalletRepository interface {
+	ApplyTransaction(txn *models.WalletTransaction) error
+	ReleasePaymentHold(paymentID uuid.UUID) (bool, error)
+	GetPaymentHold(paymentID uuid.UUID) (int64, error)
+	GetUnreleasedPaymentHolds() ([]uuid.UUID, error)
+	GetTransactionsByUserID(userID string, params dto.WalletQueryParam) ([]models.WalletTransaction, int64, error)
+}
+
+type walletRepository struct {
+	db *gorm.DB
+}
+
+func NewWalletRepository(db *gorm.DB) WalletRepository {
+	return &walletRepository{db}
+}
+
+// ApplyWalletTransaction moves the wallet balance and appends the transaction using the caller's transaction, the user
+// row is locked so BalanceAfter stays consistent when the wallet is spent twice at once
+func ApplyWalletTransaction(tx *gorm.DB, txn *models.WalletTransaction) error {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "wallet_balance_minor").
+		Where("id = ?", txn.UserID).
+		First(&user).Error; err != nil {
+		return err
+	}
+
+	balance := user.WalletBalance + txn.Amount
+	if txn.Amount < 0 && balance < 0 {
+		return ErrInsufficientWallet
+	}
+
+	if err := tx.Model(&models.User{}).
+		Where("id = ?", user.ID).
+		Update("wallet_balance_minor", balance).Error; err != nil {
+		return err
+	}
+
+	txn.BalanceAfter = balance
+	return tx.Create(txn).Error
+}
+
+func (r *walletRepository) ApplyTransaction(txn *models.WalletTransaction) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return ApplyWalletTransaction(tx, txn)
+	})
+}
+
+// ReleasePaymentHold gives back the wallet credit spent on a payment that failed, it reports whether this call
+// released it so a failure seen twice returns the credit once
+func (r *walletRepository) ReleasePaymentHold(paymentID uuid.UUID) (bool, error) {
+	released := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var payment models.Payment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", paymentID).
+			First(&payment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if payment.Status != "failed" || payment.WalletAmount <= 0 {
+			return nil
+		}
+
+		held, err := paymentHold(tx, payment.ID)
+		if err != nil {
+			return err
+		}
+		if held <= 0 {
+			return nil
+		}
+
+		released = true
+		return ApplyWalletTransaction(tx, &models.WalletTransaction{
+			UserID:    payment.UserID,
+			Type:      "release",
+			Amount:    held,
+			Currency:  payment.Currency,
+			PaymentID: &payment.ID,
+			Reason:    "Returned from unpaid order for " + payment.PackageName,
+		})
+	})
+	return released && err == nil, err
+}
+
+// reclaimPaymentHold takes the wallet share of a failed payment again when its hold was given back before the
+// payment arrived late, ErrInsufficientWallet is returned when the member spent it in the meantime
+func reclaimPaymentHold(tx *gorm.DB, payment *models.Payment) error {
+	if payment.WalletAmount <= 0 {
+		return nil
+	}
+	held, err := paymentHold(tx, payment.ID)
+	if err != nil {
+		return err
+	}
+	if held >= payment.WalletAmount {
+		return nil
+	}
+
+	return ApplyWalletTransaction(tx, &models.WalletTransaction{
+		UserID:    payment.UserID,
+		Type:      "payment",
+		Amount:    held - payment.WalletAmount,
+		Currency:  payment.Currency,
+		PaymentID: &payment.ID,
+		Reason:    "Paid towards " + payment.PackageName + " after the payment arrived late",
+	})
+}
+
+// paymentHold is the wallet credit a payment still holds, what it took minus what was given back
+func paymentHold(tx *gorm.DB, paymentID uuid.UUID) (int64, error) {
+	var net int64
+	err := tx.Model(&models.WalletTransaction{}).
+		Select("COALESCE(SUM(amount_minor), 0)").
+		Where("payment_id = ? AND type IN ?", paymentID, []string{"payment", "release"}).
+		Scan(&net).Error
+	return -net, err
+}
+
+func (r *walletRepository) GetPaymentHold(paymentID uuid.UUID) (int64, error) {
+	return paymentHold(r.db, paymentID)
+}
+
+// GetUnreleasedPaymentHolds lists failed payments whose wallet credit was not given back yet, e.g. checkouts
+// expired in bulk
+func (r *walletRepository) GetUnreleasedPaymentHolds() ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&models.Payment{}).
+		Where("status = ? AND wallet_amount_minor > 0", "failed").
+		Where("(?) < 0", r.db.Model(&models.WalletTransaction{}).
+			Select("COALESCE(SUM(amount_minor), 0)").
+			Where("wallet_transactions.payment_id = payments.id AND wallet_transactions.type IN ?", []string{"payment", "release"})).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (r *walletRepository) GetTransactionsByUserID(userID string, params dto.WalletQueryParam) ([]models.WalletTransaction, int64, error) {
+	var txns []models.WalletTransaction
+	var count int64
+
+	db := r.db.Model(&models.WalletTransaction{}).Where("user_id = ?", userID)
+	if params.Type != "" {
+		db = db.Where("type = ?", params.Type)
+	}
+
+	if err := db.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (params.Page - 1) * params.Limit
+	if err := db.Order("created_at desc").Limit(params.Limit).Offset(offset).Find(&txns).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return txns, count, nil
+}
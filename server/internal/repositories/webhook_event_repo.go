@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"errors"
+	"server/internal/dto"
+	"server/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookEventRepository interface {
+	RecordEvent(event *models.WebhookEvent) (bool, error)
+	UpdateEvent(event *models.WebhookEvent) error
+	GetEventByID(id string) (*models.WebhookEvent, error)
+	GetEvents(params dto.WebhookEventQueryParam) ([]models.WebhookEvent, int64, error)
+}
+
+type webhookEventRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookEventRepository(db *gorm.DB) WebhookEventRepository {
+	return &webhookEventRepository{db}
+}
+
+// RecordEvent stores the event unless the gateway delivered it before, event is then loaded with the stored row,
+// it reports whether the event is new
+func (r *webhookEventRepository) RecordEvent(event *models.WebhookEvent) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return true, nil
+	}
+
+	err := r.db.
+		Where("gateway = ? AND event_id = ?", event.Gateway, event.EventID).
+		First(event).Error
+	return false, err
+}
+
+func (r *webhookEventRepository) UpdateEvent(event *models.WebhookEvent) error {
+	return r.db.Save(event).Error
+}
+
+func (r *webhookEventRepository) GetEventByID(id string) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+	err := r.db.First(&event, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &event, err
+}
+
+func (r *webhookEventRepository) GetEvents(params dto.WebhookEventQueryParam) ([]models.WebhookEvent, int64, error) {
+	var events []models.WebhookEvent
+	var count int64
+
+	db := r.db.Model(&models.WebhookEvent{})
+	if params.Gateway != "" {
+		db = db.Where("gateway = ?", params.Gateway)
+	}
+	if params.Status != "" {
+		db = db.Where("status = ?", params.Status)
+	}
+	if params.Type != "" {
+		db = db.Where("type = ?", params.Type)
+	}
+	if params.Reference != "" {
+		db = db.Where("reference = ?", params.Reference)
+	}
+
+	if err := db.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (params.Page - 1) * params.Limit
+	if err := db.Order("created_at desc").Limit(params.Limit).Offset(offset).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return events, count, nil
+}
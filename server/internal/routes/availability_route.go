@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"server/internal/handlers"
+	"server/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AvailabilityRoutes(r *gin.RouterGroup, h *handlers.AvailabilityHandler) {
+	// instructor-endpoints
+	instructor := r.Group("/instructor")
+	instructor.Use(middleware.AuthRequired(), middleware.RoleOnly("instructor"))
+	instructor.GET("/availability", h.GetMyAvailability)
+	instructor.PUT("/availability", h.UpdateMyAvailability)
+	instructor.GET("/time-off", h.GetMyTimeOffs)
+	instructor.POST("/time-off", h.RequestTimeOff)
+	instructor.DELETE("/time-off/:id", h.CancelTimeOff)
+
+	// admin-endpoints
+	admin := r.Group("/admin/instructors/time-off")
+	admin.Use(middleware.AuthRequired(), middleware.RoleOnly("admin"))
+	admin.GET("", h.GetTimeOffs)
+	admin.PATCH("/:id/approve", h.ApproveTimeOff)
+	admin.PATCH("/:id/reject", h.RejectTimeOff)
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"server/internal/handlers"
+	"server/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CreditRoutes(r *gin.RouterGroup, h *handlers.CreditHandler) {
+	// customer-endpoints
+	customer := r.Group("/user-packages/credits")
+	customer.Use(middleware.AuthRequired(), middleware.RoleOnly("customer"))
+	customer.GET("", h.GetMyCreditHistory)
+	customer.POST("/transfer", h.TransferCredit)
+
+	// admin-endpoints
+	admin := r.Group("/admin/user-packages")
+	admin.Use(middleware.AuthRequired(), middleware.RoleOnly("admin"))
+	admin.GET("/:id/credits", h.GetReconciliation)
+	admin.POST("/:id/credits/adjust", h.AdjustCredit)
+	admin.POST("/:id/credits/reconcile", h.ReconcileLedger)
+}
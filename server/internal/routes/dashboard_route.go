@@ -10,4 +10,5 @@ func DashboardRoutes(r *gin.RouterGroup, handler *handlers.DashboardHandler) {
 	admin := r.Group("/admin")
 	admin.GET("/dashboard/summary", handler.GetSummary)
 	admin.GET("/dashboard/revenue", handler.GetRevenueStats)
+	admin.GET("/dashboard/forfeited-credits", handler.GetForfeitedCredits)
 }
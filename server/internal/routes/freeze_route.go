@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"server/internal/handlers"
+	"server/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func FreezeRoutes(r *gin.RouterGroup, h *handlers.FreezeHandler) {
+	// customer-endpoints
+	customer := r.Group("/user-packages/freezes")
+	customer.Use(middleware.AuthRequired(), middleware.RoleOnly("customer"))
+	customer.POST("", h.RequestFreeze)
+	customer.GET("", h.GetMyFreezes)
+	customer.DELETE("/:id", h.CancelFreeze)
+
+	// admin-endpoints
+	admin := r.Group("/admin/package-freezes")
+	admin.Use(middleware.AuthRequired(), middleware.RoleOnly("admin"))
+	admin.GET("", h.GetFreezes)
+	admin.PATCH("/:id/approve", h.ApproveFreeze)
+	admin.PATCH("/:id/reject", h.RejectFreeze)
+}
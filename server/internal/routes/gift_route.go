@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"server/internal/handlers"
+	"server/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GiftRoutes(r *gin.RouterGroup, h *handlers.GiftHandler) {
+	// customer-endpoints
+	customer := r.Group("/gifts")
+	customer.Use(middleware.AuthRequired(), middleware.RoleOnly("customer"))
+	customer.GET("/me", h.GetMyGifts)
+	customer.POST("/redeem", h.RedeemGift)
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"server/internal/handlers"
+	"server/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InvoiceRoutes(r *gin.RouterGroup, h *handlers.InvoiceHandler) {
+	// customer-endpoints
+	customer := r.Group("/payments/me")
+	customer.Use(middleware.AuthRequired(), middleware.RoleOnly("customer"))
+	customer.GET("/:id/invoice", h.GetMyInvoice)
+	customer.GET("/:id/receipt", h.GetMyReceipt)
+
+	// admin-endpoints
+	admin := r.Group("/admin/payments")
+	admin.Use(middleware.AuthRequired(), middleware.RoleOnly("admin"))
+	admin.GET("/:id/invoice", h.GetInvoice)
+	admin.GET("/:id/receipt", h.GetReceipt)
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"server/internal/handlers"
+	"server/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PackageMemberRoutes(r *gin.RouterGroup, h *handlers.PackageMemberHandler) {
+	// customer-endpoints
+	customer := r.Group("/user-packages")
+	customer.Use(middleware.AuthRequired(), middleware.RoleOnly("customer"))
+	customer.GET("/invitations", h.GetMyInvitations)
+	customer.PATCH("/invitations/:id/accept", h.AcceptInvitation)
+	customer.PATCH("/invitations/:id/decline", h.DeclineInvitation)
+	customer.GET("/:id/members", h.GetPackageUsage)
+	customer.POST("/:id/members", h.InviteMember)
+	customer.PATCH("/:id/members/:memberId", h.UpdateMemberCap)
+	customer.DELETE("/:id/members/:memberId", h.RemoveMember)
+}
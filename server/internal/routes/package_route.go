@@ -9,7 +9,7 @@ import (
 
 func PackageRoutes(r *gin.RouterGroup, h *handlers.PackageHandler) {
 	// public-endpoints
-	r.GET("/packages", h.GetAllPackages)
+	r.GET("/packages", middleware.AuthOptional(), h.GetAllPackages)
 	r.GET("/packages/:id", h.GetPackageByID)
 
 	// admin-endpoints
@@ -8,13 +8,12 @@ import (
 )
 
 func PaymentRoutes(r *gin.RouterGroup, h *handlers.PaymentHandler) {
-	// webhook-endpoints
-	r.POST("/payments/stripe/notifications", h.HandlePaymentNotifications)
-
 	// customer-endpoints
 	customer := r.Group("/payments")
 	customer.Use(middleware.AuthRequired(), middleware.RoleOnly("customer"))
 	customer.POST("", h.CreatePayment)
+	customer.POST("/upgrade/quote", h.QuoteUpgrade)
+	customer.POST("/upgrade", h.UpgradePackage)
 	customer.GET("/me", h.GetMyTransactions)
 	customer.GET("/me/:id", h.GetPaymentDetail)
 
@@ -22,5 +21,7 @@ func PaymentRoutes(r *gin.RouterGroup, h *handlers.PaymentHandler) {
 	admin := r.Group("/admin/payments")
 	admin.Use(middleware.AuthRequired(), middleware.RoleOnly("admin"))
 	admin.GET("", h.GetAllUserPayments)
+	admin.POST("/offline", h.RecordOfflinePayment)
+	admin.GET("/cash-drawer", h.GetCashDrawerReport)
 	admin.GET("/:id", h.GetPaymentDetail)
 }
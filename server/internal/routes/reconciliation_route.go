@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"server/internal/handlers"
+	"server/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReconciliationRoutes(r *gin.RouterGroup, h *handlers.ReconciliationHandler) {
+	// admin-endpoints
+	admin := r.Group("/admin/reconciliation")
+	admin.Use(middleware.AuthRequired(), middleware.RoleOnly("admin"))
+	admin.POST("/run", h.Run)
+	admin.GET("/discrepancies", h.GetDiscrepancies)
+	admin.PATCH("/discrepancies/:id/resolve", h.ResolveDiscrepancy)
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"server/internal/handlers"
+	"server/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RefundRoutes(r *gin.RouterGroup, h *handlers.RefundHandler) {
+	// admin-endpoints
+	admin := r.Group("/admin/payments")
+	admin.Use(middleware.AuthRequired(), middleware.RoleOnly("admin"))
+	admin.GET("/:id/refunds", h.GetRefunds)
+	admin.POST("/:id/refunds", h.RefundPayment)
+}
@@ -22,6 +22,7 @@ func InitRoutes(r *gin.Engine, h *bootstrap.Handlers) {
 	TemplateRoutes(api, h.TemplateHandler)
 	CategoryRoutes(api, h.CategoryHandler)
 	InstructorRoutes(api, h.InstructorHandler)
+	AvailabilityRoutes(api, h.AvailabilityHandler)
 	SubcategoryRoutes(api, h.SubcategoryHandler)
 
 	// ======== Booking Management =======================
@@ -29,10 +30,21 @@ func InitRoutes(r *gin.Engine, h *bootstrap.Handlers) {
 	BookingRoutes(api, h.BookingHandler)
 	PackageRoutes(api, h.PackageHandler)
 	UserPackageRoutes(api, h.UserPackageHandler)
+	CreditRoutes(api, h.CreditHandler)
 
 	// ======== Payment & Voucher ========================
 	VoucherRoutes(api, h.VoucherHandler)
 	PaymentRoutes(api, h.PaymentHandler)
+	InvoiceRoutes(api, h.InvoiceHandler)
+	SubscriptionRoutes(api, h.SubscriptionHandler)
+	FreezeRoutes(api, h.FreezeHandler)
+	PackageMemberRoutes(api, h.MemberHandler)
+	GiftRoutes(api, h.GiftHandler)
+	RefundRoutes(api, h.RefundHandler)
+	WalletRoutes(api, h.WalletHandler)
+	TaxRoutes(api, h.TaxHandler)
+	WebhookRoutes(api, h.WebhookHandler)
+	ReconciliationRoutes(api, h.ReconcileHandler)
 
 	// ======== Notification & Dashboard =================
 	NotificationRoutes(api, h.NotificationHandler)
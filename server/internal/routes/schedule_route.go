@@ -22,6 +22,8 @@ func ScheduleRoutes(r *gin.RouterGroup, h *handlers.ClassScheduleHandler) {
 	instructor.Use(middleware.AuthRequired(), middleware.RoleOnly("instructor"))
 	instructor.GET("", h.GetInstructorSchedules)
 	instructor.PATCH("/:id/open", h.OpenClassSchedule)
+	instructor.PATCH("/:id/start", h.StartClassSchedule)
+	instructor.PATCH("/:id/complete", h.CompleteClassSchedule)
 	instructor.GET("/:id/attendance", h.GetClassAttendances)
 
 	// admin-endpoints
@@ -29,6 +31,9 @@ func ScheduleRoutes(r *gin.RouterGroup, h *handlers.ClassScheduleHandler) {
 	admin.Use(middleware.AuthRequired(), middleware.RoleOnly("admin"))
 	admin.POST("", h.CreateClassSchedule)
 	admin.POST("/recurring", h.CreateRecurringSchedule)
+	admin.POST("/import/preview", h.PreviewScheduleImport)
+	admin.POST("/import", h.ImportSchedules)
 	admin.PUT("/:id", h.UpdateClassSchedule)
+	admin.PATCH("/:id/cancel", h.CancelClassSchedule)
 	admin.DELETE("/:id", h.DeleteClassSchedule)
 }
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"server/internal/handlers"
+	"server/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SubscriptionRoutes(r *gin.RouterGroup, h *handlers.SubscriptionHandler) {
+	// customer-endpoints
+	customer := r.Group("/subscriptions/me")
+	customer.Use(middleware.AuthRequired(), middleware.RoleOnly("customer"))
+	customer.GET("", h.GetMySubscriptions)
+	customer.PATCH("/:id/cancel", h.CancelMySubscription)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"server/internal/handlers"
+	"server/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TaxRoutes(r *gin.RouterGroup, h *handlers.TaxHandler) {
+	// admin-endpoints
+	admin := r.Group("/admin/tax-rules")
+	admin.Use(middleware.AuthRequired(), middleware.RoleOnly("admin"))
+	admin.GET("", h.GetRules)
+	admin.POST("", h.CreateRule)
+	admin.PUT("/:id", h.UpdateRule)
+	admin.DELETE("/:id", h.DeleteRule)
+}
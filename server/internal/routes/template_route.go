@@ -12,6 +12,8 @@ func TemplateRoutes(r *gin.RouterGroup, handler *handlers.ScheduleTemplateHandle
 	// admin-endpoints
 	admin.Use(middleware.AuthRequired(), middleware.RoleOnly("admin"))
 	admin.GET("", handler.GetAllTemplates)
+	admin.POST("/plan", handler.PlanTimetable)
+	admin.POST("/plan/accept", handler.AcceptTimetable)
 	admin.PUT("/:id", handler.UpdateScheduleTemplate)
 	admin.POST("/:id/run", handler.RunScheduleTemplate)
 	admin.POST("/:id/stop", handler.StopScheduleTemplate)
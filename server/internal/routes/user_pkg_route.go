@@ -13,4 +13,9 @@ func UserPackageRoutes(r *gin.RouterGroup, h *handlers.UserPackageHandler) {
 	user.Use(middleware.AuthRequired(), middleware.RoleOnly("customer"))
 	user.GET("", h.GetUserPackages)
 	user.GET("/class/:id", h.GetUserPackagesByClassID)
+
+	// admin-endpoints
+	admin := r.Group("/admin/user-packages")
+	admin.Use(middleware.AuthRequired(), middleware.RoleOnly("admin"))
+	admin.POST("/:id/extend", h.ExtendUserPackage)
 }
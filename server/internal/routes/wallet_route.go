@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"server/internal/handlers"
+	"server/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func WalletRoutes(r *gin.RouterGroup, h *handlers.WalletHandler) {
+	// customer-endpoints
+	customer := r.Group("/wallet")
+	customer.Use(middleware.AuthRequired(), middleware.RoleOnly("customer"))
+	customer.GET("/me", h.GetMyWallet)
+
+	// admin-endpoints
+	admin := r.Group("/admin/users/:id/wallet")
+	admin.Use(middleware.AuthRequired(), middleware.RoleOnly("admin"))
+	admin.GET("", h.GetUserWallet)
+	admin.POST("/top-up", h.TopUp)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"server/internal/handlers"
+	"server/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func WebhookRoutes(r *gin.RouterGroup, h *handlers.WebhookHandler) {
+	// webhook-endpoints, one per gateway e.g. /payments/stripe/notifications
+	r.POST("/payments/:gateway/notifications", h.ReceiveNotification)
+
+	// admin-endpoints
+	admin := r.Group("/admin/webhook-events")
+	admin.Use(middleware.AuthRequired(), middleware.RoleOnly("admin"))
+	admin.GET("", h.GetEvents)
+	admin.GET("/:id", h.GetEvent)
+	admin.POST("/:id/replay", h.Replay)
+}
@@ -35,6 +35,21 @@ func ResetDatabase(db *gorm.DB) {
 		&models.Attendance{},
 		&models.Instructor{},
 		&models.Location{},
+		&models.WalletTransaction{},
+		&models.PaymentDiscrepancy{},
+		&models.InvoiceSequence{},
+		&models.TaxRule{},
+		&models.PaymentTax{},
+		&models.VoucherReservation{},
+		&models.WebhookEvent{},
+		&models.PaymentRefund{},
+		&models.PackageGift{},
+		&models.UserPackageMember{},
+		&models.UserPackageFreeze{},
+		&models.Subscription{},
+		&models.CreditLedgerEntry{},
+		&models.InstructorAvailability{},
+		&models.InstructorTimeOff{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to drop tables: %v", err)
@@ -69,6 +84,21 @@ func ResetDatabase(db *gorm.DB) {
 		&models.Attendance{},
 		&models.Instructor{},
 		&models.Location{},
+		&models.WalletTransaction{},
+		&models.PaymentDiscrepancy{},
+		&models.InvoiceSequence{},
+		&models.TaxRule{},
+		&models.PaymentTax{},
+		&models.VoucherReservation{},
+		&models.WebhookEvent{},
+		&models.PaymentRefund{},
+		&models.PackageGift{},
+		&models.UserPackageMember{},
+		&models.UserPackageFreeze{},
+		&models.Subscription{},
+		&models.CreditLedgerEntry{},
+		&models.InstructorAvailability{},
+		&models.InstructorTimeOff{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate tables: %v", err)
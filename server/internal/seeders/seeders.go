@@ -3,6 +3,7 @@ package seeders
 import (
 	"log"
 	"math/rand"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,6 +11,8 @@ import (
 	"gorm.io/gorm"
 
 	"server/internal/models"
+	"server/internal/repositories"
+	"server/pkg/money"
 	"server/pkg/utils"
 )
 
@@ -499,11 +502,13 @@ func SeedPackages(db *gorm.DB) {
 		categoryClasses[class.Category.Name] = append(categoryClasses[class.Category.Name], class)
 	}
 
+	// prices are in minor units, 12000000 is IDR 120,000.00
 	packages := []models.Package{
 		{
 			Name:           "Yoga Wellness Trial",
 			Description:    "Try 1 Yoga class to relieve stress and boost flexibility.",
-			Price:          120000,
+			Price:          12000000,
+			Currency:       "IDR",
 			Credit:         1,
 			Discount:       20,
 			Expired:        14,
@@ -514,7 +519,8 @@ func SeedPackages(db *gorm.DB) {
 		{
 			Name:           "Pilates Core Pack (5x)",
 			Description:    "Enjoy 5 Mat/Reformer Pilates sessions to build core strength and posture.",
-			Price:          600000,
+			Price:          60000000,
+			Currency:       "IDR",
 			Credit:         5,
 			Discount:       10,
 			Expired:        60,
@@ -525,7 +531,8 @@ func SeedPackages(db *gorm.DB) {
 		{
 			Name:           "Cardio Burnout Pass (10x)",
 			Description:    "Get your heart pumping with 10 sessions of HIIT, Zumba, and Aerobic workouts.",
-			Price:          1000000,
+			Price:          100000000,
+			Currency:       "IDR",
 			Credit:         10,
 			Discount:       15,
 			Expired:        120,
@@ -536,7 +543,8 @@ func SeedPackages(db *gorm.DB) {
 		{
 			Name:           "Combat Starter (1x)",
 			Description:    "Experience our Martial Arts class in one exciting session.",
-			Price:          150000,
+			Price:          15000000,
+			Currency:       "IDR",
 			Credit:         1,
 			Discount:       0,
 			Expired:        14,
@@ -547,7 +555,8 @@ func SeedPackages(db *gorm.DB) {
 		{
 			Name:           "Warrior Pack (5x)",
 			Description:    "Boost your skills with 5 sessions of Boxing, Muay Thai, or Kickboxing.",
-			Price:          650000,
+			Price:          65000000,
+			Currency:       "IDR",
 			Credit:         5,
 			Discount:       5,
 			Expired:        60,
@@ -660,10 +669,10 @@ func SeedPayments(db *gorm.DB) {
 	}
 
 	now := time.Now().UTC()
-	taxRate := utils.GetTaxRate()
-	base := pkg.Price * (1 - pkg.Discount/100)
-	tax := base * taxRate
-	total := base + tax
+	price := money.New(pkg.Price, pkg.Currency)
+	base := price.Sub(price.Percent(pkg.Discount))
+	tax := base.MulRate(utils.GetTaxRate())
+	total := base.Add(tax)
 
 	// Seed payments
 	payments := []models.Payment{
@@ -675,29 +684,38 @@ func SeedPayments(db *gorm.DB) {
 		createPayment(customer2, pkg, base, tax, total, now.AddDate(0, 0, -2), "failed"),
 	}
 
-	if err := db.Create(&payments).Error; err != nil {
+	// paid payments are numbered in the order they were paid, like the checkout does
+	sort.SliceStable(payments, func(i, j int) bool { return payments[i].PaidAt.Before(payments[j].PaidAt) })
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range payments {
+			if err := repositories.AssignInvoiceNumber(tx, &payments[i]); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&payments).Error
+	})
+	if err != nil {
 		log.Printf("Failed seeding payments: %v", err)
 	} else {
 		log.Println("Seeded 6 payments: 1 for customer01, 5 for customer02")
 	}
 }
 
-func createPayment(user models.User, pkg models.Package, base, tax, total float64, paidAt time.Time, status string) models.Payment {
-	id := uuid.New()
+func createPayment(user models.User, pkg models.Package, base, tax, total money.Money, paidAt time.Time, status string) models.Payment {
 	return models.Payment{
-		ID:            id,
+		ID:            uuid.New(),
 		UserID:        user.ID,
 		Email:         user.Email,
 		Fullname:      user.Fullname,
 		PackageID:     pkg.ID,
 		PackageName:   pkg.Name,
 		PaymentMethod: "bank_transfer",
-		BasePrice:     base,
-		Tax:           tax,
-		Total:         total,
+		Currency:      total.Currency,
+		BasePrice:     base.Amount,
+		Tax:           tax.Amount,
+		Total:         total.Amount,
 		Status:        status,
 		PaidAt:        paidAt,
-		InvoiceNumber: utils.GenerateInvoiceNumber(id),
 	}
 }
 
@@ -753,9 +771,27 @@ func SeedUserPackages(db *gorm.DB) {
 
 	if err := db.Create(&userPackages).Error; err != nil {
 		log.Printf("Failed seeding user packages: %v", err)
-	} else {
-		log.Println("Seeded user packages successfully")
+		return
 	}
+
+	var entries []models.CreditLedgerEntry
+	for _, up := range userPackages {
+		entries = append(entries, models.CreditLedgerEntry{
+			UserPackageID: up.ID,
+			UserID:        up.UserID,
+			Type:          "purchase",
+			Amount:        up.RemainingCredit,
+			BalanceAfter:  up.RemainingCredit,
+			Reason:        "Seeded package",
+			CreatedAt:     up.PurchasedAt,
+		})
+	}
+	if err := db.Create(&entries).Error; err != nil {
+		log.Printf("Failed seeding credit ledger: %v", err)
+		return
+	}
+
+	log.Println("Seeded user packages successfully")
 }
 
 func SeedClassSchedules(db *gorm.DB) {
@@ -794,6 +830,7 @@ func SeedClassSchedules(db *gorm.DB) {
 		StartHour:        9,
 		StartMinute:      0,
 		IsOpened:         true,
+		Status:           "completed",
 		Duration:         class.Duration,
 		Capacity:         10,
 		Booked:           1,
@@ -814,6 +851,7 @@ func SeedClassSchedules(db *gorm.DB) {
 		StartHour:        13,
 		StartMinute:      0,
 		IsOpened:         true,
+		Status:           "completed",
 		Duration:         class.Duration,
 		Capacity:         10,
 		Booked:           1,
@@ -1027,8 +1065,8 @@ func SeedVouchers(db *gorm.DB) {
 	now := time.Now().UTC()
 	expired := now.AddDate(0, 1, 0)
 
-	max1 := 30000.0
-	max2 := 50000.0
+	max1 := int64(3000000)
+	max2 := int64(5000000)
 
 	voucher1 := models.Voucher{
 		ID:           uuid.New(),
@@ -1037,6 +1075,7 @@ func SeedVouchers(db *gorm.DB) {
 		DiscountType: "percentage",
 		Discount:     50,
 		MaxDiscount:  &max1,
+		Currency:     "IDR",
 		Quota:        10,
 		IsReusable:   false,
 		ExpiredAt:    expired,
@@ -1048,8 +1087,9 @@ func SeedVouchers(db *gorm.DB) {
 		Code:         "HEALTHY100K",
 		Description:  "Diskon langsung 100.000",
 		DiscountType: "fixed",
-		Discount:     100000,
+		Amount:       10000000,
 		MaxDiscount:  &max2,
+		Currency:     "IDR",
 		Quota:        10,
 		IsReusable:   true,
 		ExpiredAt:    expired,
@@ -10,6 +10,7 @@ import (
 	"server/internal/models"
 	"server/internal/repositories"
 	customErr "server/pkg/errors"
+	"server/pkg/money"
 	"server/pkg/utils"
 	"time"
 
@@ -135,6 +136,8 @@ func (s *authService) GetUserProfile(userID string) (*dto.AuthMeResponse, error)
 		Fullname: user.Fullname,
 		Avatar:   user.Avatar,
 		Role:     user.Role,
+
+		WalletBalance: majorUnits(user.WalletBalance, money.DefaultCurrency()),
 	}, nil
 }
 
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	customErr "server/pkg/errors"
+	"server/pkg/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AvailabilityService interface {
+	// instructor
+	GetMyAvailability(userID string) ([]dto.AvailabilityWindowResponse, error)
+	UpdateMyAvailability(userID string, req dto.UpdateAvailabilityRequest) error
+	GetMyTimeOffs(userID string) ([]dto.TimeOffResponse, error)
+	RequestTimeOff(userID string, req dto.CreateTimeOffRequest) error
+	CancelTimeOff(userID, id string) error
+
+	// admin
+	GetTimeOffs(params dto.TimeOffQueryParam) ([]dto.TimeOffResponse, error)
+	ApproveTimeOff(adminID, id string, req dto.ReviewTimeOffRequest) ([]dto.ClassScheduleResponse, error)
+	RejectTimeOff(adminID, id string, req dto.ReviewTimeOffRequest) error
+}
+
+type availabilityService struct {
+	availability repositories.AvailabilityRepository
+	instructor   repositories.InstructorRepository
+	schedule     repositories.ClassScheduleRepository
+	notif        NotificationService
+}
+
+func NewAvailabilityService(
+	availability repositories.AvailabilityRepository,
+	instructor repositories.InstructorRepository,
+	schedule repositories.ClassScheduleRepository,
+	notif NotificationService,
+) AvailabilityService {
+	return &availabilityService{
+		availability: availability,
+		instructor:   instructor,
+		schedule:     schedule,
+		notif:        notif,
+	}
+}
+
+func (s *availabilityService) getInstructor(userID string) (*models.Instructor, error) {
+	instructor, err := s.instructor.GetInstructorByUserID(userID)
+	if err != nil || instructor == nil {
+		return nil, customErr.NewNotFound("instructor not found")
+	}
+	return instructor, nil
+}
+
+func (s *availabilityService) GetMyAvailability(userID string) ([]dto.AvailabilityWindowResponse, error) {
+	instructor, err := s.getInstructor(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	windows, err := s.availability.GetAvailabilityByInstructorID(instructor.ID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch availability", err)
+	}
+
+	result := make([]dto.AvailabilityWindowResponse, 0, len(windows))
+	for _, w := range windows {
+		result = append(result, dto.AvailabilityWindowResponse{
+			ID:          w.ID.String(),
+			DayOfWeek:   w.DayOfWeek,
+			StartHour:   w.StartHour,
+			StartMinute: w.StartMinute,
+			EndHour:     w.EndHour,
+			EndMinute:   w.EndMinute,
+		})
+	}
+	return result, nil
+}
+
+// weekly windows are replaced as a whole, an empty list means available at any time
+func (s *availabilityService) UpdateMyAvailability(userID string, req dto.UpdateAvailabilityRequest) error {
+	instructor, err := s.getInstructor(userID)
+	if err != nil {
+		return err
+	}
+
+	var windows []models.InstructorAvailability
+	for _, w := range req.Windows {
+		start := w.StartHour*60 + w.StartMinute
+		end := w.EndHour*60 + w.EndMinute
+		if end <= start || end > 24*60 {
+			return customErr.NewBadRequest(fmt.Sprintf("invalid window on day %d, end time must be after start time", w.DayOfWeek))
+		}
+
+		for _, other := range windows {
+			if other.DayOfWeek != w.DayOfWeek {
+				continue
+			}
+			otherStart := other.StartHour*60 + other.StartMinute
+			otherEnd := other.EndHour*60 + other.EndMinute
+			if start < otherEnd && otherStart < end {
+				return customErr.NewBadRequest(fmt.Sprintf("overlapping availability windows on day %d", w.DayOfWeek))
+			}
+		}
+
+		windows = append(windows, models.InstructorAvailability{
+			ID:           uuid.New(),
+			InstructorID: instructor.ID,
+			DayOfWeek:    w.DayOfWeek,
+			StartHour:    w.StartHour,
+			StartMinute:  w.StartMinute,
+			EndHour:      w.EndHour,
+			EndMinute:    w.EndMinute,
+		})
+	}
+
+	if err := s.availability.ReplaceAvailability(instructor.ID, windows); err != nil {
+		return customErr.NewInternal("failed to update availability", err)
+	}
+	return nil
+}
+
+func (s *availabilityService) GetMyTimeOffs(userID string) ([]dto.TimeOffResponse, error) {
+	instructor, err := s.getInstructor(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeOffs, err := s.availability.GetTimeOffsByInstructorID(instructor.ID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch time off requests", err)
+	}
+
+	result := make([]dto.TimeOffResponse, 0, len(timeOffs))
+	for _, t := range timeOffs {
+		t.Instructor = *instructor
+		result = append(result, toTimeOffResponse(t))
+	}
+	return result, nil
+}
+
+func (s *availabilityService) RequestTimeOff(userID string, req dto.CreateTimeOffRequest) error {
+	instructor, err := s.getInstructor(userID)
+	if err != nil {
+		return err
+	}
+
+	startDate, err := utils.ParseDate(req.StartDate)
+	if err != nil {
+		return customErr.NewBadRequest(err.Error())
+	}
+	endDate, err := utils.ParseDate(req.EndDate)
+	if err != nil {
+		return customErr.NewBadRequest(err.Error())
+	}
+	if endDate.Before(startDate) {
+		return customErr.NewBadRequest("end date must be on or after start date")
+	}
+	if endDate.Before(time.Now().UTC().Truncate(24 * time.Hour)) {
+		return customErr.NewBadRequest("cannot request time off in the past")
+	}
+
+	timeOff := models.InstructorTimeOff{
+		InstructorID: instructor.ID,
+		StartDate:    startDate,
+		EndDate:      endDate,
+		Reason:       req.Reason,
+		Status:       "pending",
+	}
+
+	if err := s.availability.CreateTimeOff(&timeOff); err != nil {
+		return customErr.NewInternal("failed to create time off request", err)
+	}
+	return nil
+}
+
+func (s *availabilityService) CancelTimeOff(userID, id string) error {
+	instructor, err := s.getInstructor(userID)
+	if err != nil {
+		return err
+	}
+
+	timeOff, err := s.availability.GetTimeOffByID(id)
+	if err != nil || timeOff == nil || timeOff.InstructorID != instructor.ID {
+		return customErr.NewNotFound("time off request not found")
+	}
+	if timeOff.Status != "pending" {
+		return customErr.NewBadRequest("only pending requests can be canceled")
+	}
+
+	timeOff.Status = "canceled"
+	if err := s.availability.UpdateTimeOff(timeOff); err != nil {
+		return customErr.NewInternal("failed to cancel time off request", err)
+	}
+	return nil
+}
+
+func (s *availabilityService) GetTimeOffs(params dto.TimeOffQueryParam) ([]dto.TimeOffResponse, error) {
+	timeOffs, err := s.availability.GetTimeOffs(params.Status)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch time off requests", err)
+	}
+
+	result := make([]dto.TimeOffResponse, 0, len(timeOffs))
+	for _, t := range timeOffs {
+		result = append(result, toTimeOffResponse(t))
+	}
+	return result, nil
+}
+
+// ApproveTimeOff returns the schedules already assigned to the instructor during the leave,
+// admins are alerted that each of them needs a substitute
+func (s *availabilityService) ApproveTimeOff(adminID, id string, req dto.ReviewTimeOffRequest) ([]dto.ClassScheduleResponse, error) {
+	timeOff, err := s.reviewTimeOff(adminID, id, "approved", req)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules, err := s.schedule.GetSchedulesByInstructorInRange(timeOff.InstructorID, timeOff.StartDate, timeOff.EndDate)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch affected schedules", err)
+	}
+
+	result := make([]dto.ClassScheduleResponse, 0, len(schedules))
+	for _, schedule := range schedules {
+		result = append(result, dto.ClassScheduleResponse{
+			ID:             schedule.ID.String(),
+			ClassID:        schedule.ClassID.String(),
+			ClassName:      schedule.ClassName,
+			ClassImage:     schedule.ClassImage,
+			InstructorID:   schedule.InstructorID.String(),
+			InstructorName: schedule.InstructorName,
+			Location:       schedule.Location,
+			Date:           schedule.Date.Format("2006-01-02"),
+			StartHour:      schedule.StartHour,
+			StartMinute:    schedule.StartMinute,
+			Capacity:       schedule.Capacity,
+			DeliveryMode:   schedule.DeliveryMode,
+			OnlineCapacity: schedule.OnlineCapacity,
+			OnlineBooked:   schedule.OnlineBooked,
+			BookedCount:    schedule.Booked,
+			Duration:       schedule.Duration,
+			Color:          schedule.Color,
+			Status:         schedule.Status,
+		})
+
+		payload := dto.NotificationEvent{
+			Type:  "substitute_needed",
+			Title: "Substitute Instructor Needed",
+			Message: fmt.Sprintf(
+				"%s is on approved leave. Class %q on %s at %02d:%02d (%d booked) needs a substitute instructor.",
+				schedule.InstructorName,
+				schedule.ClassName,
+				schedule.Date.Format("January 2, 2006"),
+				schedule.StartHour,
+				schedule.StartMinute,
+				schedule.Booked,
+			),
+		}
+		if err := s.notif.SendToAdmins(payload); err != nil {
+			log.Printf("failed sending substitute alert for schedule %s: %v\n", schedule.ID, err)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *availabilityService) RejectTimeOff(adminID, id string, req dto.ReviewTimeOffRequest) error {
+	_, err := s.reviewTimeOff(adminID, id, "rejected", req)
+	return err
+}
+
+func (s *availabilityService) reviewTimeOff(adminID, id, status string, req dto.ReviewTimeOffRequest) (*models.InstructorTimeOff, error) {
+	timeOff, err := s.availability.GetTimeOffByID(id)
+	if err != nil || timeOff == nil {
+		return nil, customErr.NewNotFound("time off request not found")
+	}
+	if timeOff.Status != "pending" {
+		return nil, customErr.NewBadRequest(fmt.Sprintf("time off request is already %s", timeOff.Status))
+	}
+
+	reviewer := uuid.MustParse(adminID)
+	now := time.Now().UTC()
+	timeOff.Status = status
+	timeOff.ReviewNote = req.Note
+	timeOff.ReviewedBy = &reviewer
+	timeOff.ReviewedAt = &now
+
+	if err := s.availability.UpdateTimeOff(timeOff); err != nil {
+		return nil, customErr.NewInternal("failed to update time off request", err)
+	}
+	return timeOff, nil
+}
+
+func toTimeOffResponse(t models.InstructorTimeOff) dto.TimeOffResponse {
+	resp := dto.TimeOffResponse{
+		ID:             t.ID.String(),
+		InstructorID:   t.InstructorID.String(),
+		InstructorName: t.Instructor.User.Fullname,
+		StartDate:      t.StartDate.Format("2006-01-02"),
+		EndDate:        t.EndDate.Format("2006-01-02"),
+		Reason:         t.Reason,
+		Status:         t.Status,
+		ReviewNote:     t.ReviewNote,
+		CreatedAt:      t.CreatedAt.Format(time.RFC3339),
+	}
+	if t.ReviewedAt != nil {
+		resp.ReviewedAt = t.ReviewedAt.Format(time.RFC3339)
+	}
+	return resp
+}
@@ -8,119 +8,169 @@ import (
 	"server/internal/models"
 	"server/internal/repositories"
 	customErr "server/pkg/errors"
+	"server/pkg/meeting"
 	"server/pkg/utils"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 type BookingService interface {
 	MarkAbsentBookings() error
 	CheckedInClassSchedule(userID, bookingID string) error
-	CreateBooking(userID, packageID, scheduleID string) error
+	CreateBooking(userID, packageID, scheduleID, mode string) error
 	GetBookingDetail(userID, bookingID string) (*dto.BookingDetailResponse, error)
 	CheckoutClassSchedule(userID, bookingID string, req dto.ValidateCheckoutRequest) error
 	GetBookingByUser(userID string, params dto.BookingQueryParam) ([]dto.BookingResponse, *dto.PaginationResponse, error)
 }
 
 type bookingService struct {
-	db           *gorm.DB
 	booking      repositories.BookingRepository
 	pkg          repositories.PackageRepository
 	notification NotificationService
 	userPkg      repositories.UserPackageRepository
 	schedule     repositories.ClassScheduleRepository
+	meeting      meeting.Provider
+	freeze       repositories.FreezeRepository
+	member       repositories.PackageMemberRepository
 }
 
-func NewBookingService(db *gorm.DB, booking repositories.BookingRepository, pkg repositories.PackageRepository, notification NotificationService, userPkg repositories.UserPackageRepository, schedule repositories.ClassScheduleRepository) BookingService {
+func NewBookingService(booking repositories.BookingRepository, pkg repositories.PackageRepository, notification NotificationService, userPkg repositories.UserPackageRepository, schedule repositories.ClassScheduleRepository, meeting meeting.Provider, freeze repositories.FreezeRepository, member repositories.PackageMemberRepository) BookingService {
 	return &bookingService{
-		db:           db,
 		booking:      booking,
 		pkg:          pkg,
 		notification: notification,
 		userPkg:      userPkg,
 		schedule:     schedule,
+		meeting:      meeting,
+		freeze:       freeze,
+		member:       member,
 	}
 }
 
-func (s *bookingService) CreateBooking(userID, packageID, scheduleID string) error {
+func (s *bookingService) CreateBooking(userID, packageID, scheduleID, mode string) error {
 	schedule, err := s.schedule.GetClassScheduleByID(scheduleID)
-	if err != nil {
+	if err != nil || schedule == nil {
 		return customErr.NewNotFound("Class schedule not found")
 	}
+	if schedule.Status != "scheduled" && schedule.Status != "open" {
+		return customErr.NewBadRequest(fmt.Sprintf("Class schedule is %s and can no longer be booked", strings.ReplaceAll(schedule.Status, "_", " ")))
+	}
+
+	mode, err = resolveBookingMode(schedule, mode)
+	if err != nil {
+		return err
+	}
 
 	var userPackage models.UserPackage
 	err = s.userPkg.GetActiveUserPackages(userID, packageID, &userPackage)
 	if err != nil {
 		return customErr.NewNotFound("You don’t have an active package for this class")
 	}
-	if userPackage.RemainingCredit <= 0 {
-		return customErr.NewConflict("Not enough credit")
+
+	// fall back to a package shared with the user when they do not own one
+	var member *models.UserPackageMember
+	if userPackage.ID == uuid.Nil {
+		member, err = s.member.GetActiveMembership(userID, packageID)
+		if err != nil || member == nil {
+			return customErr.NewNotFound("You don’t have an active package for this class")
+		}
+		userPackage = member.UserPackage
+	}
+	frozen, err := s.freeze.IsFrozenOn(userPackage.ID, schedule.Date)
+	if err != nil {
+		return customErr.NewInternal("Failed to check package freeze", err)
+	}
+	if frozen {
+		return customErr.NewBadRequest("Your package is frozen on this date")
+	}
+	if err := s.checkPackageAllowance(&userPackage, schedule); err != nil {
+		return err
+	}
+	if err := s.checkPackageLocation(&userPackage, schedule); err != nil {
+		return err
 	}
 
-	count, err := s.booking.CountBookingBySchedule(schedule.ID.String())
+	count, err := s.booking.CountBookingByScheduleAndMode(schedule.ID.String(), mode)
 	if err != nil {
 		return customErr.NewInternal("Failed to count schedule bookings", err)
 	}
-	if int(count) >= schedule.Capacity {
+	if int(count) >= seatsForMode(schedule, mode) {
+		if schedule.DeliveryMode == "hybrid" {
+			return customErr.NewConflict(fmt.Sprintf("No %s seats left for this class schedule", strings.ReplaceAll(mode, "_", "-")))
+		}
 		return customErr.NewConflict("Class schedule is full")
 	}
 
 	bookingID := uuid.New()
 
-	attendanceID := uuid.New()
-
-	err = s.db.Transaction(func(tx *gorm.DB) error {
-		booking := models.Booking{
-			ID:              bookingID,
-			UserID:          uuid.MustParse(userID),
-			ClassScheduleID: schedule.ID,
-			Status:          "booked",
-		}
-		if err := tx.Create(&booking).Error; err != nil {
-			return err
-		}
-
-		attendance := models.Attendance{
-			ID:        attendanceID,
-			BookingID: booking.ID,
-		}
-		if err := tx.Create(&attendance).Error; err != nil {
-			return err
-		}
+	booking := models.Booking{
+		ID:              bookingID,
+		UserID:          uuid.MustParse(userID),
+		ClassScheduleID: schedule.ID,
+		Status:          "booked",
+		Mode:            mode,
+		UserPackageID:   &userPackage.ID,
+	}
+	var limits repositories.BookingLimits
+	if member != nil {
+		booking.PackageMemberID = &member.ID
+		limits.MemberCap = member.CreditCap
+	}
+	if userPackage.Type == "weekly_limit" {
+		limits.WeeklyLimit = userPackage.WeeklyLimit
+		limits.WeekStart, limits.WeekEnd = utils.WeekRange(schedule.Date)
+	}
 
-		if err := tx.Model(&models.UserPackage{}).
-			Where("id = ?", userPackage.ID).
-			Update("remaining_credit", gorm.Expr("remaining_credit - ?", 1)).Error; err != nil {
-			return err
+	var entry *models.CreditLedgerEntry
+	if userPackage.Type == "credit_pack" {
+		reason := fmt.Sprintf("Booked %s on %s", schedule.ClassName, schedule.Date.Format("2006-01-02"))
+		if member != nil {
+			reason += fmt.Sprintf(" by %s", member.User.Fullname)
 		}
-
-		if err := tx.Model(&models.ClassSchedule{}).
-			Where("id = ?", schedule.ID).
-			Update("booked", gorm.Expr("booked + 1")).Error; err != nil {
-			return err
+		entry = &models.CreditLedgerEntry{
+			UserPackageID: userPackage.ID,
+			Type:          "booking",
+			Amount:        -1,
+			Reason:        reason,
 		}
+	}
 
-		return nil
-	})
+	err = s.booking.BookSchedule(&booking, &models.Attendance{ID: uuid.New()}, entry, limits)
 
+	if errors.Is(err, repositories.ErrInsufficientCredit) {
+		return customErr.NewConflict("Not enough credit")
+	}
+	if errors.Is(err, repositories.ErrWeeklyLimitReached) {
+		return customErr.NewConflict(fmt.Sprintf("You have reached your limit of %d classes for this week", userPackage.WeeklyLimit))
+	}
+	if errors.Is(err, repositories.ErrMemberCapReached) {
+		return customErr.NewConflict("You have used all the credits the package owner allowed you")
+	}
 	if err != nil {
 		return customErr.NewInternal("Failed to create booking", err)
 	}
 
 	// Kirim notifikasi
+	message := fmt.Sprintf(
+		"You have successfully booked the class \"%s\" on %s at %02d:%02d.",
+		schedule.ClassName,
+		schedule.Date.Format("January 2, 2006"),
+		schedule.StartHour,
+		schedule.StartMinute,
+	)
+	if userPackage.Type == "credit_pack" && member != nil {
+		message += fmt.Sprintf(" 1 credit has been deducted from the shared %s package.", userPackage.PackageName)
+	} else if userPackage.Type == "credit_pack" {
+		message += " 1 credit has been deducted from your package."
+	}
 	payload := dto.NotificationEvent{
-		UserID: bookingID.String(),
-		Type:   "system_message",
-		Title:  "Class Booked Successfully",
-		Message: fmt.Sprintf(
-			"You have successfully booked the class \"%s\" on %s at %02d:%02d. 1 credit has been deducted from your package.",
-			schedule.ClassName,
-			schedule.Date.Format("January 2, 2006"),
-			schedule.StartHour,
-			schedule.StartMinute,
-		),
+		UserID:  bookingID.String(),
+		Type:    "system_message",
+		Title:   "Class Booked Successfully",
+		Message: message,
 	}
 	if err := s.notification.SendToUser(payload); err != nil {
 		log.Printf("Failed sending notification to user %s: %v\n", payload.UserID, err)
@@ -150,6 +200,8 @@ func (s *bookingService) GetBookingByUser(userID string, params dto.BookingQuery
 			StartMinute:    schedule.StartMinute,
 			Duration:       schedule.Duration,
 			Location:       schedule.Location,
+			DeliveryMode:   schedule.DeliveryMode,
+			Mode:           b.Mode,
 			IsOpened:       schedule.IsOpened,
 			Date:           schedule.Date.Format("2006-01-02"),
 			BookedAt:       b.CreatedAt.Format(time.RFC3339),
@@ -161,7 +213,7 @@ func (s *bookingService) GetBookingByUser(userID string, params dto.BookingQuery
 
 func (s *bookingService) GetBookingDetail(userID, bookingID string) (*dto.BookingDetailResponse, error) {
 	booking, err := s.booking.GetBookingByID(userID, bookingID)
-	if err != nil {
+	if err != nil || booking == nil {
 		return nil, customErr.NewNotFound("booking not found")
 	}
 
@@ -181,15 +233,35 @@ func (s *bookingService) GetBookingDetail(userID, bookingID string) (*dto.Bookin
 		Duration:         schedule.Duration,
 		CheckedIn:        attendance.CheckedIn,
 		CheckedOut:       attendance.CheckedOut,
+		DeliveryMode:     schedule.DeliveryMode,
+		Mode:             booking.Mode,
 		IsOpened:         schedule.IsOpened,
+		ScheduleStatus:   schedule.Status,
 		IsReviewed:       attendance.IsReviewed,
 		AttendanceStatus: attendance.Status,
 		CheckedAt:        "",
 		VerifiedAt:       "",
 	}
 
-	if schedule.ZoomLink != nil {
-		res.ZoomLink = *schedule.ZoomLink
+	// the meeting link is only shared with online attendees from shortly before start until the class ends
+	if booking.Mode == "online" {
+		start := utils.ScheduleStartTime(schedule.Date, schedule.StartHour, schedule.StartMinute)
+		duration := schedule.Duration
+		if duration <= 0 {
+			duration = 60
+		}
+		opensAt := start.Add(-utils.GetMeetingLinkLeadTime())
+		closesAt := start.Add(time.Duration(duration) * time.Minute)
+		now := time.Now()
+
+		if now.Before(opensAt) {
+			res.LinkAvailableAt = opensAt.Format(time.RFC3339)
+		} else if now.Before(closesAt) {
+			if err := ensureMeetingLink(s.meeting, s.schedule, &schedule); err != nil {
+				log.Printf("Failed creating meeting link for schedule %s: %v\n", schedule.ID, err)
+			}
+			res.ZoomLink = utils.EmptyString(schedule.ZoomLink)
+		}
 	}
 
 	if attendance.CheckedIn && attendance.CheckedAt != nil {
@@ -203,12 +275,82 @@ func (s *bookingService) GetBookingDetail(userID, bookingID string) (*dto.Bookin
 	return res, nil
 }
 
+// checkPackageAllowance applies the booking rule of the package type, credit packs need a credit and
+// memberships need the class to fall within the membership period
+func (s *bookingService) checkPackageAllowance(userPackage *models.UserPackage, schedule *models.ClassSchedule) error {
+	switch userPackage.Type {
+	case "unlimited", "weekly_limit":
+		start := utils.ScheduleStartTime(schedule.Date, schedule.StartHour, schedule.StartMinute)
+		if userPackage.ExpiredAt != nil && start.After(*userPackage.ExpiredAt) {
+			return customErr.NewBadRequest(fmt.Sprintf("Your membership ends on %s, before this class starts", userPackage.ExpiredAt.Format("January 2, 2006")))
+		}
+		// the weekly cap is counted inside the booking transaction, see CreateBooking
+		return nil
+	default:
+		if userPackage.RemainingCredit <= 0 {
+			return customErr.NewConflict("Not enough credit")
+		}
+		return nil
+	}
+}
+
+// checkPackageLocation enforces packages that are only valid at some locations
+func (s *bookingService) checkPackageLocation(userPackage *models.UserPackage, schedule *models.ClassSchedule) error {
+	pkg, err := s.pkg.GetPackageByID(userPackage.PackageID.String())
+	if err != nil {
+		return customErr.NewInternal("Failed to fetch package", err)
+	}
+	if pkg == nil || len(pkg.LocationList) == 0 {
+		return nil
+	}
+
+	locationID, err := s.schedule.GetScheduleLocationID(schedule)
+	if err != nil {
+		return customErr.NewInternal("Failed to fetch class location", err)
+	}
+	if !slices.Contains(pkg.LocationList, locationID.String()) {
+		return customErr.NewBadRequest(fmt.Sprintf("Your %s package cannot be used at %s", userPackage.PackageName, schedule.Location))
+	}
+	return nil
+}
+
+// resolveBookingMode picks how the member attends, only hybrid schedules let the member choose
+func resolveBookingMode(schedule *models.ClassSchedule, requested string) (string, error) {
+	switch schedule.DeliveryMode {
+	case "online":
+		if requested == "in_person" {
+			return "", customErr.NewBadRequest("This class is online only")
+		}
+		return "online", nil
+	case "hybrid":
+		if requested == "" {
+			return "in_person", nil
+		}
+		return requested, nil
+	default:
+		if requested == "online" {
+			return "", customErr.NewBadRequest("This class has no online seats")
+		}
+		return "in_person", nil
+	}
+}
+
+func seatsForMode(schedule *models.ClassSchedule, mode string) int {
+	if mode == "online" && schedule.DeliveryMode == "hybrid" {
+		return schedule.OnlineCapacity
+	}
+	return schedule.Capacity
+}
+
 func (s *bookingService) CheckedInClassSchedule(userID, bookingID string) error {
 	booking, err := s.booking.GetBookingByID(userID, bookingID)
 	if err != nil {
 		return customErr.NewNotFound("booking not found")
 	}
 
+	if err := ensureAttendanceOpen(booking.ClassSchedule); err != nil {
+		return err
+	}
 	if !booking.ClassSchedule.IsOpened {
 		return customErr.NewForbidden("Class schedule is not opened yet")
 	}
@@ -237,6 +379,9 @@ func (s *bookingService) CheckoutClassSchedule(userID, bookingID string, req dto
 	if err != nil {
 		return errors.New("booking not found")
 	}
+	if err := ensureAttendanceOpen(booking.ClassSchedule); err != nil {
+		return err
+	}
 	attendance := booking.Attendance
 
 	if attendance.CheckedOut {
@@ -259,6 +404,17 @@ func (s *bookingService) CheckoutClassSchedule(userID, bookingID string, req dto
 	return nil
 }
 
+// attendance is locked once the schedule reaches a final state
+func ensureAttendanceOpen(schedule models.ClassSchedule) error {
+	switch schedule.Status {
+	case "completed":
+		return customErr.NewForbidden("Class schedule is already completed, attendance is locked")
+	case "canceled":
+		return customErr.NewForbidden("Class schedule has been canceled")
+	}
+	return nil
+}
+
 // ** buat cron job
 func (s *bookingService) MarkAbsentBookings() error {
 	now := time.Now().UTC()
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"server/internal/models"
+	"server/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+// classAt is a class starting after the given time from now, in the studio's time zone like stored schedules
+func classAt(after time.Duration, mode string, capacity, onlineCapacity int) models.ClassSchedule {
+	start := time.Now().In(utils.GetStudioLocation()).Add(after)
+	return models.ClassSchedule{
+		ID:             uuid.New(),
+		ClassName:      "Morning Yoga",
+		InstructorID:   uuid.New(),
+		Date:           time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC),
+		StartHour:      start.Hour(),
+		StartMinute:    start.Minute(),
+		Duration:       60,
+		DeliveryMode:   mode,
+		Capacity:       capacity,
+		OnlineCapacity: onlineCapacity,
+		Status:         "scheduled",
+	}
+}
+
+func TestHybridSeatsAreCountedPerMode(t *testing.T) {
+	db := newStore()
+	pkg := db.addPackage(10)
+	schedules := &fakeScheduleRepo{schedules: []models.ClassSchedule{classAt(72*time.Hour, "hybrid", 2, 1)}}
+	schedule := &schedules.schedules[0]
+	svc := newTestBookingService(db, schedules)
+
+	book := func(mode string) error {
+		user := db.addUser(0)
+		ownedPackage(t, db, user, pkg, 5, 30)
+		return svc.CreateBooking(user.ID.String(), pkg.ID.String(), schedule.ID.String(), mode)
+	}
+
+	// the online seat is taken, the in-person seats are still free
+	if err := book("online"); err != nil {
+		t.Fatalf("online booking: %v", err)
+	}
+	if err := book("online"); err == nil || !strings.Contains(err.Error(), "No online seats left") {
+		t.Fatalf("err = %v, want the online seats full", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := book("in_person"); err != nil {
+			t.Fatalf("in-person booking %d: %v", i+1, err)
+		}
+	}
+	if err := book(""); err == nil || !strings.Contains(err.Error(), "No in-person seats left") {
+		t.Fatalf("err = %v, want the in-person seats full", err)
+	}
+
+	if schedule.Booked != 3 || schedule.OnlineBooked != 1 {
+		t.Fatalf("booked %d online %d, want 3 and 1", schedule.Booked, schedule.OnlineBooked)
+	}
+}
+
+func TestBookingModeFollowsTheDeliveryMode(t *testing.T) {
+	tests := []struct {
+		name      string
+		delivery  string
+		requested string
+		want      string
+		wantErr   string
+	}{
+		{name: "in-person class", delivery: "in_person", want: "in_person"},
+		{name: "online seat in an in-person class", delivery: "in_person", requested: "online", wantErr: "no online seats"},
+		{name: "online class", delivery: "online", want: "online"},
+		{name: "in-person seat in an online class", delivery: "online", requested: "in_person", wantErr: "online only"},
+		{name: "hybrid defaults to in person", delivery: "hybrid", want: "in_person"},
+		{name: "hybrid online", delivery: "hybrid", requested: "online", want: "online"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := classAt(72*time.Hour, tt.delivery, 10, 5)
+			mode, err := resolveBookingMode(&schedule, tt.requested)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || mode != tt.want {
+				t.Fatalf("mode = %q, %v, want %q", mode, err, tt.want)
+			}
+		})
+	}
+}
+
+// the link is shared from MEETING_LINK_LEAD_MINUTES before the start until the class ends, and only to online attendees
+func TestMeetingLinkWindow(t *testing.T) {
+	t.Setenv("MEETING_LINK_LEAD_MINUTES", "15")
+
+	tests := []struct {
+		name          string
+		startsIn      time.Duration
+		mode          string
+		wantLink      bool
+		wantAvailable bool
+	}{
+		{name: "before the lead time", startsIn: 2 * time.Hour, mode: "online", wantAvailable: true},
+		{name: "within the lead time", startsIn: 10 * time.Minute, mode: "online", wantLink: true},
+		{name: "during the class", startsIn: -30 * time.Minute, mode: "online", wantLink: true},
+		{name: "after the class", startsIn: -2 * time.Hour, mode: "online"},
+		{name: "in-person attendee", startsIn: 10 * time.Minute, mode: "in_person"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newStore()
+			user := db.addUser(0)
+			schedules := &fakeScheduleRepo{schedules: []models.ClassSchedule{classAt(tt.startsIn, "hybrid", 10, 5)}}
+			booking := models.Booking{ID: uuid.New(), UserID: user.ID, ClassScheduleID: schedules.schedules[0].ID, Status: "booked", Mode: tt.mode}
+			db.bookings = append(db.bookings, booking)
+
+			detail, err := newTestBookingService(db, schedules).GetBookingDetail(user.ID.String(), booking.ID.String())
+			if err != nil {
+				t.Fatalf("detail: %v", err)
+			}
+			if (detail.ZoomLink != "") != tt.wantLink {
+				t.Fatalf("link = %q, want a link %v", detail.ZoomLink, tt.wantLink)
+			}
+			if (detail.LinkAvailableAt != "") != tt.wantAvailable {
+				t.Fatalf("available at = %q, want it set %v", detail.LinkAvailableAt, tt.wantAvailable)
+			}
+			// the meeting room is created on first use and kept on the schedule
+			if tt.wantLink && schedules.schedules[0].ZoomLink == nil {
+				t.Fatalf("the meeting link was not stored on the schedule")
+			}
+		})
+	}
+}
+
+func TestSharedPackageBookingsStayWithinTheMemberCap(t *testing.T) {
+	db := newStore()
+	pkg := db.addPackage(10)
+	owner := db.addUser(0)
+	capped := db.addUser(0)
+	uncapped := db.addUser(0)
+	shared := ownedPackage(t, db, owner, pkg, 10, 30)
+	creditCap := 2
+	db.members = append(db.members,
+		models.UserPackageMember{ID: uuid.New(), UserPackageID: shared.ID, UserID: capped.ID, Status: "active", CreditCap: &creditCap},
+		models.UserPackageMember{ID: uuid.New(), UserPackageID: shared.ID, UserID: uncapped.ID, Status: "active"},
+	)
+	schedules := &fakeScheduleRepo{}
+	for i := 0; i < 8; i++ {
+		schedules.schedules = append(schedules.schedules, classAt(time.Duration(72+i)*time.Hour, "in_person", 10, 0))
+	}
+	svc := newTestBookingService(db, schedules)
+	next := 0
+	book := func(user *models.User) error {
+		next++
+		return svc.CreateBooking(user.ID.String(), pkg.ID.String(), schedules.schedules[next-1].ID.String(), "")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := book(capped); err != nil {
+			t.Fatalf("booking %d within the cap: %v", i+1, err)
+		}
+	}
+	if err := book(capped); err == nil || !strings.Contains(err.Error(), "used all the credits the package owner allowed") {
+		t.Fatalf("err = %v, want the cap reached", err)
+	}
+	if shared.RemainingCredit != 8 {
+		t.Fatalf("shared package = %d credits, want 8 after the rejected booking", shared.RemainingCredit)
+	}
+
+	// other members and the owner share what is left without the cap
+	for _, user := range []*models.User{uncapped, uncapped, uncapped, owner} {
+		if err := book(user); err != nil {
+			t.Fatalf("booking by %s: %v", user.ID, err)
+		}
+	}
+	if shared.RemainingCredit != 4 {
+		t.Fatalf("shared package = %d credits, want 4", shared.RemainingCredit)
+	}
+
+	// a refunded booking gives the member room under the cap again
+	refunded := db.bookings[0]
+	if err := db.applyLedger(&models.CreditLedgerEntry{UserPackageID: shared.ID, Type: "refund", Amount: 1, BookingID: &refunded.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := book(capped); err != nil {
+		t.Fatalf("booking after a refund: %v", err)
+	}
+	for _, booking := range db.bookings {
+		if booking.UserID == capped.ID && (booking.PackageMemberID == nil || *booking.UserPackageID != shared.ID) {
+			t.Fatalf("member booking %s is not recorded against the shared package and membership", booking.ID)
+		}
+	}
+}
+
+func TestMemberBooksWithTheirOwnPackageFirst(t *testing.T) {
+	db := newStore()
+	pkg := db.addPackage(10)
+	owner := db.addUser(0)
+	member := db.addUser(0)
+	invited := db.addUser(0)
+	shared := ownedPackage(t, db, owner, pkg, 10, 30)
+	db.members = append(db.members,
+		models.UserPackageMember{ID: uuid.New(), UserPackageID: shared.ID, UserID: member.ID, Status: "active"},
+		models.UserPackageMember{ID: uuid.New(), UserPackageID: shared.ID, UserID: invited.ID, Status: "invited"},
+	)
+	schedules := &fakeScheduleRepo{schedules: []models.ClassSchedule{
+		classAt(72*time.Hour, "in_person", 10, 0),
+		classAt(96*time.Hour, "in_person", 10, 0),
+	}}
+	svc := newTestBookingService(db, schedules)
+
+	// without a package of their own the member books on the owner's package
+	if err := svc.CreateBooking(member.ID.String(), pkg.ID.String(), schedules.schedules[0].ID.String(), ""); err != nil {
+		t.Fatalf("booking on the shared package: %v", err)
+	}
+	if shared.RemainingCredit != 9 {
+		t.Fatalf("shared package = %d credits, want 9", shared.RemainingCredit)
+	}
+
+	// once they buy their own, that one is used and the owner's is left alone
+	own := ownedPackage(t, db, member, pkg, 5, 30)
+	if err := svc.CreateBooking(member.ID.String(), pkg.ID.String(), schedules.schedules[1].ID.String(), ""); err != nil {
+		t.Fatalf("booking on their own package: %v", err)
+	}
+	if own.RemainingCredit != 4 || shared.RemainingCredit != 9 {
+		t.Fatalf("own %d shared %d, want 4 and 9", own.RemainingCredit, shared.RemainingCredit)
+	}
+	if last := db.bookings[len(db.bookings)-1]; last.PackageMemberID != nil {
+		t.Fatalf("a booking on the member's own package is recorded as a shared booking")
+	}
+
+	// an invitation that was not accepted does not share the package
+	err := svc.CreateBooking(invited.ID.String(), pkg.ID.String(), schedules.schedules[0].ID.String(), "")
+	if err == nil || !strings.Contains(err.Error(), "have an active package") {
+		t.Fatalf("err = %v, want no package for an invited user", err)
+	}
+}
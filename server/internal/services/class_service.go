@@ -56,6 +56,7 @@ func (s *classService) CreateClass(req dto.CreateClassRequest) error {
 		Title:          req.Title,
 		Image:          req.ImageURL,
 		Duration:       req.Duration,
+		DeliveryMode:   resolveDeliveryMode(req.DeliveryMode, ""),
 		Description:    req.Description,
 		AdditionalList: req.Additional,
 		TypeID:         typeID,
@@ -92,6 +93,7 @@ func (s *classService) UpdateClass(id string, req dto.UpdateClassRequest) error
 	class.IsActive = req.IsActive
 	class.Duration = req.Duration
 	class.Description = req.Description
+	utils.SetIfNotEmpty(&class.DeliveryMode, req.DeliveryMode)
 
 	if len(req.Additional) > 0 {
 		class.AdditionalList = req.Additional
@@ -178,20 +180,21 @@ func (s *classService) GetClassByID(id string) (*dto.ClassDetailResponse, error)
 	}
 
 	return &dto.ClassDetailResponse{
-		ID:          class.ID.String(),
-		Title:       class.Title,
-		Image:       class.Image,
-		IsActive:    class.IsActive,
-		Duration:    class.Duration,
-		Description: class.Description,
-		Additional:  class.AdditionalList,
-		Type:        class.Type.Name,
-		Level:       class.Level.Name,
-		Location:    class.Location.Name,
-		Category:    class.Category.Name,
-		Subcategory: class.Subcategory.Name,
-		Galleries:   galleries,
-		CreatedAt:   class.CreatedAt.Format("2006-01-02"),
+		ID:           class.ID.String(),
+		Title:        class.Title,
+		Image:        class.Image,
+		IsActive:     class.IsActive,
+		Duration:     class.Duration,
+		DeliveryMode: class.DeliveryMode,
+		Description:  class.Description,
+		Additional:   class.AdditionalList,
+		Type:         class.Type.Name,
+		Level:        class.Level.Name,
+		Location:     class.Location.Name,
+		Category:     class.Category.Name,
+		Subcategory:  class.Subcategory.Name,
+		Galleries:    galleries,
+		CreatedAt:    class.CreatedAt.Format("2006-01-02"),
 	}, nil
 }
 
@@ -213,6 +216,7 @@ func (s *classService) GetAllClasses(params dto.ClassQueryParam) ([]dto.ClassRes
 			Image:         c.Image,
 			IsActive:      c.IsActive,
 			Duration:      c.Duration,
+			DeliveryMode:  c.DeliveryMode,
 			Galleries:     galleries,
 			Description:   c.Description,
 			Additional:    c.AdditionalList,
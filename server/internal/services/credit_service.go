@@ -0,0 +1,297 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	customErr "server/pkg/errors"
+	"server/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreditService interface {
+	// customer
+	GetMyCreditHistory(userID string, params dto.CreditHistoryQueryParam) ([]dto.CreditLedgerEntryResponse, *dto.PaginationResponse, error)
+	TransferCredit(userID string, req dto.TransferCreditRequest) (*dto.CreditLedgerEntryResponse, error)
+
+	// admin
+	GetReconciliation(userPackageID string) (*dto.CreditReconciliationResponse, error)
+	AdjustCredit(adminID, userPackageID string, req dto.AdjustCreditRequest) (*dto.CreditLedgerEntryResponse, error)
+	ReconcileLedger(adminID, userPackageID string, req dto.ReconcileCreditRequest) (*dto.CreditReconciliationResponse, error)
+}
+
+type creditService struct {
+	ledger  repositories.CreditLedgerRepository
+	userPkg repositories.UserPackageRepository
+	pkg     repositories.PackageRepository
+	auth    repositories.AuthRepository
+	notif   NotificationService
+}
+
+func NewCreditService(ledger repositories.CreditLedgerRepository, userPkg repositories.UserPackageRepository, pkg repositories.PackageRepository, auth repositories.AuthRepository, notif NotificationService) CreditService {
+	return &creditService{
+		ledger:  ledger,
+		userPkg: userPkg,
+		pkg:     pkg,
+		auth:    auth,
+		notif:   notif,
+	}
+}
+
+func (s *creditService) GetMyCreditHistory(userID string, params dto.CreditHistoryQueryParam) ([]dto.CreditLedgerEntryResponse, *dto.PaginationResponse, error) {
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.Limit <= 0 {
+		params.Limit = 10
+	}
+
+	entries, total, err := s.ledger.GetEntriesByUserID(userID, params)
+	if err != nil {
+		return nil, nil, customErr.NewInternal("failed to fetch credit history", err)
+	}
+
+	result := make([]dto.CreditLedgerEntryResponse, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, toCreditLedgerEntryResponse(e, e.UserPackage.PackageName))
+	}
+
+	pagination := utils.Paginate(total, params.Page, params.Limit)
+	return result, pagination, nil
+}
+
+// TransferCredit moves unused credits to another member's package of the same kind, up to the
+// transfer limit the admin set on the package, the credits keep the expiry of the source package so they
+// only join the recipient's package when it expires at the same time and get a package of their own otherwise
+func (s *creditService) TransferCredit(userID string, req dto.TransferCreditRequest) (*dto.CreditLedgerEntryResponse, error) {
+	source, err := s.userPkg.GetUserPackageByID(req.UserPackageID)
+	if err != nil || source == nil || source.UserID.String() != userID {
+		return nil, customErr.NewNotFound("user package not found")
+	}
+	if source.Type != "credit_pack" {
+		return nil, customErr.NewBadRequest("only credit packs can be transferred")
+	}
+	if source.ExpiredAt != nil && !source.ExpiredAt.After(time.Now()) {
+		return nil, customErr.NewBadRequest("package has expired")
+	}
+
+	pkg, err := s.pkg.GetPackageByID(source.PackageID.String())
+	if err != nil || pkg == nil {
+		return nil, customErr.NewNotFound("package not found")
+	}
+	if pkg.MaxTransfer <= 0 {
+		return nil, customErr.NewBadRequest("credits of this package cannot be transferred")
+	}
+
+	recipient, err := s.auth.GetUserByEmail(strings.TrimSpace(req.Email))
+	if err != nil || recipient == nil || recipient.Role != "customer" {
+		return nil, customErr.NewNotFound("no member found with this email")
+	}
+	if recipient.ID == source.UserID {
+		return nil, customErr.NewBadRequest("you cannot transfer credits to yourself")
+	}
+
+	var target models.UserPackage
+	if err := s.userPkg.GetActiveUserPackages(recipient.ID.String(), source.PackageID.String(), &target); err != nil {
+		return nil, customErr.NewInternal("failed checking recipient package", err)
+	}
+	if target.ID == uuid.Nil || !sameExpiry(target.ExpiredAt, source.ExpiredAt) {
+		target = models.UserPackage{
+			UserID:      recipient.ID,
+			PackageID:   source.PackageID,
+			PackageName: source.PackageName,
+			Type:        source.Type,
+			ExpiredAt:   source.ExpiredAt,
+			PurchasedAt: time.Now(),
+		}
+	}
+
+	sender := uuid.MustParse(userID)
+	debit := models.CreditLedgerEntry{
+		UserPackageID: source.ID,
+		Type:          "transfer",
+		Amount:        -req.Credits,
+		Reason:        fmt.Sprintf("Transferred to %s", recipient.Email),
+		CreatedBy:     &sender,
+	}
+	credit := models.CreditLedgerEntry{
+		Type:      "transfer",
+		Amount:    req.Credits,
+		Reason:    fmt.Sprintf("Received from another member's %s", source.PackageName),
+		CreatedBy: &sender,
+	}
+	if err := s.userPkg.TransferCredits(&target, &debit, &credit, pkg.MaxTransfer); err != nil {
+		if errors.Is(err, repositories.ErrTransferLimitReached) {
+			transferred, err := s.ledger.GetTransferredOut(source.ID.String())
+			if err != nil {
+				return nil, customErr.NewInternal("failed to check transferred credits", err)
+			}
+			return nil, customErr.NewBadRequest(fmt.Sprintf("this package allows transferring %d credits, %d remaining", pkg.MaxTransfer, pkg.MaxTransfer-transferred))
+		}
+		if errors.Is(err, repositories.ErrInsufficientCredit) {
+			return nil, customErr.NewBadRequest(fmt.Sprintf("cannot transfer %d credits, only %d remaining", req.Credits, source.RemainingCredit))
+		}
+		return nil, customErr.NewInternal("failed to transfer credits", err)
+	}
+
+	message := fmt.Sprintf("You received %d %s credits. Your balance is now %d.", req.Credits, source.PackageName, credit.BalanceAfter)
+	if target.ExpiredAt != nil {
+		message = fmt.Sprintf("You received %d %s credits, they expire on %s. Your balance on this package is now %d.",
+			req.Credits, source.PackageName, target.ExpiredAt.Format("January 2, 2006"), credit.BalanceAfter)
+	}
+	payload := dto.NotificationEvent{
+		UserID:  recipient.ID.String(),
+		Type:    "system_message",
+		Title:   "Credits Received",
+		Message: message,
+	}
+	if err := s.notif.SendToUser(payload); err != nil {
+		log.Printf("failed sending credit transfer notification to user %s: %v\n", payload.UserID, err)
+	}
+
+	resp := toCreditLedgerEntryResponse(debit, source.PackageName)
+	return &resp, nil
+}
+
+func sameExpiry(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// GetReconciliation compares the stored balance with the sum of the ledger entries
+func (s *creditService) GetReconciliation(userPackageID string) (*dto.CreditReconciliationResponse, error) {
+	userPackage, err := s.userPkg.GetUserPackageByID(userPackageID)
+	if err != nil || userPackage == nil {
+		return nil, customErr.NewNotFound("user package not found")
+	}
+
+	entries, err := s.ledger.GetEntriesByUserPackageID(userPackageID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch credit ledger", err)
+	}
+
+	ledgerBalance := 0
+	result := make([]dto.CreditLedgerEntryResponse, 0, len(entries))
+	for _, e := range entries {
+		ledgerBalance += e.Amount
+		result = append(result, toCreditLedgerEntryResponse(e, userPackage.PackageName))
+	}
+
+	return &dto.CreditReconciliationResponse{
+		UserPackageID: userPackage.ID.String(),
+		StoredBalance: userPackage.RemainingCredit,
+		LedgerBalance: ledgerBalance,
+		Difference:    userPackage.RemainingCredit - ledgerBalance,
+		Reconciled:    userPackage.RemainingCredit == ledgerBalance,
+		Entries:       result,
+	}, nil
+}
+
+func (s *creditService) AdjustCredit(adminID, userPackageID string, req dto.AdjustCreditRequest) (*dto.CreditLedgerEntryResponse, error) {
+	userPackage, err := s.userPkg.GetUserPackageByID(userPackageID)
+	if err != nil || userPackage == nil {
+		return nil, customErr.NewNotFound("user package not found")
+	}
+
+	if userPackage.Type != "credit_pack" {
+		return nil, customErr.NewBadRequest("credits can only be adjusted on credit packs")
+	}
+
+	entryType := req.Type
+	if entryType == "" {
+		entryType = "adjustment"
+	}
+	if entryType == "penalty" && req.Amount > 0 {
+		return nil, customErr.NewBadRequest("a penalty must deduct credits")
+	}
+
+	admin := uuid.MustParse(adminID)
+	entry := models.CreditLedgerEntry{
+		UserPackageID: userPackage.ID,
+		Type:          entryType,
+		Amount:        req.Amount,
+		Reason:        req.Reason,
+		CreatedBy:     &admin,
+	}
+
+	if err := s.ledger.ApplyEntry(&entry); err != nil {
+		if errors.Is(err, repositories.ErrInsufficientCredit) {
+			return nil, customErr.NewBadRequest(fmt.Sprintf("cannot deduct %d credits, only %d remaining", -req.Amount, userPackage.RemainingCredit))
+		}
+		return nil, customErr.NewInternal("failed to adjust credit", err)
+	}
+
+	payload := dto.NotificationEvent{
+		UserID:  userPackage.UserID.String(),
+		Type:    "system_message",
+		Title:   "Package Credit Updated",
+		Message: fmt.Sprintf("Your %s credit was changed by %+d: %s. Your balance is now %d.", userPackage.PackageName, req.Amount, req.Reason, entry.BalanceAfter),
+	}
+	if err := s.notif.SendToUser(payload); err != nil {
+		log.Printf("failed sending credit adjustment notification to user %s: %v\n", payload.UserID, err)
+	}
+
+	resp := toCreditLedgerEntryResponse(entry, userPackage.PackageName)
+	return &resp, nil
+}
+
+// ReconcileLedger records the difference between the stored balance and the ledger as an adjustment
+// without changing the balance, mainly for packages purchased before the ledger existed
+func (s *creditService) ReconcileLedger(adminID, userPackageID string, req dto.ReconcileCreditRequest) (*dto.CreditReconciliationResponse, error) {
+	userPackage, err := s.userPkg.GetUserPackageByID(userPackageID)
+	if err != nil || userPackage == nil {
+		return nil, customErr.NewNotFound("user package not found")
+	}
+
+	ledgerBalance, err := s.ledger.GetLedgerBalance(userPackageID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to calculate ledger balance", err)
+	}
+
+	difference := userPackage.RemainingCredit - ledgerBalance
+	if difference == 0 {
+		return nil, customErr.NewBadRequest("ledger is already reconciled")
+	}
+
+	admin := uuid.MustParse(adminID)
+	entry := models.CreditLedgerEntry{
+		UserPackageID: userPackage.ID,
+		Type:          "adjustment",
+		Amount:        difference,
+		Reason:        req.Reason,
+		CreatedBy:     &admin,
+	}
+	if err := s.ledger.RecordReconciliation(&entry); err != nil {
+		return nil, customErr.NewInternal("failed to reconcile ledger", err)
+	}
+
+	return s.GetReconciliation(userPackageID)
+}
+
+func toCreditLedgerEntryResponse(e models.CreditLedgerEntry, packageName string) dto.CreditLedgerEntryResponse {
+	resp := dto.CreditLedgerEntryResponse{
+		ID:            e.ID.String(),
+		UserPackageID: e.UserPackageID.String(),
+		PackageName:   packageName,
+		Type:          e.Type,
+		Amount:        e.Amount,
+		BalanceAfter:  e.BalanceAfter,
+		Reason:        e.Reason,
+		CreatedAt:     e.CreatedAt.Format(time.RFC3339),
+	}
+	if e.PaymentID != nil {
+		resp.PaymentID = e.PaymentID.String()
+	}
+	if e.BookingID != nil {
+		resp.BookingID = e.BookingID.String()
+	}
+	return resp
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"server/internal/dto"
+	"server/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ownedPackage gives user a credit pack of pkg with credits that expires after days
+func ownedPackage(t *testing.T, db *store, user *models.User, pkg *models.Package, credits, days int) *models.UserPackage {
+	t.Helper()
+	expiredAt := time.Now().UTC().Truncate(time.Second).AddDate(0, 0, days)
+	userPackage := &models.UserPackage{ID: uuid.New(), UserID: user.ID, PackageID: pkg.ID, PackageName: pkg.Name, Type: "credit_pack", ExpiredAt: &expiredAt}
+	db.userPackages[userPackage.ID] = userPackage
+	if err := db.applyLedger(&models.CreditLedgerEntry{UserPackageID: userPackage.ID, UserID: user.ID, Type: "purchase", Amount: credits}); err != nil {
+		t.Fatal(err)
+	}
+	return userPackage
+}
+
+func TestTransferredCreditsKeepTheSourceExpiry(t *testing.T) {
+	db := newStore()
+	pkg := db.addPackage(10)
+	pkg.MaxTransfer = 6
+	sender := db.addUser(0)
+	recipient := db.addUser(0)
+	recipient.Email = "friend@example.com"
+	source := ownedPackage(t, db, sender, pkg, 10, 10)
+	// the recipient's own pack of the same kind runs much longer
+	own := ownedPackage(t, db, recipient, pkg, 2, 60)
+	svc := NewCreditService(&fakeLedgerRepo{db: db}, &fakeUserPackageRepo{db: db}, &fakePackageRepo{db: db}, &fakeAuthRepo{db: db}, &fakeNotificationService{db: db})
+
+	transfer := func(credits int) {
+		t.Helper()
+		req := dto.TransferCreditRequest{UserPackageID: source.ID.String(), Email: recipient.Email, Credits: credits}
+		if _, err := svc.TransferCredit(sender.ID.String(), req); err != nil {
+			t.Fatalf("transfer: %v", err)
+		}
+	}
+	transfer(3)
+
+	if own.RemainingCredit != 2 {
+		t.Fatalf("recipient's own pack = %d credits, the transfer must not take its longer expiry", own.RemainingCredit)
+	}
+	var received *models.UserPackage
+	for _, userPackage := range db.userPackages {
+		if userPackage.UserID == recipient.ID && userPackage.ID != own.ID {
+			received = userPackage
+		}
+	}
+	if received == nil || received.RemainingCredit != 3 || !received.ExpiredAt.Equal(*source.ExpiredAt) {
+		t.Fatalf("received package = %+v, want 3 credits expiring with the source", received)
+	}
+	last := db.notifications[len(db.notifications)-1]
+	if !strings.Contains(last.Message, source.ExpiredAt.Format("January 2, 2006")) {
+		t.Fatalf("notification %q does not tell when the credits expire", last.Message)
+	}
+
+	// a second transfer joins the package with the same expiry
+	transfer(2)
+	if received.RemainingCredit != 5 || own.RemainingCredit != 2 || len(db.userPackages) != 3 {
+		t.Fatalf("second transfer = %d received, %d own, %d packages, want 5, 2 and 3", received.RemainingCredit, own.RemainingCredit, len(db.userPackages))
+	}
+
+	// the limit of 6 leaves one more credit to transfer
+	req := dto.TransferCreditRequest{UserPackageID: source.ID.String(), Email: recipient.Email, Credits: 2}
+	if _, err := svc.TransferCredit(sender.ID.String(), req); err == nil || !strings.Contains(err.Error(), "1 remaining") {
+		t.Fatalf("transfer over the limit = %v, want it rejected with 1 remaining", err)
+	}
+	if received.RemainingCredit != 5 {
+		t.Fatalf("received = %d after a rejected transfer, want 5", received.RemainingCredit)
+	}
+}
+
+func TestReconciliationComparesTheStoredBalanceWithTheLedger(t *testing.T) {
+	db := newStore()
+	pkg := db.addPackage(10)
+	user := db.addUser(0)
+	userPackage := ownedPackage(t, db, user, pkg, 10, 30)
+	svc := NewCreditService(&fakeLedgerRepo{db: db}, &fakeUserPackageRepo{db: db}, &fakePackageRepo{db: db}, &fakeAuthRepo{db: db}, &fakeNotificationService{db: db})
+	admin := uuid.NewString()
+
+	report, err := svc.GetReconciliation(userPackage.ID.String())
+	if err != nil {
+		t.Fatalf("reconciliation: %v", err)
+	}
+	if !report.Reconciled || report.StoredBalance != 10 || report.LedgerBalance != 10 || len(report.Entries) != 1 {
+		t.Fatalf("report = %+v, want 10 stored and in the ledger", report)
+	}
+
+	// a package sold before the ledger existed holds more than its entries add up to
+	userPackage.RemainingCredit = 13
+	report, err = svc.GetReconciliation(userPackage.ID.String())
+	if err != nil {
+		t.Fatalf("reconciliation: %v", err)
+	}
+	if report.Reconciled || report.Difference != 3 {
+		t.Fatalf("report = %+v, want a difference of 3", report)
+	}
+
+	report, err = svc.ReconcileLedger(admin, userPackage.ID.String(), dto.ReconcileCreditRequest{Reason: "bought before the ledger"})
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if !report.Reconciled || report.StoredBalance != 13 || report.LedgerBalance != 13 {
+		t.Fatalf("report = %+v, want the ledger brought up to the stored 13", report)
+	}
+	if last := db.ledger[len(db.ledger)-1]; last.Type != "adjustment" || last.Amount != 3 || last.BalanceAfter != 13 {
+		t.Fatalf("reconciliation entry = %s %d balance %d, want adjustment 3 balance 13", last.Type, last.Amount, last.BalanceAfter)
+	}
+
+	// nothing is left to reconcile the second time
+	if _, err := svc.ReconcileLedger(admin, userPackage.ID.String(), dto.ReconcileCreditRequest{Reason: "again"}); err == nil || !strings.Contains(err.Error(), "already reconciled") {
+		t.Fatalf("second reconcile = %v, want it rejected", err)
+	}
+	if len(db.ledger) != 2 {
+		t.Fatalf("%d ledger entries, the second reconcile must not add one", len(db.ledger))
+	}
+}
+
+func TestAdjustCredit(t *testing.T) {
+	tests := []struct {
+		name    string
+		pkgType string
+		req     dto.AdjustCreditRequest
+		want    int
+		wantErr string
+	}{
+		{name: "goodwill credit", req: dto.AdjustCreditRequest{Amount: 2, Reason: "instructor was late"}, want: 7},
+		{name: "correction", req: dto.AdjustCreditRequest{Amount: -1, Type: "adjustment", Reason: "double refund"}, want: 4},
+		{name: "penalty", req: dto.AdjustCreditRequest{Amount: -1, Type: "penalty", Reason: "no show"}, want: 4},
+		{name: "positive penalty", req: dto.AdjustCreditRequest{Amount: 1, Type: "penalty", Reason: "no show"}, want: 5, wantErr: "a penalty must deduct credits"},
+		{name: "deducting more than left", req: dto.AdjustCreditRequest{Amount: -6, Reason: "typo"}, want: 5, wantErr: "only 5 remaining"},
+		{name: "membership", pkgType: "unlimited", req: dto.AdjustCreditRequest{Amount: 1, Reason: "goodwill"}, want: 5, wantErr: "only be adjusted on credit packs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newStore()
+			user := db.addUser(0)
+			userPackage := ownedPackage(t, db, user, db.addPackage(5), 5, 30)
+			if tt.pkgType != "" {
+				userPackage.Type = tt.pkgType
+			}
+			svc := NewCreditService(&fakeLedgerRepo{db: db}, &fakeUserPackageRepo{db: db}, &fakePackageRepo{db: db}, &fakeAuthRepo{db: db}, &fakeNotificationService{db: db})
+
+			entry, err := svc.AdjustCredit(uuid.NewString(), userPackage.ID.String(), tt.req)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("adjust: %v", err)
+			} else if entry.BalanceAfter != tt.want {
+				t.Fatalf("balance after = %d, want %d", entry.BalanceAfter, tt.want)
+			}
+			if userPackage.RemainingCredit != tt.want {
+				t.Fatalf("remaining = %d, want %d", userPackage.RemainingCredit, tt.want)
+			}
+
+			// every change goes through the ledger, so the stored balance stays reconciled
+			report, err := svc.GetReconciliation(userPackage.ID.String())
+			if err != nil || !report.Reconciled {
+				t.Fatalf("reconciliation = %+v, %v, want the ledger to agree", report, err)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 type DashboardService interface {
 	GetSummary() (*dto.DashboardSummaryResponse, error)
 	GetRevenueStats(rangeType string) (*dto.RevenueStatsResponse, error)
+	GetForfeitedCredits() (*dto.ForfeitedCreditsResponse, error)
 }
 
 type dashboardService struct {
@@ -50,9 +51,37 @@ func (s *dashboardService) GetRevenueStats(rangeType string) (*dto.RevenueStatsR
 		return nil, customErr.NewInternal("failed to fetch revenue", err)
 	}
 
+	refunded := 0.0
+	for _, st := range stats {
+		refunded += st.Refunded
+	}
+
 	return &dto.RevenueStatsResponse{
 		Range:         rangeType,
 		TotalRevenue:  total,
+		TotalRefunded: refunded,
 		RevenueSeries: stats,
 	}, nil
 }
+
+func (s *dashboardService) GetForfeitedCredits() (*dto.ForfeitedCreditsResponse, error) {
+	stats, err := s.repo.GetForfeitedCreditsByMonth()
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch forfeited credits", err)
+	}
+
+	total, restored := 0, 0
+	for _, st := range stats {
+		total += st.Credits
+		restored += st.Restored
+	}
+	if stats == nil {
+		stats = []dto.ForfeitedCreditStat{}
+	}
+
+	return &dto.ForfeitedCreditsResponse{
+		TotalCredits:  total,
+		TotalRestored: restored,
+		Series:        stats,
+	}, nil
+}
@@ -0,0 +1,979 @@
+package services
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	"server/pkg/gateway"
+	"server/pkg/meeting"
+	"server/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// store is the in-memory database behind the fake repositories, each fake only implements what the services
+// under test call and panics on anything else through the embedded interface
+type store struct {
+	mu            sync.Mutex
+	payments      map[uuid.UUID]*models.Payment
+	users         map[uuid.UUID]*models.User
+	packages      map[uuid.UUID]*models.Package
+	userPackages  map[uuid.UUID]*models.UserPackage
+	wallet        []models.WalletTransaction
+	ledger        []models.CreditLedgerEntry
+	refunds       []models.PaymentRefund
+	discrepancies []models.PaymentDiscrepancy
+	notifications []dto.NotificationEvent
+	invoices      []gateway.Invoice
+	ended         []string
+	webhooks      []models.WebhookEvent
+	bookings      []models.Booking
+	members       []models.UserPackageMember
+	freezes       []models.UserPackageFreeze
+	taxes         []models.PaymentTax
+}
+
+func newStore() *store {
+	return &store{
+		payments:     map[uuid.UUID]*models.Payment{},
+		users:        map[uuid.UUID]*models.User{},
+		packages:     map[uuid.UUID]*models.Package{},
+		userPackages: map[uuid.UUID]*models.UserPackage{},
+	}
+}
+
+func (s *store) addUser(balance int64) *models.User {
+	user := &models.User{ID: uuid.New(), Role: "customer", Fullname: "Member", Email: "member@example.com", WalletBalance: balance}
+	s.users[user.ID] = user
+	return user
+}
+
+func (s *store) addPackage(credit int) *models.Package {
+	pkg := &models.Package{ID: uuid.New(), Name: "10 Class Pack", Type: "credit_pack", Credit: credit, Expired: 30}
+	s.packages[pkg.ID] = pkg
+	return pkg
+}
+
+// addPayment stores a checkout opened on the fake gateway, the wallet share is taken the way CreatePayment does
+func (s *store) addPayment(t *testing.T, gw *gateway.FakeGateway, user *models.User, pkg *models.Package, total, wallet int64) *models.Payment {
+	t.Helper()
+	payment := &models.Payment{
+		ID:            uuid.New(),
+		PackageID:     pkg.ID,
+		PackageName:   pkg.Name,
+		UserID:        user.ID,
+		Fullname:      user.Fullname,
+		Email:         user.Email,
+		PaymentMethod: gateway.MethodCard,
+		Status:        "pending",
+		PaidAt:        time.Now().UTC().Add(-time.Hour),
+		Currency:      "IDR",
+		BasePrice:     total,
+		Total:         total,
+		Purpose:       "package",
+		Gateway:       gw.Name(),
+		WalletAmount:  wallet,
+	}
+	if charged := total - wallet; charged > 0 {
+		checkout, err := gw.CreateCheckout(gateway.CheckoutRequest{
+			Reference: payment.ID.String(),
+			Method:    gateway.MethodCard,
+			LineItems: []gateway.LineItem{{Name: pkg.Name, Amount: money.New(charged, "IDR"), Quantity: 1}},
+		})
+		if err != nil {
+			t.Fatalf("create checkout: %v", err)
+		}
+		payment.ProviderSessionID = &checkout.SessionID
+	}
+	s.payments[payment.ID] = payment
+	if wallet > 0 {
+		if err := s.applyWallet(models.WalletTransaction{UserID: user.ID, Type: "payment", Amount: -wallet, Currency: "IDR", PaymentID: &payment.ID}); err != nil {
+			t.Fatalf("take wallet share: %v", err)
+		}
+	}
+	return payment
+}
+
+func (s *store) applyWallet(txn models.WalletTransaction) error {
+	user := s.users[txn.UserID]
+	if txn.Amount < 0 && user.WalletBalance+txn.Amount < 0 {
+		return repositories.ErrInsufficientWallet
+	}
+	user.WalletBalance += txn.Amount
+	txn.BalanceAfter = user.WalletBalance
+	s.wallet = append(s.wallet, txn)
+	return nil
+}
+
+func (s *store) hold(paymentID uuid.UUID) int64 {
+	var net int64
+	for _, txn := range s.wallet {
+		if txn.PaymentID != nil && *txn.PaymentID == paymentID && (txn.Type == "payment" || txn.Type == "release") {
+			net += txn.Amount
+		}
+	}
+	return -net
+}
+
+func (s *store) applyLedger(entry *models.CreditLedgerEntry) error {
+	userPackage := s.userPackages[entry.UserPackageID]
+	if userPackage.RemainingCredit+entry.Amount < 0 {
+		return repositories.ErrInsufficientCredit
+	}
+	userPackage.RemainingCredit += entry.Amount
+	entry.BalanceAfter = userPackage.RemainingCredit
+	s.ledger = append(s.ledger, *entry)
+	return nil
+}
+
+func copyPayment(payment *models.Payment) *models.Payment {
+	c := *payment
+	return &c
+}
+
+type fakePaymentRepo struct {
+	repositories.PaymentRepository
+	db *store
+}
+
+func (r *fakePaymentRepo) GetPaymentByID(id string) (*models.Payment, error) {
+	return r.GetPaymentByOrderID(id)
+}
+
+func (r *fakePaymentRepo) GetPaymentTaxes(paymentID string) ([]models.PaymentTax, error) {
+	var taxes []models.PaymentTax
+	for _, tax := range r.db.taxes {
+		if tax.PaymentID.String() == paymentID {
+			taxes = append(taxes, tax)
+		}
+	}
+	return taxes, nil
+}
+
+func (r *fakePaymentRepo) GetPaymentByOrderID(orderID string) (*models.Payment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, payment := range r.db.payments {
+		if payment.ID.String() == orderID {
+			return copyPayment(payment), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakePaymentRepo) GetPaymentByProviderPaymentID(paymentRef string) (*models.Payment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, payment := range r.db.payments {
+		if payment.ProviderPaymentID != nil && *payment.ProviderPaymentID == paymentRef {
+			return copyPayment(payment), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakePaymentRepo) UpdatePayment(payment *models.Payment) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	r.db.payments[payment.ID] = copyPayment(payment)
+	return nil
+}
+
+func (r *fakePaymentRepo) MarkPaymentFailed(id uuid.UUID) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	payment, ok := r.db.payments[id]
+	if !ok || payment.Status != "pending" {
+		return false, nil
+	}
+	payment.Status = "failed"
+	return true, nil
+}
+
+// SettlePayment follows the repository: only pending or failed payments settle and a released wallet hold is taken again
+func (r *fakePaymentRepo) SettlePayment(payment *models.Payment, userPackage *models.UserPackage, entry *models.CreditLedgerEntry) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	current := r.db.payments[payment.ID]
+	if current.Status != "pending" && current.Status != "failed" {
+		return repositories.ErrPaymentSettled
+	}
+	if current.Status == "failed" && current.WalletAmount > 0 {
+		if held := r.db.hold(current.ID); held < current.WalletAmount {
+			txn := models.WalletTransaction{UserID: current.UserID, Type: "payment", Amount: held - current.WalletAmount, Currency: current.Currency, PaymentID: &current.ID}
+			if err := r.db.applyWallet(txn); err != nil {
+				return err
+			}
+		}
+	}
+	invoice := "INV-" + payment.ID.String()[:8]
+	payment.InvoiceNumber = &invoice
+	if userPackage != nil {
+		if userPackage.ID == uuid.Nil {
+			userPackage.ID = uuid.New()
+		}
+		r.db.userPackages[userPackage.ID] = userPackage
+		if entry != nil {
+			entry.UserPackageID = userPackage.ID
+			if err := r.db.applyLedger(entry); err != nil {
+				return err
+			}
+		}
+		payment.UserPackageID = &userPackage.ID
+	}
+	r.db.payments[payment.ID] = copyPayment(payment)
+	return nil
+}
+
+func (r *fakePaymentRepo) RecordGatewayRefund(payment *models.Payment, credit *models.WalletTransaction) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if credit != nil {
+		if err := r.db.applyWallet(*credit); err != nil {
+			return err
+		}
+	}
+	current := r.db.payments[payment.ID]
+	current.RefundedAmount = payment.RefundedAmount
+	current.Status = payment.Status
+	return nil
+}
+
+func (r *fakePaymentRepo) GetStalePendingPayments(before time.Time, limit int) ([]models.Payment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var payments []models.Payment
+	for _, payment := range r.db.payments {
+		if payment.Status == "pending" && !payment.PaidAt.After(before) && payment.ProviderSessionID != nil {
+			payments = append(payments, *payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		a, b := payments[i].LastReconciledAt, payments[j].LastReconciledAt
+		switch {
+		case a == nil && b != nil:
+			return true
+		case a != nil && b == nil:
+			return false
+		case a != nil && !a.Equal(*b):
+			return a.Before(*b)
+		}
+		return payments[i].PaidAt.Before(payments[j].PaidAt)
+	})
+	if len(payments) > limit {
+		payments = payments[:limit]
+	}
+	return payments, nil
+}
+
+func (r *fakePaymentRepo) MarkPaymentsReconciled(ids []uuid.UUID, at time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, id := range ids {
+		at := at
+		r.db.payments[id].LastReconciledAt = &at
+	}
+	return nil
+}
+
+type fakeRefundRepo struct {
+	repositories.RefundRepository
+	db *store
+}
+
+func (r *fakeRefundRepo) CreateRefund(refund *models.PaymentRefund) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	payment := r.db.payments[refund.PaymentID]
+	if refund.Amount > payment.Total-payment.RefundedAmount {
+		return repositories.ErrRefundExceedsPayment
+	}
+	refund.ID = uuid.New()
+	refund.CreatedAt = time.Now().UTC()
+	r.db.refunds = append(r.db.refunds, *refund)
+	return nil
+}
+
+func (r *fakeRefundRepo) UpdateRefund(refund *models.PaymentRefund) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	r.db.saveRefund(refund)
+	return nil
+}
+
+func (r *fakeRefundRepo) CompleteRefund(refund *models.PaymentRefund, payment *models.Payment, revoke *models.CreditLedgerEntry, endUserPackageID *uuid.UUID, credit *models.WalletTransaction) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if revoke != nil {
+		if err := r.db.applyLedger(revoke); err != nil {
+			return err
+		}
+	}
+	if credit != nil {
+		if err := r.db.applyWallet(*credit); err != nil {
+			return err
+		}
+	}
+	if endUserPackageID != nil {
+		now := time.Now().UTC()
+		r.db.userPackages[*endUserPackageID].ClosedAt = &now
+	}
+	r.db.saveRefund(refund)
+	current := r.db.payments[payment.ID]
+	current.RefundedAmount = payment.RefundedAmount
+	current.Status = payment.Status
+	return nil
+}
+
+func (r *fakeRefundRepo) GetRefundsByPaymentID(paymentID string) ([]models.PaymentRefund, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var refunds []models.PaymentRefund
+	for _, refund := range r.db.refunds {
+		if refund.PaymentID.String() == paymentID {
+			refunds = append(refunds, refund)
+		}
+	}
+	return refunds, nil
+}
+
+func (s *store) saveRefund(refund *models.PaymentRefund) {
+	for i := range s.refunds {
+		if s.refunds[i].ID == refund.ID {
+			s.refunds[i] = *refund
+		}
+	}
+}
+
+type fakeLedgerRepo struct {
+	repositories.CreditLedgerRepository
+	db *store
+}
+
+func (r *fakeLedgerRepo) GetLatestEntryByType(userPackageID string, entryTypes ...string) (*models.CreditLedgerEntry, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for i := len(r.db.ledger) - 1; i >= 0; i-- {
+		entry := r.db.ledger[i]
+		if entry.UserPackageID.String() == userPackageID && slices.Contains(entryTypes, entry.Type) {
+			return &entry, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeLedgerRepo) ApplyEntry(entry *models.CreditLedgerEntry) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return r.db.applyLedger(entry)
+}
+
+// RecordReconciliation follows the repository: the entry is appended and the stored balance is left alone
+func (r *fakeLedgerRepo) RecordReconciliation(entry *models.CreditLedgerEntry) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	userPackage := r.db.userPackages[entry.UserPackageID]
+	entry.UserID = userPackage.UserID
+	entry.BalanceAfter = userPackage.RemainingCredit
+	r.db.ledger = append(r.db.ledger, *entry)
+	return nil
+}
+
+func (r *fakeLedgerRepo) GetEntriesByUserPackageID(userPackageID string) ([]models.CreditLedgerEntry, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var entries []models.CreditLedgerEntry
+	for _, entry := range r.db.ledger {
+		if entry.UserPackageID.String() == userPackageID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (r *fakeLedgerRepo) GetLedgerBalance(userPackageID string) (int, error) {
+	entries, err := r.GetEntriesByUserPackageID(userPackageID)
+	balance := 0
+	for _, entry := range entries {
+		balance += entry.Amount
+	}
+	return balance, err
+}
+
+func (r *fakeLedgerRepo) GetTransferredOut(userPackageID string) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	total := 0
+	for _, entry := range r.db.ledger {
+		if entry.UserPackageID.String() == userPackageID && entry.Type == "transfer" && entry.Amount < 0 {
+			total -= entry.Amount
+		}
+	}
+	return total, nil
+}
+
+func (r *fakeLedgerRepo) GetPurchaseEntryByPaymentID(paymentID string) (*models.CreditLedgerEntry, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for i := len(r.db.ledger) - 1; i >= 0; i-- {
+		entry := r.db.ledger[i]
+		if entry.Type == "purchase" && entry.PaymentID != nil && entry.PaymentID.String() == paymentID {
+			return &entry, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRefundRepo) GetWalletRefunded(paymentID string) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var total int64
+	for _, refund := range r.db.refunds {
+		if refund.PaymentID.String() == paymentID && refund.Status != "failed" && refund.ProviderRefundID != nil {
+			total += refund.WalletAmount
+		}
+	}
+	return total, nil
+}
+
+type fakeAuthRepo struct {
+	repositories.AuthRepository
+	db *store
+}
+
+func (r *fakeAuthRepo) GetUserByEmail(email string) (*models.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, user := range r.db.users {
+		if user.Email == email {
+			c := *user
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeUserRepo struct {
+	repositories.UserRepository
+	db *store
+}
+
+func (r *fakeUserRepo) GetUserByID(id string) (*models.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, user := range r.db.users {
+		if user.ID.String() == id {
+			c := *user
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeWalletRepo struct {
+	repositories.WalletRepository
+	db *store
+}
+
+func (r *fakeWalletRepo) ApplyTransaction(txn *models.WalletTransaction) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return r.db.applyWallet(*txn)
+}
+
+func (r *fakeWalletRepo) GetPaymentHold(paymentID uuid.UUID) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return r.db.hold(paymentID), nil
+}
+
+func (r *fakeWalletRepo) ReleasePaymentHold(paymentID uuid.UUID) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	payment, ok := r.db.payments[paymentID]
+	if !ok || payment.Status != "failed" || payment.WalletAmount <= 0 {
+		return false, nil
+	}
+	held := r.db.hold(paymentID)
+	if held <= 0 {
+		return false, nil
+	}
+	return true, r.db.applyWallet(models.WalletTransaction{UserID: payment.UserID, Type: "release", Amount: held, Currency: payment.Currency, PaymentID: &paymentID})
+}
+
+type fakePackageRepo struct {
+	repositories.PackageRepository
+	db *store
+}
+
+func (r *fakePackageRepo) GetPackageByID(id string) (*models.Package, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, pkg := range r.db.packages {
+		if pkg.ID.String() == id {
+			c := *pkg
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeUserPackageRepo struct {
+	repositories.UserPackageRepository
+	db *store
+}
+
+func (r *fakeUserPackageRepo) GetActiveUserPackages(userID, packageID string, result *models.UserPackage) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var latest *models.UserPackage
+	for _, userPackage := range r.db.userPackages {
+		if userPackage.UserID.String() == userID && userPackage.PackageID.String() == packageID && userPackage.ClosedAt == nil &&
+			(userPackage.ExpiredAt == nil || userPackage.ExpiredAt.After(time.Now())) &&
+			(latest == nil || userPackage.PurchasedAt.After(latest.PurchasedAt)) {
+			latest = userPackage
+		}
+	}
+	if latest != nil {
+		*result = *latest
+	}
+	return nil
+}
+
+// TransferCredits follows the repository: the transfer limit is checked under the same lock that moves the credits
+func (r *fakeUserPackageRepo) TransferCredits(target *models.UserPackage, debit, credit *models.CreditLedgerEntry, maxTransfer int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	transferred := 0
+	for _, entry := range r.db.ledger {
+		if entry.UserPackageID == debit.UserPackageID && entry.Type == "transfer" && entry.Amount < 0 {
+			transferred -= entry.Amount
+		}
+	}
+	if transferred-debit.Amount > maxTransfer {
+		return repositories.ErrTransferLimitReached
+	}
+	if err := r.db.applyLedger(debit); err != nil {
+		return err
+	}
+	if target.ID == uuid.Nil {
+		target.ID = uuid.New()
+		target.RemainingCredit = 0
+		stored := *target
+		r.db.userPackages[target.ID] = &stored
+	}
+	credit.UserPackageID = target.ID
+	if err := r.db.applyLedger(credit); err != nil {
+		return err
+	}
+	target.RemainingCredit = credit.BalanceAfter
+	return nil
+}
+
+func (r *fakeUserPackageRepo) GetUserPackageByID(id string) (*models.UserPackage, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, userPackage := range r.db.userPackages {
+		if userPackage.ID.String() == id {
+			c := *userPackage
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserPackageRepo) ExtendExpiry(userPackage *models.UserPackage, expiredAt time.Time, restore *models.CreditLedgerEntry) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	stored := r.db.userPackages[userPackage.ID]
+	stored.ExpiredAt = &expiredAt
+	stored.ClosedAt = nil
+	if restore != nil {
+		restore.UserPackageID = userPackage.ID
+		if err := r.db.applyLedger(restore); err != nil {
+			return err
+		}
+	}
+	*userPackage = *stored
+	return nil
+}
+
+type fakeDiscrepancyRepo struct {
+	repositories.PaymentDiscrepancyRepository
+	db *store
+}
+
+func (r *fakeDiscrepancyRepo) RecordDiscrepancy(discrepancy *models.PaymentDiscrepancy) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	discrepancy.LastSeenAt = time.Now().UTC()
+	for i, open := range r.db.discrepancies {
+		if open.PaymentID == discrepancy.PaymentID && open.Kind == discrepancy.Kind && open.Status == "open" {
+			discrepancy.ID = open.ID
+			discrepancy.Status = open.Status
+			r.db.discrepancies[i] = *discrepancy
+			return nil
+		}
+	}
+	discrepancy.ID = uuid.New()
+	discrepancy.Status = "open"
+	r.db.discrepancies = append(r.db.discrepancies, *discrepancy)
+	return nil
+}
+
+func (r *fakeDiscrepancyRepo) GetDiscrepancies(params dto.PaymentDiscrepancyQueryParam) ([]models.PaymentDiscrepancy, int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var found []models.PaymentDiscrepancy
+	for _, discrepancy := range r.db.discrepancies {
+		if (params.Kind == "" || discrepancy.Kind == params.Kind) &&
+			(params.PaymentID == "" || discrepancy.PaymentID.String() == params.PaymentID) &&
+			(params.Status == "" || discrepancy.Status == params.Status) {
+			found = append(found, discrepancy)
+		}
+	}
+	return found, int64(len(found)), nil
+}
+
+type fakeNotificationService struct {
+	NotificationService
+	db *store
+}
+
+func (s *fakeNotificationService) SendToUser(req dto.NotificationEvent) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.notifications = append(s.db.notifications, req)
+	return nil
+}
+
+func (s *fakeNotificationService) SendToAdmins(req dto.NotificationEvent) error {
+	return s.SendToUser(req)
+}
+
+// notified tells whether a notification with the title was sent
+func (s *store) notified(title string) bool {
+	for _, n := range s.notifications {
+		if strings.EqualFold(n.Title, title) {
+			return true
+		}
+	}
+	return false
+}
+
+type fakeSubscriptionService struct {
+	SubscriptionService
+	db *store
+}
+
+func (s *fakeSubscriptionService) HandleRenewalPaid(invoice gateway.Invoice) error {
+	s.db.invoices = append(s.db.invoices, invoice)
+	return nil
+}
+
+func (s *fakeSubscriptionService) HandleRenewalFailed(invoice gateway.Invoice) error {
+	s.db.invoices = append(s.db.invoices, invoice)
+	return nil
+}
+
+func (s *fakeSubscriptionService) HandleSubscriptionEnded(providerSubscriptionID string) error {
+	s.db.ended = append(s.db.ended, providerSubscriptionID)
+	return nil
+}
+
+type fakeVoucherService struct {
+	VoucherService
+}
+
+func (fakeVoucherService) ReleaseVoucher(paymentID uuid.UUID) error { return nil }
+
+// newTestPaymentService wires the payment service to the store and a fake gateway
+func newTestPaymentService(db *store, gw *gateway.FakeGateway) PaymentService {
+	notif := &fakeNotificationService{db: db}
+	wallet := NewWalletService(&fakeWalletRepo{db: db}, &fakeUserRepo{db: db}, notif)
+	return NewPaymentService(
+		&fakePaymentRepo{db: db},
+		&fakePackageRepo{db: db},
+		&fakeUserRepo{db: db},
+		fakeVoucherService{},
+		notif,
+		&fakeUserPackageRepo{db: db},
+		&fakeSubscriptionService{db: db},
+		gateway.NewRegistry(gw),
+		nil,
+		nil,
+		wallet,
+		&fakeDiscrepancyRepo{db: db},
+		&fakeRefundRepo{db: db},
+	)
+}
+
+type fakeWebhookEventRepo struct {
+	repositories.WebhookEventRepository
+	db *store
+}
+
+func (r *fakeWebhookEventRepo) RecordEvent(event *models.WebhookEvent) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, stored := range r.db.webhooks {
+		if stored.Gateway == event.Gateway && stored.EventID == event.EventID {
+			*event = stored
+			return false, nil
+		}
+	}
+	event.ID = uuid.New()
+	r.db.webhooks = append(r.db.webhooks, *event)
+	return true, nil
+}
+
+func (r *fakeWebhookEventRepo) GetEventByID(id string) (*models.WebhookEvent, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, event := range r.db.webhooks {
+		if event.ID.String() == id {
+			return &event, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeWebhookEventRepo) UpdateEvent(event *models.WebhookEvent) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for i := range r.db.webhooks {
+		if r.db.webhooks[i].ID == event.ID {
+			r.db.webhooks[i] = *event
+		}
+	}
+	return nil
+}
+
+func newTestRefundService(db *store, gw *gateway.FakeGateway) RefundService {
+	return NewRefundService(
+		&fakeRefundRepo{db: db},
+		&fakePaymentRepo{db: db},
+		&fakeUserPackageRepo{db: db},
+		&fakeLedgerRepo{db: db},
+		gateway.NewRegistry(gw),
+		&fakeNotificationService{db: db},
+	)
+}
+
+// deliver turns a fake gateway notification into the event the payment service receives
+func deliver(t *testing.T, svc PaymentService, gw *gateway.FakeGateway, body []byte) error {
+	t.Helper()
+	event, err := gw.DecodeEvent(body)
+	if err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	return svc.ProcessWebhookEvent(event)
+}
+
+type fakeBookingRepo struct {
+	repositories.BookingRepository
+	db        *store
+	schedules *fakeScheduleRepo
+}
+
+func (r *fakeBookingRepo) CountBookingByScheduleAndMode(scheduleID, mode string) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var count int64
+	for _, booking := range r.db.bookings {
+		if booking.ClassScheduleID.String() == scheduleID && booking.Mode == mode {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *fakeBookingRepo) CountPackageBookingsInRange(userPackageID uuid.UUID, start, end time.Time) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return r.countInRange(userPackageID, start, end), nil
+}
+
+func (r *fakeBookingRepo) countInRange(userPackageID uuid.UUID, start, end time.Time) int64 {
+	var count int64
+	for _, booking := range r.db.bookings {
+		if booking.UserPackageID == nil || *booking.UserPackageID != userPackageID || booking.Status != "booked" {
+			continue
+		}
+		schedule := r.schedules.find(booking.ClassScheduleID)
+		if schedule != nil && !schedule.Date.Before(start) && schedule.Date.Before(end) {
+			count++
+		}
+	}
+	return count
+}
+
+// BookSchedule follows the repository: nothing is stored when the booking goes past the weekly limit, the credit
+// or the member's cap
+func (r *fakeBookingRepo) BookSchedule(booking *models.Booking, attendance *models.Attendance, entry *models.CreditLedgerEntry, limits repositories.BookingLimits) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if limits.WeeklyLimit > 0 && r.countInRange(*booking.UserPackageID, limits.WeekStart, limits.WeekEnd) >= int64(limits.WeeklyLimit) {
+		return repositories.ErrWeeklyLimitReached
+	}
+	if entry != nil {
+		if limits.MemberCap != nil && r.creditsUsedBy(entry.UserPackageID, booking.UserID)-entry.Amount > *limits.MemberCap {
+			return repositories.ErrMemberCapReached
+		}
+		entry.BookingID = &booking.ID
+		if err := r.db.applyLedger(entry); err != nil {
+			return err
+		}
+	}
+
+	attendance.BookingID = booking.ID
+	booking.Attendance = *attendance
+	r.db.bookings = append(r.db.bookings, *booking)
+	if schedule := r.schedules.find(booking.ClassScheduleID); schedule != nil {
+		schedule.Booked++
+		if booking.Mode == "online" {
+			schedule.OnlineBooked++
+		}
+	}
+	return nil
+}
+
+// creditsUsedBy is what the user's bookings took from the package, less what was refunded for them
+func (r *fakeBookingRepo) creditsUsedBy(userPackageID, userID uuid.UUID) int {
+	used := 0
+	for _, entry := range r.db.ledger {
+		if entry.UserPackageID != userPackageID || entry.BookingID == nil || (entry.Type != "booking" && entry.Type != "refund") {
+			continue
+		}
+		for _, booking := range r.db.bookings {
+			if booking.ID == *entry.BookingID && booking.UserID == userID {
+				used -= entry.Amount
+			}
+		}
+	}
+	return used
+}
+
+func (r *fakeBookingRepo) GetBookingByID(userID, bookingID string) (*models.Booking, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, booking := range r.db.bookings {
+		if booking.ID.String() == bookingID && booking.UserID.String() == userID {
+			if schedule := r.schedules.find(booking.ClassScheduleID); schedule != nil {
+				booking.ClassSchedule = *schedule
+			}
+			return &booking, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeFreezeRepo struct {
+	repositories.FreezeRepository
+	db *store
+}
+
+func (r *fakeFreezeRepo) IsFrozenOn(userPackageID uuid.UUID, date time.Time) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	day := date.Format("2006-01-02")
+	for _, freeze := range r.db.freezes {
+		if freeze.UserPackageID == userPackageID && freeze.Status == "approved" &&
+			day >= freeze.StartDate.Format("2006-01-02") && day <= freeze.EndDate.Format("2006-01-02") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeFreezeRepo) GetFreezeByID(id string) (*models.UserPackageFreeze, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, freeze := range r.db.freezes {
+		if freeze.ID.String() == id {
+			freeze.UserPackage = *r.db.userPackages[freeze.UserPackageID]
+			return &freeze, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeFreezeRepo) GetFreezeByPaymentID(paymentID uuid.UUID) (*models.UserPackageFreeze, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, freeze := range r.db.freezes {
+		if freeze.PaymentID != nil && *freeze.PaymentID == paymentID {
+			freeze.UserPackage = *r.db.userPackages[freeze.UserPackageID]
+			return &freeze, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeFreezeRepo) UpdateFreeze(freeze *models.UserPackageFreeze) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for i := range r.db.freezes {
+		if r.db.freezes[i].ID == freeze.ID {
+			r.db.freezes[i] = *freeze
+		}
+	}
+	return nil
+}
+
+// ApplyFreeze follows the repository: the freeze is approved and the package expiry moves by the frozen days
+func (r *fakeFreezeRepo) ApplyFreeze(freeze *models.UserPackageFreeze) error {
+	freeze.Status = "approved"
+	if err := r.UpdateFreeze(freeze); err != nil {
+		return err
+	}
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if userPackage := r.db.userPackages[freeze.UserPackageID]; userPackage.ExpiredAt != nil {
+		expiredAt := userPackage.ExpiredAt.AddDate(0, 0, freeze.Days)
+		userPackage.ExpiredAt = &expiredAt
+	}
+	return nil
+}
+
+type fakeMemberRepo struct {
+	repositories.PackageMemberRepository
+	db *store
+}
+
+func (r *fakeMemberRepo) GetActiveMembership(userID, packageID string) (*models.UserPackageMember, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, member := range r.db.members {
+		userPackage := r.db.userPackages[member.UserPackageID]
+		if member.UserID.String() == userID && member.Status == "active" && userPackage.PackageID.String() == packageID {
+			member.UserPackage = *userPackage
+			if user := r.db.users[member.UserID]; user != nil {
+				member.User = *user
+			}
+			return &member, nil
+		}
+	}
+	return nil, nil
+}
+
+// newTestBookingService wires the booking service to the store, schedules are kept by the fake schedule repository
+func newTestBookingService(db *store, schedules *fakeScheduleRepo) BookingService {
+	return NewBookingService(
+		&fakeBookingRepo{db: db, schedules: schedules},
+		&fakePackageRepo{db: db},
+		&fakeNotificationService{db: db},
+		&fakeUserPackageRepo{db: db},
+		schedules,
+		meeting.NewLocalProvider(""),
+		&fakeFreezeRepo{db: db},
+		&fakeMemberRepo{db: db},
+	)
+}
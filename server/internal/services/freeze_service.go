@@ -0,0 +1,370 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	customErr "server/pkg/errors"
+	"server/pkg/money"
+	"server/pkg/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FreezeService interface {
+	// customer
+	RequestFreeze(userID string, req dto.CreateFreezeRequest) (*dto.FreezeResponse, error)
+	GetMyFreezes(userID string) ([]dto.FreezeResponse, error)
+	CancelFreeze(userID, id string) error
+
+	// admin
+	GetFreezes(params dto.FreezeQueryParam) ([]dto.FreezeResponse, error)
+	ApproveFreeze(adminID, id string, req dto.ApproveFreezeRequest) (*dto.FreezeResponse, error)
+	RejectFreeze(adminID, id string, req dto.RejectFreezeRequest) (*dto.FreezeResponse, error)
+
+	// payment hooks
+	HandleFeePaid(payment *models.Payment) error
+	HandleFeeFailed(payment *models.Payment) error
+
+	// cron
+	ReopenUnpaidFreezes() (int, error)
+}
+
+type freezeService struct {
+	freeze  repositories.FreezeRepository
+	userPkg repositories.UserPackageRepository
+	pkg     repositories.PackageRepository
+	booking repositories.BookingRepository
+	payment PaymentService
+	notif   NotificationService
+}
+
+func NewFreezeService(freeze repositories.FreezeRepository, userPkg repositories.UserPackageRepository, pkg repositories.PackageRepository, booking repositories.BookingRepository, payment PaymentService, notif NotificationService) FreezeService {
+	return &freezeService{
+		freeze:  freeze,
+		userPkg: userPkg,
+		pkg:     pkg,
+		booking: booking,
+		payment: payment,
+		notif:   notif,
+	}
+}
+
+func (s *freezeService) RequestFreeze(userID string, req dto.CreateFreezeRequest) (*dto.FreezeResponse, error) {
+	userPackage, err := s.userPkg.GetUserPackageByID(req.UserPackageID)
+	if err != nil || userPackage == nil || userPackage.UserID.String() != userID {
+		return nil, customErr.NewNotFound("user package not found")
+	}
+	if userPackage.SubscriptionID != nil {
+		return nil, customErr.NewBadRequest("auto-renewing memberships cannot be frozen, cancel the subscription instead")
+	}
+
+	pkg, err := s.pkg.GetPackageByID(userPackage.PackageID.String())
+	if err != nil || pkg == nil {
+		return nil, customErr.NewNotFound("package not found")
+	}
+	if pkg.MaxFreezeDays <= 0 {
+		return nil, customErr.NewBadRequest("this package cannot be frozen")
+	}
+
+	start, err := utils.ParseDate(req.StartDate)
+	if err != nil {
+		return nil, customErr.NewBadRequest("invalid start date, format must be YYYY-MM-DD")
+	}
+	end, err := utils.ParseDate(req.EndDate)
+	if err != nil {
+		return nil, customErr.NewBadRequest("invalid end date, format must be YYYY-MM-DD")
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if start.Before(today) {
+		return nil, customErr.NewBadRequest("freeze cannot start in the past")
+	}
+	if end.Before(start) {
+		return nil, customErr.NewBadRequest("end date must be on or after the start date")
+	}
+	if userPackage.ExpiredAt != nil && !start.Before(*userPackage.ExpiredAt) {
+		return nil, customErr.NewBadRequest("freeze must start before the package expires")
+	}
+
+	days := int(end.Sub(start).Hours()/24) + 1
+
+	open, err := s.freeze.GetOpenFreezesByUserPackage(userPackage.ID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch existing freezes", err)
+	}
+	used := 0
+	for _, f := range open {
+		used += f.Days
+		if !start.After(f.EndDate) && !end.Before(f.StartDate) {
+			return nil, customErr.NewConflict("freeze overlaps an existing request")
+		}
+	}
+	if used+days > pkg.MaxFreezeDays {
+		return nil, customErr.NewBadRequest(fmt.Sprintf("this package allows %d freeze days, %d remaining", pkg.MaxFreezeDays, pkg.MaxFreezeDays-used))
+	}
+
+	booked, err := s.booking.CountPackageBookingsInRange(userPackage.ID, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, customErr.NewInternal("failed to check bookings", err)
+	}
+	if booked > 0 {
+		return nil, customErr.NewConflict("cancel your bookings within the freeze period first")
+	}
+
+	freeze := models.UserPackageFreeze{
+		UserPackageID: userPackage.ID,
+		UserID:        userPackage.UserID,
+		StartDate:     start,
+		EndDate:       end,
+		Days:          days,
+		Reason:        req.Reason,
+		Status:        "pending",
+		Fee:           pkg.FreezeFee,
+		Currency:      pkg.Currency,
+	}
+	if err := s.freeze.CreateFreeze(&freeze); err != nil {
+		return nil, customErr.NewInternal("failed to create freeze request", err)
+	}
+	freeze.UserPackage = *userPackage
+
+	resp := toFreezeResponse(freeze, "")
+	return &resp, nil
+}
+
+func (s *freezeService) GetMyFreezes(userID string) ([]dto.FreezeResponse, error) {
+	freezes, err := s.freeze.GetFreezesByUserID(userID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch freezes", err)
+	}
+
+	result := make([]dto.FreezeResponse, 0, len(freezes))
+	for _, f := range freezes {
+		result = append(result, toFreezeResponse(f, ""))
+	}
+	return result, nil
+}
+
+func (s *freezeService) CancelFreeze(userID, id string) error {
+	freeze, err := s.freeze.GetFreezeByID(id)
+	if err != nil || freeze == nil || freeze.UserID.String() != userID {
+		return customErr.NewNotFound("freeze request not found")
+	}
+	if freeze.Status != "pending" && freeze.Status != "awaiting_payment" {
+		return customErr.NewBadRequest(fmt.Sprintf("freeze is already %s", freeze.Status))
+	}
+
+	freeze.Status = "canceled"
+	if err := s.freeze.UpdateFreeze(freeze); err != nil {
+		return customErr.NewInternal("failed to cancel freeze", err)
+	}
+	return nil
+}
+
+func (s *freezeService) GetFreezes(params dto.FreezeQueryParam) ([]dto.FreezeResponse, error) {
+	freezes, err := s.freeze.GetFreezes(params.Status)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch freezes", err)
+	}
+
+	result := make([]dto.FreezeResponse, 0, len(freezes))
+	for _, f := range freezes {
+		result = append(result, toFreezeResponse(f, ""))
+	}
+	return result, nil
+}
+
+// ApproveFreeze applies the freeze right away, or asks the member to pay the freeze fee first
+func (s *freezeService) ApproveFreeze(adminID, id string, req dto.ApproveFreezeRequest) (*dto.FreezeResponse, error) {
+	freeze, err := s.freeze.GetFreezeByID(id)
+	if err != nil || freeze == nil {
+		return nil, customErr.NewNotFound("freeze request not found")
+	}
+	if freeze.Status != "pending" {
+		return nil, customErr.NewBadRequest(fmt.Sprintf("freeze is already %s", freeze.Status))
+	}
+
+	// the member may have booked inside the window since asking, those classes have to be canceled first
+	booked, err := s.booking.CountPackageBookingsInRange(freeze.UserPackageID, freeze.StartDate, freeze.EndDate.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, customErr.NewInternal("failed to check bookings", err)
+	}
+	if booked > 0 {
+		return nil, customErr.NewConflict("the member has bookings within the freeze period, they must be canceled first")
+	}
+
+	now := time.Now()
+	admin := uuid.MustParse(adminID)
+	freeze.ReviewNote = req.Note
+	freeze.ReviewedBy = &admin
+	freeze.ReviewedAt = &now
+	if req.WaiveFee {
+		freeze.Fee = 0
+	}
+
+	if freeze.Fee > 0 {
+		payment, err := s.payment.CreateFeePayment(freeze.UserID.String(), dto.FeeCharge{
+			PackageID:     freeze.UserPackage.PackageID.String(),
+			UserPackageID: freeze.UserPackageID.String(),
+			Description:   fmt.Sprintf("Freeze %s (%d days)", freeze.UserPackage.PackageName, freeze.Days),
+			Amount:        money.New(freeze.Fee, freeze.Currency),
+			Purpose:       "freeze_fee",
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		freeze.Status = "awaiting_payment"
+		freeze.PaymentID = &payment.ID
+		if err := s.freeze.UpdateFreeze(freeze); err != nil {
+			return nil, customErr.NewInternal("failed to update freeze", err)
+		}
+
+		s.notify(freeze, "Freeze Approved", fmt.Sprintf("Your freeze of %s was approved. Please pay the freeze fee to activate it: %s", freeze.UserPackage.PackageName, payment.PaymentLink))
+		resp := toFreezeResponse(*freeze, payment.PaymentLink)
+		return &resp, nil
+	}
+
+	if err := s.freeze.ApplyFreeze(freeze); err != nil {
+		return nil, customErr.NewInternal("failed to apply freeze", err)
+	}
+
+	s.notifyApplied(freeze)
+	resp := toFreezeResponse(*freeze, "")
+	return &resp, nil
+}
+
+func (s *freezeService) RejectFreeze(adminID, id string, req dto.RejectFreezeRequest) (*dto.FreezeResponse, error) {
+	freeze, err := s.freeze.GetFreezeByID(id)
+	if err != nil || freeze == nil {
+		return nil, customErr.NewNotFound("freeze request not found")
+	}
+	if freeze.Status != "pending" {
+		return nil, customErr.NewBadRequest(fmt.Sprintf("freeze is already %s", freeze.Status))
+	}
+
+	now := time.Now()
+	admin := uuid.MustParse(adminID)
+	freeze.Status = "rejected"
+	freeze.ReviewNote = req.Note
+	freeze.ReviewedBy = &admin
+	freeze.ReviewedAt = &now
+	if err := s.freeze.UpdateFreeze(freeze); err != nil {
+		return nil, customErr.NewInternal("failed to reject freeze", err)
+	}
+
+	s.notify(freeze, "Freeze Rejected", fmt.Sprintf("Your freeze request for %s was rejected: %s", freeze.UserPackage.PackageName, req.Note))
+	resp := toFreezeResponse(*freeze, "")
+	return &resp, nil
+}
+
+// HandleFeePaid applies the freeze once its fee payment succeeds
+func (s *freezeService) HandleFeePaid(payment *models.Payment) error {
+	freeze, err := s.freeze.GetFreezeByPaymentID(payment.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch freeze: %w", err)
+	}
+	if freeze == nil {
+		return fmt.Errorf("no freeze found for payment %s", payment.ID)
+	}
+	// a fee paid after its checkout failed still applies the approved freeze it was opened for
+	if freeze.Status != "awaiting_payment" && freeze.Status != "pending" {
+		log.Printf("freeze %s is %s, skipping fee payment %s\n", freeze.ID, freeze.Status, payment.ID)
+		return nil
+	}
+
+	if err := s.freeze.ApplyFreeze(freeze); err != nil {
+		return fmt.Errorf("failed to apply freeze: %w", err)
+	}
+
+	s.notifyApplied(freeze)
+	return nil
+}
+
+// HandleFeeFailed puts the freeze back to pending when its fee payment fails or the checkout expires, so it is
+// reviewed again instead of waiting on a dead payment link
+func (s *freezeService) HandleFeeFailed(payment *models.Payment) error {
+	freeze, err := s.freeze.GetFreezeByPaymentID(payment.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch freeze: %w", err)
+	}
+	if freeze == nil || freeze.Status != "awaiting_payment" {
+		return nil
+	}
+	return s.reopen(freeze)
+}
+
+// ** buat cron job
+// ReopenUnpaidFreezes puts back the freezes whose fee checkout was expired without a webhook telling us
+func (s *freezeService) ReopenUnpaidFreezes() (int, error) {
+	freezes, err := s.freeze.GetUnpaidFreezes()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range freezes {
+		if err := s.reopen(&freezes[i]); err != nil {
+			log.Println(err)
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (s *freezeService) reopen(freeze *models.UserPackageFreeze) error {
+	freeze.Status = "pending"
+	if err := s.freeze.UpdateFreeze(freeze); err != nil {
+		return fmt.Errorf("failed to reopen freeze %s: %w", freeze.ID, err)
+	}
+
+	s.notify(freeze, "Freeze Fee Unpaid", fmt.Sprintf("The freeze fee for %s was not paid, so the freeze is not active. It is back with the studio for review, or you can cancel it.", freeze.UserPackage.PackageName))
+	return nil
+}
+
+func (s *freezeService) notifyApplied(freeze *models.UserPackageFreeze) {
+	s.notify(freeze, "Package Frozen", fmt.Sprintf("Your %s is frozen from %s to %s, its expiry was extended by %d days.",
+		freeze.UserPackage.PackageName, freeze.StartDate.Format("2006-01-02"), freeze.EndDate.Format("2006-01-02"), freeze.Days))
+}
+
+func (s *freezeService) notify(freeze *models.UserPackageFreeze, title, message string) {
+	payload := dto.NotificationEvent{
+		UserID:  freeze.UserID.String(),
+		Type:    "system_message",
+		Title:   title,
+		Message: message,
+	}
+	if err := s.notif.SendToUser(payload); err != nil {
+		log.Printf("failed sending freeze notification to user %s: %v\n", payload.UserID, err)
+	}
+}
+
+func toFreezeResponse(f models.UserPackageFreeze, paymentLink string) dto.FreezeResponse {
+	resp := dto.FreezeResponse{
+		ID:            f.ID.String(),
+		UserPackageID: f.UserPackageID.String(),
+		PackageName:   f.UserPackage.PackageName,
+		UserID:        f.UserID.String(),
+		Fullname:      f.User.Fullname,
+		StartDate:     f.StartDate.Format("2006-01-02"),
+		EndDate:       f.EndDate.Format("2006-01-02"),
+		Days:          f.Days,
+		Reason:        f.Reason,
+		Status:        f.Status,
+		Fee:           majorUnits(f.Fee, f.Currency),
+		PaymentLink:   paymentLink,
+		ReviewNote:    f.ReviewNote,
+		CreatedAt:     f.CreatedAt.Format(time.RFC3339),
+	}
+	if f.PaymentID != nil {
+		resp.PaymentID = f.PaymentID.String()
+	}
+	if f.ReviewedAt != nil {
+		resp.ReviewedAt = f.ReviewedAt.Format(time.RFC3339)
+	}
+	return resp
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"server/internal/dto"
+	"server/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// frozen adds a freeze of userPackage in the given status covering days from start
+func frozen(db *store, userPackage *models.UserPackage, status string, start time.Time, days int) *models.UserPackageFreeze {
+	db.freezes = append(db.freezes, models.UserPackageFreeze{
+		ID:            uuid.New(),
+		UserPackageID: userPackage.ID,
+		UserID:        userPackage.UserID,
+		StartDate:     start,
+		EndDate:       start.AddDate(0, 0, days-1),
+		Days:          days,
+		Status:        status,
+		Currency:      "IDR",
+	})
+	return &db.freezes[len(db.freezes)-1]
+}
+
+func TestFrozenPackageCannotBook(t *testing.T) {
+	db := newStore()
+	pkg := db.addPackage(10)
+	user := db.addUser(0)
+	userPackage := ownedPackage(t, db, user, pkg, 10, 60)
+	schedules := &fakeScheduleRepo{schedules: []models.ClassSchedule{classAt(72*time.Hour, "in_person", 10, 0), classAt(240*time.Hour, "in_person", 10, 0)}}
+	during, after := schedules.schedules[0], schedules.schedules[1]
+	frozen(db, userPackage, "approved", during.Date.AddDate(0, 0, -1), 5)
+	svc := newTestBookingService(db, schedules)
+
+	err := svc.CreateBooking(user.ID.String(), pkg.ID.String(), during.ID.String(), "")
+	if err == nil || !strings.Contains(err.Error(), "frozen on this date") {
+		t.Fatalf("err = %v, want the frozen package rejected", err)
+	}
+	if userPackage.RemainingCredit != 10 || len(db.bookings) != 0 {
+		t.Fatalf("a frozen package was charged for a booking")
+	}
+
+	// outside the freeze the package books as usual
+	if err := svc.CreateBooking(user.ID.String(), pkg.ID.String(), after.ID.String(), ""); err != nil {
+		t.Fatalf("booking after the freeze: %v", err)
+	}
+	if userPackage.RemainingCredit != 9 {
+		t.Fatalf("remaining = %d, want 9", userPackage.RemainingCredit)
+	}
+}
+
+func TestPendingFreezeDoesNotBlockBooking(t *testing.T) {
+	db := newStore()
+	pkg := db.addPackage(10)
+	user := db.addUser(0)
+	userPackage := ownedPackage(t, db, user, pkg, 10, 60)
+	schedules := &fakeScheduleRepo{schedules: []models.ClassSchedule{classAt(72*time.Hour, "in_person", 10, 0)}}
+	frozen(db, userPackage, "awaiting_payment", schedules.schedules[0].Date, 3)
+
+	if err := newTestBookingService(db, schedules).CreateBooking(user.ID.String(), pkg.ID.String(), schedules.schedules[0].ID.String(), ""); err != nil {
+		t.Fatalf("booking with an unpaid freeze: %v", err)
+	}
+}
+
+func TestApprovedFreezePushesTheExpiryOut(t *testing.T) {
+	db := newStore()
+	pkg := db.addPackage(10)
+	user := db.addUser(0)
+	userPackage := ownedPackage(t, db, user, pkg, 10, 30)
+	expiredAt := *userPackage.ExpiredAt
+	freeze := frozen(db, userPackage, "pending", time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 7), 10)
+	freeze.Fee = 50000
+	svc := NewFreezeService(&fakeFreezeRepo{db: db}, &fakeUserPackageRepo{db: db}, &fakePackageRepo{db: db}, &fakeBookingRepo{db: db, schedules: &fakeScheduleRepo{}}, nil, &fakeNotificationService{db: db})
+
+	resp, err := svc.ApproveFreeze(uuid.NewString(), freeze.ID.String(), dto.ApproveFreezeRequest{WaiveFee: true})
+	if err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if resp.Status != "approved" || db.freezes[0].Status != "approved" {
+		t.Fatalf("status = %s, want approved", resp.Status)
+	}
+	if want := expiredAt.AddDate(0, 0, 10); !userPackage.ExpiredAt.Equal(want) {
+		t.Fatalf("expiry = %s, want %s", userPackage.ExpiredAt, want)
+	}
+	if !db.notified("Package Frozen") {
+		t.Fatalf("the member was not told about the freeze")
+	}
+
+	// an approved freeze is not applied a second time
+	if _, err := svc.ApproveFreeze(uuid.NewString(), freeze.ID.String(), dto.ApproveFreezeRequest{WaiveFee: true}); err == nil {
+		t.Fatalf("approved the same freeze twice")
+	}
+	if want := expiredAt.AddDate(0, 0, 10); !userPackage.ExpiredAt.Equal(want) {
+		t.Fatalf("expiry = %s after a second approval, want %s", userPackage.ExpiredAt, want)
+	}
+}
+
+func TestFreezeFeePaymentAppliesTheFreeze(t *testing.T) {
+	db := newStore()
+	pkg := db.addPackage(10)
+	user := db.addUser(0)
+	userPackage := ownedPackage(t, db, user, pkg, 10, 30)
+	expiredAt := *userPackage.ExpiredAt
+	freeze := frozen(db, userPackage, "awaiting_payment", time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 7), 5)
+	paymentID := uuid.New()
+	freeze.PaymentID = &paymentID
+	svc := NewFreezeService(&fakeFreezeRepo{db: db}, &fakeUserPackageRepo{db: db}, &fakePackageRepo{db: db}, &fakeBookingRepo{db: db, schedules: &fakeScheduleRepo{}}, nil, &fakeNotificationService{db: db})
+
+	payment := &models.Payment{ID: paymentID}
+	if err := svc.HandleFeePaid(payment); err != nil {
+		t.Fatalf("fee paid: %v", err)
+	}
+	if want := expiredAt.AddDate(0, 0, 5); !userPackage.ExpiredAt.Equal(want) {
+		t.Fatalf("expiry = %s, want %s", userPackage.ExpiredAt, want)
+	}
+
+	// a redelivered payment event leaves the expiry where it is
+	if err := svc.HandleFeePaid(payment); err != nil {
+		t.Fatalf("fee paid again: %v", err)
+	}
+	if want := expiredAt.AddDate(0, 0, 5); !userPackage.ExpiredAt.Equal(want) {
+		t.Fatalf("expiry = %s after the event came twice, want %s", userPackage.ExpiredAt, want)
+	}
+}
+
+func TestFreezeIsNotApprovedOverBookings(t *testing.T) {
+	db := newStore()
+	pkg := db.addPackage(10)
+	user := db.addUser(0)
+	userPackage := ownedPackage(t, db, user, pkg, 10, 30)
+	expiredAt := *userPackage.ExpiredAt
+	schedules := &fakeScheduleRepo{schedules: []models.ClassSchedule{classAt(72*time.Hour, "in_person", 10, 0)}}
+	if err := newTestBookingService(db, schedules).CreateBooking(user.ID.String(), pkg.ID.String(), schedules.schedules[0].ID.String(), ""); err != nil {
+		t.Fatalf("booking: %v", err)
+	}
+	freeze := frozen(db, userPackage, "pending", schedules.schedules[0].Date.AddDate(0, 0, -1), 3)
+	svc := NewFreezeService(&fakeFreezeRepo{db: db}, &fakeUserPackageRepo{db: db}, &fakePackageRepo{db: db}, &fakeBookingRepo{db: db, schedules: schedules}, nil, &fakeNotificationService{db: db})
+
+	if _, err := svc.ApproveFreeze(uuid.NewString(), freeze.ID.String(), dto.ApproveFreezeRequest{WaiveFee: true}); err == nil || !strings.Contains(err.Error(), "bookings within the freeze period") {
+		t.Fatalf("err = %v, want the booking to block the freeze", err)
+	}
+	if freeze.Status != "pending" || !userPackage.ExpiredAt.Equal(expiredAt) {
+		t.Fatalf("freeze %s with expiry %s, want it left pending", freeze.Status, userPackage.ExpiredAt)
+	}
+}
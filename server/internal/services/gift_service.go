@@ -0,0 +1,204 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	customErr "server/pkg/errors"
+	"server/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type GiftService interface {
+	// customer
+	GetMyGifts(userID string) (*dto.MyGiftsResponse, error)
+	RedeemGift(userID string, req dto.RedeemGiftRequest) (*dto.GiftResponse, error)
+
+	// payment hook
+	HandleGiftPaid(payment *models.Payment) error
+}
+
+type giftService struct {
+	gift        repositories.GiftRepository
+	paymentRepo repositories.PaymentRepository
+	user        repositories.UserRepository
+	auth        repositories.AuthRepository
+	payment     PaymentService
+	notif       NotificationService
+}
+
+func NewGiftService(gift repositories.GiftRepository, paymentRepo repositories.PaymentRepository, user repositories.UserRepository, auth repositories.AuthRepository, payment PaymentService, notif NotificationService) GiftService {
+	return &giftService{
+		gift:        gift,
+		paymentRepo: paymentRepo,
+		user:        user,
+		auth:        auth,
+		payment:     payment,
+		notif:       notif,
+	}
+}
+
+// HandleGiftPaid issues the gift code once the purchase is settled and sends it to the recipient
+func (s *giftService) HandleGiftPaid(payment *models.Payment) error {
+	existing, err := s.gift.GetGiftByPaymentID(payment.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check gift: %w", err)
+	}
+	if existing != nil {
+		return nil
+	}
+	if payment.GiftEmail == nil {
+		return fmt.Errorf("payment %s has no gift recipient", payment.ID)
+	}
+
+	gift := models.PackageGift{
+		PaymentID:      payment.ID,
+		PackageID:      payment.PackageID,
+		PackageName:    payment.PackageName,
+		PurchaserID:    payment.UserID,
+		RecipientEmail: *payment.GiftEmail,
+		Message:        payment.GiftMessage,
+		Status:         "issued",
+	}
+
+	// codes are random, retry the rare collision with an existing code
+	for attempt := 0; ; attempt++ {
+		gift.ID = uuid.Nil
+		gift.Code = utils.GenerateGiftCode()
+		if err = s.gift.CreateGift(&gift); err == nil {
+			break
+		}
+		if attempt == 2 {
+			return fmt.Errorf("failed to issue gift: %w", err)
+		}
+	}
+
+	subject := fmt.Sprintf("%s sent you a %s gift", payment.Fullname, payment.PackageName)
+	plain := fmt.Sprintf("%s bought you the %s package.\n\n%s\n\nRedeem it with code %s after signing in.", payment.Fullname, payment.PackageName, payment.GiftMessage, gift.Code)
+	body := fmt.Sprintf("<p>%s bought you the <b>%s</b> package.</p><p>%s</p><p>Redeem it with code <b>%s</b> after signing in.</p>",
+		html.EscapeString(payment.Fullname), html.EscapeString(payment.PackageName), html.EscapeString(payment.GiftMessage), gift.Code)
+	if err := utils.SendEmail(subject, gift.RecipientEmail, plain, body); err != nil {
+		log.Printf("failed sending gift %s to %s: %v\n", gift.ID, gift.RecipientEmail, err)
+	}
+
+	if recipient, err := s.auth.GetUserByEmail(gift.RecipientEmail); err == nil && recipient != nil {
+		s.notify(recipient.ID, "You Received a Gift", fmt.Sprintf("%s sent you the %s package. Redeem it with code %s.", payment.Fullname, payment.PackageName, gift.Code))
+	}
+	s.notify(payment.UserID, "Gift Sent", fmt.Sprintf("Your %s gift was paid and the code was sent to %s.", payment.PackageName, gift.RecipientEmail))
+	return nil
+}
+
+func (s *giftService) RedeemGift(userID string, req dto.RedeemGiftRequest) (*dto.GiftResponse, error) {
+	gift, err := s.gift.GetGiftByCode(strings.ToUpper(strings.TrimSpace(req.Code)))
+	if err != nil || gift == nil {
+		return nil, customErr.NewNotFound("gift code not found")
+	}
+	if gift.Status != "issued" {
+		return nil, customErr.NewConflict("gift has already been redeemed")
+	}
+
+	payment, err := s.paymentRepo.GetPaymentByID(gift.PaymentID.String())
+	if err != nil || payment == nil {
+		return nil, customErr.NewNotFound("gift payment not found")
+	}
+	// only a settled purchase can be redeemed, a disputed, failed or refunded one no longer pays for the gift
+	if payment.Status != "success" && payment.Status != "partially_refunded" {
+		return nil, customErr.NewBadRequest(fmt.Sprintf("this gift cannot be redeemed, its payment is %s", strings.ReplaceAll(payment.Status, "_", " ")))
+	}
+
+	redeemer := uuid.MustParse(userID)
+	claimed, err := s.gift.ClaimGift(gift, redeemer, time.Now())
+	if err != nil {
+		return nil, customErr.NewInternal("failed to redeem gift", err)
+	}
+	if !claimed {
+		return nil, customErr.NewConflict("gift has already been redeemed")
+	}
+
+	userPackage, err := s.payment.GrantPackage(payment, userID)
+	if err != nil {
+		if releaseErr := s.gift.ReleaseGift(gift); releaseErr != nil {
+			log.Printf("failed to release gift %s after redeem error: %v\n", gift.ID, releaseErr)
+		}
+		return nil, err
+	}
+	if err := s.gift.SetGiftUserPackage(gift, userPackage.ID); err != nil {
+		log.Printf("failed linking gift %s to user package %s: %v\n", gift.ID, userPackage.ID, err)
+	}
+
+	name := "The recipient"
+	if user, err := s.user.GetUserByID(userID); err == nil && user != nil {
+		name = user.Fullname
+	}
+	s.notify(gift.PurchaserID, "Gift Redeemed", fmt.Sprintf("%s redeemed your %s gift.", name, gift.PackageName))
+
+	resp := toGiftResponse(*gift)
+	return &resp, nil
+}
+
+func (s *giftService) GetMyGifts(userID string) (*dto.MyGiftsResponse, error) {
+	user, err := s.user.GetUserByID(userID)
+	if err != nil || user == nil {
+		return nil, customErr.NewNotFound("user not found")
+	}
+
+	sent, err := s.gift.GetGiftsByPurchaserID(userID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch sent gifts", err)
+	}
+	received, err := s.gift.GetGiftsByRecipientEmail(strings.ToLower(user.Email))
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch received gifts", err)
+	}
+
+	resp := &dto.MyGiftsResponse{
+		Sent:     make([]dto.GiftResponse, 0, len(sent)),
+		Received: make([]dto.GiftResponse, 0, len(received)),
+	}
+	for _, g := range sent {
+		resp.Sent = append(resp.Sent, toGiftResponse(g))
+	}
+	for _, g := range received {
+		resp.Received = append(resp.Received, toGiftResponse(g))
+	}
+	return resp, nil
+}
+
+func (s *giftService) notify(userID uuid.UUID, title, message string) {
+	payload := dto.NotificationEvent{
+		UserID:  userID.String(),
+		Type:    "system_message",
+		Title:   title,
+		Message: message,
+	}
+	if err := s.notif.SendToUser(payload); err != nil {
+		log.Printf("failed sending gift notification to user %s: %v\n", payload.UserID, err)
+	}
+}
+
+func toGiftResponse(g models.PackageGift) dto.GiftResponse {
+	resp := dto.GiftResponse{
+		ID:             g.ID.String(),
+		Code:           g.Code,
+		PackageID:      g.PackageID.String(),
+		PackageName:    g.PackageName,
+		From:           g.Purchaser.Fullname,
+		RecipientEmail: g.RecipientEmail,
+		Message:        g.Message,
+		Status:         g.Status,
+		CreatedAt:      g.CreatedAt.Format(time.RFC3339),
+	}
+	if g.UserPackageID != nil {
+		resp.UserPackageID = g.UserPackageID.String()
+	}
+	if g.RedeemedAt != nil {
+		resp.RedeemedAt = g.RedeemedAt.Format(time.RFC3339)
+	}
+	return resp
+}
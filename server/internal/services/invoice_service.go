@@ -0,0 +1,346 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"os"
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	customErr "server/pkg/errors"
+	"server/pkg/gateway"
+	"server/pkg/money"
+	"server/pkg/pdf"
+	"server/pkg/utils"
+	"slices"
+	"strings"
+	"time"
+)
+
+type InvoiceService interface {
+	GetInvoice(paymentID, userID string) (*PaymentDocument, error)
+	GetReceipt(paymentID, userID string) (*PaymentDocument, error)
+	SendReceipt(payment *models.Payment)
+}
+
+// PaymentDocument is a rendered PDF of a payment, ready to download or attach to an email
+type PaymentDocument struct {
+	Filename string
+	Content  []byte
+}
+
+type invoiceService struct {
+	payment  PaymentService
+	location repositories.LocationRepository
+}
+
+func NewInvoiceService(payment PaymentService, location repositories.LocationRepository) InvoiceService {
+	return &invoiceService{
+		payment:  payment,
+		location: location,
+	}
+}
+
+// paidStatuses are the payments the studio received money for, only these get a receipt
+var paidStatuses = []string{"success", "partially_refunded", "refunded", "disputed"}
+
+var paymentStatusLabels = map[string]string{
+	"success":            "Paid",
+	"pending":            "Awaiting payment",
+	"failed":             "Failed",
+	"refunded":           "Refunded",
+	"partially_refunded": "Partially refunded",
+	"disputed":           "Disputed",
+}
+
+var paymentMethodLabels = map[string]string{
+	gateway.MethodCard:           "Card",
+	gateway.MethodQRIS:           "QRIS",
+	gateway.MethodVirtualAccount: "Virtual account",
+	gateway.MethodEWallet:        "E-wallet",
+	"cash":                       "Cash",
+	"bank_transfer":              "Bank transfer",
+	"edc":                        "EDC card terminal",
+	walletGateway:                "Wallet credit",
+	freeGateway:                  "No charge",
+}
+
+// GetInvoice renders the invoice of a payment, a member passes their user ID and only gets their own payments
+func (s *invoiceService) GetInvoice(paymentID, userID string) (*PaymentDocument, error) {
+	detail, err := s.paymentDetail(paymentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.render(detail, "invoice")
+}
+
+// GetReceipt renders the proof of payment, which only exists once the payment is paid
+func (s *invoiceService) GetReceipt(paymentID, userID string) (*PaymentDocument, error) {
+	detail, err := s.paymentDetail(paymentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(paidStatuses, detail.Status) {
+		return nil, customErr.NewBadRequest("a receipt is only issued for a paid payment")
+	}
+	return s.render(detail, "receipt")
+}
+
+// SendReceipt emails the receipt of a payment that was just paid to the member who paid it
+func (s *invoiceService) SendReceipt(payment *models.Payment) {
+	detail, err := s.payment.GetPaymentDetail(payment.ID.String())
+	if err != nil {
+		log.Printf("failed loading payment %s for its receipt: %v\n", payment.ID, err)
+		return
+	}
+	doc, err := s.render(detail, "receipt")
+	if err != nil {
+		log.Printf("failed rendering receipt of payment %s: %v\n", payment.ID, err)
+		return
+	}
+
+	total := formatAmount(detail.Total, detail.Currency)
+	subject := fmt.Sprintf("Payment successful - %s", detail.InvoiceNumber)
+	plain := fmt.Sprintf("Hi %s,\n\nwe received your payment of %s for %s (Invoice: %s). Your receipt is attached.",
+		detail.Fullname, total, detail.PackageName, detail.InvoiceNumber)
+	body := fmt.Sprintf("<p>Hi %s,</p><p>we received your payment of <b>%s</b> for <b>%s</b> (Invoice: %s). Your receipt is attached.</p>",
+		html.EscapeString(detail.Fullname), total, html.EscapeString(detail.PackageName), html.EscapeString(detail.InvoiceNumber))
+
+	// the gateway is waiting for the webhook answer, so the mail server is not
+	go func() {
+		attachment := utils.Attachment{Filename: doc.Filename, ContentType: "application/pdf", Content: doc.Content}
+		if err := utils.SendEmail(subject, detail.Email, plain, body, attachment); err != nil {
+			log.Printf("failed sending receipt of payment %s to %s: %v\n", detail.ID, detail.Email, err)
+		}
+	}()
+}
+
+func (s *invoiceService) paymentDetail(paymentID, userID string) (*dto.PaymentDetailResponse, error) {
+	detail, err := s.payment.GetPaymentDetail(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if userID != "" && detail.UserID != userID {
+		return nil, customErr.NewNotFound("payment not found")
+	}
+	return detail, nil
+}
+
+// studioBranding is the seller printed at the top of every invoice, set with the STUDIO_* variables
+type studioBranding struct {
+	Name    string
+	Address string
+	Email   string
+	Phone   string
+	TaxID   string
+}
+
+func loadStudioBranding() studioBranding {
+	brand := studioBranding{
+		Name:    os.Getenv("STUDIO_NAME"),
+		Address: os.Getenv("STUDIO_ADDRESS"),
+		Email:   os.Getenv("STUDIO_EMAIL"),
+		Phone:   os.Getenv("STUDIO_PHONE"),
+		TaxID:   os.Getenv("STUDIO_TAX_ID"),
+	}
+	if brand.Name == "" {
+		brand.Name = "SweatUp"
+	}
+	if brand.Email == "" {
+		brand.Email = os.Getenv("USER_EMAIL")
+	}
+	return brand
+}
+
+// render lays the payment out on a single A4 page, amounts come from the payment detail so the PDF always
+// matches what the API shows
+func (s *invoiceService) render(detail *dto.PaymentDetailResponse, kind string) (*PaymentDocument, error) {
+	const (
+		margin = 50.0
+		right  = pdf.PageWidth - margin
+		middle = pdf.PageWidth / 2
+	)
+	brand := loadStudioBranding()
+	title := strings.ToUpper(kind)
+	// numbers are handed out when the payment is paid, an unpaid invoice goes by its order ID
+	number, reference := detail.InvoiceNumber, detail.InvoiceNumber
+	if number == "" {
+		number, reference = "Issued on payment", "order-"+detail.ID
+	}
+	date := detail.PaidAt
+	if t, err := time.Parse(time.RFC3339, detail.PaidAt); err == nil {
+		date = t.Format("02 Jan 2006 15:04")
+	}
+
+	doc := pdf.New(fmt.Sprintf("%s %s", brand.Name, number))
+	doc.AddPage()
+	doc.FillRect(0, 0, pdf.PageWidth, 8, 0.15)
+
+	// seller on the left, document number and date on the right
+	doc.Text(margin, 70, pdf.Bold, 20, brand.Name)
+	doc.TextRight(right, 70, pdf.Bold, 20, title)
+	y := 88.0
+	for _, line := range nonEmpty(brand.Address, brand.Email, brand.Phone, prefixed("Tax ID: ", brand.TaxID)) {
+		for _, wrapped := range pdf.Wrap(pdf.Regular, 9, line, middle-margin) {
+			doc.Text(margin, y, pdf.Regular, 9, wrapped)
+			y += 12
+		}
+	}
+	meta := [][2]string{{"No.", number}, {"Date", date}, {"Status", statusLabel(detail.Status)}}
+	for i, row := range meta {
+		doc.TextRight(right-130, 88+float64(i)*13, pdf.Regular, 9, row[0])
+		doc.TextRight(right, 88+float64(i)*13, pdf.Bold, 9, row[1])
+	}
+	y = math.Max(y, 88+float64(len(meta))*13) + 20
+	doc.Line(margin, y, right, y, 0.5, 0.8)
+	y += 24
+
+	// billing details on the left, how and where it was paid on the right
+	billed := []string{detail.Fullname}
+	if detail.Billing != nil {
+		billed = append(billed, detail.Billing.Company)
+	}
+	billed = append(billed, detail.Email)
+	if detail.Billing != nil {
+		billed = append(billed, prefixed("Tax ID: ", detail.Billing.TaxID), detail.Billing.Address)
+	}
+	paid := []string{prefixed("Method: ", methodLabel(detail.PaymentMethod))}
+	if detail.Gateway != "" {
+		paid = append(paid, "Processed by: "+detail.Gateway)
+	}
+	if detail.LocationID != "" {
+		if location, err := s.location.GetLocationByID(detail.LocationID); err == nil && location != nil {
+			paid = append(paid, "Location: "+location.Name, location.Address)
+		}
+	}
+	if detail.GiftEmail != "" {
+		paid = append(paid, "Gift for: "+detail.GiftEmail)
+	}
+	left := textBlock(doc, margin, y, middle-margin-20, "BILLED TO", nonEmpty(billed...), true)
+	rightBlock := textBlock(doc, middle+20, y, right-middle-20, "PAYMENT", nonEmpty(paid...), false)
+	y = math.Max(left, rightBlock) + 24
+
+	// the item is charged at its net price, a voucher was already taken off it
+	doc.FillRect(margin, y, right-margin, 22, 0.93)
+	doc.Text(margin+8, y+15, pdf.Bold, 9, "DESCRIPTION")
+	doc.TextRight(right-8, y+15, pdf.Bold, 9, "AMOUNT")
+	y += 40
+	doc.Text(margin+8, y, pdf.Regular, 10, detail.PackageName)
+	doc.TextRight(right-8, y, pdf.Regular, 10, formatAmount(detail.BasePrice, detail.Currency))
+	if detail.VoucherCode != "" && detail.VoucherDiscount > 0 {
+		y += 14
+		doc.Text(margin+8, y, pdf.Regular, 8, fmt.Sprintf("Voucher %s: %s off", detail.VoucherCode, formatAmount(detail.VoucherDiscount, detail.Currency)))
+	}
+	y += 16
+	doc.Line(margin, y, right, y, 0.5, 0.8)
+	y += 22
+
+	// totals, each tax on its own line as it was charged
+	totals := func(label, amount string, font pdf.Font) {
+		doc.TextRight(right-130, y, font, 10, label)
+		doc.TextRight(right-8, y, font, 10, amount)
+		y += 18
+	}
+	totals("Subtotal", formatAmount(detail.BasePrice, detail.Currency), pdf.Regular)
+	for _, t := range detail.Taxes {
+		label := taxLabel(models.PaymentTax{Name: t.Name, Rate: t.Rate})
+		if t.Inclusive {
+			label += " (included)"
+		}
+		totals(label, formatAmount(t.Amount, detail.Currency), pdf.Regular)
+	}
+	if len(detail.Taxes) == 0 && detail.Tax > 0 {
+		totals("Tax", formatAmount(detail.Tax, detail.Currency), pdf.Regular)
+	}
+	doc.Line(right-250, y-10, right, y-10, 0.5, 0.8)
+	y += 4
+	totals("Total", formatAmount(detail.Total, detail.Currency), pdf.Bold)
+	due := money.FromMajor(detail.Total, detail.Currency)
+	if detail.WalletAmount > 0 && detail.PaymentMethod != walletGateway {
+		wallet := money.FromMajor(detail.WalletAmount, detail.Currency)
+		totals("Paid from wallet", wallet.Neg().String(), pdf.Regular)
+		due = due.Sub(wallet)
+	}
+	if detail.Refunded > 0 {
+		total := money.FromMajor(detail.Total, detail.Currency)
+		refunded := money.FromMajor(detail.Refunded, detail.Currency)
+		totals("Refunded", refunded.Neg().String(), pdf.Regular)
+		totals("Net paid", total.Sub(refunded).String(), pdf.Bold)
+	}
+	switch {
+	case kind == "receipt":
+		received := fmt.Sprintf("Received on %s", date)
+		if method := methodLabel(detail.PaymentMethod); method != "" {
+			received += " via " + method
+		}
+		doc.Text(margin, y+10, pdf.Regular, 9, received+".")
+	case detail.Status == "pending":
+		totals("Amount due", due.String(), pdf.Bold)
+	}
+
+	doc.Line(margin, pdf.PageHeight-70, right, pdf.PageHeight-70, 0.5, 0.8)
+	doc.Text(margin, pdf.PageHeight-55, pdf.Bold, 9, fmt.Sprintf("Thank you for training with %s.", brand.Name))
+	doc.Text(margin, pdf.PageHeight-42, pdf.Regular, 8, "This document was issued electronically and is valid without a signature.")
+
+	return &PaymentDocument{
+		Filename: fmt.Sprintf("%s-%s.pdf", kind, strings.ReplaceAll(reference, "/", "-")),
+		Content:  doc.Bytes(),
+	}, nil
+}
+
+// textBlock writes a heading and the lines under it, wrapped to width, and returns where the block ends
+func textBlock(doc *pdf.Document, x, y, width float64, heading string, lines []string, boldFirst bool) float64 {
+	doc.Text(x, y, pdf.Bold, 9, heading)
+	y += 16
+	for i, line := range lines {
+		font, size := pdf.Regular, 9.0
+		if i == 0 && boldFirst {
+			font, size = pdf.Bold, 10
+		}
+		for _, wrapped := range pdf.Wrap(font, size, line, width) {
+			doc.Text(x, y, font, size, wrapped)
+			y += 13
+		}
+	}
+	return y
+}
+
+func formatAmount(amount float64, currency string) string {
+	return money.FromMajor(amount, currency).String()
+}
+
+func statusLabel(status string) string {
+	if label, ok := paymentStatusLabels[status]; ok {
+		return label
+	}
+	return status
+}
+
+func methodLabel(method string) string {
+	if method == "-" {
+		return ""
+	}
+	if label, ok := paymentMethodLabels[method]; ok {
+		return label
+	}
+	return method
+}
+
+func prefixed(prefix, value string) string {
+	if value == "" {
+		return ""
+	}
+	return prefix + value
+}
+
+func nonEmpty(values ...string) []string {
+	var result []string
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" && v != "-" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"server/internal/models"
+	"server/internal/repositories"
+	customErr "server/pkg/errors"
+	"server/pkg/gateway"
+
+	"github.com/google/uuid"
+)
+
+type fakeLocationRepo struct {
+	repositories.LocationRepository
+	locations []models.Location
+}
+
+func (r *fakeLocationRepo) GetLocationByID(id string) (*models.Location, error) {
+	for _, location := range r.locations {
+		if location.ID.String() == id {
+			return &location, nil
+		}
+	}
+	return nil, nil
+}
+
+// invoiceFixture is a paid payment of IDR 100,000 plus 11% PPN bought at the downtown studio
+type invoiceFixture struct {
+	db      *store
+	svc     InvoiceService
+	user    *models.User
+	payment *models.Payment
+}
+
+func newInvoiceFixture(t *testing.T) *invoiceFixture {
+	t.Setenv("STUDIO_NAME", "SweatUp Downtown")
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	user := db.addUser(0)
+	user.Fullname = "Dewi Lestari"
+	downtown := models.Location{ID: uuid.New(), Name: "Downtown", Address: "Jl. Sudirman 1"}
+
+	payment := db.addPayment(t, gw, user, db.addPackage(10), 11_100_000, 0)
+	number := "INV/2026/10/000001"
+	payment.Status = "success"
+	payment.BasePrice, payment.Tax = 10_000_000, 1_100_000
+	payment.InvoiceNumber = &number
+	payment.LocationID = &downtown.ID
+	payment.BillingCompany, payment.BillingTaxID = "PT Sehat Selalu", "01.234.567.8-901.000"
+	db.taxes = append(db.taxes, models.PaymentTax{ID: uuid.New(), PaymentID: payment.ID, Name: "PPN", Rate: 11, Taxable: 10_000_000, Amount: 1_100_000, Currency: "IDR"})
+
+	return &invoiceFixture{
+		db:      db,
+		svc:     NewInvoiceService(newTestPaymentService(db, gw), &fakeLocationRepo{locations: []models.Location{downtown}}),
+		user:    user,
+		payment: payment,
+	}
+}
+
+// shows reports whether the document prints each of the texts
+func shows(t *testing.T, doc *PaymentDocument, texts ...string) {
+	t.Helper()
+	for _, text := range texts {
+		if !strings.Contains(string(doc.Content), "("+text+")") {
+			t.Errorf("%s does not show %q", doc.Filename, text)
+		}
+	}
+}
+
+func appCode(err error) int {
+	var appErr *customErr.AppError
+	if !errors.As(err, &appErr) {
+		return 0
+	}
+	return appErr.Code
+}
+
+func TestInvoiceShowsThePaymentAsCharged(t *testing.T) {
+	f := newInvoiceFixture(t)
+
+	doc, err := f.svc.GetInvoice(f.payment.ID.String(), f.user.ID.String())
+	if err != nil {
+		t.Fatalf("invoice: %v", err)
+	}
+	if doc.Filename != "invoice-INV-2026-10-000001.pdf" || !strings.HasPrefix(string(doc.Content), "%PDF-") {
+		t.Fatalf("document = %s, want a PDF named after the invoice number", doc.Filename)
+	}
+	shows(t, doc, "SweatUp Downtown", "INVOICE", "INV/2026/10/000001", "Paid",
+		"Dewi Lestari", "PT Sehat Selalu", "Tax ID: 01.234.567.8-901.000", "Method: Card", "Location: Downtown",
+		"10 Class Pack", "IDR 100,000.00", "PPN 11%", "IDR 11,000.00", "IDR 111,000.00")
+}
+
+func TestUnpaidInvoiceGoesByItsOrderAndHasNoReceipt(t *testing.T) {
+	f := newInvoiceFixture(t)
+	f.payment.Status = "pending"
+	f.payment.InvoiceNumber = nil
+
+	doc, err := f.svc.GetInvoice(f.payment.ID.String(), f.user.ID.String())
+	if err != nil {
+		t.Fatalf("invoice: %v", err)
+	}
+	if doc.Filename != "invoice-order-"+f.payment.ID.String()+".pdf" {
+		t.Fatalf("filename = %s, want the order ID before a number is issued", doc.Filename)
+	}
+	shows(t, doc, "Issued on payment", "Awaiting payment", "Amount due")
+
+	for _, status := range []string{"pending", "failed"} {
+		f.payment.Status = status
+		if _, err := f.svc.GetReceipt(f.payment.ID.String(), f.user.ID.String()); appCode(err) != http.StatusBadRequest {
+			t.Fatalf("%s receipt err = %v, want it rejected", status, err)
+		}
+	}
+}
+
+func TestReceiptConfirmsWhatWasReceived(t *testing.T) {
+	f := newInvoiceFixture(t)
+	f.payment.Status = "partially_refunded"
+	f.payment.RefundedAmount = 1_100_000
+
+	doc, err := f.svc.GetReceipt(f.payment.ID.String(), f.user.ID.String())
+	if err != nil {
+		t.Fatalf("receipt: %v", err)
+	}
+	if doc.Filename != "receipt-INV-2026-10-000001.pdf" {
+		t.Fatalf("filename = %s", doc.Filename)
+	}
+	shows(t, doc, "RECEIPT", "Partially refunded", "IDR -11,000.00", "Net paid", "IDR 100,000.00")
+	if !strings.Contains(string(doc.Content), "via Card.") {
+		t.Fatalf("the receipt does not say how the payment was received")
+	}
+}
+
+func TestPaymentDocumentsBelongToTheirMember(t *testing.T) {
+	f := newInvoiceFixture(t)
+	other := f.db.addUser(0)
+
+	if _, err := f.svc.GetInvoice(f.payment.ID.String(), other.ID.String()); appCode(err) != http.StatusNotFound {
+		t.Fatalf("invoice of another member err = %v, want not found", err)
+	}
+	if _, err := f.svc.GetReceipt(f.payment.ID.String(), other.ID.String()); appCode(err) != http.StatusNotFound {
+		t.Fatalf("receipt of another member err = %v, want not found", err)
+	}
+	if _, err := f.svc.GetInvoice(uuid.NewString(), f.user.ID.String()); appCode(err) != http.StatusNotFound {
+		t.Fatalf("invoice of an unknown payment err = %v, want not found", err)
+	}
+	// staff download without a user ID
+	if _, err := f.svc.GetReceipt(f.payment.ID.String(), ""); err != nil {
+		t.Fatalf("staff receipt: %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"server/internal/models"
 	"server/internal/repositories"
 	customErr "server/pkg/errors"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -31,6 +32,7 @@ func (s *locationService) CreateLocation(req dto.CreateLocationRequest) error {
 		Name:        req.Name,
 		Address:     req.Address,
 		GeoLocation: req.GeoLocation,
+		Code:        strings.ToUpper(req.Code),
 	}
 
 	if err := s.repo.CreateLocation(&location); err != nil {
@@ -48,6 +50,7 @@ func (s *locationService) UpdateLocation(id string, req dto.UpdateLocationReques
 	location.Name = req.Name
 	location.Address = req.Address
 	location.GeoLocation = req.GeoLocation
+	location.Code = strings.ToUpper(req.Code)
 
 	if err := s.repo.UpdateLocation(location); err != nil {
 		return customErr.NewInternal("failed to update location", err)
@@ -80,6 +83,7 @@ func (s *locationService) GetAllLocations() ([]dto.LocationResponse, error) {
 			Name:        l.Name,
 			Address:     l.Address,
 			GeoLocation: l.GeoLocation,
+			Code:        l.Code,
 		})
 	}
 	return result, nil
@@ -96,5 +100,6 @@ func (s *locationService) GetLocationByID(id string) (*dto.LocationResponse, err
 		Name:        location.Name,
 		Address:     location.Address,
 		GeoLocation: location.GeoLocation,
+		Code:        location.Code,
 	}, nil
 }
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	customErr "server/pkg/errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PackageMemberService interface {
+	// owner
+	InviteMember(ownerID, userPackageID string, req dto.InviteMemberRequest) (*dto.PackageMemberResponse, error)
+	GetPackageUsage(ownerID, userPackageID string) (*dto.SharedPackageUsageResponse, error)
+	UpdateMemberCap(ownerID, userPackageID, memberID string, req dto.UpdateMemberCapRequest) (*dto.PackageMemberResponse, error)
+	RemoveMember(ownerID, userPackageID, memberID string) error
+
+	// invited member
+	GetMyInvitations(userID string) ([]dto.PackageInvitationResponse, error)
+	RespondInvitation(userID, id string, accept bool) error
+}
+
+type packageMemberService struct {
+	member  repositories.PackageMemberRepository
+	userPkg repositories.UserPackageRepository
+	pkg     repositories.PackageRepository
+	auth    repositories.AuthRepository
+	notif   NotificationService
+}
+
+func NewPackageMemberService(member repositories.PackageMemberRepository, userPkg repositories.UserPackageRepository, pkg repositories.PackageRepository, auth repositories.AuthRepository, notif NotificationService) PackageMemberService {
+	return &packageMemberService{
+		member:  member,
+		userPkg: userPkg,
+		pkg:     pkg,
+		auth:    auth,
+		notif:   notif,
+	}
+}
+
+func (s *packageMemberService) InviteMember(ownerID, userPackageID string, req dto.InviteMemberRequest) (*dto.PackageMemberResponse, error) {
+	userPackage, err := s.ownedPackage(ownerID, userPackageID)
+	if err != nil {
+		return nil, err
+	}
+	if userPackage.ExpiredAt != nil && !userPackage.ExpiredAt.After(time.Now()) {
+		return nil, customErr.NewBadRequest("package has expired")
+	}
+
+	pkg, err := s.pkg.GetPackageByID(userPackage.PackageID.String())
+	if err != nil || pkg == nil {
+		return nil, customErr.NewNotFound("package not found")
+	}
+	if userPackage.Type != "credit_pack" || pkg.MaxMembers <= 0 {
+		return nil, customErr.NewBadRequest("this package cannot be shared")
+	}
+
+	user, err := s.auth.GetUserByEmail(strings.TrimSpace(req.Email))
+	if err != nil || user == nil {
+		return nil, customErr.NewNotFound("no account found with this email")
+	}
+	if user.ID == userPackage.UserID {
+		return nil, customErr.NewBadRequest("you already own this package")
+	}
+	if user.Role != "customer" {
+		return nil, customErr.NewBadRequest("packages can only be shared with customers")
+	}
+
+	member, err := s.member.GetMember(userPackage.ID, user.ID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to check membership", err)
+	}
+	if member != nil && (member.Status == "invited" || member.Status == "active") {
+		return nil, customErr.NewConflict("user is already a member of this package")
+	}
+
+	count, err := s.member.CountOpenMembers(userPackage.ID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to count members", err)
+	}
+	if int(count) >= pkg.MaxMembers {
+		return nil, customErr.NewConflict(fmt.Sprintf("this package can be shared with up to %d members", pkg.MaxMembers))
+	}
+
+	owner := uuid.MustParse(ownerID)
+	if member == nil {
+		member = &models.UserPackageMember{
+			UserPackageID: userPackage.ID,
+			UserID:        user.ID,
+			InvitedBy:     owner,
+			Status:        "invited",
+			CreditCap:     req.CreditCap,
+		}
+		err = s.member.CreateMember(member)
+	} else {
+		// declined or removed members are invited again on the same row
+		member.InvitedBy = owner
+		member.Status = "invited"
+		member.CreditCap = req.CreditCap
+		member.JoinedAt = nil
+		err = s.member.UpdateMember(member)
+	}
+	if err != nil {
+		return nil, customErr.NewInternal("failed to invite member", err)
+	}
+	member.User = *user
+
+	s.notify(user.ID, "Package Invitation", fmt.Sprintf("You have been invited to share the %s package. Open your invitations to accept.", userPackage.PackageName))
+
+	resp := toPackageMemberResponse(*member, 0)
+	return &resp, nil
+}
+
+// GetPackageUsage reports how many credits the owner and every member spent, along with each booking made on the package
+func (s *packageMemberService) GetPackageUsage(ownerID, userPackageID string) (*dto.SharedPackageUsageResponse, error) {
+	userPackage, err := s.ownedPackage(ownerID, userPackageID)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.member.GetMembersByUserPackageID(userPackage.ID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch members", err)
+	}
+	usage, err := s.member.GetCreditsUsedByUser(userPackage.ID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch credit usage", err)
+	}
+	bookings, err := s.member.GetUsage(userPackage.ID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch package bookings", err)
+	}
+
+	used := make(map[string]int, len(usage))
+	for _, u := range usage {
+		used[u.UserID] = u.Used
+	}
+
+	maxMembers := 0
+	if pkg, err := s.pkg.GetPackageByID(userPackage.PackageID.String()); err == nil && pkg != nil {
+		maxMembers = pkg.MaxMembers
+	}
+
+	memberResponses := make([]dto.PackageMemberResponse, 0, len(members))
+	for _, m := range members {
+		memberResponses = append(memberResponses, toPackageMemberResponse(m, used[m.UserID.String()]))
+	}
+
+	entries := make([]dto.PackageUsageEntry, 0, len(bookings))
+	for _, b := range bookings {
+		entries = append(entries, dto.PackageUsageEntry{
+			BookingID: b.ID.String(),
+			UserID:    b.UserID.String(),
+			Fullname:  b.User.Fullname,
+			ClassName: b.ClassSchedule.ClassName,
+			Date:      b.ClassSchedule.Date.Format("2006-01-02"),
+			Status:    b.Status,
+		})
+	}
+
+	return &dto.SharedPackageUsageResponse{
+		UserPackageID:    userPackage.ID.String(),
+		PackageName:      userPackage.PackageName,
+		RemainingCredit:  userPackage.RemainingCredit,
+		MaxMembers:       maxMembers,
+		OwnerCreditsUsed: used[userPackage.UserID.String()],
+		Members:          memberResponses,
+		Bookings:         entries,
+	}, nil
+}
+
+func (s *packageMemberService) UpdateMemberCap(ownerID, userPackageID, memberID string, req dto.UpdateMemberCapRequest) (*dto.PackageMemberResponse, error) {
+	member, err := s.ownedMember(ownerID, userPackageID, memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	member.CreditCap = req.CreditCap
+	if err := s.member.UpdateMember(member); err != nil {
+		return nil, customErr.NewInternal("failed to update member", err)
+	}
+
+	used := 0
+	usage, err := s.member.GetCreditsUsedByUser(member.UserPackageID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch credit usage", err)
+	}
+	for _, u := range usage {
+		if u.UserID == member.UserID.String() {
+			used = u.Used
+		}
+	}
+
+	resp := toPackageMemberResponse(*member, used)
+	return &resp, nil
+}
+
+// RemoveMember stops the member from booking with the package, classes already booked are kept
+func (s *packageMemberService) RemoveMember(ownerID, userPackageID, memberID string) error {
+	member, err := s.ownedMember(ownerID, userPackageID, memberID)
+	if err != nil {
+		return err
+	}
+
+	member.Status = "removed"
+	if err := s.member.UpdateMember(member); err != nil {
+		return customErr.NewInternal("failed to remove member", err)
+	}
+
+	s.notify(member.UserID, "Package Access Removed", fmt.Sprintf("You no longer have access to the shared %s package.", member.UserPackage.PackageName))
+	return nil
+}
+
+func (s *packageMemberService) GetMyInvitations(userID string) ([]dto.PackageInvitationResponse, error) {
+	members, err := s.member.GetInvitationsByUserID(userID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch invitations", err)
+	}
+
+	result := make([]dto.PackageInvitationResponse, 0, len(members))
+	for _, m := range members {
+		invitation := dto.PackageInvitationResponse{
+			ID:            m.ID.String(),
+			UserPackageID: m.UserPackageID.String(),
+			PackageName:   m.UserPackage.PackageName,
+			InvitedBy:     m.Inviter.Fullname,
+			CreditCap:     m.CreditCap,
+			CreatedAt:     m.CreatedAt.Format(time.RFC3339),
+		}
+		if m.UserPackage.ExpiredAt != nil {
+			invitation.ExpiredAt = m.UserPackage.ExpiredAt.Format("2006-01-02")
+		}
+		result = append(result, invitation)
+	}
+	return result, nil
+}
+
+func (s *packageMemberService) RespondInvitation(userID, id string, accept bool) error {
+	member, err := s.member.GetMemberByID(id)
+	if err != nil || member == nil || member.UserID.String() != userID {
+		return customErr.NewNotFound("invitation not found")
+	}
+	if member.Status != "invited" {
+		return customErr.NewBadRequest(fmt.Sprintf("invitation is already %s", member.Status))
+	}
+
+	if accept {
+		now := time.Now()
+		member.Status = "active"
+		member.JoinedAt = &now
+	} else {
+		member.Status = "declined"
+	}
+	if err := s.member.UpdateMember(member); err != nil {
+		return customErr.NewInternal("failed to respond to invitation", err)
+	}
+
+	title, verb := "Invitation Declined", "declined"
+	if accept {
+		title, verb = "Invitation Accepted", "accepted"
+	}
+	s.notify(member.UserPackage.UserID, title, fmt.Sprintf("%s %s your invitation to share %s.", member.User.Fullname, verb, member.UserPackage.PackageName))
+	return nil
+}
+
+func (s *packageMemberService) ownedPackage(ownerID, userPackageID string) (*models.UserPackage, error) {
+	userPackage, err := s.userPkg.GetUserPackageByID(userPackageID)
+	if err != nil || userPackage == nil || userPackage.UserID.String() != ownerID {
+		return nil, customErr.NewNotFound("user package not found")
+	}
+	return userPackage, nil
+}
+
+func (s *packageMemberService) ownedMember(ownerID, userPackageID, memberID string) (*models.UserPackageMember, error) {
+	member, err := s.member.GetMemberByID(memberID)
+	if err != nil || member == nil ||
+		member.UserPackageID.String() != userPackageID ||
+		member.UserPackage.UserID.String() != ownerID ||
+		(member.Status != "invited" && member.Status != "active") {
+		return nil, customErr.NewNotFound("member not found")
+	}
+	return member, nil
+}
+
+func (s *packageMemberService) notify(userID uuid.UUID, title, message string) {
+	payload := dto.NotificationEvent{
+		UserID:  userID.String(),
+		Type:    "system_message",
+		Title:   title,
+		Message: message,
+	}
+	if err := s.notif.SendToUser(payload); err != nil {
+		log.Printf("failed sending package member notification to user %s: %v\n", payload.UserID, err)
+	}
+}
+
+func toPackageMemberResponse(m models.UserPackageMember, used int) dto.PackageMemberResponse {
+	resp := dto.PackageMemberResponse{
+		ID:          m.ID.String(),
+		UserID:      m.UserID.String(),
+		Fullname:    m.User.Fullname,
+		Email:       m.User.Email,
+		Status:      m.Status,
+		CreditCap:   m.CreditCap,
+		CreditsUsed: used,
+	}
+	if m.JoinedAt != nil {
+		resp.JoinedAt = m.JoinedAt.Format(time.RFC3339)
+	}
+	return resp
+}
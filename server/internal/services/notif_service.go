@@ -15,6 +15,7 @@ type NotificationService interface {
 	SendClassReminder() error
 	MarkAllAsRead(userID string) error
 	SendToUser(req dto.NotificationEvent) error
+	SendToAdmins(req dto.NotificationEvent) error
 	SendNotificationByType(req dto.SendNotificationRequest) error
 	GetAllNotifications(userID string) ([]dto.NotificationResponse, error)
 	GetSettingsByUser(userID string) ([]dto.NotificationSettingResponse, error)
@@ -169,10 +170,13 @@ func (s *notificationService) SendToUser(req dto.NotificationEvent) error {
 	if err != nil {
 		return err
 	}
+	if ntype == nil {
+		return fmt.Errorf("notification type %s not found", req.Type)
+	}
 
 	for _, channel := range []string{"browser"} {
 		setting, err := s.repo.FindSetting(uid, ntype.ID, channel)
-		if err != nil || !setting.Enabled {
+		if err != nil || setting == nil || !setting.Enabled {
 			continue
 		}
 
@@ -197,3 +201,27 @@ func (s *notificationService) SendToUser(req dto.NotificationEvent) error {
 
 	return nil
 }
+
+// operational alerts for admins are always delivered to the browser, regardless of user settings
+func (s *notificationService) SendToAdmins(req dto.NotificationEvent) error {
+	adminIDs, err := s.repo.GetUserIDsByRole("admin")
+	if err != nil {
+		return customErr.NewInternal("Failed to fetch admin users", err)
+	}
+
+	var notifs []models.Notification
+	for _, id := range adminIDs {
+		notifs = append(notifs, models.Notification{
+			UserID:   id,
+			TypeCode: req.Type,
+			Title:    req.Title,
+			Message:  req.Message,
+			Channel:  "browser",
+		})
+	}
+	if len(notifs) == 0 {
+		return nil
+	}
+
+	return s.repo.InsertNotifications(notifs)
+}
@@ -6,7 +6,9 @@ import (
 	"server/internal/models"
 	"server/internal/repositories"
 	customErr "server/pkg/errors"
+	"server/pkg/money"
 	"server/pkg/utils"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -16,18 +18,28 @@ type PackageService interface {
 	CreatePackage(req dto.CreatePackageRequest) error
 	UpdatePackage(id string, req dto.UpdatePackageRequest) error
 	GetPackageByID(id string) (*dto.PackageDetailResponse, error)
-	GetAllPackages(params dto.PackageQueryParam) ([]dto.PackageListResponse, *dto.PaginationResponse, error)
+	GetAllPackages(userID string, params dto.PackageQueryParam) ([]dto.PackageListResponse, *dto.PaginationResponse, error)
 }
 
 type packageService struct {
-	repo repositories.PackageRepository
+	repo    repositories.PackageRepository
+	payment repositories.PaymentRepository
+	userPkg repositories.UserPackageRepository
 }
 
-func NewPackageService(repo repositories.PackageRepository) PackageService {
-	return &packageService{repo}
+func NewPackageService(repo repositories.PackageRepository, payment repositories.PaymentRepository, userPkg repositories.UserPackageRepository) PackageService {
+	return &packageService{repo, payment, userPkg}
 }
 
 func (s *packageService) CreatePackage(req dto.CreatePackageRequest) error {
+	pkgType, err := validatePackageType(req.Type, req.Credit, req.WeeklyLimit, req.Expired, req.AutoRenew)
+	if err != nil {
+		return err
+	}
+	if (req.MaxMembers > 0 || req.MaxTransfer > 0) && pkgType != "credit_pack" {
+		return customErr.NewBadRequest("only credit packs can be shared or transferred")
+	}
+
 	var classes []models.Class
 	for _, classID := range req.ClassIDs {
 		classUUID, err := uuid.Parse(classID)
@@ -37,21 +49,39 @@ func (s *packageService) CreatePackage(req dto.CreatePackageRequest) error {
 		classes = append(classes, models.Class{ID: classUUID})
 	}
 
+	currency := money.DefaultCurrency()
 	pkg := models.Package{
 		Name:           req.Name,
-		Price:          req.Price,
+		Price:          money.FromMajor(req.Price, currency).Amount,
+		Currency:       currency,
+		Type:           pkgType,
 		Credit:         req.Credit,
+		WeeklyLimit:    req.WeeklyLimit,
+		AutoRenew:      req.AutoRenew,
+		MaxFreezeDays:  req.MaxFreeze,
+		FreezeFee:      money.FromMajor(req.FreezeFee, currency).Amount,
+		MaxMembers:     req.MaxMembers,
+		MaxTransfer:    req.MaxTransfer,
 		Discount:       req.Discount,
+		TaxCategory:    req.TaxCategory,
+		TaxInclusive:   req.TaxInclusive,
+		TaxExempt:      req.TaxExempt,
 		Image:          req.ImageURL,
 		IsActive:       req.IsActive,
 		AdditionalList: req.Additional,
 		Description:    req.Description,
 		Classes:        classes,
 	}
+	if err := applyEligibilityRules(&pkg, req.PackageEligibilityRequest); err != nil {
+		return err
+	}
 
 	if req.Expired != 0 {
 		pkg.Expired = req.Expired
 	}
+	if pkg.AutoRenew && !packagePrice(&pkg).IsPositive() {
+		return customErr.NewBadRequest("auto-renewing memberships cannot be free")
+	}
 
 	if err := s.repo.CreatePackage(&pkg); err != nil {
 		return customErr.NewInternal("failed to create package", err)
@@ -65,12 +95,33 @@ func (s *packageService) UpdatePackage(id string, req dto.UpdatePackageRequest)
 		return customErr.NewNotFound("package not found")
 	}
 
+	pkgType, err := validatePackageType(req.Type, req.Credit, req.WeeklyLimit, req.Expired, req.AutoRenew)
+	if err != nil {
+		return err
+	}
+	if (req.MaxMembers > 0 || req.MaxTransfer > 0) && pkgType != "credit_pack" {
+		return customErr.NewBadRequest("only credit packs can be shared or transferred")
+	}
+
 	pkg.Name = req.Name
-	pkg.Price = req.Price
+	pkg.Price = money.FromMajor(req.Price, pkg.Currency).Amount
+	pkg.Type = pkgType
 	pkg.Credit = req.Credit
+	pkg.WeeklyLimit = req.WeeklyLimit
+	pkg.AutoRenew = req.AutoRenew
+	pkg.MaxFreezeDays = req.MaxFreeze
+	pkg.FreezeFee = money.FromMajor(req.FreezeFee, pkg.Currency).Amount
+	pkg.MaxMembers = req.MaxMembers
+	pkg.MaxTransfer = req.MaxTransfer
 	pkg.Expired = req.Expired
 	pkg.Description = req.Description
 	pkg.IsActive = req.IsActive
+	pkg.TaxCategory = req.TaxCategory
+	pkg.TaxInclusive = req.TaxInclusive
+	pkg.TaxExempt = req.TaxExempt
+	if err := applyEligibilityRules(pkg, req.PackageEligibilityRequest); err != nil {
+		return err
+	}
 
 	if req.ImageURL != "" {
 		pkg.Image = req.ImageURL
@@ -92,6 +143,9 @@ func (s *packageService) UpdatePackage(id string, req dto.UpdatePackageRequest)
 		}
 		pkg.Classes = classes
 	}
+	if pkg.AutoRenew && !packagePrice(pkg).IsPositive() {
+		return customErr.NewBadRequest("auto-renewing memberships cannot be free")
+	}
 
 	if err := s.repo.UpdatePackage(pkg); err != nil {
 		return customErr.NewInternal("failed to update package", err)
@@ -99,14 +153,180 @@ func (s *packageService) UpdatePackage(id string, req dto.UpdatePackageRequest)
 	return nil
 }
 
-func (s *packageService) GetAllPackages(params dto.PackageQueryParam) ([]dto.PackageListResponse, *dto.PaginationResponse, error) {
-	packages, total, err := s.repo.GetAllPackages(params)
+// packagePrice is what the package sells for after its own discount, before vouchers and tax
+func packagePrice(pkg *models.Package) money.Money {
+	price := money.New(pkg.Price, pkg.Currency)
+	return price.Sub(price.Percent(pkg.Discount))
+}
+
+// validatePackageType checks the fields each package type relies on, Expired is the validity
+// of a credit pack and the period length of a membership
+func validatePackageType(pkgType string, credit, weeklyLimit, expired int, autoRenew bool) (string, error) {
+	if pkgType == "" {
+		pkgType = "credit_pack"
+	}
+
+	switch pkgType {
+	case "credit_pack":
+		if credit <= 0 {
+			return "", customErr.NewBadRequest("credit packs need at least 1 credit")
+		}
+		if autoRenew {
+			return "", customErr.NewBadRequest("only memberships can auto-renew")
+		}
+	case "weekly_limit":
+		if weeklyLimit <= 0 {
+			return "", customErr.NewBadRequest("weekly plans need a weekly class limit")
+		}
+	}
+
+	if autoRenew && expired > 365 {
+		return "", customErr.NewBadRequest("auto-renewing memberships can bill at most once a year")
+	}
+	return pkgType, nil
+}
+
+// applyEligibilityRules copies the purchase rules onto the package, an empty location list opens it to every location
+func applyEligibilityRules(pkg *models.Package, req dto.PackageEligibilityRequest) error {
+	pkg.FirstPurchaseOnly = req.FirstPurchaseOnly
+	pkg.MaxPerUser = req.MaxPerUser
+	pkg.Segment = req.Segment
+	if pkg.Segment == "" {
+		pkg.Segment = "all"
+	}
+
+	pkg.AvailableFrom, pkg.AvailableUntil = nil, nil
+	if req.AvailableFrom != "" {
+		from, err := utils.ParseDate(req.AvailableFrom)
+		if err != nil {
+			return customErr.NewBadRequest("invalid available from date, format must be YYYY-MM-DD")
+		}
+		pkg.AvailableFrom = &from
+	}
+	if req.AvailableUntil != "" {
+		until, err := utils.ParseDate(req.AvailableUntil)
+		if err != nil {
+			return customErr.NewBadRequest("invalid available until date, format must be YYYY-MM-DD")
+		}
+		pkg.AvailableUntil = &until
+	}
+	if pkg.AvailableFrom != nil && pkg.AvailableUntil != nil && pkg.AvailableUntil.Before(*pkg.AvailableFrom) {
+		return customErr.NewBadRequest("available until must be on or after available from")
+	}
+
+	pkg.LocationList = []string{}
+	for _, id := range req.LocationIDs {
+		locationID, err := uuid.Parse(id)
+		if err != nil {
+			return customErr.NewBadRequest("invalid location ID format")
+		}
+		pkg.LocationList = append(pkg.LocationList, locationID.String())
+	}
+	return nil
+}
+
+// purchaseHistory is what the eligibility rules of a package are checked against
+type purchaseHistory struct {
+	purchases map[string]int64
+	hasActive bool
+}
+
+func loadPurchaseHistory(payment repositories.PaymentRepository, userPkg repositories.UserPackageRepository, userID string) (*purchaseHistory, error) {
+	purchases, err := payment.CountPackagePurchases(userID)
+	if err != nil {
+		return nil, err
+	}
+	hasActive, err := userPkg.HasActivePackage(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &purchaseHistory{purchases: purchases, hasActive: hasActive}, nil
+}
+
+// purchaseIneligibility explains why the package cannot be bought, it is empty when the purchase is allowed
+func purchaseIneligibility(pkg *models.Package, history *purchaseHistory, now time.Time) string {
+	if !pkg.IsActive {
+		return "this package is not available"
+	}
+	if pkg.AvailableFrom != nil && now.Before(*pkg.AvailableFrom) {
+		return fmt.Sprintf("this package is available from %s", pkg.AvailableFrom.Format("2006-01-02"))
+	}
+	if pkg.AvailableUntil != nil && !now.Before(pkg.AvailableUntil.AddDate(0, 0, 1)) {
+		return "this package is no longer available"
+	}
+
+	var total int64
+	for _, count := range history.purchases {
+		total += count
+	}
+	if pkg.FirstPurchaseOnly && total > 0 {
+		return "this package is only available on your first purchase"
+	}
+	if pkg.MaxPerUser > 0 && history.purchases[pkg.ID.String()] >= int64(pkg.MaxPerUser) {
+		return fmt.Sprintf("this package can only be purchased %d time(s) per member", pkg.MaxPerUser)
+	}
+
+	switch pkg.Segment {
+	case "members":
+		if !history.hasActive {
+			return "this package is only available to members with an active package"
+		}
+	case "lapsed":
+		if history.hasActive || total == 0 {
+			return "this package is only available to returning members without an active package"
+		}
+	}
+	return ""
+}
+
+// hasBuyerRules reports whether eligibility depends on who buys the package
+func hasBuyerRules(pkg *models.Package) bool {
+	return pkg.FirstPurchaseOnly || pkg.MaxPerUser > 0 || (pkg.Segment != "" && pkg.Segment != "all")
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// GetAllPackages flags the packages a signed-in customer cannot buy, or leaves them out with EligibleOnly
+func (s *packageService) GetAllPackages(userID string, params dto.PackageQueryParam) ([]dto.PackageListResponse, *dto.PaginationResponse, error) {
+	var history *purchaseHistory
+	if userID != "" {
+		var err error
+		if history, err = loadPurchaseHistory(s.payment, s.userPkg, userID); err != nil {
+			return nil, nil, customErr.NewInternal("failed to fetch purchase history", err)
+		}
+	}
+
+	// eligibility is not known to the query, so filtered results are paginated here
+	query := params
+	filter := history != nil && params.EligibleOnly
+	if filter {
+		query.Page, query.Limit = 1, -1
+	}
+
+	packages, total, err := s.repo.GetAllPackages(query)
 	if err != nil {
 		return nil, nil, customErr.NewInternal("failed to fetch packages", err)
 	}
 
+	now := time.Now().UTC()
 	var results []dto.PackageListResponse
 	for _, p := range packages {
+		var eligible *bool
+		var reason string
+		if history != nil {
+			reason = purchaseIneligibility(&p, history, now)
+			ok := reason == ""
+			if filter && !ok {
+				continue
+			}
+			eligible = &ok
+		}
+
 		var classes []dto.ClassSummaryResponse
 		for _, c := range p.Classes {
 			classes = append(classes, dto.ClassSummaryResponse{
@@ -120,17 +340,44 @@ func (s *packageService) GetAllPackages(params dto.PackageQueryParam) ([]dto.Pac
 			ID:          p.ID.String(),
 			Name:        p.Name,
 			Description: p.Description,
-			Price:       p.Price,
+			Currency:    p.Currency,
+			Price:       majorUnits(p.Price, p.Currency),
+			Type:        p.Type,
 			Credit:      p.Credit,
+			WeeklyLimit: p.WeeklyLimit,
+			AutoRenew:   p.AutoRenew,
+			MaxFreeze:   p.MaxFreezeDays,
+			FreezeFee:   majorUnits(p.FreezeFee, p.Currency),
+			MaxMembers:  p.MaxMembers,
+			MaxTransfer: p.MaxTransfer,
 			Image:       p.Image,
 			Discount:    p.Discount,
 			Expired:     p.Expired,
 			IsActive:    p.IsActive,
 			Additional:  p.AdditionalList,
 			Classes:     classes,
+
+			TaxCategory:  p.TaxCategory,
+			TaxInclusive: p.TaxInclusive,
+			TaxExempt:    p.TaxExempt,
+
+			FirstPurchaseOnly: p.FirstPurchaseOnly,
+			MaxPerUser:        p.MaxPerUser,
+			Segment:           p.Segment,
+			AvailableFrom:     formatDate(p.AvailableFrom),
+			AvailableUntil:    formatDate(p.AvailableUntil),
+			LocationIDs:       p.LocationList,
+			Eligible:          eligible,
+			IneligibleReason:  reason,
 		})
 	}
 
+	if filter {
+		total = int64(len(results))
+		start := min((params.Page-1)*params.Limit, len(results))
+		results = results[start:min(start+params.Limit, len(results))]
+	}
+
 	pagination := utils.Paginate(total, params.Page, params.Limit)
 	return results, pagination, nil
 }
@@ -155,14 +402,33 @@ func (s *packageService) GetPackageByID(id string) (*dto.PackageDetailResponse,
 		ID:          pkg.ID.String(),
 		Name:        pkg.Name,
 		Description: pkg.Description,
-		Price:       pkg.Price,
+		Currency:    pkg.Currency,
+		Price:       majorUnits(pkg.Price, pkg.Currency),
+		Type:        pkg.Type,
 		Credit:      pkg.Credit,
+		WeeklyLimit: pkg.WeeklyLimit,
+		AutoRenew:   pkg.AutoRenew,
+		MaxFreeze:   pkg.MaxFreezeDays,
+		FreezeFee:   majorUnits(pkg.FreezeFee, pkg.Currency),
+		MaxMembers:  pkg.MaxMembers,
+		MaxTransfer: pkg.MaxTransfer,
 		Discount:    pkg.Discount,
 		Expired:     pkg.Expired,
 		Image:       pkg.Image,
 		IsActive:    pkg.IsActive,
 		Additional:  pkg.AdditionalList,
 		Classes:     classes,
+
+		TaxCategory:  pkg.TaxCategory,
+		TaxInclusive: pkg.TaxInclusive,
+		TaxExempt:    pkg.TaxExempt,
+
+		FirstPurchaseOnly: pkg.FirstPurchaseOnly,
+		MaxPerUser:        pkg.MaxPerUser,
+		Segment:           pkg.Segment,
+		AvailableFrom:     formatDate(pkg.AvailableFrom),
+		AvailableUntil:    formatDate(pkg.AvailableUntil),
+		LocationIDs:       pkg.LocationList,
 	}, nil
 }
 
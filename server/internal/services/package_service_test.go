@@ -0,0 +1,26 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"server/internal/dto"
+	customErr "server/pkg/errors"
+)
+
+func TestFreeAutoRenewingMembershipIsRejected(t *testing.T) {
+	svc := NewPackageService(nil, nil, nil)
+	err := svc.CreatePackage(dto.CreatePackageRequest{
+		Name:      "Unlimited Monthly",
+		Type:      "unlimited",
+		Price:     500_000,
+		Discount:  100,
+		Expired:   30,
+		AutoRenew: true,
+	})
+
+	var appErr *customErr.AppError
+	if !errors.As(err, &appErr) || appErr.Code != 400 || appErr.Message != "auto-renewing memberships cannot be free" {
+		t.Fatalf("err = %v, want a bad request", err)
+	}
+}
@@ -1,39 +1,78 @@
 package services
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"server/internal/dto"
 	"server/internal/models"
 	"server/internal/repositories"
 	customErr "server/pkg/errors"
+	"server/pkg/gateway"
+	"server/pkg/money"
 	"server/pkg/utils"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/stripe/stripe-go/v75"
-	"github.com/stripe/stripe-go/v75/checkout/session"
 	"gorm.io/gorm"
 )
 
 type PaymentService interface {
 	ExpireOldPendingPayments() error
-	StripeWebhookNotification(event stripe.Event) error
+	ProcessWebhookEvent(event *gateway.Event) error
 	GetPaymentDetail(paymentID string) (*dto.PaymentDetailResponse, error)
 	CreatePayment(userID string, req dto.CreatePaymentRequest) (*dto.CreatePaymentResponse, error)
+	CreateFeePayment(userID string, fee dto.FeeCharge) (*models.Payment, error)
+	OnPaymentSucceeded(purpose string, hook PaymentSucceededHook)
+	OnPaymentFailed(purpose string, hook PaymentFailedHook)
+	OnPaymentSettled(listener PaymentSettledListener)
+	GrantPackage(payment *models.Payment, userID string) (*models.UserPackage, error)
+	QuoteUpgrade(userID string, req dto.UpgradePackageRequest) (*dto.UpgradeQuoteResponse, error)
+	UpgradePackage(userID string, req dto.UpgradePackageRequest) (*dto.CreatePaymentResponse, error)
+	RecordOfflinePayment(staffID string, req dto.OfflinePaymentRequest) (*dto.PaymentDetailResponse, error)
+	GetCashDrawerReport(params dto.CashDrawerQueryParam) (*dto.CashDrawerReport, error)
 	GetAllUserPayments(params dto.PaymentQueryParam) ([]dto.PaymentListResponse, *dto.PaginationResponse, error)
 	GetPaymentsByUserID(userID string, params dto.PaymentQueryParam) ([]dto.PaymentListResponse, *dto.PaginationResponse, error)
 }
+
+// payments that never passed through a payment provider, offline ones were taken at the front desk, wallet ones
+// were paid in full from store credit and free ones had nothing left to pay after discounts
+const (
+	offlineGateway = "offline"
+	walletGateway  = "wallet"
+	freeGateway    = "free"
+)
+
+// PaymentSucceededHook fulfils a paid payment whose purpose is not a package purchase
+type PaymentSucceededHook func(payment *models.Payment) error
+
+// PaymentFailedHook gives back what a payment of another purpose was holding once it failed or its checkout expired
+type PaymentFailedHook func(payment *models.Payment) error
+
+// PaymentSettledListener is told about every payment that was just paid, whatever it was for
+type PaymentSettledListener func(payment *models.Payment)
+
 type paymentService struct {
-	payment repositories.PaymentRepository
-	pkg     repositories.PackageRepository
-	user    repositories.UserRepository
-	voucher VoucherService
-	notif   NotificationService
-	userPkg repositories.UserPackageRepository
+	payment      repositories.PaymentRepository
+	pkg          repositories.PackageRepository
+	user         repositories.UserRepository
+	voucher      VoucherService
+	notif        NotificationService
+	userPkg      repositories.UserPackageRepository
+	subscription SubscriptionService
+	gateways     *gateway.Registry
+	tax          TaxService
+	location     repositories.LocationRepository
+	wallet       WalletService
+	discrepancy  repositories.PaymentDiscrepancyRepository
+	refund       repositories.RefundRepository
+	onSuccess    map[string]PaymentSucceededHook
+	onFailed     map[string]PaymentFailedHook
+	onSettled    []PaymentSettledListener
 }
 
 func NewPaymentService(
@@ -43,139 +82,290 @@ func NewPaymentService(
 	voucher VoucherService,
 	notif NotificationService,
 	userPkg repositories.UserPackageRepository,
+	subscription SubscriptionService,
+	gateways *gateway.Registry,
+	tax TaxService,
+	location repositories.LocationRepository,
+	wallet WalletService,
+	discrepancy repositories.PaymentDiscrepancyRepository,
+	refund repositories.RefundRepository,
 ) PaymentService {
 	return &paymentService{
-		payment: payment,
-		pkg:     pkg,
-		user:    user,
-		voucher: voucher,
-		notif:   notif,
-		userPkg: userPkg,
-	}
-}
-
-func buildLineItem(name string, unitAmount float64, quantity int64) *stripe.CheckoutSessionLineItemParams {
-	if unitAmount < 0 {
-		unitAmount = 0
-	}
-	return &stripe.CheckoutSessionLineItemParams{
-		PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-			Currency: stripe.String("idr"),
-			ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
-				Name: stripe.String(name),
-			},
-			UnitAmount: stripe.Int64(int64(unitAmount * 100)),
-		},
-		Quantity: stripe.Int64(quantity),
+		payment:      payment,
+		pkg:          pkg,
+		user:         user,
+		voucher:      voucher,
+		notif:        notif,
+		userPkg:      userPkg,
+		subscription: subscription,
+		gateways:     gateways,
+		tax:          tax,
+		location:     location,
+		wallet:       wallet,
+		discrepancy:  discrepancy,
+		refund:       refund,
+		onSuccess:    map[string]PaymentSucceededHook{},
+		onFailed:     map[string]PaymentFailedHook{},
 	}
 }
 
-func (s *paymentService) CreatePayment(userID string, req dto.CreatePaymentRequest) (*dto.CreatePaymentResponse, error) {
+// majorUnits converts a stored minor unit amount for API responses
+func majorUnits(amount int64, currency string) float64 {
+	return money.New(amount, currency).Major()
+}
+
+// invoiceNumber is empty until the payment is paid, numbers are only handed out to settled payments
+func invoiceNumber(payment *models.Payment) string {
+	if payment.InvoiceNumber == nil {
+		return ""
+	}
+	return *payment.InvoiceNumber
+}
+
+// paymentLocation is where the package is bought, a package sold at a single location is bought there
+func (s *paymentService) paymentLocation(pkg *models.Package, requested *string) (*uuid.UUID, error) {
+	if requested == nil || *requested == "" {
+		if len(pkg.LocationList) == 1 {
+			id, err := uuid.Parse(pkg.LocationList[0])
+			if err == nil {
+				return &id, nil
+			}
+		}
+		return nil, nil
+	}
+
+	if len(pkg.LocationList) > 0 && !slices.Contains(pkg.LocationList, *requested) {
+		return nil, customErr.NewBadRequest("this package is not sold at the selected location")
+	}
+	location, err := s.location.GetLocationByID(*requested)
+	if err != nil || location == nil {
+		return nil, customErr.NewNotFound("location not found")
+	}
+	return &location.ID, nil
+}
+
+func checkoutURLs() (string, string) {
+	if os.Getenv("NODE_ENV") == "production" {
+		return os.Getenv("STRIPE_SUCCESS_URL_PROD"), os.Getenv("STRIPE_CANCEL_URL_PROD")
+	}
+	return os.Getenv("STRIPE_SUCCESS_URL_DEV"), os.Getenv("STRIPE_CANCEL_URL_DEV")
+}
+
+// prepareOrder checks the member may buy the package and prices it with the voucher and taxes, the returned
+// payment is pending and not stored yet
+func (s *paymentService) prepareOrder(userID string, req dto.CreatePaymentRequest) (*models.Payment, *models.Package, error) {
 	uid := uuid.MustParse(userID)
 
 	pkg, err := s.pkg.GetPackageByID(req.PackageID)
-	if err != nil {
-		return nil, customErr.ErrNotFound
+	if err != nil || pkg == nil {
+		return nil, nil, customErr.ErrNotFound
 	}
 
 	user, err := s.user.GetUserByID(userID)
-	if err != nil {
-		return nil, customErr.ErrNotFound
+	if err != nil || user == nil {
+		return nil, nil, customErr.ErrNotFound
 	}
 
-	taxRate := utils.GetTaxRate()
-	discounted := pkg.Price * (1 - pkg.Discount/100)
-	base := discounted
+	base := packagePrice(pkg)
 
 	var voucherCode *string
-	var voucherDiscount float64
+	voucherDiscount := money.Zero(base.Currency)
 
-	if req.VoucherCode != nil {
-		apply, err := s.voucher.ApplyVoucher(dto.ApplyVoucherRequest{
-			Code:  *req.VoucherCode,
-			Total: base,
-		})
-		if err == nil {
-			base = apply.FinalTotal
-			voucherCode = &apply.Code
-			voucherDiscount = apply.DiscountValue
-		}
+	if pkg.AutoRenew && req.VoucherCode != nil && *req.VoucherCode != "" {
+		return nil, nil, customErr.NewBadRequest("vouchers cannot be applied to auto-renewing memberships")
 	}
 
-	tax := base * taxRate
-	total := base + tax
-
-	paymentID := uuid.New()
-	invoice := utils.GenerateInvoiceNumber(paymentID)
+	// a gift is paid now and granted to whoever redeems the code sent to the recipient
+	purpose := "package"
+	var giftEmail *string
+	if req.GiftEmail != nil && strings.TrimSpace(*req.GiftEmail) != "" {
+		if pkg.AutoRenew {
+			return nil, nil, customErr.NewBadRequest("auto-renewing memberships cannot be gifted")
+		}
+		email := strings.ToLower(strings.TrimSpace(*req.GiftEmail))
+		if email == strings.ToLower(user.Email) {
+			return nil, nil, customErr.NewBadRequest("you cannot send a gift to yourself")
+		}
+		if hasBuyerRules(pkg) {
+			return nil, nil, customErr.NewBadRequest("introductory offers cannot be gifted")
+		}
+		purpose = "gift"
+		giftEmail = &email
+	}
 
-	lineItems := []*stripe.CheckoutSessionLineItemParams{
-		buildLineItem(pkg.Name, base, 1),
+	// gifts are only held to the package's availability, the buyer rules apply to the member buying for themselves
+	history := &purchaseHistory{}
+	if purpose == "package" {
+		if history, err = loadPurchaseHistory(s.payment, s.userPkg, userID); err != nil {
+			return nil, nil, customErr.NewInternal("failed to fetch purchase history", err)
+		}
 	}
-	if tax > 0 {
-		lineItems = append(lineItems, buildLineItem("Tax (PPN) 10% ", tax, 1))
+	if reason := purchaseIneligibility(pkg, history, time.Now().UTC()); reason != "" {
+		return nil, nil, customErr.NewBadRequest(reason)
 	}
 
-	var successURL, cancelURL string
-	if os.Getenv("NODE_ENV") == "production" {
-		successURL = os.Getenv("STRIPE_SUCCESS_URL_PROD")
-		cancelURL = os.Getenv("STRIPE_CANCEL_URL_PROD")
-	} else {
-		successURL = os.Getenv("STRIPE_SUCCESS_URL_DEV")
-		cancelURL = os.Getenv("STRIPE_CANCEL_URL_DEV")
+	locationID, err := s.paymentLocation(pkg, req.LocationID)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	params := &stripe.CheckoutSessionParams{
-		PaymentMethodTypes: stripe.StringSlice([]string{"card"}),
-		LineItems:          lineItems,
-		Mode:               stripe.String(string(stripe.CheckoutSessionModePayment)),
-		SuccessURL:         stripe.String(successURL),
-		CancelURL:          stripe.String(cancelURL),
-		ClientReferenceID:  stripe.String(paymentID.String()),
-		Metadata: map[string]string{
-			"order_id":   paymentID.String(),
-			"user_id":    userID,
-			"package_id": pkg.ID.String(),
-		},
+	if req.VoucherCode != nil {
+		quote, err := s.voucher.QuoteVoucher(&userID, *req.VoucherCode, base)
+		if err == nil {
+			base = quote.Final
+			voucherCode = &quote.Code
+			voucherDiscount = quote.Discount
+		}
 	}
 
-	sess, err := session.New(params)
+	// the voucher comes off the price as sold, an inclusive price keeps its tax inside what is left
+	taxes, err := s.tax.Calculate(packageTaxRequest(pkg, base, locationID))
 	if err != nil {
-		return nil, customErr.ErrInternalServer
+		return nil, nil, err
 	}
 
 	payment := models.Payment{
-		ID:              paymentID,
+		ID:              uuid.New(),
 		PackageID:       pkg.ID,
 		PackageName:     pkg.Name,
-		InvoiceNumber:   invoice,
 		Fullname:        user.Fullname,
 		Email:           user.Email,
-		PaymentLink:     sess.URL,
 		UserID:          uid,
 		PaymentMethod:   "-",
 		Status:          "pending",
-		BasePrice:       base,
-		Tax:             tax,
-		Total:           total,
+		Currency:        taxes.Total.Currency,
+		BasePrice:       taxes.Net.Amount,
+		Tax:             taxes.Tax.Amount,
+		Total:           taxes.Total.Amount,
+		Taxes:           taxes.Lines,
+		LocationID:      locationID,
 		VoucherCode:     voucherCode,
-		VoucherDiscount: voucherDiscount,
+		VoucherDiscount: voucherDiscount.Amount,
+		Purpose:         purpose,
+		GiftEmail:       giftEmail,
+		GiftMessage:     req.GiftMessage,
 	}
+	if req.Billing != nil {
+		payment.BillingCompany = strings.TrimSpace(req.Billing.Company)
+		payment.BillingTaxID = strings.TrimSpace(req.Billing.TaxID)
+		payment.BillingAddress = strings.TrimSpace(req.Billing.Address)
+	}
+	return &payment, pkg, nil
+}
 
-	if err := s.payment.CreatePayment(&payment); err != nil {
-		return nil, customErr.NewInternal("Failed to create payment", err)
+func (s *paymentService) CreatePayment(userID string, req dto.CreatePaymentRequest) (*dto.CreatePaymentResponse, error) {
+	payment, pkg, err := s.prepareOrder(userID, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.UseWallet || req.WalletAmount != nil {
+		if err := s.applyWallet(payment, pkg, req.WalletAmount); err != nil {
+			return nil, err
+		}
+	}
+	paymentID := payment.ID
+	total := money.New(payment.Total, payment.Currency)
+	net := money.New(payment.BasePrice, payment.Currency)
+
+	// the voucher use is held before the checkout opens so a voucher that ran out is never charged at its discount
+	if payment.VoucherCode != nil {
+		if err := s.voucher.ReserveVoucher(payment.UserID, *payment.VoucherCode, paymentID); err != nil {
+			return nil, err
+		}
+	}
+	releaseVoucher := func() {
+		if payment.VoucherCode == nil {
+			return
+		}
+		if err := s.voucher.ReleaseVoucher(paymentID); err != nil {
+			log.Printf("failed releasing voucher held by payment %s: %v\n", paymentID, err)
+		}
+	}
+
+	switch {
+	case payment.Total == 0:
+		if pkg.AutoRenew {
+			releaseVoucher()
+			return nil, customErr.NewBadRequest("auto-renewing memberships cannot be free")
+		}
+		return s.settleWithoutGateway(payment, freeGateway)
+	case payment.WalletAmount == payment.Total:
+		return s.settleWithoutGateway(payment, walletGateway)
+	}
+
+	successURL, cancelURL := checkoutURLs()
+
+	metadata := map[string]string{
+		"order_id":   paymentID.String(),
+		"user_id":    userID,
+		"package_id": pkg.ID.String(),
+		"purpose":    payment.Purpose,
+	}
+
+	var checkoutURL, sessionID, gatewayName string
+	if pkg.AutoRenew {
+		if req.PaymentMethod != "" && req.PaymentMethod != gateway.MethodCard {
+			return nil, customErr.NewBadRequest("auto-renewing memberships can only be paid by card")
+		}
+		billing, err := s.gateways.ForSubscriptions()
+		if err != nil {
+			return nil, customErr.NewInternal("failed to create subscription checkout", err)
+		}
+		checkout, err := billing.CreateSubscriptionCheckout(gateway.SubscriptionRequest{
+			Reference:     paymentID.String(),
+			CustomerEmail: payment.Email,
+			ProductName:   pkg.Name,
+			Amount:        total,
+			IntervalDays:  pkg.Expired,
+			SuccessURL:    successURL,
+			CancelURL:     cancelURL,
+			Metadata:      metadata,
+		})
+		if err != nil {
+			return nil, customErr.NewInternal("failed to create subscription checkout", err)
+		}
+		checkoutURL, sessionID, gatewayName = checkout.URL, checkout.SessionID, billing.Name()
+	} else {
+		lineItems := buildLineItems(pkg.Name, net, payment.Taxes)
+		if payment.WalletAmount > 0 {
+			// gateways take no negative lines, so what the wallet leaves to pay is charged as one line
+			paid := money.New(payment.WalletAmount, payment.Currency)
+			lineItems = []gateway.LineItem{{Name: fmt.Sprintf("%s (%s paid from wallet)", pkg.Name, paid), Amount: total.Sub(paid), Quantity: 1}}
+		}
+		checkout, name, err := s.openCheckout(req.PaymentMethod, gateway.CheckoutRequest{
+			Reference:     paymentID.String(),
+			CustomerEmail: payment.Email,
+			LineItems:     lineItems,
+			SuccessURL:    successURL,
+			CancelURL:     cancelURL,
+			Metadata:      metadata,
+		})
+		if err != nil {
+			releaseVoucher()
+			return nil, err
+		}
+		checkoutURL, sessionID, gatewayName = checkout.URL, checkout.SessionID, name
+	}
+
+	payment.PaymentLink = checkoutURL
+	payment.Gateway = gatewayName
+	if sessionID != "" {
+		payment.ProviderSessionID = &sessionID
 	}
 
-	if payment.VoucherCode != nil && *payment.VoucherCode != "" {
-		if err := s.voucher.DecreaseQuota(payment.UserID, *payment.VoucherCode); err != nil {
-			return nil, customErr.NewInternal("failed to decrease voucher quota", err)
+	if err := s.payment.CreatePayment(payment); err != nil {
+		releaseVoucher()
+		if errors.Is(err, repositories.ErrInsufficientWallet) {
+			return nil, customErr.NewBadRequest("your wallet balance changed, please try again")
 		}
+		return nil, customErr.NewInternal("Failed to create payment", err)
 	}
 
 	payload := dto.NotificationEvent{
-		UserID:  user.ID.String(),
+		UserID:  payment.UserID.String(),
 		Title:   "Pending Payments",
 		Type:    "system_message",
-		Message: fmt.Sprintf("Thank you %s, your purchasement with invoice no. %s is created. Please complete your payment", user.Fullname, invoice),
+		Message: fmt.Sprintf("Thank you %s, your order for %s is created. Please complete your payment", payment.Fullname, pkg.Name),
 	}
 
 	if err := s.notif.SendToUser(payload); err != nil {
@@ -184,40 +374,595 @@ func (s *paymentService) CreatePayment(userID string, req dto.CreatePaymentReque
 
 	return &dto.CreatePaymentResponse{
 		PaymentID: paymentID.String(),
-		SnapURL:   sess.URL,
-		SessionID: sess.ID,
+		SnapURL:   checkoutURL,
+		SessionID: sessionID,
+		Status:    payment.Status,
+	}, nil
+}
+
+// applyWallet sets the share of the order paid from the wallet, all of the balance unless limit asks for less,
+// the balance itself is only taken when the payment is stored
+func (s *paymentService) applyWallet(payment *models.Payment, pkg *models.Package, limit *float64) error {
+	if pkg.AutoRenew {
+		return customErr.NewBadRequest("wallet credit cannot pay auto-renewing memberships")
+	}
+	if payment.Currency != money.DefaultCurrency() {
+		return customErr.NewBadRequest(fmt.Sprintf("wallet credit cannot pay in %s", payment.Currency))
+	}
+
+	user, err := s.user.GetUserByID(payment.UserID.String())
+	if err != nil || user == nil {
+		return customErr.ErrNotFound
+	}
+	balance := money.New(user.WalletBalance, payment.Currency)
+	amount := balance
+	if limit != nil {
+		amount = money.FromMajor(*limit, payment.Currency)
+		if amount.Amount > balance.Amount {
+			return customErr.NewBadRequest(fmt.Sprintf("your wallet balance is %s", balance))
+		}
+	}
+	amount = amount.Min(money.New(payment.Total, payment.Currency))
+	if !amount.IsPositive() {
+		return customErr.NewBadRequest("your wallet is empty")
+	}
+
+	payment.WalletAmount = amount.Amount
+	return nil
+}
+
+// settleWithoutGateway settles an order no gateway has to charge, paid in full from the wallet or free, it goes
+// through the same fulfilment as a gateway payment
+func (s *paymentService) settleWithoutGateway(payment *models.Payment, via string) (*dto.CreatePaymentResponse, error) {
+	payment.Gateway = via
+	if err := s.payment.CreatePayment(payment); err != nil {
+		s.abandonPayment(payment)
+		if errors.Is(err, repositories.ErrInsufficientWallet) {
+			return nil, customErr.NewBadRequest("your wallet balance changed, please try again")
+		}
+		return nil, customErr.NewInternal("Failed to create payment", err)
+	}
+
+	err := s.handlePaymentSucceeded(&gateway.Event{
+		Type:      gateway.EventPaymentSucceeded,
+		Reference: payment.ID.String(),
+		Method:    via,
+	})
+	if err != nil {
+		s.abandonPayment(payment)
+		return nil, customErr.NewInternal("failed to settle payment", err)
+	}
+
+	return &dto.CreatePaymentResponse{
+		PaymentID: payment.ID.String(),
+		Status:    "success",
 	}, nil
 }
 
-func (s *paymentService) StripeWebhookNotification(event stripe.Event) error {
-	if event.Type != "checkout.session.completed" {
-		return fmt.Errorf("%s is not a valid event", event.Type)
+// abandonPayment fails a payment that could not be settled right away and gives back what it held
+func (s *paymentService) abandonPayment(payment *models.Payment) {
+	if _, err := s.payment.MarkPaymentFailed(payment.ID); err != nil {
+		log.Printf("failed marking payment %s as failed: %v\n", payment.ID, err)
+	}
+	if payment.VoucherCode != nil {
+		if err := s.voucher.ReleaseVoucher(payment.ID); err != nil {
+			log.Printf("failed releasing voucher held by payment %s: %v\n", payment.ID, err)
+		}
+	}
+	if payment.WalletAmount > 0 {
+		if err := s.wallet.ReleasePayment(payment.ID); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// RecordOfflinePayment books a purchase paid at the front desk in cash, by bank transfer or on the EDC terminal,
+// it is settled right away through the same path as a gateway payment so the package is activated the same way
+func (s *paymentService) RecordOfflinePayment(staffID string, req dto.OfflinePaymentRequest) (*dto.PaymentDetailResponse, error) {
+	user, err := s.user.GetUserByID(req.UserID)
+	if err != nil || user == nil {
+		return nil, customErr.NewNotFound("user not found")
+	}
+	if user.Role != "customer" {
+		return nil, customErr.NewBadRequest("packages can only be sold to customers")
+	}
+
+	payment, pkg, err := s.prepareOrder(req.UserID, dto.CreatePaymentRequest{
+		PackageID:   req.PackageID,
+		VoucherCode: req.VoucherCode,
+		LocationID:  req.LocationID,
+		Billing:     req.Billing,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pkg.AutoRenew {
+		return nil, customErr.NewBadRequest("auto-renewing memberships can only be paid by card")
+	}
+
+	receivedBy := uuid.MustParse(staffID)
+	payment.Gateway = offlineGateway
+	payment.ReceivedBy = &receivedBy
+	payment.OfflineReference = strings.TrimSpace(req.Reference)
+
+	if payment.VoucherCode != nil {
+		if err := s.voucher.ReserveVoucher(payment.UserID, *payment.VoucherCode, payment.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.payment.CreatePayment(payment); err != nil {
+		s.abandonPayment(payment)
+		return nil, customErr.NewInternal("Failed to create payment", err)
+	}
+
+	err = s.handlePaymentSucceeded(&gateway.Event{
+		Type:      gateway.EventPaymentSucceeded,
+		Reference: payment.ID.String(),
+		Method:    req.Method,
+	})
+	if err != nil {
+		s.abandonPayment(payment)
+		return nil, customErr.NewInternal("failed to settle offline payment", err)
+	}
+
+	return s.GetPaymentDetail(payment.ID.String())
+}
+
+// CreateFeePayment opens a checkout for a one-off fee, the hook registered for the purpose runs once it is paid
+func (s *paymentService) CreateFeePayment(userID string, fee dto.FeeCharge) (*models.Payment, error) {
+	user, err := s.user.GetUserByID(userID)
+	if err != nil || user == nil {
+		return nil, customErr.NewNotFound("user not found")
+	}
+
+	// fees are taxed like the package they belong to, a package that was removed leaves the default rules
+	taxRequest := TaxRequest{Amount: fee.Amount, At: time.Now()}
+	if pkg, err := s.pkg.GetPackageByID(fee.PackageID); err == nil && pkg != nil {
+		taxRequest = packageTaxRequest(pkg, fee.Amount, nil)
+	}
+	taxes, err := s.tax.Calculate(taxRequest)
+	if err != nil {
+		return nil, err
+	}
+	paymentID := uuid.New()
+
+	successURL, cancelURL := checkoutURLs()
+	checkout, gatewayName, err := s.openCheckout(fee.Method, gateway.CheckoutRequest{
+		Reference:     paymentID.String(),
+		CustomerEmail: user.Email,
+		LineItems:     buildLineItems(fee.Description, taxes.Net, taxes.Lines),
+		SuccessURL:    successURL,
+		CancelURL:     cancelURL,
+		Metadata: map[string]string{
+			"order_id": paymentID.String(),
+			"user_id":  userID,
+			"purpose":  fee.Purpose,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payment := models.Payment{
+		ID:                paymentID,
+		PackageID:         uuid.MustParse(fee.PackageID),
+		PackageName:       fee.Description,
+		Fullname:          user.Fullname,
+		Email:             user.Email,
+		PaymentLink:       checkout.URL,
+		UserID:            user.ID,
+		PaymentMethod:     "-",
+		Gateway:           gatewayName,
+		ProviderSessionID: &checkout.SessionID,
+		Status:            "pending",
+		Purpose:           fee.Purpose,
+		Currency:          taxes.Total.Currency,
+		BasePrice:         taxes.Net.Amount,
+		Tax:               taxes.Tax.Amount,
+		Total:             taxes.Total.Amount,
+		Taxes:             taxes.Lines,
+	}
+	if err := s.payment.CreatePayment(&payment); err != nil {
+		return nil, customErr.NewInternal("Failed to create payment", err)
+	}
+	return &payment, nil
+}
+
+func (s *paymentService) OnPaymentSucceeded(purpose string, hook PaymentSucceededHook) {
+	s.onSuccess[purpose] = hook
+}
+
+func (s *paymentService) OnPaymentFailed(purpose string, hook PaymentFailedHook) {
+	s.onFailed[purpose] = hook
+}
+
+func (s *paymentService) OnPaymentSettled(listener PaymentSettledListener) {
+	s.onSettled = append(s.onSettled, listener)
+}
+
+// openCheckout starts the checkout on the gateway configured for the payment method, cards are the default
+func (s *paymentService) openCheckout(method string, req gateway.CheckoutRequest) (*gateway.Checkout, string, error) {
+	if method == "" {
+		method = gateway.MethodCard
+	}
+	gw, err := s.gateways.ForMethod(method)
+	if err != nil {
+		return nil, "", customErr.NewBadRequest(err.Error())
+	}
+
+	req.Method = method
+	checkout, err := gw.CreateCheckout(req)
+	if err != nil {
+		return nil, "", customErr.NewInternal("failed to create checkout session", err)
+	}
+	return checkout, gw.Name(), nil
+}
+
+// ErrUnhandledEvent marks gateway events the platform has no use for, they are acknowledged so the
+// gateway does not keep delivering them
+var ErrUnhandledEvent = errors.New("event is not handled")
+
+// ProcessWebhookEvent fulfils a verified gateway event, Stripe subscription events are passed through
+// as they are since renewals are billed outside the gateway
+func (s *paymentService) ProcessWebhookEvent(event *gateway.Event) error {
+	switch event.Type {
+	case gateway.EventPaymentSucceeded:
+		return s.handlePaymentSucceeded(event)
+	case gateway.EventPaymentProcessing:
+		return s.handlePaymentProcessing(event)
+	case gateway.EventPaymentFailed, gateway.EventCheckoutExpired:
+		return s.handlePaymentFailed(event)
+	case gateway.EventPaymentRefunded:
+		return s.handlePaymentRefunded(event)
+	case gateway.EventPaymentDisputed:
+		return s.handlePaymentDisputed(event)
+	case gateway.EventInvoicePaid:
+		return s.subscription.HandleRenewalPaid(*event.Invoice)
+	case gateway.EventInvoicePaymentFailed:
+		return s.subscription.HandleRenewalFailed(*event.Invoice)
+	case gateway.EventSubscriptionEnded:
+		return s.subscription.HandleSubscriptionEnded(event.SubscriptionID)
+	}
+	return fmt.Errorf("%w: %s", ErrUnhandledEvent, event.Type)
+}
+
+// handlePaymentProcessing keeps the payment pending while a delayed method like a bank transfer clears
+func (s *paymentService) handlePaymentProcessing(event *gateway.Event) error {
+	payment, err := s.payment.GetPaymentByOrderID(event.Reference)
+	if err != nil || payment == nil {
+		return fmt.Errorf("payment not found: %w", err)
+	}
+	if payment.Status != "pending" {
+		return nil
+	}
+
+	payment.PaymentMethod = event.Method
+	if event.PaymentReference != "" {
+		payment.ProviderPaymentID = &event.PaymentReference
+	}
+	if err := s.payment.UpdatePayment(payment); err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+	log.Printf("payment %s is processing through %s, waiting for the outcome\n", payment.ID, event.Method)
+	return nil
+}
+
+// handlePaymentFailed closes a pending payment that failed or whose checkout expired, the voucher use it
+// took is given back and the hook of its purpose undoes what was waiting on it
+func (s *paymentService) handlePaymentFailed(event *gateway.Event) error {
+	payment, err := s.payment.GetPaymentByOrderID(event.Reference)
+	if err != nil || payment == nil {
+		return fmt.Errorf("payment not found: %w", err)
+	}
+
+	failed, err := s.payment.MarkPaymentFailed(payment.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+	if !failed {
+		log.Printf("payment %s is %s, ignoring %s\n", payment.ID, payment.Status, event.Type)
+		return nil
+	}
+	log.Printf("payment %s marked failed after %s\n", payment.ID, event.Type)
+
+	if err := s.voucher.ReleaseVoucher(payment.ID); err != nil {
+		log.Printf("failed releasing voucher of payment %s: %v\n", payment.ID, err)
+	}
+	if payment.WalletAmount > 0 {
+		if err := s.wallet.ReleasePayment(payment.ID); err != nil {
+			log.Println(err)
+		}
+	}
+	if hook, ok := s.onFailed[payment.Purpose]; ok {
+		if err := hook(payment); err != nil {
+			log.Printf("failed undoing %s payment %s: %v\n", payment.Purpose, payment.ID, err)
+		}
+	}
+
+	title, message := "Payment Failed", fmt.Sprintf("Your payment for %s did not go through. Please try again.", payment.PackageName)
+	if event.Type == gateway.EventCheckoutExpired {
+		title, message = "Payment Expired", fmt.Sprintf("Your checkout for %s expired before it was paid.", payment.PackageName)
+	}
+	payload := dto.NotificationEvent{
+		UserID:  payment.UserID.String(),
+		Type:    "system_message",
+		Title:   title,
+		Message: message,
+	}
+	if err := s.notif.SendToUser(payload); err != nil {
+		log.Printf("failed sending notification to user %s: %v\n", payload.UserID, err)
+	}
+	return nil
+}
+
+// handlePaymentRefunded follows refunds made at the gateway, refunds issued by an admin are recorded when they
+// are made so only an amount beyond what is recorded changes the payment, credits are not revoked here
+func (s *paymentService) handlePaymentRefunded(event *gateway.Event) error {
+	payment, err := s.paymentForGatewayEvent(event)
+	if err != nil {
+		return err
+	}
+
+	if event.Amount.Currency != payment.Currency {
+		return fmt.Errorf("refund of payment %s is in %s, the payment was made in %s", payment.ID, event.Amount.Currency, payment.Currency)
+	}
+	// the gateway reports what it returned of the amount it captured, refunds paid out as wallet credit come on top
+	walletRefunded, err := s.refund.GetWalletRefunded(payment.ID.String())
+	if err != nil {
+		return fmt.Errorf("failed to fetch wallet refunds of payment %s: %w", payment.ID, err)
+	}
+	refunded := event.Amount
+	if refunded.Amount <= payment.RefundedAmount-walletRefunded {
+		return nil
+	}
+
+	payment.RefundedAmount = refunded.Amount + walletRefunded
+	payment.Status = "partially_refunded"
+	var credit *models.WalletTransaction
+	if refunded.Amount >= payment.Total-payment.WalletAmount {
+		// everything the gateway captured went back, the share paid from the wallet goes back to the wallet
+		if rest := payment.Total - payment.RefundedAmount; rest > 0 {
+			credit = &models.WalletTransaction{
+				UserID:    payment.UserID,
+				Type:      "refund",
+				Amount:    rest,
+				Currency:  payment.Currency,
+				PaymentID: &payment.ID,
+				Reason:    fmt.Sprintf("Refund of %s made at %s", payment.PackageName, payment.Gateway),
+			}
+			payment.RefundedAmount = payment.Total
+		}
+		payment.Status = "refunded"
+	}
+	if err := s.payment.RecordGatewayRefund(payment, credit); err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+	log.Printf("payment %s was refunded %s at %s outside the platform, credits were not revoked\n", payment.ID, refunded, payment.Gateway)
+	if credit != nil {
+		log.Printf("wallet share %s of payment %s returned to the wallet\n", money.New(credit.Amount, credit.Currency), payment.ID)
 	}
+	return nil
+}
 
-	var session stripe.CheckoutSession
-	if err := json.Unmarshal(event.Data.Raw, &session); err != nil {
-		return fmt.Errorf("invalid session data")
+// handlePaymentDisputed flags a charged-back payment, it no longer counts as paid until the dispute is settled
+func (s *paymentService) handlePaymentDisputed(event *gateway.Event) error {
+	payment, err := s.paymentForGatewayEvent(event)
+	if err != nil {
+		return err
+	}
+	if payment.Status == "disputed" {
+		return nil
 	}
 
-	orderID, ok := session.Metadata["order_id"]
-	if !ok || orderID == "" {
-		return fmt.Errorf("missing order_id in Stripe metadata")
+	previous := payment.Status
+	payment.Status = "disputed"
+	if err := s.payment.UpdatePayment(payment); err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
 	}
+	log.Printf("payment %s (%s, was %s) is disputed for %s: %s\n", payment.ID, invoiceNumber(payment), previous, event.Amount, event.Reason)
+	return nil
+}
 
-	payment, err := s.payment.GetPaymentByOrderID(orderID)
+// paymentForGatewayEvent finds the payment of a charge event by the gateway's payment reference,
+// falling back to our own reference
+func (s *paymentService) paymentForGatewayEvent(event *gateway.Event) (*models.Payment, error) {
+	var payment *models.Payment
+	var err error
+	if event.PaymentReference != "" {
+		payment, err = s.payment.GetPaymentByProviderPaymentID(event.PaymentReference)
+	}
+	if err == nil && payment == nil && event.Reference != "" {
+		payment, err = s.payment.GetPaymentByOrderID(event.Reference)
+	}
 	if err != nil {
+		return nil, fmt.Errorf("failed to fetch payment: %w", err)
+	}
+	if payment == nil {
+		return nil, fmt.Errorf("%w: no payment for %s", ErrUnhandledEvent, event.PaymentReference)
+	}
+	return payment, nil
+}
+
+// handlePaymentSucceeded settles the payment and fulfils it, a package purchase or upgrade and the held voucher
+// use are stored in the same transaction as the payment so a failure leaves the payment pending for the gateway
+// to retry
+func (s *paymentService) handlePaymentSucceeded(event *gateway.Event) error {
+	if event.Reference == "" {
+		return fmt.Errorf("missing order reference in webhook")
+	}
+
+	payment, err := s.payment.GetPaymentByOrderID(event.Reference)
+	if err != nil || payment == nil {
 		return fmt.Errorf("payment not found: %w", err)
 	}
 
 	if payment.Status == "success" {
+		// the payment was settled but its fulfilment may have failed, hooks skip work they did already
+		if _, ok := s.onSuccess[payment.Purpose]; ok {
+			return s.runSuccessHook(payment)
+		}
+		log.Printf("payment %s is already settled, skipping event %s\n", payment.ID, event.ID)
 		return nil
 	}
-	payment.PaymentMethod = "card"
+	// a failed payment gave its wallet share back, the late payment only settles when the wallet can pay it again
+	if payment.Status == "failed" && payment.WalletAmount > 0 {
+		covered, err := s.wallet.CoversPayment(payment)
+		if err != nil {
+			return err
+		}
+		if !covered {
+			return s.refundWalletShortfall(payment, event)
+		}
+	}
+	failed := payment.Status == "failed"
+	payment.PaymentMethod = event.Method
+	if payment.PaymentMethod == "" {
+		payment.PaymentMethod = gateway.MethodCard
+	}
 	payment.Status = "success"
 	payment.PaidAt = time.Now().UTC()
+	if event.PaymentReference != "" {
+		payment.ProviderPaymentID = &event.PaymentReference
+	}
 
-	if err := s.payment.UpdatePayment(payment); err != nil {
-		return fmt.Errorf("failed to update payment: %w", err)
+	var userPackage *models.UserPackage
+	switch payment.Purpose {
+	case "upgrade":
+		err = s.applyUpgrade(payment)
+	case "", "package":
+		userPackage, err = s.settlePackage(payment)
+	default:
+		if _, ok := s.onSuccess[payment.Purpose]; !ok {
+			return fmt.Errorf("no fulfilment registered for %s payments", payment.Purpose)
+		}
+		err = s.payment.SettlePayment(payment, nil, nil)
+	}
+	if errors.Is(err, repositories.ErrPaymentSettled) {
+		log.Printf("payment %s was settled by another delivery, skipping event %s\n", payment.ID, event.ID)
+		return nil
+	}
+	if failed && errors.Is(err, repositories.ErrInsufficientWallet) {
+		payment.Status = "failed"
+		return s.refundWalletShortfall(payment, event)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("payment %s (%s) settled through %s\n", payment.ID, payment.Purpose, payment.Gateway)
+	for _, listener := range s.onSettled {
+		listener(payment)
+	}
+
+	if _, ok := s.onSuccess[payment.Purpose]; ok {
+		return s.runSuccessHook(payment)
+	}
+	if userPackage != nil && event.SubscriptionID != "" {
+		return s.subscription.Activate(payment, userPackage, event.SubscriptionID)
+	}
+	return nil
+}
+
+// refundWalletShortfall hands back a late gateway payment whose wallet share was spent after the order failed, the
+// order stays failed and the finding is kept for admins
+func (s *paymentService) refundWalletShortfall(payment *models.Payment, event *gateway.Event) error {
+	earlier, _, err := s.discrepancy.GetDiscrepancies(dto.PaymentDiscrepancyQueryParam{
+		Kind:      "wallet_shortfall",
+		PaymentID: payment.ID.String(),
+		Page:      1,
+		Limit:     1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch discrepancies of payment %s: %w", payment.ID, err)
+	}
+	if len(earlier) > 0 && earlier[0].Action == "refunded" {
+		log.Printf("late payment %s was refunded already, skipping event %s\n", payment.ID, event.ID)
+		return nil
+	}
+
+	charged := money.New(payment.Total-payment.WalletAmount, payment.Currency)
+	if event.Amount.IsPositive() {
+		charged = event.Amount
+	}
+	discrepancy := models.PaymentDiscrepancy{
+		PaymentID:      payment.ID,
+		Gateway:        payment.Gateway,
+		Kind:           "wallet_shortfall",
+		OurStatus:      payment.Status,
+		ProviderStatus: gateway.StatePaid,
+		ExpectedAmount: payment.Total - payment.WalletAmount,
+		ProviderAmount: charged.Amount,
+		Currency:       payment.Currency,
+		Action:         "refunded",
+	}
+
+	result, err := s.refundLatePayment(payment, event, charged)
+	if err != nil {
+		discrepancy.Action = "none"
+		discrepancy.Detail = fmt.Sprintf("the wallet no longer holds the %s paid from it and refunding the %s charged failed: %v", money.New(payment.WalletAmount, payment.Currency), charged, err)
+	} else {
+		discrepancy.Detail = fmt.Sprintf("the wallet no longer holds the %s paid from it, the %s charged was refunded (%s)", money.New(payment.WalletAmount, payment.Currency), charged, result.ID)
+	}
+	if err := s.discrepancy.RecordDiscrepancy(&discrepancy); err != nil {
+		return fmt.Errorf("failed to record discrepancy of payment %s: %w", payment.ID, err)
+	}
+	log.Printf("late payment %s was not fulfilled: %s\n", payment.ID, discrepancy.Detail)
+
+	if discrepancy.Action == "refunded" {
+		payload := dto.NotificationEvent{
+			UserID:  payment.UserID.String(),
+			Type:    "system_message",
+			Title:   "Payment Refunded",
+			Message: fmt.Sprintf("Your payment of %s for %s arrived after the order had expired and your wallet credit was already used elsewhere, so the payment was refunded. Please place a new order.", charged, payment.PackageName),
+		}
+		if err := s.notif.SendToUser(payload); err != nil {
+			log.Printf("failed sending notification to user %s: %v\n", payload.UserID, err)
+		}
+	}
+	return nil
+}
+
+func (s *paymentService) refundLatePayment(payment *models.Payment, event *gateway.Event, charged money.Money) (*gateway.Refund, error) {
+	gw, err := s.gateways.Get(payment.Gateway)
+	if err != nil {
+		return nil, err
+	}
+	result, err := gw.Refund(gateway.RefundRequest{
+		PaymentReference: event.PaymentReference,
+		Amount:           charged,
+		Reason:           "order expired before the payment arrived",
+		Metadata: map[string]string{
+			"payment_id": payment.ID.String(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Status == gateway.RefundStatusFailed {
+		return nil, fmt.Errorf("refund %s was declined", result.ID)
+	}
+	return result, nil
+}
+
+// runSuccessHook fulfils payments of other purposes once the payment is settled, so nothing is handed out for a
+// payment that did not commit. Hooks skip work they did already so a retried event finishes a failed fulfilment
+func (s *paymentService) runSuccessHook(payment *models.Payment) error {
+	if err := s.onSuccess[payment.Purpose](payment); err != nil {
+		return fmt.Errorf("payment %s is settled but its fulfilment failed: %w", payment.ID, err)
+	}
+	return nil
+}
+
+func (s *paymentService) settlePackage(payment *models.Payment) (*models.UserPackage, error) {
+	userPackage, entry, err := s.buildActivation(payment, payment.UserID, fmt.Sprintf("Purchased %s", payment.PackageName))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.payment.SettlePayment(payment, userPackage, entry); err != nil {
+		if errors.Is(err, repositories.ErrPaymentSettled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to activate package: %w", err)
 	}
 
 	// TODO: Use RabbitMQ to emit "payment_success" event for async email delivery (only in production with EDA)
@@ -229,51 +974,272 @@ func (s *paymentService) StripeWebhookNotification(event stripe.Event) error {
 			"Hi %s, your payment for %q (Invoice: %s) was successful. Your package has been activated and is now ready to use.",
 			payment.Fullname,
 			payment.PackageName,
-			payment.InvoiceNumber,
+			invoiceNumber(payment),
 		),
 	}
 
 	if err := s.notif.SendToUser(payload); err != nil {
 		log.Printf("failed sending notification to user %s: %v\n", payload.UserID, err)
 	}
-	// TODO: Use RabbitMQ to emit "payment_success" event for async email delivery (only in production with EDA)
+	return userPackage, nil
+}
 
-	pkg, err := s.pkg.GetPackageByID(payment.PackageID.String())
+// GrantPackage activates the package paid by payment for another user, used when a gift is redeemed
+func (s *paymentService) GrantPackage(payment *models.Payment, userID string) (*models.UserPackage, error) {
+	recipient, err := uuid.Parse(userID)
 	if err != nil {
-		return customErr.ErrNotFound
+		return nil, customErr.NewBadRequest("invalid user id")
+	}
+	userPackage, entry, err := s.buildActivation(payment, recipient, fmt.Sprintf("Redeemed gift %s (%s)", payment.PackageName, invoiceNumber(payment)))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.payment.LinkPackage(payment, userPackage, entry); err != nil {
+		return nil, customErr.NewInternal("failed to activate package", err)
+	}
+	return userPackage, nil
+}
+
+func (s *paymentService) QuoteUpgrade(userID string, req dto.UpgradePackageRequest) (*dto.UpgradeQuoteResponse, error) {
+	quote, _, _, err := s.quoteUpgrade(userID, req)
+	return quote, err
+}
+
+// UpgradePackage charges the prorated difference, the package is switched once the checkout is paid
+func (s *paymentService) UpgradePackage(userID string, req dto.UpgradePackageRequest) (*dto.CreatePaymentResponse, error) {
+	quote, target, base, err := s.quoteUpgrade(userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := s.CreateFeePayment(userID, dto.FeeCharge{
+		PackageID:     target.ID.String(),
+		UserPackageID: quote.UserPackageID,
+		Description:   fmt.Sprintf("Upgrade %s to %s", quote.CurrentPackage, target.Name),
+		Amount:        base,
+		Purpose:       "upgrade",
+		Method:        req.PaymentMethod,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.CreatePaymentResponse{
+		PaymentID: payment.ID.String(),
+		SnapURL:   payment.PaymentLink,
+	}, nil
+}
+
+// quoteUpgrade values the unused part of the current package against what was paid for it,
+// credit packs are valued by whichever of remaining credits or remaining days is used up more, the amount to charge
+// before tax is returned with the quote
+func (s *paymentService) quoteUpgrade(userID string, req dto.UpgradePackageRequest) (*dto.UpgradeQuoteResponse, *models.Package, money.Money, error) {
+	var none money.Money
+	userPackage, err := s.userPkg.GetUserPackageByID(req.UserPackageID)
+	if err != nil || userPackage == nil || userPackage.UserID.String() != userID {
+		return nil, nil, none, customErr.NewNotFound("user package not found")
+	}
+	if userPackage.SubscriptionID != nil {
+		return nil, nil, none, customErr.NewBadRequest("auto-renewing memberships cannot be upgraded, cancel the subscription instead")
+	}
+
+	now := time.Now().UTC()
+	if userPackage.ExpiredAt == nil || !userPackage.ExpiredAt.After(now) {
+		return nil, nil, none, customErr.NewBadRequest("package has expired")
+	}
+	if userPackage.PackageID.String() == req.PackageID {
+		return nil, nil, none, customErr.NewBadRequest("you already own this package")
+	}
+
+	current, err := s.pkg.GetPackageByID(userPackage.PackageID.String())
+	if err != nil || current == nil {
+		return nil, nil, none, customErr.NewNotFound("package not found")
+	}
+	target, err := s.pkg.GetPackageByID(req.PackageID)
+	if err != nil || target == nil {
+		return nil, nil, none, customErr.NewNotFound("package not found")
+	}
+	if target.AutoRenew {
+		return nil, nil, none, customErr.NewBadRequest("auto-renewing memberships cannot be upgraded to, purchase them directly")
+	}
+	if hasBuyerRules(target) {
+		return nil, nil, none, customErr.NewBadRequest("introductory offers cannot be upgraded to")
+	}
+	if reason := purchaseIneligibility(target, &purchaseHistory{}, now); reason != "" {
+		return nil, nil, none, customErr.NewBadRequest(reason)
+	}
+	if target.Type == "credit_pack" && target.Credit < userPackage.RemainingCredit {
+		return nil, nil, none, customErr.NewBadRequest(fmt.Sprintf("target package must include at least your %d remaining credits", userPackage.RemainingCredit))
+	}
+
+	targetPrice := packagePrice(target)
+	paid := packagePrice(current)
+	last, err := s.payment.GetLatestPaidPackagePayment(userID, current.ID.String())
+	if err != nil {
+		return nil, nil, none, customErr.NewInternal("failed to fetch package payment", err)
+	}
+	if last != nil {
+		paid = money.New(last.BasePrice, last.Currency)
+	}
+	if paid.Currency != targetPrice.Currency {
+		return nil, nil, none, customErr.NewBadRequest("packages priced in different currencies cannot be upgraded between")
+	}
+
+	// the unused share is kept as a fraction so the value is rounded once
+	remainingDays := int(math.Ceil(userPackage.ExpiredAt.Sub(now).Hours() / 24))
+	usedNum, usedDen := int64(1), int64(1)
+	if current.Expired > 0 && remainingDays < current.Expired {
+		usedNum, usedDen = int64(remainingDays), int64(current.Expired)
+	}
+	if userPackage.Type == "credit_pack" && current.Credit > 0 && int64(userPackage.RemainingCredit)*usedDen < usedNum*int64(current.Credit) {
+		usedNum, usedDen = int64(userPackage.RemainingCredit), int64(current.Credit)
+	}
+
+	unused := paid.Ratio(usedNum, usedDen)
+	base := targetPrice.Sub(unused)
+	if !base.IsPositive() {
+		return nil, nil, none, customErr.NewBadRequest("the target package is not an upgrade over your current package")
+	}
+	taxes, err := s.tax.Calculate(packageTaxRequest(target, base, nil))
+	if err != nil {
+		return nil, nil, none, err
+	}
+
+	newCredit := 0
+	if target.Type == "credit_pack" {
+		newCredit = target.Credit
+	}
+
+	return &dto.UpgradeQuoteResponse{
+		UserPackageID:   userPackage.ID.String(),
+		CurrentPackage:  userPackage.PackageName,
+		TargetPackageID: target.ID.String(),
+		TargetPackage:   target.Name,
+		RemainingCredit: userPackage.RemainingCredit,
+		RemainingDays:   remainingDays,
+		UnusedValue:     unused.Major(),
+		TargetPrice:     targetPrice.Major(),
+		BasePrice:       taxes.Net.Major(),
+		Tax:             taxes.Tax.Major(),
+		Total:           taxes.Total.Major(),
+		NewCredit:       newCredit,
+		NewExpiredAt:    now.AddDate(0, 0, target.Expired).Format("2006-01-02"),
+		Taxes:           toPaymentTaxResponses(taxes.Lines),
+	}, target, base, nil
+}
+
+// applyUpgrade switches the paid user package to the new package in place, so bookings and members stay attached,
+// the credit balance is topped up to the new package's credits
+func (s *paymentService) applyUpgrade(payment *models.Payment) error {
+	if payment.UserPackageID == nil {
+		return fmt.Errorf("upgrade payment %s has no user package", payment.ID)
+	}
+	userPackage, err := s.userPkg.GetUserPackageByID(payment.UserPackageID.String())
+	if err != nil || userPackage == nil {
+		return fmt.Errorf("user package %s not found: %w", payment.UserPackageID, err)
+	}
+	pkg, err := s.pkg.GetPackageByID(payment.PackageID.String())
+	if err != nil || pkg == nil {
+		return fmt.Errorf("package %s not found: %w", payment.PackageID, err)
+	}
+
+	now := time.Now().UTC()
+	expired := now.AddDate(0, 0, pkg.Expired)
+	previous := userPackage.PackageName
+	userPackage.PackageID = pkg.ID
+	userPackage.PackageName = pkg.Name
+	userPackage.Type = pkg.Type
+	userPackage.WeeklyLimit = pkg.WeeklyLimit
+	userPackage.ExpiredAt = &expired
+	userPackage.PurchasedAt = now
+	userPackage.ClosedAt = nil
+	userPackage.ExpiryRemindedDays = 0
+
+	reason := fmt.Sprintf("Upgraded %s to %s", previous, pkg.Name)
+	var entry *models.CreditLedgerEntry
+	switch {
+	case pkg.Type == "credit_pack":
+		entry = &models.CreditLedgerEntry{
+			Type:      "purchase",
+			Amount:    pkg.Credit - userPackage.RemainingCredit,
+			PaymentID: &payment.ID,
+			Reason:    reason,
+		}
+	case userPackage.RemainingCredit > 0:
+		// credits left on a credit pack have no use on a membership
+		entry = &models.CreditLedgerEntry{
+			Type:      "adjustment",
+			Amount:    -userPackage.RemainingCredit,
+			PaymentID: &payment.ID,
+			Reason:    reason,
+		}
+	}
+	if err := s.payment.SettlePayment(payment, userPackage, entry); err != nil {
+		if errors.Is(err, repositories.ErrPaymentSettled) {
+			return err
+		}
+		return fmt.Errorf("failed to upgrade user package: %w", err)
+	}
+
+	payload := dto.NotificationEvent{
+		UserID:  payment.UserID.String(),
+		Type:    "system_message",
+		Title:   "Package Upgraded",
+		Message: fmt.Sprintf("Your %s was upgraded to %s (Invoice: %s) and is valid until %s.", previous, pkg.Name, invoiceNumber(payment), expired.Format("2006-01-02")),
+	}
+	if err := s.notif.SendToUser(payload); err != nil {
+		log.Printf("failed sending notification to user %s: %v\n", payload.UserID, err)
+	}
+	return nil
+}
+
+// buildActivation prepares the purchased package for ownerID, a repeat purchase of an active package extends it,
+// credit packs receive their credits through the returned ledger entry
+func (s *paymentService) buildActivation(payment *models.Payment, ownerID uuid.UUID, reason string) (*models.UserPackage, *models.CreditLedgerEntry, error) {
+	pkg, err := s.pkg.GetPackageByID(payment.PackageID.String())
+	if err != nil || pkg == nil {
+		return nil, nil, customErr.ErrNotFound
 	}
 
 	var existing models.UserPackage
 	now := time.Now().UTC()
 
-	err = s.userPkg.GetActiveUserPackages(payment.UserID.String(), payment.PackageID.String(), &existing)
+	err = s.userPkg.GetActiveUserPackages(ownerID.String(), payment.PackageID.String(), &existing)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return customErr.NewInternal("failed checking existing user package", err)
+		return nil, nil, customErr.NewInternal("failed checking existing user package", err)
 	}
 
+	userPackage := &existing
 	if existing.ID == uuid.Nil {
 		expired := now.AddDate(0, 0, pkg.Expired)
-		newUP := models.UserPackage{
-			ID:              uuid.New(),
-			UserID:          payment.UserID,
-			PackageID:       payment.PackageID,
-			PackageName:     payment.PackageName,
-			RemainingCredit: pkg.Credit,
-			ExpiredAt:       &expired,
-			PurchasedAt:     now,
+		userPackage = &models.UserPackage{
+			UserID:      ownerID,
+			PackageID:   payment.PackageID,
+			PackageName: payment.PackageName,
+			ExpiredAt:   &expired,
+			PurchasedAt: now,
 		}
-		return s.userPkg.CreateUserPackage(&newUP)
-	}
-
-	existing.RemainingCredit += pkg.Credit
-	if existing.ExpiredAt != nil {
+	} else if existing.ExpiredAt != nil {
 		*existing.ExpiredAt = existing.ExpiredAt.AddDate(0, 0, pkg.Expired)
+		existing.PurchasedAt = now
 	} else {
 		exp := now.AddDate(0, 0, pkg.Expired)
 		existing.ExpiredAt = &exp
+		existing.PurchasedAt = now
 	}
-	existing.PurchasedAt = now
-	return s.userPkg.UpdateUserPackage(&existing)
+	userPackage.Type = pkg.Type
+	userPackage.WeeklyLimit = pkg.WeeklyLimit
+	userPackage.ExpiryRemindedDays = 0
+
+	if pkg.Type != "credit_pack" {
+		return userPackage, nil, nil
+	}
+	return userPackage, &models.CreditLedgerEntry{
+		Type:      "purchase",
+		Amount:    pkg.Credit,
+		PaymentID: &payment.ID,
+		Reason:    reason,
+	}, nil
 }
 
 func (s *paymentService) GetPaymentsByUserID(userID string, params dto.PaymentQueryParam) ([]dto.PaymentListResponse, *dto.PaginationResponse, error) {
@@ -288,12 +1254,13 @@ func (s *paymentService) GetPaymentsByUserID(userID string, params dto.PaymentQu
 		results = append(results, dto.PaymentListResponse{
 			ID:            p.ID.String(),
 			UserID:        p.UserID.String(),
-			InvoiceNumber: p.InvoiceNumber,
+			InvoiceNumber: invoiceNumber(&p),
 			Email:         p.Email,
 			Fullname:      p.Fullname,
 			PackageID:     p.PackageID.String(),
 			PackageName:   p.PackageName,
-			Total:         p.Total,
+			Total:         majorUnits(p.Total, p.Currency),
+			Refunded:      majorUnits(p.RefundedAmount, p.Currency),
 			PaymentMethod: p.PaymentMethod,
 			Status:        p.Status,
 			PaidAt:        p.PaidAt.Format("2006-01-02"),
@@ -316,10 +1283,11 @@ func (s *paymentService) GetAllUserPayments(params dto.PaymentQueryParam) ([]dto
 			UserID:        p.UserID.String(),
 			PackageID:     p.PackageID.String(),
 			PackageName:   p.PackageName,
-			InvoiceNumber: p.InvoiceNumber,
+			InvoiceNumber: invoiceNumber(&p),
 			Email:         p.Email,
 			Fullname:      p.Fullname,
-			Total:         p.Total,
+			Total:         majorUnits(p.Total, p.Currency),
+			Refunded:      majorUnits(p.RefundedAmount, p.Currency),
 			PaymentMethod: p.PaymentMethod,
 			Status:        p.Status,
 			PaidAt:        p.PaidAt.Format("2006-01-02"),
@@ -331,40 +1299,143 @@ func (s *paymentService) GetAllUserPayments(params dto.PaymentQueryParam) ([]dto
 
 func (s *paymentService) GetPaymentDetail(paymentID string) (*dto.PaymentDetailResponse, error) {
 	payment, err := s.payment.GetPaymentByID(paymentID)
-	if err != nil {
+	if err != nil || payment == nil {
 		return nil, customErr.NewNotFound("payment not found")
 	}
 
 	result := dto.PaymentDetailResponse{
 		ID:              payment.ID.String(),
 		UserID:          payment.UserID.String(),
-		InvoiceNumber:   payment.InvoiceNumber,
+		InvoiceNumber:   invoiceNumber(payment),
 		Email:           payment.Email,
 		Fullname:        payment.Fullname,
 		PackageID:       payment.PackageID.String(),
 		PackageName:     payment.PackageName,
-		BasePrice:       payment.BasePrice,
-		Tax:             payment.Tax,
-		Total:           payment.Total,
-		VoucherCode:     *payment.VoucherCode,
-		VoucherDiscount: payment.VoucherDiscount,
+		Currency:        payment.Currency,
+		BasePrice:       majorUnits(payment.BasePrice, payment.Currency),
+		Tax:             majorUnits(payment.Tax, payment.Currency),
+		Total:           majorUnits(payment.Total, payment.Currency),
+		WalletAmount:    majorUnits(payment.WalletAmount, payment.Currency),
+		Refunded:        majorUnits(payment.RefundedAmount, payment.Currency),
+		VoucherDiscount: majorUnits(payment.VoucherDiscount, payment.Currency),
 		PaymentMethod:   payment.PaymentMethod,
+		Gateway:         payment.Gateway,
 		Status:          payment.Status,
 		PaidAt:          payment.PaidAt.Format(time.RFC3339),
+		Purpose:         payment.Purpose,
+	}
+	if payment.VoucherCode != nil {
+		result.VoucherCode = *payment.VoucherCode
+	}
+	if payment.GiftEmail != nil {
+		result.GiftEmail = *payment.GiftEmail
+	}
+	if payment.LocationID != nil {
+		result.LocationID = payment.LocationID.String()
+	}
+	if payment.ReceivedBy != nil {
+		result.ReceivedBy = payment.ReceivedBy.String()
+		result.Reference = payment.OfflineReference
+	}
+	if payment.BillingCompany != "" || payment.BillingTaxID != "" || payment.BillingAddress != "" {
+		result.Billing = &dto.BillingDetails{
+			Company: payment.BillingCompany,
+			TaxID:   payment.BillingTaxID,
+			Address: payment.BillingAddress,
+		}
+	}
+
+	taxes, err := s.payment.GetPaymentTaxes(payment.ID.String())
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch payment taxes", err)
 	}
+	result.Taxes = toPaymentTaxResponses(taxes)
 
 	return &result, nil
 }
 
+// GetCashDrawerReport totals the front-desk payments of a day in the studio's calendar per staff member who took
+// them, the cash total is what the drawer should hold at the end of the day before any refunds
+func (s *paymentService) GetCashDrawerReport(params dto.CashDrawerQueryParam) (*dto.CashDrawerReport, error) {
+	loc := utils.GetStudioLocation()
+	day := time.Now().In(loc)
+	if params.Date != "" {
+		var err error
+		if day, err = time.ParseInLocation("2006-01-02", params.Date, loc); err != nil {
+			return nil, customErr.NewBadRequest("date must be in YYYY-MM-DD format")
+		}
+	}
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+
+	payments, err := s.payment.GetOfflinePayments(from.UTC(), from.AddDate(0, 0, 1).UTC(), params.StaffID, params.LocationID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch offline payments", err)
+	}
+
+	report := &dto.CashDrawerReport{Date: from.Format("2006-01-02"), Staff: []dto.CashDrawerStaff{}}
+	staffIndex := map[uuid.UUID]int{}
+	for _, payment := range payments {
+		i, ok := staffIndex[*payment.ReceivedBy]
+		if !ok {
+			staff := dto.CashDrawerStaff{StaffID: payment.ReceivedBy.String(), Methods: []dto.CashDrawerMethod{}}
+			if user, err := s.user.GetUserByID(staff.StaffID); err == nil && user != nil {
+				staff.StaffName = user.Fullname
+			}
+			report.Staff = append(report.Staff, staff)
+			i = len(report.Staff) - 1
+			staffIndex[*payment.ReceivedBy] = i
+		}
+		staff := &report.Staff[i]
+
+		total := majorUnits(payment.Total, payment.Currency)
+		if payment.PaymentMethod == "cash" {
+			staff.Cash += total
+		}
+
+		j := slices.IndexFunc(staff.Methods, func(m dto.CashDrawerMethod) bool {
+			return m.Method == payment.PaymentMethod && m.Currency == payment.Currency
+		})
+		if j < 0 {
+			staff.Methods = append(staff.Methods, dto.CashDrawerMethod{Method: payment.PaymentMethod, Currency: payment.Currency})
+			j = len(staff.Methods) - 1
+		}
+		staff.Methods[j].Count++
+		staff.Methods[j].Total += total
+
+		staff.Payments = append(staff.Payments, dto.CashDrawerPayment{
+			ID:            payment.ID.String(),
+			InvoiceNumber: invoiceNumber(&payment),
+			Fullname:      payment.Fullname,
+			PackageName:   payment.PackageName,
+			Method:        payment.PaymentMethod,
+			Reference:     payment.OfflineReference,
+			Status:        payment.Status,
+			Total:         total,
+			PaidAt:        payment.PaidAt.In(loc).Format(time.RFC3339),
+		})
+	}
+
+	return report, nil
+}
+
 // ** khusus cron job update status to failed
 func (s *paymentService) ExpireOldPendingPayments() error {
 	rows, err := s.payment.ExpireOldPendingPayments()
 	if err != nil {
 		return fmt.Errorf("failed to expire pending payments: %w", err)
 	}
-	if rows == 0 {
-		return fmt.Errorf("no expired pending payments found")
-	}
 	fmt.Printf("%d pending payments marked as failed\n", rows)
+
+	released, err := s.voucher.ReleaseStaleReservations()
+	if err != nil {
+		return fmt.Errorf("failed to release voucher reservations: %w", err)
+	}
+	fmt.Printf("%d voucher reservations released\n", released)
+
+	returned, err := s.wallet.ReleaseStaleHolds()
+	if err != nil {
+		return fmt.Errorf("failed to return wallet credit: %w", err)
+	}
+	fmt.Printf("%d wallet holds returned\n", returned)
 	return nil
 }
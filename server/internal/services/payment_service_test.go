@@ -0,0 +1,322 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"server/internal/dto"
+	"server/internal/models"
+	customErr "server/pkg/errors"
+	"server/pkg/gateway"
+	"server/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+func expireCheckout(t *testing.T, sessionID string) []byte {
+	t.Helper()
+	body, err := json.Marshal(gateway.FakeWebhook{Type: gateway.EventCheckoutExpired, SessionID: sessionID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// paidPayment is a payment settled through the fake gateway, wallet is the share paid from store credit
+func paidPayment(t *testing.T, db *store, gw *gateway.FakeGateway, svc PaymentService, total, wallet int64) *models.Payment {
+	t.Helper()
+	user := db.addUser(wallet)
+	payment := db.addPayment(t, gw, user, db.addPackage(10), total, wallet)
+	body, err := gw.Settle(*payment.ProviderSessionID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := deliver(t, svc, gw, body); err != nil {
+		t.Fatalf("settle payment: %v", err)
+	}
+	return db.payments[payment.ID]
+}
+
+func refundedAtGateway(t *testing.T, payment *models.Payment, amount int64) []byte {
+	t.Helper()
+	body, err := json.Marshal(gateway.FakeWebhook{
+		Type:      gateway.EventPaymentRefunded,
+		SessionID: *payment.ProviderSessionID,
+		Amount:    amount,
+		Currency:  payment.Currency,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestGatewayRefundOfWalletSplitPayment(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	svc := newTestPaymentService(db, gw)
+	payment := paidPayment(t, db, gw, svc, 100_000, 30_000)
+	user := db.users[payment.UserID]
+
+	if err := deliver(t, svc, gw, refundedAtGateway(t, payment, 40_000)); err != nil {
+		t.Fatalf("partial refund: %v", err)
+	}
+	got := db.payments[payment.ID]
+	if got.Status != "partially_refunded" || got.RefundedAmount != 40_000 {
+		t.Fatalf("after partial refund = %s/%d, want partially_refunded/40000", got.Status, got.RefundedAmount)
+	}
+
+	// the gateway returned everything it captured, the wallet share follows
+	if err := deliver(t, svc, gw, refundedAtGateway(t, payment, 70_000)); err != nil {
+		t.Fatalf("full refund: %v", err)
+	}
+	got = db.payments[payment.ID]
+	if got.Status != "refunded" || got.RefundedAmount != 100_000 {
+		t.Fatalf("after full refund = %s/%d, want refunded/100000", got.Status, got.RefundedAmount)
+	}
+	if user.WalletBalance != 30_000 {
+		t.Fatalf("wallet = %d, want the 30000 share returned", user.WalletBalance)
+	}
+
+	// a redelivered notification changes nothing
+	if err := deliver(t, svc, gw, refundedAtGateway(t, payment, 70_000)); err != nil {
+		t.Fatalf("redelivered refund: %v", err)
+	}
+	if user.WalletBalance != 30_000 || db.payments[payment.ID].RefundedAmount != 100_000 {
+		t.Fatalf("redelivery refunded the wallet share twice")
+	}
+}
+
+func TestGatewayRefundCountsEarlierWalletRefunds(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	svc := newTestPaymentService(db, gw)
+	payment := paidPayment(t, db, gw, svc, 100_000, 30_000)
+
+	// an admin already returned the wallet share to the wallet
+	reference := "wallet-1"
+	db.refunds = append(db.refunds, models.PaymentRefund{PaymentID: payment.ID, Amount: 30_000, WalletAmount: 30_000, Status: "succeeded", ProviderRefundID: &reference})
+	db.payments[payment.ID].RefundedAmount = 30_000
+	db.payments[payment.ID].Status = "partially_refunded"
+
+	if err := deliver(t, svc, gw, refundedAtGateway(t, payment, 20_000)); err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	got := db.payments[payment.ID]
+	if got.Status != "partially_refunded" || got.RefundedAmount != 50_000 {
+		t.Fatalf("after gateway refund = %s/%d, want partially_refunded/50000", got.Status, got.RefundedAmount)
+	}
+
+	if err := deliver(t, svc, gw, refundedAtGateway(t, payment, 70_000)); err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	got = db.payments[payment.ID]
+	if got.Status != "refunded" || got.RefundedAmount != 100_000 {
+		t.Fatalf("after gateway refund = %s/%d, want refunded/100000", got.Status, got.RefundedAmount)
+	}
+	if balance := db.users[payment.UserID].WalletBalance; balance != 0 {
+		t.Fatalf("wallet = %d, the share was already returned by the admin", balance)
+	}
+}
+
+func TestLateSuccessAfterFailTakesReleasedWalletCreditAgain(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	svc := newTestPaymentService(db, gw)
+	user := db.addUser(50_000)
+	pkg := db.addPackage(10)
+	payment := db.addPayment(t, gw, user, pkg, 100_000, 30_000)
+
+	if err := deliver(t, svc, gw, expireCheckout(t, *payment.ProviderSessionID)); err != nil {
+		t.Fatalf("expire checkout: %v", err)
+	}
+	if got := db.payments[payment.ID].Status; got != "failed" {
+		t.Fatalf("status after expiry = %s, want failed", got)
+	}
+	if got := user.WalletBalance; got != 50_000 {
+		t.Fatalf("wallet after expiry = %d, want the hold released to 50000", got)
+	}
+
+	body, err := gw.Settle(*payment.ProviderSessionID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := deliver(t, svc, gw, body); err != nil {
+		t.Fatalf("late payment: %v", err)
+	}
+
+	settled := db.payments[payment.ID]
+	if settled.Status != "success" {
+		t.Fatalf("status after late payment = %s, want success", settled.Status)
+	}
+	if got := user.WalletBalance; got != 20_000 {
+		t.Fatalf("wallet after late payment = %d, want the 30000 share taken again", got)
+	}
+	if got := db.hold(payment.ID); got != 30_000 {
+		t.Fatalf("wallet hold = %d, want 30000", got)
+	}
+	if settled.UserPackageID == nil || db.userPackages[*settled.UserPackageID].RemainingCredit != 10 {
+		t.Fatalf("package was not granted with its credits")
+	}
+	if len(db.discrepancies) != 0 {
+		t.Fatalf("got %d discrepancies, want none", len(db.discrepancies))
+	}
+}
+
+func TestLateSuccessAfterFailRefundsWhenWalletWasSpent(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	svc := newTestPaymentService(db, gw)
+	user := db.addUser(50_000)
+	pkg := db.addPackage(10)
+	payment := db.addPayment(t, gw, user, pkg, 100_000, 30_000)
+
+	if err := deliver(t, svc, gw, expireCheckout(t, *payment.ProviderSessionID)); err != nil {
+		t.Fatalf("expire checkout: %v", err)
+	}
+	// the released credit pays for another order before the late payment arrives
+	db.addPayment(t, gw, user, pkg, 40_000, 40_000)
+
+	body, err := gw.Settle(*payment.ProviderSessionID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := deliver(t, svc, gw, body); err != nil {
+		t.Fatalf("late payment: %v", err)
+	}
+
+	if got := db.payments[payment.ID].Status; got != "failed" {
+		t.Fatalf("status after late payment = %s, want failed", got)
+	}
+	if got := user.WalletBalance; got != 10_000 {
+		t.Fatalf("wallet = %d, want 10000 left untouched", got)
+	}
+	if len(db.userPackages) != 0 {
+		t.Fatalf("package was granted without the wallet share being paid")
+	}
+	if len(db.discrepancies) != 1 {
+		t.Fatalf("got %d discrepancies, want 1", len(db.discrepancies))
+	}
+	found := db.discrepancies[0]
+	if found.Kind != "wallet_shortfall" || found.Action != "refunded" || found.ProviderAmount != 70_000 {
+		t.Fatalf("discrepancy = %s/%s/%d, want wallet_shortfall/refunded/70000", found.Kind, found.Action, found.ProviderAmount)
+	}
+	if !db.notified("Payment Refunded") {
+		t.Fatalf("member was not told about the refund")
+	}
+
+	// the charged amount was refunded in full, the gateway takes no more
+	refund, err := gw.Refund(gateway.RefundRequest{PaymentReference: "fake_pay_" + *payment.ProviderSessionID, Amount: money.New(1, "IDR")})
+	if err != nil || refund.Status != gateway.RefundStatusFailed {
+		t.Fatalf("charged amount was not refunded at the gateway")
+	}
+
+	// a redelivered success does not refund twice
+	body, err = gw.Settle(*payment.ProviderSessionID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := deliver(t, svc, gw, body); err != nil {
+		t.Fatalf("redelivered payment: %v", err)
+	}
+	if len(db.discrepancies) != 1 || db.discrepancies[0].Action != "refunded" {
+		t.Fatalf("redelivery changed the discrepancy")
+	}
+}
+
+func TestSuccessHookRunsAfterSettlementAndRetries(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	svc := newTestPaymentService(db, gw)
+	payment := db.addPayment(t, gw, db.addUser(0), db.addPackage(10), 100_000, 0)
+	payment.Purpose = "gift"
+
+	var seen []string
+	failing := true
+	svc.OnPaymentSucceeded("gift", func(p *models.Payment) error {
+		seen = append(seen, db.payments[p.ID].Status)
+		if failing {
+			return errors.New("mail server down")
+		}
+		return nil
+	})
+
+	body, err := gw.Settle(*payment.ProviderSessionID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := deliver(t, svc, gw, body); err == nil {
+		t.Fatalf("a failed fulfilment was acknowledged")
+	}
+	failing = false
+	if err := deliver(t, svc, gw, body); err != nil {
+		t.Fatalf("redelivery: %v", err)
+	}
+
+	if len(seen) != 2 || seen[0] != "success" || seen[1] != "success" {
+		t.Fatalf("hook saw payment status %v, want it settled before every run", seen)
+	}
+}
+
+func TestSubscriptionEventsReachTheSubscriptionService(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	svc := newTestPaymentService(db, gw)
+
+	events := []gateway.FakeWebhook{
+		{Type: gateway.EventInvoicePaid, SubscriptionID: "fake_sub_1", InvoiceID: "fake_in_1", Amount: 300_000, Currency: "IDR"},
+		{Type: gateway.EventInvoicePaymentFailed, SubscriptionID: "fake_sub_1", InvoiceID: "fake_in_2", AttemptCount: 1},
+		{Type: gateway.EventSubscriptionEnded, SubscriptionID: "fake_sub_1"},
+	}
+	for _, hook := range events {
+		body, err := json.Marshal(hook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := deliver(t, svc, gw, body); err != nil {
+			t.Fatalf("%s: %v", hook.Type, err)
+		}
+	}
+
+	if len(db.invoices) != 2 || db.invoices[0].ID != "fake_in_1" || db.invoices[0].AmountPaid.Amount != 300_000 || db.invoices[1].AttemptCount != 1 {
+		t.Fatalf("invoices = %+v", db.invoices)
+	}
+	if len(db.ended) != 1 || db.ended[0] != "fake_sub_1" {
+		t.Fatalf("ended = %v, want fake_sub_1", db.ended)
+	}
+}
+
+func TestOfflinePaymentOnlyForCustomers(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	svc := newTestPaymentService(db, gw)
+	pkg := db.addPackage(10)
+	staff := db.addUser(0)
+	staff.Role = "instructor"
+
+	tests := []struct {
+		name     string
+		userID   string
+		wantCode int
+	}{
+		{name: "unknown user", userID: uuid.NewString(), wantCode: 404},
+		{name: "staff account", userID: staff.ID.String(), wantCode: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.RecordOfflinePayment(uuid.NewString(), dto.OfflinePaymentRequest{
+				UserID:    tt.userID,
+				PackageID: pkg.ID.String(),
+				Method:    "cash",
+			})
+			var appErr *customErr.AppError
+			if !errors.As(err, &appErr) || appErr.Code != tt.wantCode {
+				t.Fatalf("err = %v, want %d", err, tt.wantCode)
+			}
+			if len(db.payments) != 0 {
+				t.Fatalf("a payment was recorded")
+			}
+		})
+	}
+}
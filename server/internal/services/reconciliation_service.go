@@ -0,0 +1,226 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	customErr "server/pkg/errors"
+	"server/pkg/gateway"
+	"server/pkg/money"
+	"server/pkg/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReconciliationService interface {
+	// cron
+	ReconcilePendingPayments() (*dto.ReconciliationResult, error)
+
+	// admin
+	GetDiscrepancies(params dto.PaymentDiscrepancyQueryParam) ([]dto.PaymentDiscrepancyResponse, *dto.PaginationResponse, error)
+	ResolveDiscrepancy(id, adminID string, req dto.ResolveDiscrepancyRequest) (*dto.PaymentDiscrepancyResponse, error)
+}
+
+type reconciliationService struct {
+	discrepancy repositories.PaymentDiscrepancyRepository
+	payment     repositories.PaymentRepository
+	pkg         repositories.PackageRepository
+	payments    PaymentService
+	gateways    *gateway.Registry
+}
+
+func NewReconciliationService(discrepancy repositories.PaymentDiscrepancyRepository, payment repositories.PaymentRepository, pkg repositories.PackageRepository, payments PaymentService, gateways *gateway.Registry) ReconciliationService {
+	return &reconciliationService{
+		discrepancy: discrepancy,
+		payment:     payment,
+		pkg:         pkg,
+		payments:    payments,
+		gateways:    gateways,
+	}
+}
+
+// reconcileBatch caps the checkouts asked about in one run so a backlog does not hit the gateway's rate limits
+const reconcileBatch = 100
+
+// ReconcilePendingPayments asks the gateway about checkouts that stayed pending longer than PAYMENT_RECONCILE_AFTER_MINUTES,
+// a lost notification is made up for through the same fulfilment as the webhook and every difference is recorded for admins
+func (s *reconciliationService) ReconcilePendingPayments() (*dto.ReconciliationResult, error) {
+	payments, err := s.payment.GetStalePendingPayments(time.Now().UTC().Add(-utils.GetReconcileAfter()), reconcileBatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pending payments: %w", err)
+	}
+
+	result := &dto.ReconciliationResult{}
+	checked := make([]uuid.UUID, 0, len(payments))
+	for i := range payments {
+		payment := &payments[i]
+		result.Checked++
+		checked = append(checked, payment.ID)
+
+		discrepancy := s.reconcile(payment)
+		if discrepancy == nil {
+			continue
+		}
+		switch discrepancy.Action {
+		case "fulfilled":
+			result.Fulfilled++
+		case "failed":
+			result.Failed++
+		}
+		result.Discrepancies++
+		if err := s.discrepancy.RecordDiscrepancy(discrepancy); err != nil {
+			log.Printf("failed recording discrepancy of payment %s: %v\n", payment.ID, err)
+		}
+	}
+
+	if err := s.payment.MarkPaymentsReconciled(checked, time.Now().UTC()); err != nil {
+		log.Printf("failed marking %d payments as reconciled: %v\n", len(checked), err)
+	}
+	return result, nil
+}
+
+// reconcile compares one pending payment with its gateway and settles it when the gateway has the final word,
+// a payment that matches the gateway returns nil
+func (s *reconciliationService) reconcile(payment *models.Payment) *models.PaymentDiscrepancy {
+	discrepancy := &models.PaymentDiscrepancy{
+		PaymentID:      payment.ID,
+		Gateway:        payment.Gateway,
+		OurStatus:      payment.Status,
+		ExpectedAmount: payment.Total - payment.WalletAmount,
+		Currency:       payment.Currency,
+		Action:         "none",
+	}
+
+	gw, err := s.gateways.Get(payment.Gateway)
+	if err != nil {
+		discrepancy.Kind = "unknown_at_provider"
+		discrepancy.Detail = err.Error()
+		return discrepancy
+	}
+	status, err := gw.QueryStatus(*payment.ProviderSessionID)
+	if err != nil {
+		discrepancy.Kind = "unknown_at_provider"
+		discrepancy.Detail = fmt.Sprintf("checkout %s could not be looked up: %v", *payment.ProviderSessionID, err)
+		return discrepancy
+	}
+	discrepancy.ProviderStatus = status.State
+	discrepancy.ProviderAmount = status.Amount.Amount
+
+	// a charge that differs from the order is never fulfilled automatically, an admin decides what happens to it,
+	// the share paid from the wallet was never sent to the gateway
+	if !status.Amount.IsZero() && (status.Amount.Currency != payment.Currency || status.Amount.Amount != discrepancy.ExpectedAmount) {
+		discrepancy.Kind = "amount_mismatch"
+		discrepancy.Detail = fmt.Sprintf("the gateway charges %s where %s is due", status.Amount, money.New(discrepancy.ExpectedAmount, payment.Currency))
+		return discrepancy
+	}
+
+	event := &gateway.Event{
+		ID:               "reconcile_" + payment.ID.String(),
+		Reference:        payment.ID.String(),
+		SessionID:        *payment.ProviderSessionID,
+		PaymentReference: status.PaymentReference,
+		Method:           status.Method,
+	}
+	switch status.State {
+	case gateway.StatePaid:
+		if pkg, err := s.pkg.GetPackageByID(payment.PackageID.String()); err == nil && pkg != nil && pkg.AutoRenew && payment.Purpose == "package" {
+			// the subscription is only known from the billing provider's own notification
+			discrepancy.Kind = "status_mismatch"
+			discrepancy.Detail = "membership checkout was paid but its subscription notification never arrived"
+			return discrepancy
+		}
+		event.Type = gateway.EventPaymentSucceeded
+		discrepancy.Action = "fulfilled"
+	case gateway.StateFailed:
+		event.Type = gateway.EventPaymentFailed
+		discrepancy.Action = "failed"
+	case gateway.StateExpired:
+		event.Type = gateway.EventCheckoutExpired
+		discrepancy.Action = "failed"
+	default:
+		return nil
+	}
+
+	discrepancy.Kind = "status_mismatch"
+	if err := s.payments.ProcessWebhookEvent(event); err != nil {
+		log.Printf("reconciling payment %s (%s at %s) failed: %v\n", payment.ID, status.State, payment.Gateway, err)
+		discrepancy.Action = "none"
+		discrepancy.Detail = fmt.Sprintf("settling the payment failed: %v", err)
+		return discrepancy
+	}
+	log.Printf("payment %s reconciled as %s with %s\n", payment.ID, status.State, payment.Gateway)
+	return discrepancy
+}
+
+func (s *reconciliationService) GetDiscrepancies(params dto.PaymentDiscrepancyQueryParam) ([]dto.PaymentDiscrepancyResponse, *dto.PaginationResponse, error) {
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.Limit <= 0 {
+		params.Limit = 10
+	}
+
+	discrepancies, total, err := s.discrepancy.GetDiscrepancies(params)
+	if err != nil {
+		return nil, nil, customErr.NewInternal("failed to fetch payment discrepancies", err)
+	}
+
+	result := make([]dto.PaymentDiscrepancyResponse, 0, len(discrepancies))
+	for _, d := range discrepancies {
+		result = append(result, toDiscrepancyResponse(d))
+	}
+
+	pagination := utils.Paginate(total, params.Page, params.Limit)
+	return result, pagination, nil
+}
+
+// ResolveDiscrepancy closes a finding once an admin dealt with it, the job opens a new one if the difference remains
+func (s *reconciliationService) ResolveDiscrepancy(id, adminID string, req dto.ResolveDiscrepancyRequest) (*dto.PaymentDiscrepancyResponse, error) {
+	discrepancy, err := s.discrepancy.GetDiscrepancyByID(id)
+	if err != nil || discrepancy == nil {
+		return nil, customErr.NewNotFound("payment discrepancy not found")
+	}
+	if discrepancy.Status == "resolved" {
+		return nil, customErr.NewConflict("payment discrepancy is already resolved")
+	}
+
+	now := time.Now().UTC()
+	resolvedBy := uuid.MustParse(adminID)
+	discrepancy.Status = "resolved"
+	discrepancy.Resolution = req.Resolution
+	discrepancy.ResolvedBy = &resolvedBy
+	discrepancy.ResolvedAt = &now
+	if err := s.discrepancy.UpdateDiscrepancy(discrepancy); err != nil {
+		return nil, customErr.NewInternal("failed to resolve payment discrepancy", err)
+	}
+
+	resp := toDiscrepancyResponse(*discrepancy)
+	return &resp, nil
+}
+
+func toDiscrepancyResponse(d models.PaymentDiscrepancy) dto.PaymentDiscrepancyResponse {
+	resp := dto.PaymentDiscrepancyResponse{
+		ID:             d.ID.String(),
+		PaymentID:      d.PaymentID.String(),
+		Gateway:        d.Gateway,
+		Kind:           d.Kind,
+		OurStatus:      d.OurStatus,
+		ProviderStatus: d.ProviderStatus,
+		Currency:       d.Currency,
+		ExpectedAmount: majorUnits(d.ExpectedAmount, d.Currency),
+		ProviderAmount: majorUnits(d.ProviderAmount, d.Currency),
+		Action:         d.Action,
+		Detail:         d.Detail,
+		Status:         d.Status,
+		Resolution:     d.Resolution,
+		FirstSeenAt:    d.CreatedAt.Format(time.RFC3339),
+		LastSeenAt:     d.LastSeenAt.Format(time.RFC3339),
+	}
+	if d.ResolvedAt != nil {
+		resp.ResolvedAt = d.ResolvedAt.Format(time.RFC3339)
+	}
+	return resp
+}
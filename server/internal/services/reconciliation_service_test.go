@@ -0,0 +1,142 @@
+package services
+
+import (
+	"testing"
+
+	"server/internal/models"
+	"server/pkg/gateway"
+
+	"github.com/google/uuid"
+)
+
+func newTestReconciliationService(db *store, gw *gateway.FakeGateway) ReconciliationService {
+	return NewReconciliationService(
+		&fakeDiscrepancyRepo{db: db},
+		&fakePaymentRepo{db: db},
+		&fakePackageRepo{db: db},
+		newTestPaymentService(db, gw),
+		gateway.NewRegistry(gw),
+	)
+}
+
+func discrepancyOf(db *store, payment *models.Payment) *models.PaymentDiscrepancy {
+	for i := range db.discrepancies {
+		if db.discrepancies[i].PaymentID == payment.ID {
+			return &db.discrepancies[i]
+		}
+	}
+	return nil
+}
+
+func TestReconcilePendingPayments(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	svc := newTestReconciliationService(db, gw)
+	user := db.addUser(50_000)
+	pkg := db.addPackage(10)
+
+	// the success notification was lost
+	paid := db.addPayment(t, gw, user, pkg, 100_000, 30_000)
+	if err := gw.SetState(*paid.ProviderSessionID, gateway.StatePaid); err != nil {
+		t.Fatal(err)
+	}
+	// the checkout ran out without the expiry notification
+	expired := db.addPayment(t, gw, user, pkg, 100_000, 20_000)
+	if err := gw.SetState(*expired.ProviderSessionID, gateway.StateExpired); err != nil {
+		t.Fatal(err)
+	}
+	// the gateway charges something other than the order
+	mismatched := db.addPayment(t, gw, user, pkg, 100_000, 0)
+	mismatched.Total = 120_000
+	if err := gw.SetState(*mismatched.ProviderSessionID, gateway.StatePaid); err != nil {
+		t.Fatal(err)
+	}
+	// the gateway that opened the checkout is no longer configured
+	unknown := db.addPayment(t, gw, user, pkg, 100_000, 0)
+	unknown.Gateway = "retired"
+	// still open at the gateway, nothing to do
+	open := db.addPayment(t, gw, user, pkg, 100_000, 0)
+
+	result, err := svc.ReconcilePendingPayments()
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if result.Checked != 5 || result.Fulfilled != 1 || result.Failed != 1 || result.Discrepancies != 4 {
+		t.Fatalf("result = %+v, want 5 checked, 1 fulfilled, 1 failed and 4 discrepancies", result)
+	}
+
+	if got := db.payments[paid.ID]; got.Status != "success" || got.UserPackageID == nil {
+		t.Fatalf("paid checkout = %s, want success with the package granted", got.Status)
+	}
+	if found := discrepancyOf(db, paid); found == nil || found.Kind != "status_mismatch" || found.Action != "fulfilled" {
+		t.Fatalf("paid discrepancy = %+v, want status_mismatch/fulfilled", found)
+	}
+
+	if got := db.payments[expired.ID].Status; got != "failed" {
+		t.Fatalf("expired checkout = %s, want failed", got)
+	}
+	if found := discrepancyOf(db, expired); found == nil || found.Kind != "status_mismatch" || found.Action != "failed" {
+		t.Fatalf("expired discrepancy = %+v, want status_mismatch/failed", found)
+	}
+	if user.WalletBalance != 20_000 {
+		t.Fatalf("wallet = %d, want the 20000 share of the expired checkout released and the paid share kept", user.WalletBalance)
+	}
+
+	if got := db.payments[mismatched.ID].Status; got != "pending" {
+		t.Fatalf("mismatched checkout = %s, want it left pending for an admin", got)
+	}
+	if found := discrepancyOf(db, mismatched); found == nil || found.Kind != "amount_mismatch" || found.Action != "none" || found.ProviderAmount != 100_000 {
+		t.Fatalf("mismatched discrepancy = %+v, want amount_mismatch/none charging 100000", found)
+	}
+
+	if got := db.payments[unknown.ID].Status; got != "pending" {
+		t.Fatalf("unknown gateway checkout = %s, want it left pending", got)
+	}
+	if found := discrepancyOf(db, unknown); found == nil || found.Kind != "unknown_at_provider" || found.Action != "none" {
+		t.Fatalf("unknown gateway discrepancy = %+v, want unknown_at_provider/none", found)
+	}
+
+	if got := db.payments[open.ID].Status; got != "pending" || discrepancyOf(db, open) != nil {
+		t.Fatalf("open checkout = %s, want it pending without a discrepancy", got)
+	}
+
+	// a finding seen again stays one open row
+	if _, err := svc.ReconcilePendingPayments(); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if len(db.discrepancies) != 4 {
+		t.Fatalf("got %d discrepancies after a second run, want 4", len(db.discrepancies))
+	}
+}
+
+func TestReconcileWorksThroughABacklog(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	svc := newTestReconciliationService(db, gw)
+	user := db.addUser(0)
+	pkg := db.addPackage(10)
+
+	// checkouts still open at the gateway stay pending, one more than a run takes
+	for i := 0; i <= reconcileBatch; i++ {
+		db.addPayment(t, gw, user, pkg, 100_000, 0)
+	}
+
+	seen := map[uuid.UUID]bool{}
+	for run := 0; run < 2; run++ {
+		result, err := svc.ReconcilePendingPayments()
+		if err != nil {
+			t.Fatalf("run %d: %v", run+1, err)
+		}
+		if result.Checked != reconcileBatch {
+			t.Fatalf("run %d checked %d, want %d", run+1, result.Checked, reconcileBatch)
+		}
+		for id, payment := range db.payments {
+			if payment.LastReconciledAt != nil {
+				seen[id] = true
+			}
+		}
+	}
+	if len(seen) != reconcileBatch+1 {
+		t.Fatalf("checked %d of %d pending payments over two runs", len(seen), reconcileBatch+1)
+	}
+}
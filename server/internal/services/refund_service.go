@@ -0,0 +1,314 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	customErr "server/pkg/errors"
+	"server/pkg/gateway"
+	"server/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RefundService interface {
+	// admin
+	RefundPayment(adminID, paymentID string, req dto.RefundPaymentRequest) (*dto.RefundResponse, error)
+	GetRefunds(paymentID string) ([]dto.RefundResponse, error)
+}
+
+type refundService struct {
+	refund  repositories.RefundRepository
+	payment repositories.PaymentRepository
+	userPkg repositories.UserPackageRepository
+	ledger  repositories.CreditLedgerRepository
+	gateway *gateway.Registry
+	notif   NotificationService
+}
+
+func NewRefundService(refund repositories.RefundRepository, payment repositories.PaymentRepository, userPkg repositories.UserPackageRepository, ledger repositories.CreditLedgerRepository, gateways *gateway.Registry, notif NotificationService) RefundService {
+	return &refundService{
+		refund:  refund,
+		payment: payment,
+		userPkg: userPkg,
+		ledger:  ledger,
+		gateway: gateways,
+		notif:   notif,
+	}
+}
+
+// RefundPayment returns money through the gateway that took the payment or as wallet credit, then takes back the unused credits
+// in proportion to the refunded amount, a full refund of a membership ends it
+func (s *refundService) RefundPayment(adminID, paymentID string, req dto.RefundPaymentRequest) (*dto.RefundResponse, error) {
+	payment, err := s.payment.GetPaymentByID(paymentID)
+	if err != nil || payment == nil {
+		return nil, customErr.NewNotFound("payment not found")
+	}
+	if payment.Status != "success" && payment.Status != "partially_refunded" {
+		return nil, customErr.NewBadRequest(fmt.Sprintf("a %s payment cannot be refunded", payment.Status))
+	}
+
+	refundable := money.New(payment.Total-payment.RefundedAmount, payment.Currency)
+	amount := refundable
+	if req.Amount != nil {
+		amount = money.FromMajor(*req.Amount, payment.Currency)
+	}
+	if !amount.IsPositive() || amount.Amount > refundable.Amount {
+		return nil, customErr.NewBadRequest(fmt.Sprintf("refund amount must be between 0 and %s", refundable))
+	}
+
+	toWallet, err := s.walletShare(payment, amount, req.ToWallet)
+	if err != nil {
+		return nil, err
+	}
+	if toWallet > 0 && payment.Currency != money.DefaultCurrency() {
+		return nil, customErr.NewBadRequest(fmt.Sprintf("a %s payment cannot be refunded to the wallet", payment.Currency))
+	}
+
+	// money taken at the front desk is handed back there, only gateway payments are refunded through the provider
+	var gw gateway.PaymentGateway
+	provider := walletGateway
+	if toWallet < amount.Amount {
+		provider = offlineGateway
+		if payment.Gateway != offlineGateway {
+			if gw, err = s.gateway.Get(payment.Gateway); err != nil {
+				return nil, customErr.NewBadRequest(err.Error())
+			}
+			provider = gw.Name()
+		}
+	}
+
+	refund := models.PaymentRefund{
+		PaymentID:    payment.ID,
+		Amount:       amount.Amount,
+		Currency:     amount.Currency,
+		Reason:       req.Reason,
+		Status:       "pending",
+		Provider:     provider,
+		WalletAmount: toWallet,
+		RefundedBy:   uuid.MustParse(adminID),
+	}
+	if err := s.refund.CreateRefund(&refund); err != nil {
+		if errors.Is(err, repositories.ErrRefundExceedsPayment) {
+			return nil, customErr.NewConflict("another refund of this payment is in progress")
+		}
+		return nil, customErr.NewInternal("failed to record refund", err)
+	}
+
+	result, err := sendRefund(gw, payment, &refund, req.Reason)
+	if err != nil || result.Status == gateway.RefundStatusFailed {
+		refund.Status = "failed"
+		if updateErr := s.refund.UpdateRefund(&refund); updateErr != nil {
+			log.Printf("failed marking refund %s as failed: %v\n", refund.ID, updateErr)
+		}
+		if err == nil {
+			err = fmt.Errorf("refund %s was declined", result.ID)
+		}
+		return nil, customErr.NewBadRequest(fmt.Sprintf("payment provider rejected the refund: %v", err))
+	}
+
+	refund.Status = result.Status
+	refund.ProviderRefundID = &result.ID
+	payment.RefundedAmount += amount.Amount
+	payment.Status = "partially_refunded"
+	if payment.RefundedAmount >= payment.Total {
+		payment.Status = "refunded"
+	}
+
+	var credit *models.WalletTransaction
+	if toWallet > 0 {
+		credit = &models.WalletTransaction{
+			UserID:    payment.UserID,
+			Type:      "refund",
+			Amount:    toWallet,
+			Currency:  payment.Currency,
+			PaymentID: &payment.ID,
+			Reason:    fmt.Sprintf("Refund of %s: %s", payment.PackageName, req.Reason),
+			CreatedBy: &refund.RefundedBy,
+		}
+	}
+
+	revoke, endUserPackageID, err := s.revocation(payment, &refund)
+	if err != nil {
+		log.Printf("refund %s of payment %s went through at the provider but credits could not be resolved: %v\n", result.ID, payment.ID, err)
+	}
+	err = s.refund.CompleteRefund(&refund, payment, revoke, endUserPackageID, credit)
+	if errors.Is(err, repositories.ErrInsufficientCredit) {
+		// the credits were booked in the meantime, the money is already returned so keep the refund
+		refund.RevokedCredits = 0
+		err = s.refund.CompleteRefund(&refund, payment, nil, endUserPackageID, credit)
+	}
+	if err != nil {
+		log.Printf("refund %s of payment %s went through at the provider but was not saved: %v\n", result.ID, payment.ID, err)
+		return nil, customErr.NewInternal("refund was sent but could not be saved", err)
+	}
+
+	message := fmt.Sprintf("We refunded %s of your payment for %s (Invoice: %s).", amount, payment.PackageName, invoiceNumber(payment))
+	if toWallet > 0 {
+		message += fmt.Sprintf(" %s of it was added to your wallet.", money.New(toWallet, payment.Currency))
+	}
+	if refund.RevokedCredits > 0 {
+		message += fmt.Sprintf(" %d unused credit(s) were removed from your package.", refund.RevokedCredits)
+	}
+	payload := dto.NotificationEvent{
+		UserID:  payment.UserID.String(),
+		Type:    "system_message",
+		Title:   "Payment Refunded",
+		Message: message,
+	}
+	if err := s.notif.SendToUser(payload); err != nil {
+		log.Printf("failed sending refund notification to user %s: %v\n", payload.UserID, err)
+	}
+
+	resp := toRefundResponse(refund)
+	return &resp, nil
+}
+
+// walletShare is the part of a refund returned as wallet credit, all of it when asked for, otherwise the gateway
+// returns what it still holds and only the share paid from the wallet goes back there
+func (s *refundService) walletShare(payment *models.Payment, amount money.Money, toWallet bool) (int64, error) {
+	if toWallet {
+		return amount.Amount, nil
+	}
+
+	walletRefunded, err := s.refund.GetWalletRefunded(payment.ID.String())
+	if err != nil {
+		return 0, customErr.NewInternal("failed to fetch earlier refunds", err)
+	}
+	captured := payment.Total - payment.WalletAmount - (payment.RefundedAmount - walletRefunded)
+	if captured >= amount.Amount {
+		return 0, nil
+	}
+	if captured < 0 {
+		captured = 0
+	}
+	return amount.Amount - captured, nil
+}
+
+// sendRefund asks the gateway to return its part of the money, without a gateway the refund was paid out at the desk
+// and a refund made of wallet credit only needs no one
+func sendRefund(gw gateway.PaymentGateway, payment *models.Payment, refund *models.PaymentRefund, reason string) (*gateway.Refund, error) {
+	switch {
+	case refund.WalletAmount == refund.Amount:
+		return &gateway.Refund{ID: "wallet-" + refund.ID.String(), Status: gateway.RefundStatusSucceeded}, nil
+	case gw == nil:
+		return &gateway.Refund{ID: "offline-" + refund.ID.String(), Status: gateway.RefundStatusSucceeded}, nil
+	}
+
+	reference := ""
+	if payment.ProviderPaymentID != nil {
+		reference = *payment.ProviderPaymentID
+	}
+	return gw.Refund(gateway.RefundRequest{
+		PaymentReference: reference,
+		Amount:           money.New(refund.Amount-refund.WalletAmount, refund.Currency),
+		Reason:           reason,
+		Metadata: map[string]string{
+			"payment_id": payment.ID.String(),
+			"refund_id":  refund.ID.String(),
+		},
+	})
+}
+
+// revocation works out what the refund takes back from the package the payment paid for, partial refunds
+// revoke the refunded share of the credits and never more than is left unused
+func (s *refundService) revocation(payment *models.Payment, refund *models.PaymentRefund) (*models.CreditLedgerEntry, *uuid.UUID, error) {
+	if payment.Purpose != "package" && payment.Purpose != "gift" && payment.Purpose != "upgrade" {
+		return nil, nil, nil
+	}
+
+	purchase, err := s.ledger.GetPurchaseEntryByPaymentID(payment.ID.String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userPackageID := ""
+	if payment.UserPackageID != nil {
+		userPackageID = payment.UserPackageID.String()
+	} else if purchase != nil {
+		userPackageID = purchase.UserPackageID.String()
+	}
+	if userPackageID == "" {
+		return nil, nil, nil
+	}
+
+	userPackage, err := s.userPkg.GetUserPackageByID(userPackageID)
+	if err != nil || userPackage == nil {
+		return nil, nil, err
+	}
+
+	fullyRefunded := payment.Status == "refunded"
+	if userPackage.Type != "credit_pack" {
+		if fullyRefunded {
+			return nil, &userPackage.ID, nil
+		}
+		return nil, nil, nil
+	}
+	if purchase == nil || purchase.Amount <= 0 {
+		return nil, nil, nil
+	}
+
+	previous, err := s.refund.GetRefundsByPaymentID(payment.ID.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	revoked := 0
+	for _, r := range previous {
+		if r.ID != refund.ID && r.Status != "failed" {
+			revoked += r.RevokedCredits
+		}
+	}
+
+	credits := purchase.Amount - revoked
+	if !fullyRefunded && payment.Total > 0 {
+		// the refunded share of the credits, rounded half-up
+		share := (int64(purchase.Amount)*refund.Amount*2 + payment.Total) / (2 * payment.Total)
+		credits = min(credits, int(share))
+	}
+	credits = min(credits, userPackage.RemainingCredit)
+	if credits <= 0 {
+		return nil, nil, nil
+	}
+
+	refund.RevokedCredits = credits
+	return &models.CreditLedgerEntry{
+		UserPackageID: userPackage.ID,
+		Type:          "adjustment",
+		Amount:        -credits,
+		PaymentID:     &payment.ID,
+		Reason:        fmt.Sprintf("Refunded %s of %s (%s)", money.New(refund.Amount, refund.Currency), payment.PackageName, invoiceNumber(payment)),
+	}, nil, nil
+}
+
+func (s *refundService) GetRefunds(paymentID string) ([]dto.RefundResponse, error) {
+	refunds, err := s.refund.GetRefundsByPaymentID(paymentID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch refunds", err)
+	}
+
+	result := make([]dto.RefundResponse, 0, len(refunds))
+	for _, r := range refunds {
+		result = append(result, toRefundResponse(r))
+	}
+	return result, nil
+}
+
+func toRefundResponse(r models.PaymentRefund) dto.RefundResponse {
+	return dto.RefundResponse{
+		ID:             r.ID.String(),
+		PaymentID:      r.PaymentID.String(),
+		Amount:         majorUnits(r.Amount, r.Currency),
+		WalletAmount:   majorUnits(r.WalletAmount, r.Currency),
+		Reason:         r.Reason,
+		Status:         r.Status,
+		Provider:       r.Provider,
+		RevokedCredits: r.RevokedCredits,
+		RefundedBy:     r.Admin.Fullname,
+		CreatedAt:      r.CreatedAt.Format(time.RFC3339),
+	}
+}
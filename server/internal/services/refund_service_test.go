@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"server/internal/dto"
+	customErr "server/pkg/errors"
+	"server/pkg/gateway"
+	"server/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+func refundRequest(amount *float64, toWallet bool) dto.RefundPaymentRequest {
+	return dto.RefundPaymentRequest{Amount: amount, Reason: "requested by member", ToWallet: toWallet}
+}
+
+func major(amount float64) *float64 {
+	return &amount
+}
+
+func TestRefundPaymentInFull(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	payment := paidPayment(t, db, gw, newTestPaymentService(db, gw), 100_000, 0)
+	svc := newTestRefundService(db, gw)
+
+	resp, err := svc.RefundPayment(uuid.NewString(), payment.ID.String(), refundRequest(nil, false))
+	if err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	if resp.Provider != "fake" || resp.Status != gateway.RefundStatusSucceeded || resp.RevokedCredits != 10 {
+		t.Fatalf("refund = %s/%s/%d credits, want fake/succeeded/10", resp.Provider, resp.Status, resp.RevokedCredits)
+	}
+	got := db.payments[payment.ID]
+	if got.Status != "refunded" || got.RefundedAmount != 100_000 {
+		t.Fatalf("payment = %s/%d, want refunded/100000", got.Status, got.RefundedAmount)
+	}
+	if credits := db.userPackages[*got.UserPackageID].RemainingCredit; credits != 0 {
+		t.Fatalf("package keeps %d credits, want 0", credits)
+	}
+	if !db.notified("Payment Refunded") {
+		t.Fatalf("member was not told about the refund")
+	}
+
+	if _, err := svc.RefundPayment(uuid.NewString(), payment.ID.String(), refundRequest(nil, false)); err == nil {
+		t.Fatalf("a refunded payment was refunded again")
+	}
+}
+
+func TestRefundPaymentPartially(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	payment := paidPayment(t, db, gw, newTestPaymentService(db, gw), 100_000, 0)
+	svc := newTestRefundService(db, gw)
+
+	resp, err := svc.RefundPayment(uuid.NewString(), payment.ID.String(), refundRequest(major(400), false))
+	if err != nil {
+		t.Fatalf("first refund: %v", err)
+	}
+	if resp.RevokedCredits != 4 {
+		t.Fatalf("revoked %d credits, want the refunded share of 4", resp.RevokedCredits)
+	}
+	got := db.payments[payment.ID]
+	if got.Status != "partially_refunded" || got.RefundedAmount != 40_000 {
+		t.Fatalf("payment = %s/%d, want partially_refunded/40000", got.Status, got.RefundedAmount)
+	}
+
+	if _, err := svc.RefundPayment(uuid.NewString(), payment.ID.String(), refundRequest(major(700), false)); err == nil {
+		t.Fatalf("refunded more than what is left")
+	}
+
+	resp, err = svc.RefundPayment(uuid.NewString(), payment.ID.String(), refundRequest(nil, false))
+	if err != nil {
+		t.Fatalf("second refund: %v", err)
+	}
+	if resp.Amount != 600 || resp.RevokedCredits != 6 {
+		t.Fatalf("second refund = %.0f with %d credits, want 600 with 6", resp.Amount, resp.RevokedCredits)
+	}
+	if got := db.payments[payment.ID]; got.Status != "refunded" || got.RefundedAmount != 100_000 {
+		t.Fatalf("payment = %s/%d, want refunded/100000", got.Status, got.RefundedAmount)
+	}
+}
+
+func TestRefundWalletSplitPayment(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	payment := paidPayment(t, db, gw, newTestPaymentService(db, gw), 100_000, 30_000)
+	user := db.users[payment.UserID]
+	svc := newTestRefundService(db, gw)
+
+	// the gateway returns the 70000 it captured, the rest goes back to the wallet
+	resp, err := svc.RefundPayment(uuid.NewString(), payment.ID.String(), refundRequest(major(800), false))
+	if err != nil {
+		t.Fatalf("first refund: %v", err)
+	}
+	if resp.Provider != "fake" || resp.WalletAmount != 100 {
+		t.Fatalf("first refund = %s with %.0f to the wallet, want fake with 100", resp.Provider, resp.WalletAmount)
+	}
+	if user.WalletBalance != 10_000 {
+		t.Fatalf("wallet = %d, want 10000", user.WalletBalance)
+	}
+	captured := gateway.RefundRequest{PaymentReference: *payment.ProviderPaymentID, Amount: money.New(1, "IDR")}
+	if refund, _ := gw.Refund(captured); refund.Status != gateway.RefundStatusFailed {
+		t.Fatalf("the gateway still holds money after returning all it captured")
+	}
+
+	// nothing is left at the gateway, the rest is wallet credit only
+	resp, err = svc.RefundPayment(uuid.NewString(), payment.ID.String(), refundRequest(nil, false))
+	if err != nil {
+		t.Fatalf("second refund: %v", err)
+	}
+	if resp.Provider != walletGateway || resp.WalletAmount != 200 {
+		t.Fatalf("second refund = %s with %.0f to the wallet, want wallet with 200", resp.Provider, resp.WalletAmount)
+	}
+	if user.WalletBalance != 30_000 {
+		t.Fatalf("wallet = %d, want the 30000 paid from it", user.WalletBalance)
+	}
+	if got := db.payments[payment.ID]; got.Status != "refunded" || got.RefundedAmount != 100_000 {
+		t.Fatalf("payment = %s/%d, want refunded/100000", got.Status, got.RefundedAmount)
+	}
+}
+
+func TestRefundDeclinedByGateway(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	payment := paidPayment(t, db, gw, newTestPaymentService(db, gw), 100_000, 0)
+	svc := newTestRefundService(db, gw)
+
+	// the money was already returned at the gateway's dashboard, so the gateway declines
+	if _, err := gw.Refund(gateway.RefundRequest{PaymentReference: *payment.ProviderPaymentID, Amount: money.New(100_000, "IDR")}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := svc.RefundPayment(uuid.NewString(), payment.ID.String(), refundRequest(nil, false))
+	var appErr *customErr.AppError
+	if !errors.As(err, &appErr) || appErr.Code != 400 {
+		t.Fatalf("err = %v, want a bad request", err)
+	}
+	if len(db.refunds) != 1 || db.refunds[0].Status != "failed" {
+		t.Fatalf("declined refund was not recorded as failed")
+	}
+	got := db.payments[payment.ID]
+	if got.Status != "success" || got.RefundedAmount != 0 {
+		t.Fatalf("payment = %s/%d, want it untouched", got.Status, got.RefundedAmount)
+	}
+	if credits := db.userPackages[*got.UserPackageID].RemainingCredit; credits != 10 {
+		t.Fatalf("package keeps %d credits, want 10", credits)
+	}
+}
@@ -2,6 +2,8 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"log"
 	"server/internal/dto"
 	"server/internal/models"
 	"server/internal/repositories"
@@ -93,3 +95,23 @@ func (s *reviewService) CreateReview(userID string, bookingID string, req dto.Cr
 
 	return nil
 }
+
+// NewReviewRequestHook asks every member who attended a completed class to leave a review
+func NewReviewRequestHook(notification NotificationService) ScheduleCompletedHook {
+	return func(schedule models.ClassSchedule, bookings []models.Booking) {
+		for _, b := range bookings {
+			if b.Attendance.Status != "attended" || b.Attendance.IsReviewed {
+				continue
+			}
+			payload := dto.NotificationEvent{
+				UserID:  b.UserID.String(),
+				Type:    "system_message",
+				Title:   "How Was Your Class?",
+				Message: fmt.Sprintf("Thanks for joining \"%s\" with %s. Let us know how it went by leaving a review.", schedule.ClassName, schedule.InstructorName),
+			}
+			if err := notification.SendToUser(payload); err != nil {
+				log.Printf("failed sending review request to user %s: %v\n", payload.UserID, err)
+			}
+		}
+	}
+}
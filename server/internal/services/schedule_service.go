@@ -1,11 +1,14 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"server/internal/dto"
 	"server/internal/models"
 	"server/internal/repositories"
 	customErr "server/pkg/errors"
+	"server/pkg/meeting"
 	"server/pkg/utils"
 	"time"
 
@@ -18,6 +21,8 @@ type ClassScheduleService interface {
 	CreateClassSchedule(req dto.CreateScheduleRequest) error
 	CreateRecurringSchedule(req dto.CreateRecurringScheduleRequest) error
 	UpdateClassSchedule(id string, req dto.UpdateClassScheduleRequest) error
+	ImportSchedules(rows []dto.ScheduleImportRow, commit bool) (*dto.ScheduleImportResponse, error)
+	CancelClassSchedule(id string, req dto.CancelClassScheduleRequest) error
 
 	// customer
 	GetSchedulesWithBookingStatus(userID string) ([]dto.ClassScheduleResponse, error)
@@ -26,10 +31,24 @@ type ClassScheduleService interface {
 
 	// instructor only
 	OpenClassSchedule(id string, req dto.OpenClassScheduleRequest) error
+	StartClassSchedule(userID, id string) error
+	CompleteClassSchedule(userID, id string) error
 	GetAttendancesForSchedule(scheduleID string) ([]dto.AttendanceWithUserResponse, error)
 	GetSchedulesByInstructor(userID string, params dto.InstructorScheduleQueryParam) ([]dto.InstructorScheduleResponse, *dto.PaginationResponse, error)
+
+	// cron
+	AdvanceScheduleLifecycle() error
+
+	// OnScheduleCompleted registers a hook that runs after a schedule is completed
+	OnScheduleCompleted(hook ScheduleCompletedHook)
 }
 
+// ScheduleCompletedHook receives the completed schedule and its bookings with final attendance
+type ScheduleCompletedHook func(schedule models.ClassSchedule, bookings []models.Booking)
+
+// schedules left running are completed automatically once this long has passed after the class ends
+const scheduleCompletionGrace = 30 * time.Minute
+
 type classScheduleService struct {
 	schedule    repositories.ClassScheduleRepository
 	template    ScheduleTemplateService
@@ -37,6 +56,9 @@ type classScheduleService struct {
 	instructor  repositories.InstructorRepository
 	bookingRepo repositories.BookingRepository
 	packageRepo repositories.PackageRepository
+	meeting     meeting.Provider
+	notif       NotificationService
+	onComplete  []ScheduleCompletedHook
 }
 
 func NewClassScheduleService(
@@ -46,6 +68,8 @@ func NewClassScheduleService(
 	instructor repositories.InstructorRepository,
 	bookingRepo repositories.BookingRepository,
 	packageRepo repositories.PackageRepository,
+	meeting meeting.Provider,
+	notif NotificationService,
 ) ClassScheduleService {
 	return &classScheduleService{
 		schedule:    schedule,
@@ -54,37 +78,59 @@ func NewClassScheduleService(
 		instructor:  instructor,
 		bookingRepo: bookingRepo,
 		packageRepo: packageRepo,
+		meeting:     meeting,
+		notif:       notif,
 	}
 }
 
 // Service
 func (s *classScheduleService) CreateClassSchedule(req dto.CreateScheduleRequest) error {
+	schedule, err := s.buildClassSchedule(req)
+	if err != nil {
+		return err
+	}
 
+	err = s.schedule.CreateClassSchedule(schedule)
+	if err != nil {
+		return customErr.NewInternal("Failed to create schedule", err)
+	}
+
+	return nil
+}
+
+// buildClassSchedule runs the class/instructor lookup and conflict checks for a new schedule
+func (s *classScheduleService) buildClassSchedule(req dto.CreateScheduleRequest) (*models.ClassSchedule, error) {
 	parsedDate, err := utils.ParseDate(req.Date)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := utils.ValidateScheduleNotInPast(parsedDate, req.StartHour, req.StartMinute); err != nil {
-		return err
+		return nil, err
 	}
 
 	class, err := s.class.GetClassByID(req.ClassID)
-	if err != nil {
-		return customErr.NewNotFound("class not found")
+	if err != nil || class == nil {
+		return nil, customErr.NewNotFound("class not found")
 	}
 
 	instructor, err := s.instructor.GetInstructorByID(req.InstructorID)
+	if err != nil || instructor == nil {
+		return nil, customErr.NewNotFound("instructor not found")
+	}
+
+	err = s.template.CheckInstructorConflict(req.InstructorID, parsedDate, req.StartHour, req.StartMinute, class.Duration)
 	if err != nil {
-		return customErr.NewNotFound("instructor not found")
+		return nil, customErr.NewConflict(err.Error())
 	}
 
-	err = s.template.CheckInstructorConflict(req.InstructorID, parsedDate, req.StartHour, req.StartMinute)
+	mode := resolveDeliveryMode(req.DeliveryMode, class.DeliveryMode)
+	onlineCapacity, err := resolveOnlineCapacity(mode, req.OnlineCapacity)
 	if err != nil {
-		return customErr.NewConflict(err.Error())
+		return nil, err
 	}
 
-	schedule := models.ClassSchedule{
+	return &models.ClassSchedule{
 		ID:             uuid.New(),
 		ClassID:        class.ID,
 		ClassName:      class.Title,
@@ -93,32 +139,177 @@ func (s *classScheduleService) CreateClassSchedule(req dto.CreateScheduleRequest
 		InstructorName: instructor.User.Fullname,
 		Location:       class.Location.Name,
 		Duration:       class.Duration,
+		DeliveryMode:   mode,
 		Capacity:       req.Capacity,
+		OnlineCapacity: onlineCapacity,
 		Color:          req.Color,
 		Date:           parsedDate,
 		StartHour:      req.StartHour,
 		StartMinute:    req.StartMinute,
+	}, nil
+}
+
+// resolveDeliveryMode falls back to the class delivery mode when none is requested
+func resolveDeliveryMode(requested, fallback string) string {
+	if requested != "" {
+		return requested
+	}
+	if fallback != "" {
+		return fallback
+	}
+	return "in_person"
+}
+
+// only hybrid schedules keep a separate online capacity, online schedules use Capacity for online seats
+func resolveOnlineCapacity(mode string, onlineCapacity int) (int, error) {
+	if mode != "hybrid" {
+		return 0, nil
+	}
+	if onlineCapacity <= 0 {
+		return 0, customErr.NewBadRequest("online capacity is required for hybrid schedules")
+	}
+	return onlineCapacity, nil
+}
+
+// ensureMeetingLink creates the online room of an online or hybrid schedule on first use
+func ensureMeetingLink(provider meeting.Provider, repo repositories.ClassScheduleRepository, schedule *models.ClassSchedule) error {
+	if schedule.DeliveryMode == "in_person" || schedule.DeliveryMode == "" || schedule.ZoomLink != nil {
+		return nil
 	}
 
-	err = s.schedule.CreateClassSchedule(&schedule)
+	m, err := provider.CreateMeeting(meeting.Request{
+		ScheduleID: schedule.ID.String(),
+		Title:      schedule.ClassName,
+		StartAt:    utils.ScheduleStartTime(schedule.Date, schedule.StartHour, schedule.StartMinute),
+		Duration:   schedule.Duration,
+	})
 	if err != nil {
-		return customErr.NewInternal("Failed to create schedule", err)
+		return err
 	}
 
-	return nil
+	schedule.ZoomLink = &m.JoinURL
+	schedule.MeetingID = m.ID
+	schedule.MeetingProvider = provider.Name()
+	return repo.UpdateMeetingLink(schedule.ID, schedule.MeetingProvider, schedule.MeetingID, m.JoinURL)
+}
+
+func (s *classScheduleService) ImportSchedules(rows []dto.ScheduleImportRow, commit bool) (*dto.ScheduleImportResponse, error) {
+	result := &dto.ScheduleImportResponse{TotalRows: len(rows)}
+	var accepted []models.ClassSchedule
+
+	for _, row := range rows {
+		item := dto.ScheduleImportRowResponse{
+			Row:            row.Row,
+			ClassName:      row.Class,
+			InstructorName: row.Instructor,
+			Date:           row.Date,
+			Capacity:       row.Capacity,
+			Color:          row.Color,
+		}
+
+		schedule, err := s.validateImportRow(row, accepted)
+		if err != nil {
+			item.Error = err.Error()
+			result.InvalidRows++
+			result.Rows = append(result.Rows, item)
+			continue
+		}
+
+		item.IsValid = true
+		item.ClassID = schedule.ClassID.String()
+		item.ClassName = schedule.ClassName
+		item.InstructorID = schedule.InstructorID.String()
+		item.InstructorName = schedule.InstructorName
+		item.Date = schedule.Date.Format("2006-01-02")
+		item.StartHour = schedule.StartHour
+		item.StartMinute = schedule.StartMinute
+
+		accepted = append(accepted, *schedule)
+		result.ValidRows++
+		result.Rows = append(result.Rows, item)
+	}
+
+	if !commit || result.InvalidRows > 0 {
+		return result, nil
+	}
+
+	if err := s.schedule.CreateClassSchedules(accepted); err != nil {
+		return nil, customErr.NewInternal("failed to import schedules", err)
+	}
+	result.Committed = true
+
+	return result, nil
+}
+
+// validateImportRow resolves class and instructor by id or name, then applies the same
+// validation as CreateClassSchedule plus a conflict check against rows earlier in the file
+func (s *classScheduleService) validateImportRow(row dto.ScheduleImportRow, accepted []models.ClassSchedule) (*models.ClassSchedule, error) {
+	if row.Error != "" {
+		return nil, customErr.NewBadRequest(row.Error)
+	}
+	if row.Class == "" || row.Instructor == "" || row.Date == "" || row.Time == "" {
+		return nil, customErr.NewBadRequest("class, instructor, date and time are required")
+	}
+	if row.Capacity <= 0 {
+		return nil, customErr.NewBadRequest("capacity must be greater than 0")
+	}
+
+	hour, minute, err := utils.ParseClockTime(row.Time)
+	if err != nil {
+		return nil, customErr.NewBadRequest(err.Error())
+	}
+
+	classID := row.Class
+	if _, err := uuid.Parse(row.Class); err != nil {
+		class, err := s.class.GetClassByTitle(row.Class)
+		if err != nil || class == nil {
+			return nil, customErr.NewNotFound(fmt.Sprintf("class %q not found", row.Class))
+		}
+		classID = class.ID.String()
+	}
+
+	instructorID := row.Instructor
+	if _, err := uuid.Parse(row.Instructor); err != nil {
+		instructor, err := s.instructor.GetInstructorByName(row.Instructor)
+		if err != nil || instructor == nil {
+			return nil, customErr.NewNotFound(fmt.Sprintf("instructor %q not found", row.Instructor))
+		}
+		instructorID = instructor.ID.String()
+	}
+
+	schedule, err := s.buildClassSchedule(dto.CreateScheduleRequest{
+		ClassID:      classID,
+		InstructorID: instructorID,
+		Date:         row.Date,
+		StartHour:    hour,
+		StartMinute:  minute,
+		Capacity:     row.Capacity,
+		Color:        row.Color,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.template.CheckScheduleConflict(instructorID, schedule.Date, hour, minute, accepted); err != nil {
+		return nil, customErr.NewConflict(err.Error() + " (within import file)")
+	}
+
+	return schedule, nil
 }
 
 func (s *classScheduleService) CreateRecurringSchedule(req dto.CreateRecurringScheduleRequest) error {
 	templateReq := dto.CreateScheduleTemplateRequest{
-		ClassID:      req.ClassID,
-		InstructorID: req.InstructorID,
-		DayOfWeeks:   req.DayOfWeeks,
-		StartHour:    req.StartHour,
-		StartMinute:  req.StartMinute,
-		Date:         req.Date,
-		Capacity:     req.Capacity,
-		Color:        req.Color,
-		EndDate:      req.EndDate,
+		ClassID:        req.ClassID,
+		InstructorID:   req.InstructorID,
+		DayOfWeeks:     req.DayOfWeeks,
+		StartHour:      req.StartHour,
+		StartMinute:    req.StartMinute,
+		Date:           req.Date,
+		Capacity:       req.Capacity,
+		Color:          req.Color,
+		EndDate:        req.EndDate,
+		DeliveryMode:   req.DeliveryMode,
+		OnlineCapacity: req.OnlineCapacity,
 	}
 
 	templateID, err := s.template.CreateScheduleTemplate(templateReq)
@@ -148,9 +339,28 @@ func (s *classScheduleService) UpdateClassSchedule(id string, req dto.UpdateClas
 	if err != nil {
 		return customErr.NewNotFound("schedule not found")
 	}
+	if schedule.Status == "completed" || schedule.Status == "canceled" {
+		return customErr.NewBadRequest(fmt.Sprintf("cannot update a %s schedule", schedule.Status))
+	}
+
+	mode := resolveDeliveryMode(req.DeliveryMode, schedule.DeliveryMode)
+	if mode != schedule.DeliveryMode && schedule.Booked > 0 {
+		return customErr.NewBadRequest("cannot change delivery mode of a schedule with bookings")
+	}
+	onlineCapacity, err := resolveOnlineCapacity(mode, req.OnlineCapacity)
+	if err != nil {
+		return err
+	}
 
-	if req.Capacity < schedule.Booked {
-		return fmt.Errorf("capacity cannot be less than booked participant (%d)", schedule.Booked)
+	inPersonBooked := schedule.Booked - schedule.OnlineBooked
+	if mode == "online" {
+		inPersonBooked = schedule.Booked
+	}
+	if req.Capacity < inPersonBooked {
+		return fmt.Errorf("capacity cannot be less than booked participant (%d)", inPersonBooked)
+	}
+	if mode == "hybrid" && onlineCapacity < schedule.OnlineBooked {
+		return fmt.Errorf("online capacity cannot be less than booked online participant (%d)", schedule.OnlineBooked)
 	}
 
 	// only if class changed, do checking
@@ -175,7 +385,7 @@ func (s *classScheduleService) UpdateClassSchedule(id string, req dto.UpdateClas
 		schedule.InstructorName = instructor.User.Fullname
 	}
 
-	err = s.template.CheckInstructorConflict(req.InstructorID, parsedDate, req.StartHour, req.StartMinute)
+	err = s.template.CheckInstructorConflict(req.InstructorID, parsedDate, req.StartHour, req.StartMinute, schedule.Duration)
 	if err != nil {
 		return customErr.NewConflict(err.Error())
 	}
@@ -183,6 +393,8 @@ func (s *classScheduleService) UpdateClassSchedule(id string, req dto.UpdateClas
 	schedule.Color = req.Color
 	schedule.Date = parsedDate
 	schedule.Capacity = req.Capacity
+	schedule.DeliveryMode = mode
+	schedule.OnlineCapacity = onlineCapacity
 	schedule.StartHour = req.StartHour
 	schedule.StartMinute = req.StartMinute
 
@@ -233,10 +445,11 @@ func (s *classScheduleService) GetClassScheduleByID(scheduleID, userID string) (
 	var pkgResponses []dto.PackageListResponse
 	for _, p := range packages {
 		pkgResponses = append(pkgResponses, dto.PackageListResponse{
-			ID:    p.ID.String(),
-			Name:  p.Name,
-			Price: p.Price,
-			Image: p.Image,
+			ID:       p.ID.String(),
+			Name:     p.Name,
+			Currency: p.Currency,
+			Price:    majorUnits(p.Price, p.Currency),
+			Image:    p.Image,
 		})
 	}
 
@@ -255,8 +468,12 @@ func (s *classScheduleService) GetClassScheduleByID(scheduleID, userID string) (
 			StartHour:      schedule.StartHour,
 			StartMinute:    schedule.StartMinute,
 			Capacity:       schedule.Capacity,
+			DeliveryMode:   schedule.DeliveryMode,
+			OnlineCapacity: schedule.OnlineCapacity,
+			OnlineBooked:   schedule.OnlineBooked,
 			BookedCount:    schedule.Booked,
 			Color:          schedule.Color,
+			Status:         schedule.Status,
 			Duration:       schedule.Duration,
 			IsBooked:       isBooked,
 		},
@@ -284,9 +501,13 @@ func (s *classScheduleService) GetSchedulesByFilter(filter dto.ClassScheduleQuer
 			StartHour:      schedule.StartHour,
 			StartMinute:    schedule.StartMinute,
 			Capacity:       schedule.Capacity,
+			DeliveryMode:   schedule.DeliveryMode,
+			OnlineCapacity: schedule.OnlineCapacity,
+			OnlineBooked:   schedule.OnlineBooked,
 			Duration:       schedule.Duration,
 			BookedCount:    schedule.Booked,
 			Color:          schedule.Color,
+			Status:         schedule.Status,
 			IsBooked:       false,
 		})
 	}
@@ -317,8 +538,12 @@ func (s *classScheduleService) GetSchedulesWithBookingStatus(userID string) ([]d
 			StartMinute:    schedule.StartMinute,
 			Duration:       schedule.Duration,
 			Capacity:       schedule.Capacity,
+			DeliveryMode:   schedule.DeliveryMode,
+			OnlineCapacity: schedule.OnlineCapacity,
+			OnlineBooked:   schedule.OnlineBooked,
 			BookedCount:    schedule.Booked,
 			Color:          schedule.Color,
+			Status:         schedule.Status,
 			IsBooked:       isBooked,
 		})
 	}
@@ -351,9 +576,13 @@ func (s *classScheduleService) GetSchedulesByInstructor(userID string, params dt
 			StartHour:        schedule.StartHour,
 			StartMinute:      schedule.StartMinute,
 			Capacity:         schedule.Capacity,
+			DeliveryMode:     schedule.DeliveryMode,
+			OnlineCapacity:   schedule.OnlineCapacity,
+			OnlineBooked:     schedule.OnlineBooked,
 			Duration:         schedule.Duration,
 			BookedCount:      schedule.Booked,
 			IsOpened:         schedule.IsOpened,
+			Status:           schedule.Status,
 			Date:             schedule.Date.Format("2006-01-02"),
 			ZoomLink:         utils.EmptyString(schedule.ZoomLink),
 			VerificationCode: utils.EmptyString(schedule.VerificationCode),
@@ -368,12 +597,14 @@ func (s *classScheduleService) OpenClassSchedule(id string, req dto.OpenClassSch
 	if err != nil {
 		return customErr.NewNotFound("no schedule found")
 	}
-	if schedule.IsOpened {
-		return fmt.Errorf("schedule already opened")
+	if schedule.Status != "scheduled" {
+		return customErr.NewBadRequest(fmt.Sprintf("schedule is already %s", schedule.Status))
 	}
 
 	if req.ZoomLink != "" {
 		schedule.ZoomLink = &req.ZoomLink
+	} else if err := ensureMeetingLink(s.meeting, s.schedule, schedule); err != nil {
+		return customErr.NewInternal("failed to create meeting link", err)
 	}
 
 	schedule.VerificationCode = &req.VerificationCode
@@ -381,6 +612,186 @@ func (s *classScheduleService) OpenClassSchedule(id string, req dto.OpenClassSch
 	return s.schedule.OpenSchedule(schedule.ID, schedule)
 }
 
+// instructorSchedule loads a schedule taught by the instructor account, other instructors' classes are not found
+func (s *classScheduleService) instructorSchedule(userID, id string) (*models.ClassSchedule, error) {
+	instructor, err := s.instructor.GetInstructorByUserID(userID)
+	if err != nil || instructor == nil {
+		return nil, customErr.NewNotFound("instructor not found")
+	}
+	schedule, err := s.schedule.GetClassScheduleByID(id)
+	if err != nil || schedule == nil || schedule.InstructorID != instructor.ID {
+		return nil, customErr.NewNotFound("no schedule found")
+	}
+	return schedule, nil
+}
+
+func (s *classScheduleService) StartClassSchedule(userID, id string) error {
+	schedule, err := s.instructorSchedule(userID, id)
+	if err != nil {
+		return err
+	}
+	if schedule.Status != "open" {
+		return customErr.NewBadRequest("only opened schedules can be started")
+	}
+
+	if err := s.schedule.UpdateScheduleStatus(schedule.ID, []string{"open"}, "in_progress"); err != nil {
+		return s.transitionError(err, "failed to start schedule")
+	}
+	return nil
+}
+
+func (s *classScheduleService) CompleteClassSchedule(userID, id string) error {
+	schedule, err := s.instructorSchedule(userID, id)
+	if err != nil {
+		return err
+	}
+	if schedule.Status != "open" && schedule.Status != "in_progress" {
+		return customErr.NewBadRequest(fmt.Sprintf("cannot complete a %s schedule", schedule.Status))
+	}
+
+	return s.completeSchedule(schedule)
+}
+
+// completeSchedule finalizes attendance and then runs the completion hooks, hook failures never undo the completion
+func (s *classScheduleService) completeSchedule(schedule *models.ClassSchedule) error {
+	if err := s.schedule.CompleteSchedule(schedule, time.Now().UTC()); err != nil {
+		return s.transitionError(err, "failed to complete schedule")
+	}
+	schedule.Status = "completed"
+
+	if len(s.onComplete) == 0 {
+		return nil
+	}
+
+	bookings, err := s.schedule.GetAttendancesByScheduleID(schedule.ID.String())
+	if err != nil {
+		log.Printf("failed fetching bookings for completed schedule %s: %v\n", schedule.ID, err)
+		return nil
+	}
+	for _, hook := range s.onComplete {
+		hook(*schedule, bookings)
+	}
+	return nil
+}
+
+func (s *classScheduleService) CancelClassSchedule(id string, req dto.CancelClassScheduleRequest) error {
+	schedule, err := s.schedule.GetClassScheduleByID(id)
+	if err != nil || schedule == nil {
+		return customErr.NewNotFound("schedule not found")
+	}
+	if schedule.Status != "scheduled" && schedule.Status != "open" {
+		return customErr.NewBadRequest(fmt.Sprintf("cannot cancel a %s schedule", schedule.Status))
+	}
+
+	bookings, packageTypes, err := s.schedule.CancelSchedule(schedule, req.Reason, time.Now().UTC())
+	if err != nil {
+		return s.transitionError(err, "failed to cancel schedule")
+	}
+
+	for _, b := range bookings {
+		message := fmt.Sprintf(
+			"The class \"%s\" on %s at %02d:%02d has been canceled: %s.",
+			schedule.ClassName,
+			schedule.Date.Format("January 2, 2006"),
+			schedule.StartHour,
+			schedule.StartMinute,
+			req.Reason,
+		)
+		switch packageTypes[b.ID] {
+		case "credit_pack":
+			message += " Your credit has been returned to your package."
+		case "weekly_limit":
+			message += " The class no longer counts towards your weekly limit."
+		}
+		payload := dto.NotificationEvent{
+			UserID:  b.UserID.String(),
+			Type:    "system_message",
+			Title:   "Class Canceled",
+			Message: message,
+		}
+		if err := s.notif.SendToUser(payload); err != nil {
+			log.Printf("failed sending cancel notification to user %s: %v\n", payload.UserID, err)
+		}
+	}
+	return nil
+}
+
+// ** buat cron job
+// AdvanceScheduleLifecycle starts opened schedules once their start time has passed and completes the
+// ones still running after their end time plus the grace period, a schedule that was never opened did not
+// take place so it is canceled and its bookings refunded
+func (s *classScheduleService) AdvanceScheduleLifecycle() error {
+	now := time.Now()
+
+	schedules, err := s.schedule.GetSchedulesPendingTransition(now)
+	if err != nil {
+		return err
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+
+		duration := schedule.Duration
+		if duration <= 0 {
+			duration = 60
+		}
+		start := utils.ScheduleStartTime(schedule.Date, schedule.StartHour, schedule.StartMinute)
+		end := start.Add(time.Duration(duration) * time.Minute)
+
+		switch {
+		case now.After(end.Add(scheduleCompletionGrace)) && schedule.Status == "scheduled":
+			s.cancelUnopenedSchedule(schedule)
+		case now.After(end.Add(scheduleCompletionGrace)):
+			if err := s.completeSchedule(schedule); err != nil {
+				log.Printf("failed completing schedule %s: %v\n", schedule.ID, err)
+			}
+		case schedule.Status == "open" && now.After(start):
+			if err := s.schedule.UpdateScheduleStatus(schedule.ID, []string{"open"}, "in_progress"); err != nil {
+				log.Printf("failed starting schedule %s: %v\n", schedule.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// cancelUnopenedSchedule cancels a class whose instructor never opened it and tells the admins so they can
+// follow up with the instructor
+func (s *classScheduleService) cancelUnopenedSchedule(schedule *models.ClassSchedule) {
+	err := s.CancelClassSchedule(schedule.ID.String(), dto.CancelClassScheduleRequest{Reason: "the class was never opened"})
+	if err != nil {
+		log.Printf("failed canceling unopened schedule %s: %v\n", schedule.ID, err)
+		return
+	}
+	log.Printf("schedule %s was never opened and has been canceled\n", schedule.ID)
+
+	payload := dto.NotificationEvent{
+		Type:  "system_message",
+		Title: "Class Not Held",
+		Message: fmt.Sprintf(
+			"The class \"%s\" on %s at %02d:%02d was never opened by %s, it was canceled and the booked credits were returned.",
+			schedule.ClassName,
+			schedule.Date.Format("January 2, 2006"),
+			schedule.StartHour,
+			schedule.StartMinute,
+			schedule.InstructorName,
+		),
+	}
+	if err := s.notif.SendToAdmins(payload); err != nil {
+		log.Printf("failed notifying admins about unopened schedule %s: %v\n", schedule.ID, err)
+	}
+}
+
+func (s *classScheduleService) OnScheduleCompleted(hook ScheduleCompletedHook) {
+	s.onComplete = append(s.onComplete, hook)
+}
+
+func (s *classScheduleService) transitionError(err error, msg string) error {
+	if errors.Is(err, repositories.ErrScheduleStatusChanged) {
+		return customErr.NewConflict("schedule status has changed, please refresh and try again")
+	}
+	return customErr.NewInternal(msg, err)
+}
+
 func (s *classScheduleService) GetAttendancesForSchedule(scheduleID string) ([]dto.AttendanceWithUserResponse, error) {
 	bookings, err := s.schedule.GetAttendancesByScheduleID(scheduleID)
 	if err != nil {
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"server/internal/dto"
+	"server/internal/models"
+	"server/pkg/meeting"
+
+	"github.com/google/uuid"
+)
+
+// newScheduleService wires the schedule service to the fakes of the timetable fixture
+func (f *timetableFixture) newScheduleService() ClassScheduleService {
+	return NewClassScheduleService(f.schedules, f.svc, f.classes, f.instructors, nil, nil, meeting.NewLocalProvider(""), &fakeNotificationService{db: f.db})
+}
+
+func TestImportSchedulesIsAllOrNothing(t *testing.T) {
+	f := newTimetableFixture()
+	svc := f.newScheduleService()
+	date := time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02")
+
+	rows := []dto.ScheduleImportRow{
+		{Row: 2, Class: "Morning Yoga", Instructor: "Dewi", Date: date, Time: "07:00", Capacity: 12},
+		{Row: 3, Class: f.class.ID.String(), Instructor: f.instructor.ID.String(), Date: date, Time: "09:30", Capacity: 12},
+		{Row: 4, Class: "Morning Yoga", Instructor: "Budi", Date: date, Time: "11:00", Capacity: 12},
+	}
+	result, err := svc.ImportSchedules(rows, true)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if result.ValidRows != 2 || result.InvalidRows != 1 || result.Committed {
+		t.Fatalf("valid %d invalid %d committed %v, want 2 1 false", result.ValidRows, result.InvalidRows, result.Committed)
+	}
+	if len(f.schedules.batches) != 0 {
+		t.Fatalf("%d batches written, a file with an invalid row writes nothing", len(f.schedules.batches))
+	}
+
+	// without the bad row the whole file is written as one batch
+	result, err = svc.ImportSchedules(rows[:2], true)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if !result.Committed || len(f.schedules.batches) != 1 || len(f.schedules.batches[0]) != 2 {
+		t.Fatalf("committed %v with batches %v, want one batch of 2", result.Committed, f.schedules.batches)
+	}
+}
+
+func TestImportSchedulesDryRunWritesNothing(t *testing.T) {
+	f := newTimetableFixture()
+	date := time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02")
+
+	result, err := f.newScheduleService().ImportSchedules([]dto.ScheduleImportRow{
+		{Row: 2, Class: "Morning Yoga", Instructor: "Dewi", Date: date, Time: "07:00", Capacity: 12},
+	}, false)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if result.ValidRows != 1 || result.Committed || len(f.schedules.batches) != 0 {
+		t.Fatalf("valid %d committed %v batches %d, want a preview only", result.ValidRows, result.Committed, len(f.schedules.batches))
+	}
+}
+
+func TestImportSchedulesRejectsBadRows(t *testing.T) {
+	date := time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02")
+	valid := dto.ScheduleImportRow{Row: 2, Class: "Morning Yoga", Instructor: "Dewi", Date: date, Time: "07:00", Capacity: 12}
+
+	tests := []struct {
+		name    string
+		setup   func(f *timetableFixture)
+		rows    func(row dto.ScheduleImportRow) []dto.ScheduleImportRow
+		wantErr string
+	}{
+		{
+			name: "unknown instructor",
+			rows: func(row dto.ScheduleImportRow) []dto.ScheduleImportRow {
+				row.Instructor = "Budi"
+				return []dto.ScheduleImportRow{row}
+			},
+			wantErr: `instructor "Budi" not found`,
+		},
+		{
+			name: "unknown class",
+			rows: func(row dto.ScheduleImportRow) []dto.ScheduleImportRow {
+				row.Class = "Boxing"
+				return []dto.ScheduleImportRow{row}
+			},
+			wantErr: `class "Boxing" not found`,
+		},
+		{
+			name: "malformed time",
+			rows: func(row dto.ScheduleImportRow) []dto.ScheduleImportRow {
+				row.Time = "7am"
+				return []dto.ScheduleImportRow{row}
+			},
+			wantErr: "format must be HH:MM",
+		},
+		{
+			name: "date in the past",
+			rows: func(row dto.ScheduleImportRow) []dto.ScheduleImportRow {
+				row.Date = time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+				return []dto.ScheduleImportRow{row}
+			},
+			wantErr: "in the past",
+		},
+		{
+			name: "no capacity",
+			rows: func(row dto.ScheduleImportRow) []dto.ScheduleImportRow {
+				row.Capacity = 0
+				return []dto.ScheduleImportRow{row}
+			},
+			wantErr: "capacity must be greater than 0",
+		},
+		{
+			name: "row the parser could not read",
+			rows: func(row dto.ScheduleImportRow) []dto.ScheduleImportRow {
+				return []dto.ScheduleImportRow{{Row: 2, Error: "missing DTSTART"}}
+			},
+			wantErr: "missing DTSTART",
+		},
+		{
+			name: "same slot twice in the file",
+			rows: func(row dto.ScheduleImportRow) []dto.ScheduleImportRow {
+				again := row
+				again.Row = 3
+				again.Time = "07:30"
+				return []dto.ScheduleImportRow{row, again}
+			},
+			wantErr: "within import file",
+		},
+		{
+			name: "slot taken by an existing schedule",
+			setup: func(f *timetableFixture) {
+				day, _ := time.Parse("2006-01-02", date)
+				f.schedules.schedules = append(f.schedules.schedules, models.ClassSchedule{
+					ID:             uuid.New(),
+					InstructorID:   f.instructor.ID,
+					InstructorName: f.instructor.User.Fullname,
+					Date:           day,
+					StartHour:      7,
+					StartMinute:    30,
+				})
+			},
+			rows: func(row dto.ScheduleImportRow) []dto.ScheduleImportRow {
+				return []dto.ScheduleImportRow{row}
+			},
+			wantErr: "already booked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTimetableFixture()
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			written := len(f.schedules.batches)
+
+			result, err := f.newScheduleService().ImportSchedules(tt.rows(valid), true)
+			if err != nil {
+				t.Fatalf("import: %v", err)
+			}
+			last := result.Rows[len(result.Rows)-1]
+			if last.IsValid || !strings.Contains(last.Error, tt.wantErr) {
+				t.Fatalf("row %d valid %v error %q, want %q", last.Row, last.IsValid, last.Error, tt.wantErr)
+			}
+			if result.Committed || len(f.schedules.batches) != written {
+				t.Fatalf("an import with a bad row was written")
+			}
+		})
+	}
+}
+
+// taught adds a schedule of the fixture's instructor in the given status that starts after the given time from now
+func (f *timetableFixture) taught(status string, startsIn time.Duration) *models.ClassSchedule {
+	schedule := classAt(startsIn, "in_person", 10, 0)
+	schedule.ClassID = f.class.ID
+	schedule.InstructorID = f.instructor.ID
+	schedule.InstructorName = f.instructor.User.Fullname
+	schedule.Status = status
+	schedule.IsOpened = status == "open" || status == "in_progress"
+	f.schedules.schedules = append(f.schedules.schedules, schedule)
+	return f.schedules.find(schedule.ID)
+}
+
+func TestScheduleStatusChanges(t *testing.T) {
+	actions := map[string]func(svc ClassScheduleService, userID, id string) error{
+		"start":    func(svc ClassScheduleService, userID, id string) error { return svc.StartClassSchedule(userID, id) },
+		"complete": func(svc ClassScheduleService, userID, id string) error { return svc.CompleteClassSchedule(userID, id) },
+		"cancel": func(svc ClassScheduleService, userID, id string) error {
+			return svc.CancelClassSchedule(id, dto.CancelClassScheduleRequest{Reason: "studio closed"})
+		},
+	}
+	// the status each action leads to from every status it is allowed in, any other status is rejected
+	allowed := map[string]map[string]string{
+		"start":    {"open": "in_progress"},
+		"complete": {"open": "completed", "in_progress": "completed"},
+		"cancel":   {"scheduled": "canceled", "open": "canceled"},
+	}
+
+	for action, run := range actions {
+		for _, from := range []string{"scheduled", "open", "in_progress", "completed", "canceled"} {
+			t.Run(action+" "+from, func(t *testing.T) {
+				f := newTimetableFixture()
+				f.instructor.UserID = uuid.New()
+				schedule := f.taught(from, -10*time.Minute)
+
+				err := run(f.newScheduleService(), f.instructor.UserID.String(), schedule.ID.String())
+				want, ok := allowed[action][from]
+				if !ok {
+					if err == nil || schedule.Status != from {
+						t.Fatalf("%s moved a %s schedule to %s", action, from, schedule.Status)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("%s: %v", action, err)
+				}
+				if schedule.Status != want {
+					t.Fatalf("status = %s, want %s", schedule.Status, want)
+				}
+			})
+		}
+	}
+}
+
+func TestOnlyTheInstructorStartsAndCompletesTheirSchedule(t *testing.T) {
+	f := newTimetableFixture()
+	f.instructor.UserID = uuid.New()
+	other := &models.Instructor{ID: uuid.New(), UserID: uuid.New(), User: models.User{Fullname: "Budi"}}
+	f.instructors.instructors[other.ID.String()] = other
+	schedule := f.taught("open", -10*time.Minute)
+	svc := f.newScheduleService()
+
+	if err := svc.StartClassSchedule(other.UserID.String(), schedule.ID.String()); err == nil || !strings.Contains(err.Error(), "no schedule found") {
+		t.Fatalf("start by another instructor: err = %v", err)
+	}
+	if err := svc.CompleteClassSchedule(other.UserID.String(), schedule.ID.String()); err == nil || !strings.Contains(err.Error(), "no schedule found") {
+		t.Fatalf("complete by another instructor: err = %v", err)
+	}
+	if err := svc.StartClassSchedule(uuid.NewString(), schedule.ID.String()); err == nil || !strings.Contains(err.Error(), "instructor not found") {
+		t.Fatalf("start by a user who is no instructor: err = %v", err)
+	}
+	if schedule.Status != "open" {
+		t.Fatalf("status = %s, want the schedule left open", schedule.Status)
+	}
+
+	if err := svc.StartClassSchedule(f.instructor.UserID.String(), schedule.ID.String()); err != nil {
+		t.Fatalf("start by its instructor: %v", err)
+	}
+}
+
+func TestAdvanceScheduleLifecycle(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		startsIn time.Duration
+		want     string
+	}{
+		{name: "open class that started", status: "open", startsIn: -10 * time.Minute, want: "in_progress"},
+		{name: "open class still to come", status: "open", startsIn: time.Hour, want: "open"},
+		{name: "running class within the grace period", status: "in_progress", startsIn: -80 * time.Minute, want: "in_progress"},
+		{name: "running class past the grace period", status: "in_progress", startsIn: -100 * time.Minute, want: "completed"},
+		{name: "open class past the grace period", status: "open", startsIn: -100 * time.Minute, want: "completed"},
+		{name: "unopened class that started", status: "scheduled", startsIn: -10 * time.Minute, want: "scheduled"},
+		{name: "unopened class past the grace period", status: "scheduled", startsIn: -100 * time.Minute, want: "canceled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTimetableFixture()
+			schedule := f.taught(tt.status, tt.startsIn)
+
+			if err := f.newScheduleService().AdvanceScheduleLifecycle(); err != nil {
+				t.Fatalf("advance: %v", err)
+			}
+			if schedule.Status != tt.want {
+				t.Fatalf("status = %s, want %s", schedule.Status, tt.want)
+			}
+			if notified := f.db.notified("Class Not Held"); notified != (tt.want == "canceled") {
+				t.Fatalf("admins notified %v about a %s class", notified, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttendanceIsLockedOnceTheScheduleIsFinal(t *testing.T) {
+	for status, wantErr := range map[string]string{"completed": "attendance is locked", "canceled": "has been canceled"} {
+		t.Run(status, func(t *testing.T) {
+			f := newTimetableFixture()
+			schedule := f.taught(status, -2*time.Hour)
+			schedule.IsOpened = true
+			code := "1234"
+			schedule.VerificationCode = &code
+			user := f.db.addUser(0)
+			booking := models.Booking{ID: uuid.New(), UserID: user.ID, ClassScheduleID: schedule.ID, Status: "booked", Mode: "in_person"}
+			f.db.bookings = append(f.db.bookings, booking)
+			svc := newTestBookingService(f.db, f.schedules)
+
+			if err := svc.CheckedInClassSchedule(user.ID.String(), booking.ID.String()); err == nil || !strings.Contains(err.Error(), wantErr) {
+				t.Fatalf("check in: err = %v, want %q", err, wantErr)
+			}
+			if err := svc.CheckoutClassSchedule(user.ID.String(), booking.ID.String(), dto.ValidateCheckoutRequest{VerificationCode: code}); err == nil || !strings.Contains(err.Error(), wantErr) {
+				t.Fatalf("check out: err = %v, want %q", err, wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	customErr "server/pkg/errors"
+	"server/pkg/gateway"
+	"server/pkg/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SubscriptionService interface {
+	// customer
+	GetMySubscriptions(userID string) ([]dto.SubscriptionResponse, error)
+	CancelMySubscription(userID, id string) (*dto.SubscriptionResponse, error)
+
+	// billing events
+	Activate(payment *models.Payment, userPackage *models.UserPackage, providerSubscriptionID string) error
+	HandleRenewalPaid(invoice gateway.Invoice) error
+	HandleRenewalFailed(invoice gateway.Invoice) error
+	HandleSubscriptionEnded(providerSubscriptionID string) error
+
+	// cron
+	ExpireEndedSubscriptions() error
+}
+
+type subscriptionService struct {
+	subscription repositories.SubscriptionRepository
+	payment      repositories.PaymentRepository
+	userPkg      repositories.UserPackageRepository
+	user         repositories.UserRepository
+	pkg          repositories.PackageRepository
+	tax          TaxService
+	notif        NotificationService
+	gateways     *gateway.Registry
+}
+
+func NewSubscriptionService(
+	subscription repositories.SubscriptionRepository,
+	payment repositories.PaymentRepository,
+	userPkg repositories.UserPackageRepository,
+	user repositories.UserRepository,
+	pkg repositories.PackageRepository,
+	tax TaxService,
+	notif NotificationService,
+	gateways *gateway.Registry,
+) SubscriptionService {
+	return &subscriptionService{
+		subscription: subscription,
+		payment:      payment,
+		userPkg:      userPkg,
+		user:         user,
+		pkg:          pkg,
+		tax:          tax,
+		notif:        notif,
+		gateways:     gateways,
+	}
+}
+
+func (s *subscriptionService) GetMySubscriptions(userID string) ([]dto.SubscriptionResponse, error) {
+	subscriptions, err := s.subscription.GetSubscriptionsByUserID(userID)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch subscriptions", err)
+	}
+
+	result := make([]dto.SubscriptionResponse, 0, len(subscriptions))
+	for _, sub := range subscriptions {
+		result = append(result, toSubscriptionResponse(sub))
+	}
+	return result, nil
+}
+
+// CancelMySubscription stops renewals, the membership stays usable until the current period ends
+func (s *subscriptionService) CancelMySubscription(userID, id string) (*dto.SubscriptionResponse, error) {
+	sub, err := s.subscription.GetSubscriptionByID(id)
+	if err != nil || sub == nil || sub.UserID.String() != userID {
+		return nil, customErr.NewNotFound("subscription not found")
+	}
+	if sub.Status == "canceled" {
+		return nil, customErr.NewBadRequest("subscription is already canceled")
+	}
+	if sub.CancelAtPeriodEnd {
+		return nil, customErr.NewBadRequest("subscription is already set to cancel at the end of the period")
+	}
+
+	billing, err := s.gateways.Subscriptions(sub.Provider)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to cancel subscription with the payment provider", err)
+	}
+	if err := billing.CancelAtPeriodEnd(sub.ProviderSubscriptionID); err != nil {
+		return nil, customErr.NewInternal("failed to cancel subscription with the payment provider", err)
+	}
+
+	sub.CancelAtPeriodEnd = true
+	if err := s.subscription.UpdateSubscription(sub); err != nil {
+		return nil, customErr.NewInternal("failed to update subscription", err)
+	}
+
+	payload := dto.NotificationEvent{
+		UserID:  sub.UserID.String(),
+		Type:    "system_message",
+		Title:   "Membership Renewal Canceled",
+		Message: fmt.Sprintf("Your %s membership will not renew. You can keep booking classes until %s.", sub.PackageName, sub.CurrentPeriodEnd.Format("January 2, 2006")),
+	}
+	if err := s.notif.SendToUser(payload); err != nil {
+		log.Printf("failed sending notification to user %s: %v\n", payload.UserID, err)
+	}
+
+	resp := toSubscriptionResponse(*sub)
+	return &resp, nil
+}
+
+// Activate links the first paid period to the provider subscription so later renewals can find it
+func (s *subscriptionService) Activate(payment *models.Payment, userPackage *models.UserPackage, providerSubscriptionID string) error {
+	existing, err := s.subscription.GetSubscriptionByProviderID(providerSubscriptionID)
+	if err != nil {
+		return customErr.NewInternal("failed checking subscription", err)
+	}
+	if existing != nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	sub := models.Subscription{
+		UserID:                 payment.UserID,
+		PackageID:              payment.PackageID,
+		UserPackageID:          userPackage.ID,
+		PackageName:            payment.PackageName,
+		Provider:               payment.Gateway,
+		ProviderSubscriptionID: providerSubscriptionID,
+		Status:                 "active",
+		CurrentPeriodStart:     now,
+		CurrentPeriodEnd:       *userPackage.ExpiredAt,
+	}
+	if err := s.subscription.CreateSubscription(&sub); err != nil {
+		return customErr.NewInternal("failed to create subscription", err)
+	}
+
+	payment.SubscriptionID = &sub.ID
+	if err := s.payment.UpdatePayment(payment); err != nil {
+		return customErr.NewInternal("failed to link payment to subscription", err)
+	}
+
+	userPackage.SubscriptionID = &sub.ID
+	if err := s.userPkg.UpdateUserPackage(userPackage); err != nil {
+		return customErr.NewInternal("failed to link package to subscription", err)
+	}
+	return nil
+}
+
+// HandleRenewalPaid records the renewal payment and extends the membership by one period in one transaction,
+// the first invoice is already covered by the checkout session
+func (s *subscriptionService) HandleRenewalPaid(invoice gateway.Invoice) error {
+	if invoice.Reason != gateway.InvoiceReasonCycle {
+		return nil
+	}
+
+	sub, err := s.subscription.GetSubscriptionByProviderID(invoice.SubscriptionID)
+	if err != nil || sub == nil {
+		return customErr.NewNotFound("subscription not found")
+	}
+
+	existing, err := s.payment.GetPaymentByProviderInvoiceID(invoice.ID)
+	if err != nil {
+		return customErr.NewInternal("failed checking renewal payment", err)
+	}
+	if existing != nil {
+		return nil
+	}
+
+	userPackage, err := s.userPkg.GetUserPackageByID(sub.UserPackageID.String())
+	if err != nil || userPackage == nil {
+		return customErr.NewNotFound("user package not found")
+	}
+
+	user, err := s.user.GetUserByID(sub.UserID.String())
+	if err != nil || user == nil {
+		return customErr.NewNotFound("user not found")
+	}
+
+	// the renewal charges the price set at checkout, the tax inside it follows the rules in effect today
+	taxRequest := TaxRequest{Amount: invoice.AmountPaid, Inclusive: true, At: time.Now()}
+	if pkg, err := s.pkg.GetPackageByID(sub.PackageID.String()); err == nil && pkg != nil {
+		taxRequest = packageTaxRequest(pkg, invoice.AmountPaid, nil)
+		taxRequest.Inclusive = true
+	}
+	taxes, err := s.tax.Calculate(taxRequest)
+	if err != nil {
+		return err
+	}
+	paymentID := uuid.New()
+	invoiceID := invoice.ID
+	var paymentReference *string
+	if invoice.PaymentReference != "" {
+		paymentReference = &invoice.PaymentReference
+	}
+	payment := models.Payment{
+		ID:                paymentID,
+		PackageID:         sub.PackageID,
+		PackageName:       sub.PackageName,
+		UserID:            sub.UserID,
+		Fullname:          user.Fullname,
+		Email:             user.Email,
+		PaymentMethod:     "card",
+		Gateway:           sub.Provider,
+		PaymentLink:       invoice.HostedURL,
+		Status:            "success",
+		Currency:          invoice.AmountPaid.Currency,
+		BasePrice:         taxes.Net.Amount,
+		Tax:               taxes.Tax.Amount,
+		Total:             invoice.AmountPaid.Amount,
+		Taxes:             taxes.Lines,
+		SubscriptionID:    &sub.ID,
+		ProviderInvoiceID: &invoiceID,
+		ProviderPaymentID: paymentReference,
+	}
+
+	periodStart := sub.CurrentPeriodEnd
+	periodEnd := periodStart.AddDate(0, 0, sub.Package.Expired)
+	userPackage.ExpiredAt = &periodEnd
+	userPackage.ClosedAt = nil
+	userPackage.ExpiryRemindedDays = 0
+
+	sub.Status = "active"
+	sub.CurrentPeriodStart = periodStart
+	sub.CurrentPeriodEnd = periodEnd
+	sub.FailedAttempts = 0
+	sub.NextRetryAt = nil
+	if err := s.subscription.RecordRenewal(sub, &payment, userPackage); err != nil {
+		return customErr.NewInternal("failed to record renewal", err)
+	}
+
+	payload := dto.NotificationEvent{
+		UserID:  sub.UserID.String(),
+		Type:    "system_message",
+		Title:   "Membership Renewed",
+		Message: fmt.Sprintf("Your %s membership has been renewed until %s (Invoice: %s).", sub.PackageName, periodEnd.Format("January 2, 2006"), invoiceNumber(&payment)),
+	}
+	if err := s.notif.SendToUser(payload); err != nil {
+		log.Printf("failed sending notification to user %s: %v\n", payload.UserID, err)
+	}
+	return nil
+}
+
+// HandleRenewalFailed runs dunning, the member is asked to update their card on every failed attempt
+// and the subscription is canceled once the provider stops retrying or the retry limit is reached
+func (s *subscriptionService) HandleRenewalFailed(invoice gateway.Invoice) error {
+	sub, err := s.subscription.GetSubscriptionByProviderID(invoice.SubscriptionID)
+	if err != nil || sub == nil {
+		return customErr.NewNotFound("subscription not found")
+	}
+	if sub.Status == "canceled" {
+		return nil
+	}
+
+	sub.FailedAttempts = invoice.AttemptCount
+	sub.NextRetryAt = invoice.NextAttemptAt
+
+	if invoice.NextAttemptAt == nil || invoice.AttemptCount >= utils.GetSubscriptionMaxRetries() {
+		if err := s.cancelNow(sub); err != nil {
+			log.Printf("failed canceling subscription %s with provider: %v\n", sub.ProviderSubscriptionID, err)
+		}
+		now := time.Now().UTC()
+		sub.Status = "canceled"
+		sub.NextRetryAt = nil
+		sub.CanceledAt = &now
+		if err := s.subscription.UpdateSubscription(sub); err != nil {
+			return customErr.NewInternal("failed to update subscription", err)
+		}
+
+		s.notify(sub, "Membership Canceled", fmt.Sprintf(
+			"We couldn't collect the renewal payment for your %s membership after %d attempts, so it has been canceled. Your access ends on %s.",
+			sub.PackageName, invoice.AttemptCount, sub.CurrentPeriodEnd.Format("January 2, 2006"),
+		))
+		return nil
+	}
+
+	sub.Status = "past_due"
+	if err := s.subscription.UpdateSubscription(sub); err != nil {
+		return customErr.NewInternal("failed to update subscription", err)
+	}
+
+	message := fmt.Sprintf("The renewal payment for your %s membership failed. We will retry on %s, please make sure your card details are up to date.",
+		sub.PackageName, invoice.NextAttemptAt.Format("January 2, 2006"))
+	if invoice.HostedURL != "" {
+		message += " You can also pay now: " + invoice.HostedURL
+	}
+	s.notify(sub, "Membership Payment Failed", message)
+	return nil
+}
+
+func (s *subscriptionService) cancelNow(sub *models.Subscription) error {
+	billing, err := s.gateways.Subscriptions(sub.Provider)
+	if err != nil {
+		return err
+	}
+	return billing.CancelNow(sub.ProviderSubscriptionID)
+}
+
+func (s *subscriptionService) HandleSubscriptionEnded(providerSubscriptionID string) error {
+	sub, err := s.subscription.GetSubscriptionByProviderID(providerSubscriptionID)
+	if err != nil || sub == nil {
+		return customErr.NewNotFound("subscription not found")
+	}
+	if sub.Status == "canceled" {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	sub.Status = "canceled"
+	sub.NextRetryAt = nil
+	sub.CanceledAt = &now
+	if err := s.subscription.UpdateSubscription(sub); err != nil {
+		return customErr.NewInternal("failed to update subscription", err)
+	}
+	return nil
+}
+
+// ** buat cron job
+// ExpireEndedSubscriptions closes subscriptions canceled at period end, for providers that don't report it back
+func (s *subscriptionService) ExpireEndedSubscriptions() error {
+	subscriptions, err := s.subscription.GetEndedSubscriptions(time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	for i := range subscriptions {
+		sub := &subscriptions[i]
+		now := time.Now().UTC()
+		sub.Status = "canceled"
+		sub.CanceledAt = &now
+		if err := s.subscription.UpdateSubscription(sub); err != nil {
+			log.Printf("failed closing subscription %s: %v\n", sub.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *subscriptionService) notify(sub *models.Subscription, title, message string) {
+	payload := dto.NotificationEvent{
+		UserID:  sub.UserID.String(),
+		Type:    "system_message",
+		Title:   title,
+		Message: message,
+	}
+	if err := s.notif.SendToUser(payload); err != nil {
+		log.Printf("failed sending notification to user %s: %v\n", payload.UserID, err)
+	}
+}
+
+func toSubscriptionResponse(sub models.Subscription) dto.SubscriptionResponse {
+	resp := dto.SubscriptionResponse{
+		ID:                 sub.ID.String(),
+		PackageID:          sub.PackageID.String(),
+		PackageName:        sub.PackageName,
+		Status:             sub.Status,
+		CurrentPeriodStart: sub.CurrentPeriodStart.Format(time.RFC3339),
+		CurrentPeriodEnd:   sub.CurrentPeriodEnd.Format(time.RFC3339),
+		CancelAtPeriodEnd:  sub.CancelAtPeriodEnd,
+		FailedAttempts:     sub.FailedAttempts,
+	}
+	if sub.NextRetryAt != nil {
+		resp.NextRetryAt = sub.NextRetryAt.Format(time.RFC3339)
+	}
+	if sub.CanceledAt != nil {
+		resp.CanceledAt = sub.CanceledAt.Format(time.RFC3339)
+	}
+	return resp
+}
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"fmt"
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	customErr "server/pkg/errors"
+	"server/pkg/gateway"
+	"server/pkg/money"
+	"server/pkg/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TaxService interface {
+	// checkout
+	Calculate(req TaxRequest) (*TaxResult, error)
+
+	// admin
+	CreateRule(req dto.TaxRuleRequest) (*dto.TaxRuleResponse, error)
+	UpdateRule(id string, req dto.TaxRuleRequest) (*dto.TaxRuleResponse, error)
+	DeleteRule(id string) error
+	GetRules(params dto.TaxRuleQueryParam) ([]dto.TaxRuleResponse, *dto.PaginationResponse, error)
+}
+
+// TaxRequest prices Amount under the tax rules in effect At, an inclusive Amount already contains the tax
+type TaxRequest struct {
+	Amount     money.Money
+	LocationID *uuid.UUID
+	Category   string
+	Inclusive  bool
+	Exempt     bool
+	At         time.Time
+}
+
+// TaxResult splits a price into its net amount and the taxes charged on it, Net plus Tax is always Total
+type TaxResult struct {
+	Net   money.Money
+	Tax   money.Money
+	Total money.Money
+	Lines []models.PaymentTax
+}
+
+type taxService struct {
+	repo     repositories.TaxRuleRepository
+	location repositories.LocationRepository
+}
+
+func NewTaxService(repo repositories.TaxRuleRepository, location repositories.LocationRepository) TaxService {
+	return &taxService{
+		repo:     repo,
+		location: location,
+	}
+}
+
+// packageTaxRequest prices amount the way the package is sold, the payment's location picks the local rules
+func packageTaxRequest(pkg *models.Package, amount money.Money, locationID *uuid.UUID) TaxRequest {
+	return TaxRequest{
+		Amount:     amount,
+		LocationID: locationID,
+		Category:   pkg.TaxCategory,
+		Inclusive:  pkg.TaxInclusive,
+		Exempt:     pkg.TaxExempt,
+		At:         time.Now(),
+	}
+}
+
+// Calculate applies one rule per tax name, the one matching both location and category beats one matching the
+// location, which beats one matching the category, each tax is rounded half-up on its own line.
+// When no rule is configured at all PAYMENT_TAX_RATE is charged as a single tax
+func (s *taxService) Calculate(req TaxRequest) (*TaxResult, error) {
+	if req.At.IsZero() {
+		req.At = time.Now()
+	}
+	result := &TaxResult{Net: req.Amount, Tax: money.Zero(req.Amount.Currency), Total: req.Amount}
+	if req.Exempt || !req.Amount.IsPositive() {
+		return result, nil
+	}
+
+	rules, err := s.repo.GetEffectiveRules(req.At, req.LocationID, req.Category)
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch tax rules", err)
+	}
+	applied := pickTaxRules(rules, req.LocationID, req.Category)
+	if len(applied) == 0 {
+		_, total, err := s.repo.GetRules(dto.TaxRuleQueryParam{Page: 1, Limit: 1}, nil)
+		if err != nil {
+			return nil, customErr.NewInternal("failed to fetch tax rules", err)
+		}
+		if total == 0 && utils.GetTaxRate() > 0 {
+			applied = []models.TaxRule{{Name: "Tax", Rate: utils.GetTaxRate() * 100}}
+		}
+	}
+	if len(applied) == 0 {
+		return result, nil
+	}
+
+	totalRate := 0.0
+	for _, rule := range applied {
+		totalRate += rule.Rate
+	}
+	if req.Inclusive {
+		result.Net = req.Amount.WithoutRate(totalRate / 100)
+	}
+
+	for _, rule := range applied {
+		line := models.PaymentTax{
+			Name:      rule.Name,
+			Rate:      rule.Rate,
+			Inclusive: req.Inclusive,
+			Taxable:   result.Net.Amount,
+			Amount:    result.Net.Percent(rule.Rate).Amount,
+			Currency:  result.Net.Currency,
+		}
+		if rule.ID != uuid.Nil {
+			line.TaxRuleID = &rule.ID
+		}
+		result.Lines = append(result.Lines, line)
+	}
+
+	if req.Inclusive {
+		// the rounding difference goes to the last line so the taxes add up to what is inside the price
+		charged := int64(0)
+		for _, line := range result.Lines[:len(result.Lines)-1] {
+			charged += line.Amount
+		}
+		result.Lines[len(result.Lines)-1].Amount = req.Amount.Amount - result.Net.Amount - charged
+	}
+
+	for _, line := range result.Lines {
+		result.Tax = result.Tax.Add(money.New(line.Amount, line.Currency))
+	}
+	result.Total = result.Net.Add(result.Tax)
+	return result, nil
+}
+
+// pickTaxRules keeps the most specific rule of each tax name, rules come newest first so the latest wins a tie
+func pickTaxRules(rules []models.TaxRule, locationID *uuid.UUID, category string) []models.TaxRule {
+	specificity := func(rule models.TaxRule) int {
+		score := 0
+		if rule.LocationID != nil && locationID != nil && *rule.LocationID == *locationID {
+			score += 2
+		}
+		if rule.TaxCategory != "" && rule.TaxCategory == category {
+			score++
+		}
+		return score
+	}
+
+	best := map[string]models.TaxRule{}
+	for _, rule := range rules {
+		current, ok := best[rule.Name]
+		if !ok || specificity(rule) > specificity(current) {
+			best[rule.Name] = rule
+		}
+	}
+
+	applied := make([]models.TaxRule, 0, len(best))
+	for _, rule := range best {
+		applied = append(applied, rule)
+	}
+	sort.Slice(applied, func(i, j int) bool { return applied[i].Name < applied[j].Name })
+	return applied
+}
+
+// taxLabel names a tax on checkout pages and invoices, e.g. "PPN 11%"
+func taxLabel(line models.PaymentTax) string {
+	return fmt.Sprintf("%s %s%%", line.Name, strconv.FormatFloat(line.Rate, 'f', -1, 64))
+}
+
+func buildLineItems(name string, net money.Money, taxes []models.PaymentTax) []gateway.LineItem {
+	items := []gateway.LineItem{{Name: name, Amount: net.Max(money.Zero(net.Currency)), Quantity: 1}}
+	for _, line := range taxes {
+		if line.Amount > 0 {
+			items = append(items, gateway.LineItem{Name: taxLabel(line), Amount: money.New(line.Amount, line.Currency), Quantity: 1})
+		}
+	}
+	return items
+}
+
+func toPaymentTaxResponses(taxes []models.PaymentTax) []dto.PaymentTaxResponse {
+	result := make([]dto.PaymentTaxResponse, 0, len(taxes))
+	for _, t := range taxes {
+		result = append(result, dto.PaymentTaxResponse{
+			Name:      t.Name,
+			Rate:      t.Rate,
+			Inclusive: t.Inclusive,
+			Taxable:   majorUnits(t.Taxable, t.Currency),
+			Amount:    majorUnits(t.Amount, t.Currency),
+		})
+	}
+	return result
+}
+
+func (s *taxService) CreateRule(req dto.TaxRuleRequest) (*dto.TaxRuleResponse, error) {
+	rule := models.TaxRule{}
+	if err := s.applyRuleRequest(&rule, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateRule(&rule); err != nil {
+		return nil, customErr.NewInternal("failed to create tax rule", err)
+	}
+	return s.getRule(rule.ID.String())
+}
+
+// UpdateRule edits a rule that has not taken effect yet, a rule in effect can only be given an end date so
+// payments already taxed by it keep matching it, a new rate is added as a new rule
+func (s *taxService) UpdateRule(id string, req dto.TaxRuleRequest) (*dto.TaxRuleResponse, error) {
+	rule, err := s.repo.GetRuleByID(id)
+	if err != nil || rule == nil {
+		return nil, customErr.NewNotFound("tax rule not found")
+	}
+
+	now := time.Now()
+	if rule.EffectiveFrom.After(now) {
+		if err := s.applyRuleRequest(rule, req); err != nil {
+			return nil, err
+		}
+	} else {
+		updated := *rule
+		if err := s.applyRuleRequest(&updated, req); err != nil {
+			return nil, err
+		}
+		if updated.Name != rule.Name || updated.Rate != rule.Rate || updated.TaxCategory != rule.TaxCategory ||
+			!sameLocation(updated.LocationID, rule.LocationID) || !updated.EffectiveFrom.Equal(rule.EffectiveFrom) {
+			return nil, customErr.NewBadRequest("this rule is already in effect, set its end date and add a new rule for the change")
+		}
+		if updated.EffectiveUntil != nil && updated.EffectiveUntil.Before(now) {
+			return nil, customErr.NewBadRequest("a rule in effect cannot end in the past")
+		}
+		rule.EffectiveUntil = updated.EffectiveUntil
+	}
+
+	if err := s.repo.UpdateRule(rule); err != nil {
+		return nil, customErr.NewInternal("failed to update tax rule", err)
+	}
+	return s.getRule(rule.ID.String())
+}
+
+// DeleteRule removes a rule that has not taken effect yet, rules that taxed payments are ended instead
+func (s *taxService) DeleteRule(id string) error {
+	rule, err := s.repo.GetRuleByID(id)
+	if err != nil || rule == nil {
+		return customErr.NewNotFound("tax rule not found")
+	}
+	if !rule.EffectiveFrom.After(time.Now()) {
+		return customErr.NewBadRequest("this rule has taken effect, set its end date instead")
+	}
+
+	if err := s.repo.DeleteRule(id); err != nil {
+		return customErr.NewInternal("failed to delete tax rule", err)
+	}
+	return nil
+}
+
+func (s *taxService) GetRules(params dto.TaxRuleQueryParam) ([]dto.TaxRuleResponse, *dto.PaginationResponse, error) {
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.Limit <= 0 {
+		params.Limit = 10
+	}
+
+	var activeOn *time.Time
+	if params.ActiveOn != "" {
+		date, err := utils.ParseDate(params.ActiveOn)
+		if err != nil {
+			return nil, nil, customErr.NewBadRequest(err.Error())
+		}
+		activeOn = &date
+	}
+
+	rules, total, err := s.repo.GetRules(params, activeOn)
+	if err != nil {
+		return nil, nil, customErr.NewInternal("failed to fetch tax rules", err)
+	}
+
+	result := make([]dto.TaxRuleResponse, 0, len(rules))
+	for _, r := range rules {
+		result = append(result, toTaxRuleResponse(r))
+	}
+
+	pagination := utils.Paginate(total, params.Page, params.Limit)
+	return result, pagination, nil
+}
+
+func (s *taxService) getRule(id string) (*dto.TaxRuleResponse, error) {
+	rule, err := s.repo.GetRuleByID(id)
+	if err != nil || rule == nil {
+		return nil, customErr.NewNotFound("tax rule not found")
+	}
+	resp := toTaxRuleResponse(*rule)
+	return &resp, nil
+}
+
+func (s *taxService) applyRuleRequest(rule *models.TaxRule, req dto.TaxRuleRequest) error {
+	from, err := utils.ParseDate(req.EffectiveFrom)
+	if err != nil {
+		return customErr.NewBadRequest(err.Error())
+	}
+	var until *time.Time
+	if req.EffectiveUntil != nil && *req.EffectiveUntil != "" {
+		date, err := utils.ParseDate(*req.EffectiveUntil)
+		if err != nil {
+			return customErr.NewBadRequest(err.Error())
+		}
+		if !date.After(from) {
+			return customErr.NewBadRequest("effectiveUntil must be after effectiveFrom")
+		}
+		until = &date
+	}
+
+	var locationID *uuid.UUID
+	if req.LocationID != nil && *req.LocationID != "" {
+		location, err := s.location.GetLocationByID(*req.LocationID)
+		if err != nil || location == nil {
+			return customErr.NewNotFound("location not found")
+		}
+		locationID = &location.ID
+	}
+
+	rule.Name = strings.TrimSpace(req.Name)
+	rule.Rate = req.Rate
+	rule.LocationID = locationID
+	rule.TaxCategory = strings.TrimSpace(req.TaxCategory)
+	rule.EffectiveFrom = from
+	rule.EffectiveUntil = until
+	return nil
+}
+
+func sameLocation(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func toTaxRuleResponse(r models.TaxRule) dto.TaxRuleResponse {
+	resp := dto.TaxRuleResponse{
+		ID:             r.ID.String(),
+		Name:           r.Name,
+		Rate:           r.Rate,
+		TaxCategory:    r.TaxCategory,
+		EffectiveFrom:  r.EffectiveFrom.Format("2006-01-02"),
+		EffectiveUntil: formatDate(r.EffectiveUntil),
+		CreatedAt:      r.CreatedAt.Format(time.RFC3339),
+	}
+	if r.LocationID != nil {
+		resp.LocationID = r.LocationID.String()
+	}
+	if r.Location != nil {
+		resp.LocationName = r.Location.Name
+	}
+	return resp
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	"server/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// fakeTaxRuleRepo filters rules the way the repository's query does, newest first
+type fakeTaxRuleRepo struct {
+	repositories.TaxRuleRepository
+	rules []models.TaxRule
+}
+
+func (r *fakeTaxRuleRepo) GetEffectiveRules(at time.Time, locationID *uuid.UUID, category string) ([]models.TaxRule, error) {
+	var rules []models.TaxRule
+	for _, rule := range r.rules {
+		if rule.EffectiveFrom.After(at) || (rule.EffectiveUntil != nil && !rule.EffectiveUntil.After(at)) {
+			continue
+		}
+		if rule.TaxCategory != "" && rule.TaxCategory != category {
+			continue
+		}
+		if rule.LocationID != nil && (locationID == nil || *rule.LocationID != *locationID) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	for i := 1; i < len(rules); i++ {
+		for j := i; j > 0 && rules[j].EffectiveFrom.After(rules[j-1].EffectiveFrom); j-- {
+			rules[j], rules[j-1] = rules[j-1], rules[j]
+		}
+	}
+	return rules, nil
+}
+
+func (r *fakeTaxRuleRepo) GetRules(params dto.TaxRuleQueryParam, activeOn *time.Time) ([]models.TaxRule, int64, error) {
+	return r.rules, int64(len(r.rules)), nil
+}
+
+func taxRule(name string, rate float64, locationID *uuid.UUID, category string, from time.Time, until *time.Time) models.TaxRule {
+	return models.TaxRule{ID: uuid.New(), Name: name, Rate: rate, LocationID: locationID, TaxCategory: category, EffectiveFrom: from, EffectiveUntil: until}
+}
+
+func TestMostSpecificTaxRuleWinsPerName(t *testing.T) {
+	downtown := uuid.New()
+	since := time.Now().AddDate(-1, 0, 0)
+	svc := NewTaxService(&fakeTaxRuleRepo{rules: []models.TaxRule{
+		taxRule("PPN", 11, nil, "", since, nil),
+		taxRule("PPN", 10, nil, "wellness", since, nil),
+		taxRule("PPN", 12, &downtown, "", since, nil),
+		taxRule("PPN", 13, &downtown, "wellness", since, nil),
+		taxRule("Service", 5, nil, "", since, nil),
+	}}, nil)
+
+	tests := []struct {
+		name       string
+		locationID *uuid.UUID
+		category   string
+		wantPPN    float64
+	}{
+		{name: "no location or category", wantPPN: 11},
+		{name: "category only", category: "wellness", wantPPN: 10},
+		{name: "location beats category", locationID: &downtown, wantPPN: 12},
+		{name: "location and category", locationID: &downtown, category: "wellness", wantPPN: 13},
+		{name: "another location", locationID: new(uuid.UUID), category: "fitness", wantPPN: 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.Calculate(TaxRequest{Amount: money.New(100_000, "IDR"), LocationID: tt.locationID, Category: tt.category})
+			if err != nil {
+				t.Fatalf("calculate: %v", err)
+			}
+			// one line per tax name, the service charge applies everywhere
+			if len(result.Lines) != 2 || result.Lines[0].Name != "PPN" || result.Lines[1].Name != "Service" {
+				t.Fatalf("lines = %+v, want PPN and Service", result.Lines)
+			}
+			if result.Lines[0].Rate != tt.wantPPN {
+				t.Fatalf("PPN rate = %v, want %v", result.Lines[0].Rate, tt.wantPPN)
+			}
+			wantTax := money.New(100_000, "IDR").Percent(tt.wantPPN).Amount + 5_000
+			if result.Tax.Amount != wantTax || result.Total.Amount != 100_000+wantTax {
+				t.Fatalf("tax %d total %d, want %d on top of the price", result.Tax.Amount, result.Total.Amount, wantTax)
+			}
+		})
+	}
+}
+
+func TestTaxRulesApplyWithinTheirEffectiveDates(t *testing.T) {
+	now := time.Now()
+	changed := now.AddDate(0, 0, -10)
+	svc := NewTaxService(&fakeTaxRuleRepo{rules: []models.TaxRule{
+		taxRule("PPN", 10, nil, "", now.AddDate(-2, 0, 0), &changed),
+		taxRule("PPN", 11, nil, "", changed, nil),
+		taxRule("PPN", 12, nil, "", now.AddDate(0, 1, 0), nil),
+	}}, nil)
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		wantRate float64
+	}{
+		{name: "before the change", at: now.AddDate(0, 0, -30), wantRate: 10},
+		{name: "on the day of the change", at: changed, wantRate: 11},
+		{name: "today, the announced rate is not in effect yet", at: now, wantRate: 11},
+		{name: "after the announced rate starts", at: now.AddDate(0, 2, 0), wantRate: 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.Calculate(TaxRequest{Amount: money.New(100_000, "IDR"), At: tt.at})
+			if err != nil {
+				t.Fatalf("calculate: %v", err)
+			}
+			if len(result.Lines) != 1 || result.Lines[0].Rate != tt.wantRate {
+				t.Fatalf("lines = %+v, want PPN at %v%%", result.Lines, tt.wantRate)
+			}
+		})
+	}
+}
+
+func TestInclusiveTaxRoundingLandsOnTheLastLine(t *testing.T) {
+	since := time.Now().AddDate(-1, 0, 0)
+	svc := NewTaxService(&fakeTaxRuleRepo{rules: []models.TaxRule{
+		taxRule("Service", 5, nil, "", since, nil),
+		taxRule("PPN", 11, nil, "", since, nil),
+	}}, nil)
+
+	// 10,002 holds a net of 8,622, PPN rounds to 948 and the service charge to 431, one rupiah short
+	result, err := svc.Calculate(TaxRequest{Amount: money.New(10_002, "IDR"), Inclusive: true})
+	if err != nil {
+		t.Fatalf("calculate: %v", err)
+	}
+	if result.Net.Amount != 8_622 || result.Total.Amount != 10_002 {
+		t.Fatalf("net %d total %d, want 8622 inside 10002", result.Net.Amount, result.Total.Amount)
+	}
+	if len(result.Lines) != 2 || result.Lines[0].Amount != 948 || result.Lines[1].Name != "Service" || result.Lines[1].Amount != 432 {
+		t.Fatalf("lines = %+v, want PPN 948 and the rounding difference on Service 432", result.Lines)
+	}
+	for _, line := range result.Lines {
+		if !line.Inclusive || line.Taxable != 8_622 {
+			t.Fatalf("line %s = %+v, want inclusive on the net", line.Name, line)
+		}
+	}
+}
+
+func TestTaxFallsBackToTheConfiguredRateWithoutRules(t *testing.T) {
+	t.Setenv("PAYMENT_TAX_RATE", "0.1")
+	svc := NewTaxService(&fakeTaxRuleRepo{}, nil)
+
+	result, err := svc.Calculate(TaxRequest{Amount: money.New(50_000, "IDR")})
+	if err != nil {
+		t.Fatalf("calculate: %v", err)
+	}
+	if result.Tax.Amount != 5_000 || len(result.Lines) != 1 || result.Lines[0].TaxRuleID != nil {
+		t.Fatalf("tax %d lines %+v, want a single 10%% tax without a rule", result.Tax.Amount, result.Lines)
+	}
+
+	exempt, err := svc.Calculate(TaxRequest{Amount: money.New(50_000, "IDR"), Exempt: true})
+	if err != nil {
+		t.Fatalf("calculate: %v", err)
+	}
+	if exempt.Tax.Amount != 0 || exempt.Total.Amount != 50_000 || len(exempt.Lines) != 0 {
+		t.Fatalf("exempt = %+v, want no tax", exempt)
+	}
+
+	// once any rule exists the fallback is off, even when none matches
+	other := uuid.New()
+	svc = NewTaxService(&fakeTaxRuleRepo{rules: []models.TaxRule{taxRule("PPN", 11, &other, "", time.Now().AddDate(-1, 0, 0), nil)}}, nil)
+	result, err = svc.Calculate(TaxRequest{Amount: money.New(50_000, "IDR")})
+	if err != nil {
+		t.Fatalf("calculate: %v", err)
+	}
+	if result.Tax.Amount != 0 {
+		t.Fatalf("tax = %d, want none when configured rules do not match", result.Tax.Amount)
+	}
+}
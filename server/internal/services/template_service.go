@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"slices"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -25,17 +26,23 @@ type ScheduleTemplateService interface {
 	CreateScheduleTemplate(req dto.CreateScheduleTemplateRequest) (string, error)
 	UpdateScheduleTemplate(id string, req dto.UpdateScheduleTemplateRequest) error
 
+	// timetable planner
+	PlanTimetable(req dto.PlanTimetableRequest) (*dto.PlanTimetableResponse, error)
+	AcceptTimetable(req dto.AcceptTimetableRequest) ([]string, error)
+
 	// conflict check
-	CheckInstructorConflict(ID string, date time.Time, hour, minute int) error
+	CheckInstructorConflict(ID string, date time.Time, hour, minute, duration int) error
 	CheckScheduleConflict(ID string, date time.Time, hour, minute int, schedules []models.ClassSchedule) error
 	CheckTemplateConflict(ID string, date time.Time, hour, minute int, templates []models.ScheduleTemplate) error
+	CheckInstructorAvailability(ID string, date time.Time, hour, minute, duration int, windows []models.InstructorAvailability, leaves []models.InstructorTimeOff) error
 }
 
 type scheduleTemplateService struct {
-	template   repositories.ScheduleTemplateRepository
-	class      repositories.ClassRepository
-	instructor repositories.InstructorRepository
-	schedule   repositories.ClassScheduleRepository
+	template     repositories.ScheduleTemplateRepository
+	class        repositories.ClassRepository
+	instructor   repositories.InstructorRepository
+	schedule     repositories.ClassScheduleRepository
+	availability repositories.AvailabilityRepository
 }
 
 func NewScheduleTemplateService(
@@ -43,8 +50,9 @@ func NewScheduleTemplateService(
 	class repositories.ClassRepository,
 	instructor repositories.InstructorRepository,
 	schedule repositories.ClassScheduleRepository,
+	availability repositories.AvailabilityRepository,
 ) ScheduleTemplateService {
-	return &scheduleTemplateService{template, class, instructor, schedule}
+	return &scheduleTemplateService{template, class, instructor, schedule, availability}
 }
 
 func (s *scheduleTemplateService) GetAllTemplates() ([]dto.ScheduleTemplateResponse, error) {
@@ -63,10 +71,13 @@ func (s *scheduleTemplateService) GetAllTemplates() ([]dto.ScheduleTemplateRespo
 			ClassName:      t.ClassName,
 			InstructorID:   t.InstructorID.String(),
 			InstructorName: t.InstructorName,
+			Room:           t.Room,
 			DayOfWeeks:     days,
 			StartHour:      t.StartHour,
 			StartMinute:    t.StartMinute,
+			DeliveryMode:   t.DeliveryMode,
 			Capacity:       t.Capacity,
+			OnlineCapacity: t.OnlineCapacity,
 			IsActive:       t.IsActive,
 			EndDate:        t.EndDate.Format("2006-01-02"),
 			CreatedAt:      t.CreatedAt.Format("2006-01-02"),
@@ -108,32 +119,24 @@ func (s *scheduleTemplateService) CreateScheduleTemplate(req dto.CreateScheduleT
 		return "", customErr.NewInternal("failed to fetch schedule templates", err)
 	}
 
-	for date := now; !date.After(endDate); date = date.AddDate(0, 0, 1) {
-		weekday := int(date.Weekday())
-		if !containsInt(req.DayOfWeeks, weekday) {
-			continue
-		}
-
-		if err := s.CheckTemplateConflict(req.InstructorID, date, req.StartHour, req.StartMinute, templates); err != nil {
-			return "", customErr.NewConflict(err.Error())
-		}
-	}
-
 	schedules, err := s.schedule.GetClassSchedules()
 	if err != nil {
 		return "", customErr.NewInternal("failed to fetch class schedules", err)
 	}
 
-	for date := now; !date.After(endDate); date = date.AddDate(0, 0, 1) {
-		weekday := int(date.Weekday())
-		if !containsInt(req.DayOfWeeks, weekday) {
-			continue
-		}
+	windows, leaves, err := s.getInstructorAvailability(instructor.ID)
+	if err != nil {
+		return "", err
+	}
 
-		if err := s.CheckScheduleConflict(req.InstructorID, date, req.StartHour, req.StartMinute, schedules); err != nil {
-			return "", customErr.NewConflict(err.Error())
-		}
+	if err := s.checkTemplateSlot(req.InstructorID, req.DayOfWeeks, req.StartHour, req.StartMinute, class.Duration, endDate, templates, schedules, windows, leaves); err != nil {
+		return "", customErr.NewConflict(err.Error())
+	}
 
+	mode := resolveDeliveryMode(req.DeliveryMode, class.DeliveryMode)
+	onlineCapacity, err := resolveOnlineCapacity(mode, req.OnlineCapacity)
+	if err != nil {
+		return "", err
 	}
 
 	template := models.ScheduleTemplate{
@@ -147,7 +150,9 @@ func (s *scheduleTemplateService) CreateScheduleTemplate(req dto.CreateScheduleT
 		DayOfWeeks:     utils.IntSliceToJSON(req.DayOfWeeks),
 		StartHour:      req.StartHour,
 		StartMinute:    req.StartMinute,
+		DeliveryMode:   mode,
 		Capacity:       req.Capacity,
+		OnlineCapacity: onlineCapacity,
 		IsActive:       false,
 		Color:          req.Color,
 		EndDate:        endDate,
@@ -207,6 +212,10 @@ func (s *scheduleTemplateService) UpdateScheduleTemplate(id string, req dto.Upda
 	}
 
 	if needsConflictCheck {
+		class, err := s.class.GetClassByID(template.ClassID.String())
+		if err != nil {
+			return customErr.NewNotFound("class not found")
+		}
 		templates, err := s.template.GetAllTemplates()
 		if err != nil {
 			return customErr.NewInternal("failed to fetch schedule templates", err)
@@ -215,6 +224,10 @@ func (s *scheduleTemplateService) UpdateScheduleTemplate(id string, req dto.Upda
 		if err != nil {
 			return customErr.NewInternal("failed to fetch class schedules", err)
 		}
+		windows, leaves, err := s.getInstructorAvailability(template.InstructorID)
+		if err != nil {
+			return err
+		}
 
 		now := time.Now().UTC()
 		for date := now; !date.After(endDate); date = date.AddDate(0, 0, 1) {
@@ -228,10 +241,21 @@ func (s *scheduleTemplateService) UpdateScheduleTemplate(id string, req dto.Upda
 			if err := s.CheckScheduleConflict(req.InstructorID, date, req.StartHour, req.StartMinute, schedules); err != nil {
 				return customErr.NewConflict(err.Error())
 			}
+			if err := s.CheckInstructorAvailability(req.InstructorID, date, req.StartHour, req.StartMinute, class.Duration, windows, leaves); err != nil {
+				return customErr.NewConflict(err.Error())
+			}
 		}
 	}
 
+	mode := resolveDeliveryMode(req.DeliveryMode, template.DeliveryMode)
+	onlineCapacity, err := resolveOnlineCapacity(mode, req.OnlineCapacity)
+	if err != nil {
+		return err
+	}
+
 	// always update these regardless of conflict
+	template.DeliveryMode = mode
+	template.OnlineCapacity = onlineCapacity
 	template.EndDate = endDate
 	template.Capacity = req.Capacity
 	template.StartHour = req.StartHour
@@ -322,7 +346,9 @@ func (s *scheduleTemplateService) GenerateScheduleByTemplateID(id string) error
 			InstructorID:   template.InstructorID,
 			InstructorName: template.InstructorName,
 			Location:       template.Location,
+			DeliveryMode:   template.DeliveryMode,
 			Capacity:       template.Capacity,
+			OnlineCapacity: template.OnlineCapacity,
 			Color:          template.Color,
 			Date:           date,
 			StartHour:      template.StartHour,
@@ -356,7 +382,36 @@ func (s *scheduleTemplateService) GenerateScheduleByTemplateID(id string) error
 	return nil
 }
 
-func (s *scheduleTemplateService) CheckInstructorConflict(ID string, date time.Time, hour, minute int) error {
+// checkTemplateSlot runs the instructor checks for every template day from today until the end date
+func (s *scheduleTemplateService) checkTemplateSlot(
+	instructorID string,
+	days []int,
+	hour, minute, duration int,
+	endDate time.Time,
+	templates []models.ScheduleTemplate,
+	schedules []models.ClassSchedule,
+	windows []models.InstructorAvailability,
+	leaves []models.InstructorTimeOff,
+) error {
+	now := time.Now().UTC()
+	for date := now; !date.After(endDate); date = date.AddDate(0, 0, 1) {
+		if !containsInt(days, int(date.Weekday())) {
+			continue
+		}
+		if err := s.CheckTemplateConflict(instructorID, date, hour, minute, templates); err != nil {
+			return err
+		}
+		if err := s.CheckScheduleConflict(instructorID, date, hour, minute, schedules); err != nil {
+			return err
+		}
+		if err := s.CheckInstructorAvailability(instructorID, date, hour, minute, duration, windows, leaves); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *scheduleTemplateService) CheckInstructorConflict(ID string, date time.Time, hour, minute, duration int) error {
 	schedules, err := s.schedule.GetClassSchedules()
 	if err != nil {
 		return customErr.NewInternal("failed to fetch class schedules", err)
@@ -377,6 +432,73 @@ func (s *scheduleTemplateService) CheckInstructorConflict(ID string, date time.T
 		return customErr.NewConflict(err.Error())
 	}
 
+	windows, leaves, err := s.getInstructorAvailability(uuid.MustParse(ID))
+	if err != nil {
+		return err
+	}
+
+	err = s.CheckInstructorAvailability(ID, date, hour, minute, duration, windows, leaves)
+	if err != nil {
+		return customErr.NewConflict(err.Error())
+	}
+
+	return nil
+}
+
+func (s *scheduleTemplateService) getInstructorAvailability(instructorID uuid.UUID) ([]models.InstructorAvailability, []models.InstructorTimeOff, error) {
+	windows, err := s.availability.GetAvailabilityByInstructorID(instructorID)
+	if err != nil {
+		return nil, nil, customErr.NewInternal("failed to fetch instructor availability", err)
+	}
+
+	leaves, err := s.availability.GetApprovedTimeOffs(instructorID, time.Now().UTC().AddDate(0, 0, -1))
+	if err != nil {
+		return nil, nil, customErr.NewInternal("failed to fetch instructor time off", err)
+	}
+
+	return windows, leaves, nil
+}
+
+// instructors without any availability window are treated as always available, otherwise the whole class from
+// its start until start plus duration minutes has to fit in one window
+func (s *scheduleTemplateService) CheckInstructorAvailability(ID string, date time.Time, hour, minute, duration int, windows []models.InstructorAvailability, leaves []models.InstructorTimeOff) error {
+	instructorID := uuid.MustParse(ID)
+	day := date.Format("2006-01-02")
+
+	for _, l := range leaves {
+		if l.InstructorID != instructorID || l.Status != "approved" {
+			continue
+		}
+		if day >= l.StartDate.Format("2006-01-02") && day <= l.EndDate.Format("2006-01-02") {
+			return fmt.Errorf("instructor is on approved leave from %s to %s",
+				l.StartDate.Format("2006-01-02"), l.EndDate.Format("2006-01-02"))
+		}
+	}
+
+	var hasWindow bool
+	weekday := int(date.Weekday())
+	if duration <= 0 {
+		duration = 60
+	}
+	start := hour*60 + minute
+	end := start + duration
+
+	for _, w := range windows {
+		if w.InstructorID != instructorID {
+			continue
+		}
+		hasWindow = true
+		if w.DayOfWeek != weekday {
+			continue
+		}
+		if start >= w.StartHour*60+w.StartMinute && end <= w.EndHour*60+w.EndMinute {
+			return nil
+		}
+	}
+
+	if hasWindow {
+		return fmt.Errorf("instructor is not available on %s at %02d:%02d", date.Weekday(), hour, minute)
+	}
 	return nil
 }
 
@@ -458,3 +580,459 @@ func (s *scheduleTemplateService) AutoGenerateSchedules() error {
 
 	return nil
 }
+
+// PlanTimetable drafts a conflict-free weekly template set for a location without saving it.
+// Classes with the fewest matching instructors are placed first, each session takes the
+// least loaded day, the smallest room that fits and the least loaded instructor.
+func (s *scheduleTemplateService) PlanTimetable(req dto.PlanTimetableRequest) (*dto.PlanTimetableResponse, error) {
+	endDate, err := utils.ParseDate(req.EndDate)
+	if err != nil {
+		return nil, customErr.NewBadRequest(err.Error())
+	}
+	if endDate.Before(time.Now().UTC()) {
+		return nil, customErr.NewBadRequest("end date must be in the future")
+	}
+
+	days := req.DayOfWeeks
+	if len(days) == 0 {
+		days = []int{1, 2, 3, 4, 5, 6}
+	}
+	openHour, closeHour, maxPerDay := req.OpenHour, req.CloseHour, req.MaxClassesPerDay
+	if openHour == 0 {
+		openHour = 8
+	}
+	if closeHour == 0 {
+		closeHour = 20
+	}
+	if maxPerDay == 0 {
+		maxPerDay = 3
+	}
+	if closeHour <= openHour {
+		return nil, customErr.NewBadRequest("close hour must be after open hour")
+	}
+
+	rooms := slices.Clone(req.Rooms)
+	slices.SortStableFunc(rooms, func(a, b dto.TimetableRoomRequest) int { return a.Capacity - b.Capacity })
+	seenRooms := map[string]bool{}
+	for _, r := range rooms {
+		if seenRooms[r.Name] {
+			return nil, customErr.NewBadRequest(fmt.Sprintf("duplicate room %q", r.Name))
+		}
+		seenRooms[r.Name] = true
+	}
+
+	instructors, err := s.instructor.GetAllInstructors()
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch instructors", err)
+	}
+	if len(req.InstructorIDs) > 0 {
+		instructors = slices.DeleteFunc(instructors, func(i models.Instructor) bool {
+			return !slices.Contains(req.InstructorIDs, i.ID.String())
+		})
+	}
+
+	templates, err := s.template.GetUnexpiredTemplates(today())
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch schedule templates", err)
+	}
+	schedules, err := s.schedule.GetClassSchedules()
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch class schedules", err)
+	}
+	limits := timetableLimits{rooms: map[string]int{}, maxPerDay: maxPerDay}
+	for _, r := range rooms {
+		limits.rooms[r.Name] = r.Capacity
+	}
+
+	type planClass struct {
+		class       *models.Class
+		req         dto.TimetableClassRequest
+		instructors []models.Instructor
+	}
+
+	var classes []planClass
+	for _, c := range req.Classes {
+		class, err := s.class.GetClassByID(c.ClassID)
+		if err != nil || class == nil {
+			return nil, customErr.NewNotFound(fmt.Sprintf("class %s not found", c.ClassID))
+		}
+		if class.LocationID.String() != req.LocationID {
+			return nil, customErr.NewBadRequest(fmt.Sprintf("class %s does not belong to the selected location", class.Title))
+		}
+
+		var eligible []models.Instructor
+		for _, i := range instructors {
+			if matchesSpecialty(i, class) {
+				eligible = append(eligible, i)
+			}
+		}
+		classes = append(classes, planClass{class: class, req: c, instructors: eligible})
+	}
+
+	slices.SortStableFunc(classes, func(a, b planClass) int {
+		if len(a.instructors) != len(b.instructors) {
+			return len(a.instructors) - len(b.instructors)
+		}
+		return b.req.SessionsPerWeek - a.req.SessionsPerWeek
+	})
+
+	availability := map[uuid.UUID]instructorAvailability{}
+	schedulesByInstructor := map[uuid.UUID][]models.ClassSchedule{}
+	for _, sc := range schedules {
+		schedulesByInstructor[sc.InstructorID] = append(schedulesByInstructor[sc.InstructorID], sc)
+	}
+
+	resp := &dto.PlanTimetableResponse{EndDate: endDate.Format("2006-01-02"), Rooms: req.Rooms, MaxClassesPerDay: maxPerDay}
+	instructorLoad := map[uuid.UUID]int{}
+	dayLoad := map[int]int{}
+	var drafts []models.ScheduleTemplate
+
+	for _, pc := range classes {
+		resp.TotalSessions += pc.req.SessionsPerWeek
+
+		if len(pc.instructors) == 0 {
+			resp.Unplaced = append(resp.Unplaced, dto.TimetableUnplaced{
+				ClassID: pc.class.ID.String(), ClassName: pc.class.Title,
+				Sessions: pc.req.SessionsPerWeek, Reason: "no instructor with a matching specialty",
+			})
+			continue
+		}
+
+		onlineCapacity, err := resolveOnlineCapacity(resolveDeliveryMode("", pc.class.DeliveryMode), pc.req.OnlineCapacity)
+		if err != nil {
+			return nil, customErr.NewBadRequest(fmt.Sprintf("class %s: %s", pc.class.Title, err.Error()))
+		}
+
+		var fitting []dto.TimetableRoomRequest
+		for _, r := range rooms {
+			if r.Capacity >= pc.req.Capacity {
+				fitting = append(fitting, r)
+			}
+		}
+		if len(fitting) == 0 {
+			resp.Unplaced = append(resp.Unplaced, dto.TimetableUnplaced{
+				ClassID: pc.class.ID.String(), ClassName: pc.class.Title,
+				Sessions: pc.req.SessionsPerWeek, Reason: "no room fits the requested capacity",
+			})
+			continue
+		}
+
+		for _, i := range pc.instructors {
+			if _, ok := availability[i.ID]; ok {
+				continue
+			}
+			windows, leaves, err := s.getInstructorAvailability(i.ID)
+			if err != nil {
+				return nil, err
+			}
+			availability[i.ID] = instructorAvailability{windows, leaves}
+		}
+
+		usedDays := map[int]bool{}
+		missing := 0
+
+		for n := 0; n < pc.req.SessionsPerWeek; n++ {
+			dayOrder := slices.Clone(days)
+			slices.SortStableFunc(dayOrder, func(a, b int) int {
+				if usedDays[a] != usedDays[b] {
+					if usedDays[a] {
+						return 1
+					}
+					return -1
+				}
+				return dayLoad[a] - dayLoad[b]
+			})
+			instructorOrder := slices.Clone(pc.instructors)
+			slices.SortStableFunc(instructorOrder, func(a, b models.Instructor) int {
+				return instructorLoad[a.ID] - instructorLoad[b.ID]
+			})
+
+			placed := false
+		search:
+			for _, day := range dayOrder {
+				for hour := openHour; hour < closeHour; hour++ {
+					for _, room := range fitting {
+						for _, i := range instructorOrder {
+							slot := timetableSlot{class: pc.class, instructor: i, room: room.Name, capacity: pc.req.Capacity,
+								days: []int{day}, hour: hour, endDate: endDate}
+							if err := s.validateTimetableSlot(slot, limits, templates, schedulesByInstructor[i.ID], availability[i.ID]); err != nil {
+								continue
+							}
+
+							draft := models.ScheduleTemplate{
+								ID:             uuid.New(),
+								ClassID:        pc.class.ID,
+								ClassName:      pc.class.Title,
+								ClassImage:     pc.class.Image,
+								InstructorID:   i.ID,
+								InstructorName: i.User.Fullname,
+								Location:       pc.class.Location.Name,
+								Room:           room.Name,
+								DayOfWeeks:     utils.IntSliceToJSON([]int{day}),
+								StartHour:      hour,
+								StartMinute:    0,
+								DeliveryMode:   resolveDeliveryMode("", pc.class.DeliveryMode),
+								Capacity:       pc.req.Capacity,
+								OnlineCapacity: onlineCapacity,
+								Color:          pc.req.Color,
+								EndDate:        endDate,
+							}
+							drafts = append(drafts, draft)
+							templates = append(templates, draft)
+							instructorLoad[i.ID]++
+							dayLoad[day]++
+							usedDays[day] = true
+							placed = true
+							break search
+						}
+					}
+				}
+			}
+
+			if !placed {
+				missing++
+			}
+		}
+
+		resp.PlacedSessions += pc.req.SessionsPerWeek - missing
+		if missing > 0 {
+			resp.Unplaced = append(resp.Unplaced, dto.TimetableUnplaced{
+				ClassID: pc.class.ID.String(), ClassName: pc.class.Title,
+				Sessions: missing, Reason: "no conflict-free slot left within the given days, hours and rooms",
+			})
+		}
+	}
+
+	// sessions sharing class, instructor, room and time become one template with several days
+	index := map[string]int{}
+	for _, d := range drafts {
+		day := utils.JSONToIntSlice(d.DayOfWeeks)[0]
+		key := fmt.Sprintf("%s|%s|%s|%02d:%02d", d.ClassID, d.InstructorID, d.Room, d.StartHour, d.StartMinute)
+		if i, ok := index[key]; ok {
+			resp.Slots[i].DayOfWeeks = append(resp.Slots[i].DayOfWeeks, day)
+			slices.Sort(resp.Slots[i].DayOfWeeks)
+			continue
+		}
+		index[key] = len(resp.Slots)
+		resp.Slots = append(resp.Slots, dto.TimetableSlot{
+			ClassID:        d.ClassID.String(),
+			ClassName:      d.ClassName,
+			InstructorID:   d.InstructorID.String(),
+			InstructorName: d.InstructorName,
+			Room:           d.Room,
+			DayOfWeeks:     []int{day},
+			StartHour:      d.StartHour,
+			StartMinute:    d.StartMinute,
+			Capacity:       d.Capacity,
+			OnlineCapacity: d.OnlineCapacity,
+			Color:          d.Color,
+		})
+	}
+
+	return resp, nil
+}
+
+// AcceptTimetable re-validates an (optionally edited) draft against the planner's rules and creates all of its
+// templates at once, the draft is sent back with the rooms and daily limit it was planned with
+func (s *scheduleTemplateService) AcceptTimetable(req dto.AcceptTimetableRequest) ([]string, error) {
+	endDate, err := utils.ParseDate(req.EndDate)
+	if err != nil {
+		return nil, customErr.NewBadRequest(err.Error())
+	}
+	if endDate.Before(time.Now().UTC()) {
+		return nil, customErr.NewBadRequest("end date must be in the future")
+	}
+
+	maxPerDay := req.MaxClassesPerDay
+	if maxPerDay == 0 {
+		maxPerDay = 3
+	}
+	limits := timetableLimits{rooms: map[string]int{}, maxPerDay: maxPerDay}
+	for _, r := range req.Rooms {
+		if _, ok := limits.rooms[r.Name]; ok {
+			return nil, customErr.NewBadRequest(fmt.Sprintf("duplicate room %q", r.Name))
+		}
+		limits.rooms[r.Name] = r.Capacity
+	}
+
+	templates, err := s.template.GetUnexpiredTemplates(today())
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch schedule templates", err)
+	}
+	schedules, err := s.schedule.GetClassSchedules()
+	if err != nil {
+		return nil, customErr.NewInternal("failed to fetch class schedules", err)
+	}
+	availability := map[uuid.UUID]instructorAvailability{}
+
+	var created []models.ScheduleTemplate
+	for n, slot := range req.Slots {
+		class, err := s.class.GetClassByID(slot.ClassID)
+		if err != nil || class == nil {
+			return nil, customErr.NewNotFound(fmt.Sprintf("slot %d: class not found", n+1))
+		}
+		instructor, err := s.instructor.GetInstructorByID(slot.InstructorID)
+		if err != nil || instructor == nil {
+			return nil, customErr.NewNotFound(fmt.Sprintf("slot %d: instructor not found", n+1))
+		}
+
+		mode := resolveDeliveryMode("", class.DeliveryMode)
+		onlineCapacity, err := resolveOnlineCapacity(mode, slot.OnlineCapacity)
+		if err != nil {
+			return nil, customErr.NewBadRequest(fmt.Sprintf("slot %d: %s", n+1, err.Error()))
+		}
+
+		if _, ok := availability[instructor.ID]; !ok {
+			windows, leaves, err := s.getInstructorAvailability(instructor.ID)
+			if err != nil {
+				return nil, err
+			}
+			availability[instructor.ID] = instructorAvailability{windows, leaves}
+		}
+		check := timetableSlot{class: class, instructor: *instructor, room: slot.Room, capacity: slot.Capacity,
+			days: slot.DayOfWeeks, hour: slot.StartHour, minute: slot.StartMinute, endDate: endDate}
+		if err := s.validateTimetableSlot(check, limits, templates, schedules, availability[instructor.ID]); err != nil {
+			return nil, customErr.NewConflict(fmt.Sprintf("slot %d: %v", n+1, err))
+		}
+
+		template := models.ScheduleTemplate{
+			ID:             uuid.New(),
+			ClassID:        class.ID,
+			ClassName:      class.Title,
+			ClassImage:     class.Image,
+			InstructorID:   instructor.ID,
+			InstructorName: instructor.User.Fullname,
+			Location:       class.Location.Name,
+			Room:           slot.Room,
+			DayOfWeeks:     utils.IntSliceToJSON(slot.DayOfWeeks),
+			StartHour:      slot.StartHour,
+			StartMinute:    slot.StartMinute,
+			DeliveryMode:   mode,
+			Capacity:       slot.Capacity,
+			OnlineCapacity: onlineCapacity,
+			IsActive:       false,
+			Color:          slot.Color,
+			EndDate:        endDate,
+		}
+		created = append(created, template)
+		templates = append(templates, template)
+	}
+
+	if err := s.template.CreateTemplates(created); err != nil {
+		return nil, customErr.NewInternal("failed to create templates", err)
+	}
+
+	ids := make([]string, 0, len(created))
+	for _, t := range created {
+		ids = append(ids, t.ID.String())
+	}
+	return ids, nil
+}
+
+type instructorAvailability struct {
+	windows []models.InstructorAvailability
+	leaves  []models.InstructorTimeOff
+}
+
+// timetableLimits are the rules of the location a timetable is planned for, rooms maps each room to its capacity
+type timetableLimits struct {
+	rooms     map[string]int
+	maxPerDay int
+}
+
+type timetableSlot struct {
+	class        *models.Class
+	instructor   models.Instructor
+	room         string
+	capacity     int
+	days         []int
+	hour, minute int
+	endDate      time.Time
+}
+
+// validateTimetableSlot is shared by PlanTimetable and AcceptTimetable so an accepted draft meets the same rules the
+// planner placed it by, templates and schedules are what the slot must not clash with
+func (s *scheduleTemplateService) validateTimetableSlot(
+	slot timetableSlot,
+	limits timetableLimits,
+	templates []models.ScheduleTemplate,
+	schedules []models.ClassSchedule,
+	availability instructorAvailability,
+) error {
+	if !matchesSpecialty(slot.instructor, slot.class) {
+		return fmt.Errorf("instructor %s has no specialty matching %s", slot.instructor.User.Fullname, slot.class.Title)
+	}
+
+	if slot.room != "" {
+		capacity, ok := limits.rooms[slot.room]
+		if !ok {
+			return fmt.Errorf("room %s is not one of the timetable rooms", slot.room)
+		}
+		if capacity < slot.capacity {
+			return fmt.Errorf("room %s holds %d, the class needs %d", slot.room, capacity, slot.capacity)
+		}
+		for _, day := range slot.days {
+			if t := roomConflict(templates, slot.class.Location.Name, slot.room, day, slot.hour, slot.minute); t != nil {
+				return fmt.Errorf("room %s is already used by %s on %s at %02d:%02d",
+					slot.room, t.ClassName, time.Weekday(day), t.StartHour, t.StartMinute)
+			}
+		}
+	}
+
+	for _, day := range slot.days {
+		if countInstructorClasses(templates, slot.instructor.ID, day) >= limits.maxPerDay {
+			return fmt.Errorf("instructor %s already teaches %d classes on %s",
+				slot.instructor.User.Fullname, limits.maxPerDay, time.Weekday(day))
+		}
+	}
+
+	return s.checkTemplateSlot(slot.instructor.ID.String(), slot.days, slot.hour, slot.minute, slot.class.Duration, slot.endDate,
+		templates, schedules, availability.windows, availability.leaves)
+}
+
+// today is the start of the current day, a template ending today still runs
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// specialties are a comma separated list matched against the class title, type, category and subcategory
+func matchesSpecialty(instructor models.Instructor, class *models.Class) bool {
+	fields := []string{class.Title, class.Type.Name, class.Category.Name, class.Subcategory.Name}
+	for _, specialty := range strings.Split(instructor.Specialties, ",") {
+		specialty = strings.ToLower(strings.TrimSpace(specialty))
+		if specialty == "" {
+			continue
+		}
+		for _, field := range fields {
+			field = strings.ToLower(strings.TrimSpace(field))
+			if field != "" && (strings.Contains(field, specialty) || strings.Contains(specialty, field)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func roomConflict(templates []models.ScheduleTemplate, location, room string, day, hour, minute int) *models.ScheduleTemplate {
+	start := hour*60 + minute
+	for i, t := range templates {
+		if t.Room != room || t.Location != location || !containsInt(utils.JSONToIntSlice(t.DayOfWeeks), day) {
+			continue
+		}
+		tplStart := t.StartHour*60 + t.StartMinute
+		if start < tplStart+60 && tplStart < start+60 {
+			return &templates[i]
+		}
+	}
+	return nil
+}
+
+func countInstructorClasses(templates []models.ScheduleTemplate, instructorID uuid.UUID, day int) int {
+	count := 0
+	for _, t := range templates {
+		if t.InstructorID == instructorID && containsInt(utils.JSONToIntSlice(t.DayOfWeeks), day) {
+			count++
+		}
+	}
+	return count
+}
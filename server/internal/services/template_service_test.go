@@ -0,0 +1,338 @@
+package services
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	"server/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+type fakeTemplateRepo struct {
+	repositories.ScheduleTemplateRepository
+	templates []models.ScheduleTemplate
+}
+
+func (r *fakeTemplateRepo) GetUnexpiredTemplates(date time.Time) ([]models.ScheduleTemplate, error) {
+	var templates []models.ScheduleTemplate
+	for _, t := range r.templates {
+		if !t.EndDate.Before(date) {
+			templates = append(templates, t)
+		}
+	}
+	return templates, nil
+}
+
+func (r *fakeTemplateRepo) GetAllTemplates() ([]models.ScheduleTemplate, error) {
+	return r.templates, nil
+}
+
+func (r *fakeTemplateRepo) CreateTemplates(templates []models.ScheduleTemplate) error {
+	r.templates = append(r.templates, templates...)
+	return nil
+}
+
+type fakeClassRepo struct {
+	repositories.ClassRepository
+	classes map[string]*models.Class
+}
+
+func (r *fakeClassRepo) GetClassByID(id string) (*models.Class, error) {
+	return r.classes[id], nil
+}
+
+func (r *fakeClassRepo) GetClassByTitle(title string) (*models.Class, error) {
+	for _, c := range r.classes {
+		if c.Title == title {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeInstructorRepo struct {
+	repositories.InstructorRepository
+	instructors map[string]*models.Instructor
+}
+
+func (r *fakeInstructorRepo) GetInstructorByID(id string) (*models.Instructor, error) {
+	return r.instructors[id], nil
+}
+
+func (r *fakeInstructorRepo) GetInstructorByUserID(userID string) (*models.Instructor, error) {
+	for _, i := range r.instructors {
+		if i.UserID.String() == userID {
+			return i, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeInstructorRepo) GetInstructorByName(fullname string) (*models.Instructor, error) {
+	for _, i := range r.instructors {
+		if i.User.Fullname == fullname {
+			return i, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeScheduleRepo struct {
+	repositories.ClassScheduleRepository
+	schedules []models.ClassSchedule
+	// each batch passed to CreateClassSchedules, the real repository writes a batch in one transaction
+	batches [][]models.ClassSchedule
+}
+
+func (r *fakeScheduleRepo) GetClassSchedules() ([]models.ClassSchedule, error) {
+	return r.schedules, nil
+}
+
+func (r *fakeScheduleRepo) find(id uuid.UUID) *models.ClassSchedule {
+	for i := range r.schedules {
+		if r.schedules[i].ID == id {
+			return &r.schedules[i]
+		}
+	}
+	return nil
+}
+
+func (r *fakeScheduleRepo) GetClassScheduleByID(id string) (*models.ClassSchedule, error) {
+	schedule := r.find(uuid.MustParse(id))
+	if schedule == nil {
+		return nil, nil
+	}
+	c := *schedule
+	return &c, nil
+}
+
+func (r *fakeScheduleRepo) UpdateMeetingLink(scheduleID uuid.UUID, provider, meetingID, link string) error {
+	schedule := r.find(scheduleID)
+	schedule.MeetingProvider, schedule.MeetingID, schedule.ZoomLink = provider, meetingID, &link
+	return nil
+}
+
+func (r *fakeScheduleRepo) UpdateScheduleStatus(scheduleID uuid.UUID, from []string, to string) error {
+	schedule := r.find(scheduleID)
+	if schedule == nil || !slices.Contains(from, schedule.Status) {
+		return repositories.ErrScheduleStatusChanged
+	}
+	schedule.Status = to
+	return nil
+}
+
+func (r *fakeScheduleRepo) CompleteSchedule(schedule *models.ClassSchedule, completedAt time.Time) error {
+	if err := r.UpdateScheduleStatus(schedule.ID, []string{"scheduled", "open", "in_progress"}, "completed"); err != nil {
+		return err
+	}
+	stored := r.find(schedule.ID)
+	stored.IsOpened = false
+	stored.CompletedAt = &completedAt
+	return nil
+}
+
+func (r *fakeScheduleRepo) CancelSchedule(schedule *models.ClassSchedule, reason string, canceledAt time.Time) ([]models.Booking, map[uuid.UUID]string, error) {
+	if err := r.UpdateScheduleStatus(schedule.ID, []string{"scheduled", "open"}, "canceled"); err != nil {
+		return nil, nil, err
+	}
+	stored := r.find(schedule.ID)
+	stored.IsOpened = false
+	stored.CancelReason = reason
+	stored.CanceledAt = &canceledAt
+	return nil, nil, nil
+}
+
+func (r *fakeScheduleRepo) GetSchedulesPendingTransition(until time.Time) ([]models.ClassSchedule, error) {
+	var schedules []models.ClassSchedule
+	for _, schedule := range r.schedules {
+		if slices.Contains([]string{"scheduled", "open", "in_progress"}, schedule.Status) && !schedule.Date.After(until) {
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules, nil
+}
+
+func (r *fakeScheduleRepo) CreateClassSchedules(schedules []models.ClassSchedule) error {
+	r.batches = append(r.batches, schedules)
+	r.schedules = append(r.schedules, schedules...)
+	return nil
+}
+
+type fakeAvailabilityRepo struct {
+	repositories.AvailabilityRepository
+}
+
+func (r *fakeAvailabilityRepo) GetAvailabilityByInstructorID(instructorID uuid.UUID) ([]models.InstructorAvailability, error) {
+	return nil, nil
+}
+
+func (r *fakeAvailabilityRepo) GetApprovedTimeOffs(instructorID uuid.UUID, from time.Time) ([]models.InstructorTimeOff, error) {
+	return nil, nil
+}
+
+type timetableFixture struct {
+	db          *store
+	templates   *fakeTemplateRepo
+	schedules   *fakeScheduleRepo
+	classes     *fakeClassRepo
+	instructors *fakeInstructorRepo
+	svc         ScheduleTemplateService
+	class       *models.Class
+	instructor  *models.Instructor
+	endDate     time.Time
+}
+
+func newTimetableFixture() *timetableFixture {
+	class := &models.Class{ID: uuid.New(), Title: "Morning Yoga", DeliveryMode: "in_person", Duration: 60}
+	class.Location.Name = "Downtown"
+	instructor := &models.Instructor{ID: uuid.New(), Specialties: "yoga, pilates", User: models.User{Fullname: "Dewi"}}
+	templates := &fakeTemplateRepo{}
+	schedules := &fakeScheduleRepo{}
+	classes := &fakeClassRepo{classes: map[string]*models.Class{class.ID.String(): class}}
+	instructors := &fakeInstructorRepo{instructors: map[string]*models.Instructor{instructor.ID.String(): instructor}}
+	return &timetableFixture{
+		db:          newStore(),
+		templates:   templates,
+		schedules:   schedules,
+		classes:     classes,
+		instructors: instructors,
+		svc:         NewScheduleTemplateService(templates, classes, instructors, schedules, &fakeAvailabilityRepo{}),
+		class:       class,
+		instructor:  instructor,
+		endDate:     time.Now().UTC().AddDate(0, 0, 30),
+	}
+}
+
+// existing adds a template of the fixture's instructor on Mondays at hour in room
+func (f *timetableFixture) existing(hour int, room string, endDate time.Time) {
+	f.templates.templates = append(f.templates.templates, models.ScheduleTemplate{
+		ID:           uuid.New(),
+		ClassID:      f.class.ID,
+		ClassName:    f.class.Title,
+		InstructorID: f.instructor.ID,
+		Location:     f.class.Location.Name,
+		Room:         room,
+		DayOfWeeks:   utils.IntSliceToJSON([]int{1}),
+		StartHour:    hour,
+		EndDate:      endDate,
+	})
+}
+
+func (f *timetableFixture) accept(slot dto.TimetableSlot) error {
+	slot.ClassID = f.class.ID.String()
+	slot.InstructorID = f.instructor.ID.String()
+	if slot.DayOfWeeks == nil {
+		slot.DayOfWeeks = []int{1}
+	}
+	_, err := f.svc.AcceptTimetable(dto.AcceptTimetableRequest{
+		EndDate: f.endDate.Format("2006-01-02"),
+		Rooms:   []dto.TimetableRoomRequest{{Name: "Studio A", Capacity: 20}, {Name: "Studio B", Capacity: 8}},
+		Slots:   []dto.TimetableSlot{slot},
+	})
+	return err
+}
+
+func TestAcceptTimetableAppliesThePlannerRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(f *timetableFixture)
+		slot    dto.TimetableSlot
+		wantErr string
+	}{
+		{
+			name: "valid slot",
+			slot: dto.TimetableSlot{Room: "Studio A", StartHour: 9, Capacity: 15},
+		},
+		{
+			name:    "instructor without a matching specialty",
+			setup:   func(f *timetableFixture) { f.instructor.Specialties = "boxing" },
+			slot:    dto.TimetableSlot{Room: "Studio A", StartHour: 9, Capacity: 15},
+			wantErr: "no specialty matching",
+		},
+		{
+			name:    "room smaller than the class",
+			slot:    dto.TimetableSlot{Room: "Studio B", StartHour: 9, Capacity: 15},
+			wantErr: "room Studio B holds 8",
+		},
+		{
+			name:    "room outside the timetable",
+			slot:    dto.TimetableSlot{Room: "Rooftop", StartHour: 9, Capacity: 15},
+			wantErr: "not one of the timetable rooms",
+		},
+		{
+			name: "instructor at the daily class limit",
+			setup: func(f *timetableFixture) {
+				for _, hour := range []int{8, 11, 14} {
+					f.existing(hour, "Studio B", f.endDate)
+				}
+			},
+			slot:    dto.TimetableSlot{Room: "Studio A", StartHour: 17, Capacity: 15},
+			wantErr: "already teaches 3 classes",
+		},
+		{
+			name:    "room taken by a running template",
+			setup:   func(f *timetableFixture) { f.existing(9, "Studio A", f.endDate) },
+			slot:    dto.TimetableSlot{Room: "Studio A", StartHour: 9, Capacity: 15},
+			wantErr: "already used by",
+		},
+		{
+			name:  "room taken by an expired template",
+			setup: func(f *timetableFixture) { f.existing(9, "Studio A", time.Now().UTC().AddDate(0, 0, -7)) },
+			slot:  dto.TimetableSlot{Room: "Studio A", StartHour: 9, Capacity: 15},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTimetableFixture()
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			err := f.accept(tt.slot)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("accept: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInstructorAvailabilityChecksTheClassDuration(t *testing.T) {
+	f := newTimetableFixture()
+	instructorID := f.instructor.ID
+	// a Monday window from 09:00 to 11:00
+	windows := []models.InstructorAvailability{{InstructorID: instructorID, DayOfWeek: 1, StartHour: 9, EndHour: 11}}
+	monday := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		hour, minute int
+		duration     int
+		wantErr      bool
+	}{
+		{name: "90 minutes fit from the start", hour: 9, duration: 90},
+		{name: "90 minutes run past the window", hour: 10, duration: 90, wantErr: true},
+		{name: "30 minutes fit just before the end", hour: 10, minute: 30, duration: 30},
+		{name: "unknown duration counts an hour", hour: 10, minute: 30, wantErr: true},
+		{name: "starts before the window", hour: 8, minute: 45, duration: 30, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := f.svc.CheckInstructorAvailability(instructorID.String(), monday, tt.hour, tt.minute, tt.duration, windows, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,25 +1,43 @@
 package services
 
 import (
+	"fmt"
+	"log"
+	"math"
 	"server/internal/dto"
 	"server/internal/models"
 	"server/internal/repositories"
 	customErr "server/pkg/errors"
 	"server/pkg/utils"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type UserPackageService interface {
 	GetUserPackagesByClassID(userID, classID string) ([]dto.UserPackageResponse, error)
 	GetUserPackages(userID string, params dto.PackageQueryParam) ([]dto.UserPackageResponse, *dto.PaginationResponse, error)
+
+	// admin
+	ExtendUserPackage(adminID, id string, req dto.ExtendUserPackageRequest) (*dto.UserPackageResponse, error)
+
+	// cron
+	CloseExpiredPackages() error
+	SendExpiryReminders() error
 }
 
 type userPackageService struct {
-	repo repositories.UserPackageRepository
+	repo   repositories.UserPackageRepository
+	ledger repositories.CreditLedgerRepository
+	notif  NotificationService
 }
 
-func NewUserPackageService(repo repositories.UserPackageRepository) UserPackageService {
-	return &userPackageService{repo}
+func NewUserPackageService(repo repositories.UserPackageRepository, ledger repositories.CreditLedgerRepository, notif NotificationService) UserPackageService {
+	return &userPackageService{
+		repo:   repo,
+		ledger: ledger,
+		notif:  notif,
+	}
 }
 
 func (s *userPackageService) GetUserPackages(userID string, params dto.PackageQueryParam) ([]dto.UserPackageResponse, *dto.PaginationResponse, error) {
@@ -42,6 +60,8 @@ func (s *userPackageService) GetUserPackages(userID string, params dto.PackageQu
 		results = append(results, dto.UserPackageResponse{
 			ID:              p.ID.String(),
 			PackageName:     p.PackageName,
+			Type:            p.Type,
+			WeeklyLimit:     p.WeeklyLimit,
 			RemainingCredit: p.RemainingCredit,
 			ExpiredAt:       expired,
 			PurchasedAt:     p.PurchasedAt.Format("2006-01-02"),
@@ -74,16 +94,170 @@ func (s *userPackageService) GetUserPackagesByClassID(userID, classID string) ([
 			ID:              up.ID.String(),
 			PackageID:       up.Package.ID.String(),
 			PackageName:     up.Package.Name,
+			Type:            up.Type,
+			WeeklyLimit:     up.WeeklyLimit,
 			RemainingCredit: up.RemainingCredit,
 			ExpiredAt:       expiredAt,
 			ExpiredInDays:   expiredInDays,
 			PurchasedAt:     up.PurchasedAt.Format("2006-01-02"),
+			Shared:          up.UserID.String() != userID,
 		})
 	}
 
 	return result, nil
 }
 
+// ExtendUserPackage grants a grace period on top of the current expiry, credits forfeited when
+// the package expired are given back as an expiry_restore entry
+func (s *userPackageService) ExtendUserPackage(adminID, id string, req dto.ExtendUserPackageRequest) (*dto.UserPackageResponse, error) {
+	userPackage, err := s.repo.GetUserPackageByID(id)
+	if err != nil || userPackage == nil {
+		return nil, customErr.NewNotFound("user package not found")
+	}
+	if userPackage.ExpiredAt == nil {
+		return nil, customErr.NewBadRequest("this package does not expire")
+	}
+	if userPackage.SubscriptionID != nil {
+		return nil, customErr.NewBadRequest("auto-renewing memberships are extended by their subscription")
+	}
+
+	now := time.Now().UTC()
+	base := *userPackage.ExpiredAt
+	if base.Before(now) {
+		base = now
+	}
+	expiredAt := base.AddDate(0, 0, req.Days)
+
+	var restore *models.CreditLedgerEntry
+	if userPackage.ClosedAt != nil && userPackage.Type == "credit_pack" {
+		// only a forfeit no extension has restored yet is given back, a package that expired again
+		// with nothing left has no newer expiry entry and must not get the earlier credits twice
+		forfeited, err := s.ledger.GetLatestEntryByType(userPackage.ID.String(), "expiry", "expiry_restore")
+		if err != nil {
+			return nil, customErr.NewInternal("failed to fetch forfeited credits", err)
+		}
+		if forfeited != nil && forfeited.Type == "expiry" && forfeited.Amount < 0 {
+			admin := uuid.MustParse(adminID)
+			restore = &models.CreditLedgerEntry{
+				Type:      "expiry_restore",
+				Amount:    -forfeited.Amount,
+				Reason:    fmt.Sprintf("Grace extension of %d days: %s", req.Days, req.Reason),
+				CreatedBy: &admin,
+			}
+		}
+	}
+
+	if err := s.repo.ExtendExpiry(userPackage, expiredAt, restore); err != nil {
+		return nil, customErr.NewInternal("failed to extend package", err)
+	}
+
+	message := fmt.Sprintf("Your %s has been extended until %s.", userPackage.PackageName, expiredAt.Format("January 2, 2006"))
+	if restore != nil {
+		message = fmt.Sprintf("Your %s has been extended until %s and %d expired credits were restored.", userPackage.PackageName, expiredAt.Format("January 2, 2006"), restore.Amount)
+	}
+	s.notify(userPackage, "Package Extended", message)
+
+	resp := toUserPackageResponse(*userPackage)
+	return &resp, nil
+}
+
+// CloseExpiredPackages forfeits the unused credit of every package past its expiry date
+func (s *userPackageService) CloseExpiredPackages() error {
+	now := time.Now().UTC()
+	userPackages, err := s.repo.GetUnprocessedExpiredPackages(now)
+	if err != nil {
+		return err
+	}
+
+	for i := range userPackages {
+		up := &userPackages[i]
+		forfeited, err := s.repo.ExpireUserPackage(up, now)
+		if err != nil {
+			log.Printf("failed to close expired package %s: %v\n", up.ID, err)
+			continue
+		}
+
+		message := fmt.Sprintf("Your %s expired on %s.", up.PackageName, up.ExpiredAt.Format("January 2, 2006"))
+		if forfeited > 0 {
+			message = fmt.Sprintf("Your %s expired on %s, %d unused credits have been forfeited.", up.PackageName, up.ExpiredAt.Format("January 2, 2006"), forfeited)
+		}
+		s.notify(up, "Package Expired", message)
+	}
+	return nil
+}
+
+// SendExpiryReminders notifies members once per reminder threshold as their package approaches expiry
+func (s *userPackageService) SendExpiryReminders() error {
+	thresholds := utils.GetExpiryReminderDays()
+	if len(thresholds) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	userPackages, err := s.repo.GetPackagesExpiringBetween(now, now.AddDate(0, 0, thresholds[0]))
+	if err != nil {
+		return err
+	}
+
+	for i := range userPackages {
+		up := &userPackages[i]
+		if up.Type == "credit_pack" && up.RemainingCredit == 0 {
+			continue
+		}
+
+		daysLeft := int(math.Ceil(up.ExpiredAt.Sub(now).Hours() / 24))
+		due := 0
+		for _, t := range thresholds {
+			if daysLeft <= t {
+				due = t
+			}
+		}
+		if due == 0 || (up.ExpiryRemindedDays != 0 && up.ExpiryRemindedDays <= due) {
+			continue
+		}
+
+		message := fmt.Sprintf("Your %s expires in %d day(s) on %s.", up.PackageName, daysLeft, up.ExpiredAt.Format("January 2, 2006"))
+		if up.Type == "credit_pack" {
+			message = fmt.Sprintf("Your %s expires in %d day(s) on %s, book a class to use your %d remaining credits.", up.PackageName, daysLeft, up.ExpiredAt.Format("January 2, 2006"), up.RemainingCredit)
+		}
+		s.notify(up, "Package Expiring Soon", message)
+
+		if err := s.repo.MarkExpiryReminded(up.ID, due); err != nil {
+			log.Printf("failed to mark expiry reminder for package %s: %v\n", up.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *userPackageService) notify(up *models.UserPackage, title, message string) {
+	payload := dto.NotificationEvent{
+		UserID:  up.UserID.String(),
+		Type:    "system_message",
+		Title:   title,
+		Message: message,
+	}
+	if err := s.notif.SendToUser(payload); err != nil {
+		log.Printf("failed sending package notification to user %s: %v\n", payload.UserID, err)
+	}
+}
+
+func toUserPackageResponse(up models.UserPackage) dto.UserPackageResponse {
+	resp := dto.UserPackageResponse{
+		ID:              up.ID.String(),
+		PackageID:       up.PackageID.String(),
+		PackageName:     up.PackageName,
+		Type:            up.Type,
+		WeeklyLimit:     up.WeeklyLimit,
+		RemainingCredit: up.RemainingCredit,
+		PurchasedAt:     up.PurchasedAt.Format("2006-01-02"),
+	}
+	if up.ExpiredAt != nil {
+		resp.ExpiredAt = up.ExpiredAt.Format("2006-01-02")
+		resp.ExpiredInDays = max(0, int(time.Until(*up.ExpiredAt).Hours()/24))
+	}
+	return resp
+}
+
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"server/internal/dto"
+	"server/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestExtendExpiredPackageRestoresForfeitedCredits(t *testing.T) {
+	db := newStore()
+	user := db.addUser(0)
+	expiredAt := time.Now().UTC().AddDate(0, 0, -2)
+	userPackage := &models.UserPackage{ID: uuid.New(), UserID: user.ID, Type: "credit_pack", ExpiredAt: &expiredAt, ClosedAt: &expiredAt}
+	db.userPackages[userPackage.ID] = userPackage
+	for _, entry := range []models.CreditLedgerEntry{
+		{UserPackageID: userPackage.ID, UserID: user.ID, Type: "purchase", Amount: 10},
+		{UserPackageID: userPackage.ID, UserID: user.ID, Type: "booking", Amount: -4},
+		{UserPackageID: userPackage.ID, UserID: user.ID, Type: "expiry", Amount: -6},
+	} {
+		if err := db.applyLedger(&entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := NewUserPackageService(&fakeUserPackageRepo{db: db}, &fakeLedgerRepo{db: db}, &fakeNotificationService{db: db})
+
+	resp, err := svc.ExtendUserPackage(uuid.NewString(), userPackage.ID.String(), dto.ExtendUserPackageRequest{Days: 7, Reason: "member was ill"})
+	if err != nil {
+		t.Fatalf("extend: %v", err)
+	}
+	if resp.RemainingCredit != 6 {
+		t.Fatalf("remaining = %d, want the 6 forfeited credits back", resp.RemainingCredit)
+	}
+	restore := db.ledger[len(db.ledger)-1]
+	if restore.Type != "expiry_restore" || restore.Amount != 6 {
+		t.Fatalf("restore entry = %s %d, want expiry_restore 6", restore.Type, restore.Amount)
+	}
+
+	// the package is open again, a second extension only moves the expiry
+	if _, err := svc.ExtendUserPackage(uuid.NewString(), userPackage.ID.String(), dto.ExtendUserPackageRequest{Days: 7, Reason: "member was ill"}); err != nil {
+		t.Fatalf("second extend: %v", err)
+	}
+	if len(db.ledger) != 4 || db.userPackages[userPackage.ID].RemainingCredit != 6 {
+		t.Fatalf("second extension restored credits again")
+	}
+}
+
+func TestExtendingTwiceRestoresForfeitedCreditsOnce(t *testing.T) {
+	db := newStore()
+	user := db.addUser(0)
+	expiredAt := time.Now().UTC().AddDate(0, 0, -2)
+	userPackage := &models.UserPackage{ID: uuid.New(), UserID: user.ID, Type: "credit_pack", ExpiredAt: &expiredAt, ClosedAt: &expiredAt}
+	db.userPackages[userPackage.ID] = userPackage
+	for _, entry := range []models.CreditLedgerEntry{
+		{UserPackageID: userPackage.ID, UserID: user.ID, Type: "purchase", Amount: 5},
+		{UserPackageID: userPackage.ID, UserID: user.ID, Type: "expiry", Amount: -5},
+	} {
+		if err := db.applyLedger(&entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := NewUserPackageService(&fakeUserPackageRepo{db: db}, &fakeLedgerRepo{db: db}, &fakeNotificationService{db: db})
+	extend := func() {
+		t.Helper()
+		if _, err := svc.ExtendUserPackage(uuid.NewString(), userPackage.ID.String(), dto.ExtendUserPackageRequest{Days: 3, Reason: "grace"}); err != nil {
+			t.Fatalf("extend: %v", err)
+		}
+	}
+
+	extend()
+	if userPackage.RemainingCredit != 5 {
+		t.Fatalf("remaining = %d after the first extension, want 5", userPackage.RemainingCredit)
+	}
+
+	// the restored credits are spent and the package expires again with nothing left to forfeit
+	if err := db.applyLedger(&models.CreditLedgerEntry{UserPackageID: userPackage.ID, UserID: user.ID, Type: "booking", Amount: -5}); err != nil {
+		t.Fatal(err)
+	}
+	closedAt := time.Now().UTC()
+	userPackage.ExpiredAt, userPackage.ClosedAt = &closedAt, &closedAt
+
+	extend()
+	if userPackage.RemainingCredit != 0 {
+		t.Fatalf("remaining = %d after the second extension, the earlier forfeit was restored twice", userPackage.RemainingCredit)
+	}
+	if last := db.ledger[len(db.ledger)-1]; last.Type != "booking" {
+		t.Fatalf("last ledger entry = %s %d, want no second restore", last.Type, last.Amount)
+	}
+}
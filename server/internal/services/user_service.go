@@ -5,6 +5,7 @@ import (
 	"server/internal/dto"
 	"server/internal/repositories"
 	customErr "server/pkg/errors"
+	"server/pkg/money"
 	"server/pkg/utils"
 	"time"
 
@@ -137,6 +138,8 @@ func (s *userService) GetUserDetail(id string) (*dto.UserDetailResponse, error)
 		Bio:       user.Bio,
 		LastLogin: lastLogin,
 		JoinedAt:  user.CreatedAt.Format("2006-01-02"),
+
+		WalletBalance: majorUnits(user.WalletBalance, money.DefaultCurrency()),
 	}
 
 	if user.Birthday != nil {
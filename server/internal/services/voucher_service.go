@@ -2,11 +2,15 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"log"
 	"server/internal/dto"
 	"server/internal/models"
 	"server/internal/repositories"
 	customErr "server/pkg/errors"
+	"server/pkg/money"
 	"server/pkg/utils"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -16,9 +20,20 @@ type VoucherService interface {
 	DeleteVoucher(id string) error
 	CreateVoucher(dto.CreateVoucherRequest) error
 	GetAllVouchers() ([]dto.VoucherResponse, error)
-	DecreaseQuota(userID uuid.UUID, code string) error
+	ReserveVoucher(userID uuid.UUID, code string, paymentID uuid.UUID) error
+	ReleaseVoucher(paymentID uuid.UUID) error
+	ReleaseStaleReservations() (int, error)
 	UpdateVoucher(id string, req dto.UpdateVoucherRequest) error
 	ApplyVoucher(req dto.ApplyVoucherRequest) (*dto.ApplyVoucherResponse, error)
+	QuoteVoucher(userID *string, code string, total money.Money) (*VoucherQuote, error)
+}
+
+// VoucherQuote is what a voucher takes off a total, Final is never below zero
+type VoucherQuote struct {
+	Code     string
+	Voucher  *models.Voucher
+	Discount money.Money
+	Final    money.Money
 }
 
 type voucherService struct {
@@ -51,15 +66,14 @@ func (s *voucherService) CreateVoucher(req dto.CreateVoucherRequest) error {
 	}
 
 	voucher := models.Voucher{
-		Code:         req.Code,
-		Description:  req.Description,
-		DiscountType: req.DiscountType,
-		Discount:     req.Discount,
-		MaxDiscount:  req.MaxDiscount,
-		IsReusable:   req.IsReusable,
-		Quota:        req.Quota,
-		ExpiredAt:    expiredAt,
+		Code:        req.Code,
+		Description: req.Description,
+		Currency:    money.DefaultCurrency(),
+		IsReusable:  req.IsReusable,
+		Quota:       req.Quota,
+		ExpiredAt:   expiredAt,
 	}
+	setVoucherDiscount(&voucher, req.DiscountType, req.Discount, req.MaxDiscount)
 
 	if err := s.repo.CreateNewVoucher(&voucher); err != nil {
 		return customErr.NewInternal("failed to create voucher", err)
@@ -75,14 +89,15 @@ func (s *voucherService) GetAllVouchers() ([]dto.VoucherResponse, error) {
 
 	var result []dto.VoucherResponse
 	for _, v := range vouchers {
+		discount, maxDiscount := voucherDiscountValues(&v)
 		result = append(result, dto.VoucherResponse{
 			ID:           v.ID.String(),
 			Code:         v.Code,
 			Description:  v.Description,
 			DiscountType: v.DiscountType,
-			Discount:     v.Discount,
+			Discount:     discount,
 			IsReusable:   v.IsReusable,
-			MaxDiscount:  v.MaxDiscount,
+			MaxDiscount:  maxDiscount,
 			Quota:        v.Quota,
 			ExpiredAt:    v.ExpiredAt.Format("2006-01-02"),
 			CreatedAt:    v.CreatedAt.Format("2006-01-02"),
@@ -92,7 +107,11 @@ func (s *voucherService) GetAllVouchers() ([]dto.VoucherResponse, error) {
 	return result, nil
 }
 
-func (s *voucherService) DecreaseQuota(userID uuid.UUID, code string) error {
+// voucherHoldDuration matches how long a checkout stays payable before the payment is expired
+const voucherHoldDuration = 24 * time.Hour
+
+// ReserveVoucher holds one use of the voucher for the payment until it is paid or abandoned
+func (s *voucherService) ReserveVoucher(userID uuid.UUID, code string, paymentID uuid.UUID) error {
 	voucher, err := s.repo.GetByCode(code)
 	switch {
 	case errors.Is(err, gorm.ErrRecordNotFound):
@@ -101,21 +120,54 @@ func (s *voucherService) DecreaseQuota(userID uuid.UUID, code string) error {
 		return customErr.NewInternal("failed to get voucher", err)
 	}
 
-	if !voucher.IsReusable {
-		if err := s.repo.InsertUsedVoucher(userID, voucher.ID); err != nil {
-			return customErr.NewInternal("failed to insert used voucher", err)
+	reservation := models.VoucherReservation{
+		VoucherID: voucher.ID,
+		UserID:    userID,
+		PaymentID: paymentID,
+		Status:    "held",
+		ExpiresAt: time.Now().Add(voucherHoldDuration),
+	}
+	if err := s.repo.HoldVoucher(&reservation, !voucher.IsReusable); err != nil {
+		if errors.Is(err, repositories.ErrVoucherUnavailable) {
+			return customErr.NewConflict("voucher is no longer available")
 		}
+		return customErr.NewInternal("failed to reserve voucher", err)
 	}
+	return nil
+}
 
-	if voucher.Quota > 0 {
-		voucher.Quota--
-		if err := s.repo.UpdateVoucher(voucher); err != nil {
-			return customErr.NewInternal("failed to update voucher quota", err)
-		}
+func (s *voucherService) ReleaseVoucher(paymentID uuid.UUID) error {
+	released, err := s.repo.ReleaseReservation(paymentID)
+	if err != nil {
+		return customErr.NewInternal("failed to release voucher", err)
+	}
+	if released {
+		log.Printf("voucher held by payment %s released\n", paymentID)
 	}
 	return nil
 }
 
+// ReleaseStaleReservations frees holds of checkouts that expired or failed without a webhook releasing them
+func (s *voucherService) ReleaseStaleReservations() (int, error) {
+	reservations, err := s.repo.GetStaleReservations(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, r := range reservations {
+		released, err := s.repo.ReleaseReservation(r.PaymentID)
+		if err != nil {
+			log.Printf("failed releasing voucher held by payment %s: %v\n", r.PaymentID, err)
+			continue
+		}
+		if released {
+			count++
+		}
+	}
+	return count, nil
+}
+
 func (s *voucherService) UpdateVoucher(id string, req dto.UpdateVoucherRequest) error {
 	expiredAt, err := utils.ParseDate(req.ExpiredAt)
 	if err != nil {
@@ -131,12 +183,10 @@ func (s *voucherService) UpdateVoucher(id string, req dto.UpdateVoucherRequest)
 	}
 
 	voucher.Quota = req.Quota
-	voucher.Discount = req.Discount
 	voucher.ExpiredAt = expiredAt
 	voucher.IsReusable = req.IsReusable
-	voucher.MaxDiscount = req.MaxDiscount
 	voucher.Description = req.Description
-	voucher.DiscountType = req.DiscountType
+	setVoucherDiscount(voucher, req.DiscountType, req.Discount, req.MaxDiscount)
 
 	if err := s.repo.UpdateVoucher(voucher); err != nil {
 		return customErr.NewInternal("failed to update voucher", err)
@@ -145,7 +195,25 @@ func (s *voucherService) UpdateVoucher(id string, req dto.UpdateVoucherRequest)
 }
 
 func (s *voucherService) ApplyVoucher(req dto.ApplyVoucherRequest) (*dto.ApplyVoucherResponse, error) {
-	voucher, err := s.repo.GetValidVoucherByCode(req.Code)
+	quote, err := s.QuoteVoucher(req.UserID, req.Code, money.FromMajor(req.Total, money.DefaultCurrency()))
+	if err != nil {
+		return nil, err
+	}
+
+	discount, maxDiscount := voucherDiscountValues(quote.Voucher)
+	return &dto.ApplyVoucherResponse{
+		Code:          quote.Code,
+		DiscountType:  quote.Voucher.DiscountType,
+		Discount:      discount,
+		MaxDiscount:   maxDiscount,
+		DiscountValue: quote.Discount.Major(),
+		FinalTotal:    quote.Final.Major(),
+	}, nil
+}
+
+// QuoteVoucher works out the discount in minor units, a percentage is rounded half-up before the cap applies
+func (s *voucherService) QuoteVoucher(userID *string, code string, total money.Money) (*VoucherQuote, error) {
+	voucher, err := s.repo.GetValidVoucherByCode(code)
 	switch {
 	case errors.Is(err, gorm.ErrRecordNotFound):
 		return nil, customErr.NewBadRequest("invalid or expired voucher")
@@ -153,11 +221,8 @@ func (s *voucherService) ApplyVoucher(req dto.ApplyVoucherRequest) (*dto.ApplyVo
 		return nil, customErr.NewInternal("failed to fetch voucher", err)
 	}
 
-	var userUUID uuid.UUID
-	hasUser := req.UserID != nil && *req.UserID != ""
-
-	if hasUser {
-		userUUID, err = uuid.Parse(*req.UserID)
+	if userID != nil && *userID != "" {
+		userUUID, err := uuid.Parse(*userID)
 		if err != nil {
 			return nil, customErr.NewBadRequest("invalid user id")
 		}
@@ -173,24 +238,54 @@ func (s *voucherService) ApplyVoucher(req dto.ApplyVoucherRequest) (*dto.ApplyVo
 		}
 	}
 
-	var discountValue float64
+	if voucher.DiscountType != "percentage" && voucher.Currency != total.Currency {
+		return nil, customErr.NewBadRequest(fmt.Sprintf("voucher can only be used on %s payments", voucher.Currency))
+	}
+
+	var discount money.Money
 	if voucher.DiscountType == "percentage" {
-		discountValue = req.Total * (voucher.Discount / 100)
-		if voucher.MaxDiscount != nil && discountValue > *voucher.MaxDiscount {
-			discountValue = *voucher.MaxDiscount
+		discount = total.Percent(voucher.Discount)
+		if voucher.MaxDiscount != nil && voucher.Currency == total.Currency {
+			discount = discount.Min(money.New(*voucher.MaxDiscount, voucher.Currency))
 		}
 	} else {
-		discountValue = min(voucher.Discount, req.Total)
+		discount = money.New(voucher.Amount, voucher.Currency)
 	}
+	discount = discount.Min(total).Max(money.Zero(total.Currency))
 
-	final := req.Total - discountValue
-
-	return &dto.ApplyVoucherResponse{
-		Code:          voucher.Code,
-		DiscountType:  voucher.DiscountType,
-		Discount:      voucher.Discount,
-		MaxDiscount:   voucher.MaxDiscount,
-		DiscountValue: discountValue,
-		FinalTotal:    final,
+	return &VoucherQuote{
+		Code:     voucher.Code,
+		Voucher:  voucher,
+		Discount: discount,
+		Final:    total.Sub(discount),
 	}, nil
 }
+
+// setVoucherDiscount stores a percentage as is and a fixed discount, like the percentage cap, in minor units
+func setVoucherDiscount(voucher *models.Voucher, discountType string, discount float64, maxDiscount *float64) {
+	voucher.DiscountType = discountType
+	voucher.Discount, voucher.Amount, voucher.MaxDiscount = 0, 0, nil
+	if discountType == "percentage" {
+		voucher.Discount = discount
+	} else {
+		voucher.Amount = money.FromMajor(discount, voucher.Currency).Amount
+	}
+	if maxDiscount != nil {
+		amount := money.FromMajor(*maxDiscount, voucher.Currency).Amount
+		voucher.MaxDiscount = &amount
+	}
+}
+
+// voucherDiscountValues is the discount as the API shows it, a percentage or an amount in major units
+func voucherDiscountValues(voucher *models.Voucher) (float64, *float64) {
+	discount := voucher.Discount
+	if voucher.DiscountType != "percentage" {
+		discount = majorUnits(voucher.Amount, voucher.Currency)
+	}
+	var maxDiscount *float64
+	if voucher.MaxDiscount != nil {
+		amount := majorUnits(*voucher.MaxDiscount, voucher.Currency)
+		maxDiscount = &amount
+	}
+	return discount, maxDiscount
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	customErr "server/pkg/errors"
+	"server/pkg/money"
+	"server/pkg/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WalletService interface {
+	// customer & admin
+	GetWallet(userID string, params dto.WalletQueryParam) (*dto.WalletResponse, *dto.PaginationResponse, error)
+
+	// admin
+	TopUp(adminID, userID string, req dto.WalletTopUpRequest) (*dto.WalletTransactionResponse, error)
+
+	// payment
+	ReleasePayment(paymentID uuid.UUID) error
+	ReleaseStaleHolds() (int, error)
+	CoversPayment(payment *models.Payment) (bool, error)
+}
+
+type walletService struct {
+	wallet repositories.WalletRepository
+	user   repositories.UserRepository
+	notif  NotificationService
+}
+
+func NewWalletService(wallet repositories.WalletRepository, user repositories.UserRepository, notif NotificationService) WalletService {
+	return &walletService{
+		wallet: wallet,
+		user:   user,
+		notif:  notif,
+	}
+}
+
+func (s *walletService) GetWallet(userID string, params dto.WalletQueryParam) (*dto.WalletResponse, *dto.PaginationResponse, error) {
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.Limit <= 0 {
+		params.Limit = 10
+	}
+
+	user, err := s.user.GetUserByID(userID)
+	if err != nil || user == nil {
+		return nil, nil, customErr.NewNotFound("user not found")
+	}
+
+	txns, total, err := s.wallet.GetTransactionsByUserID(userID, params)
+	if err != nil {
+		return nil, nil, customErr.NewInternal("failed to fetch wallet transactions", err)
+	}
+
+	currency := money.DefaultCurrency()
+	result := &dto.WalletResponse{
+		UserID:       user.ID.String(),
+		Balance:      majorUnits(user.WalletBalance, currency),
+		Currency:     currency,
+		Transactions: make([]dto.WalletTransactionResponse, 0, len(txns)),
+	}
+	for _, txn := range txns {
+		result.Transactions = append(result.Transactions, toWalletTransactionResponse(txn))
+	}
+
+	pagination := utils.Paginate(total, params.Page, params.Limit)
+	return result, pagination, nil
+}
+
+// TopUp adds store credit to a member's wallet, e.g. as compensation or a promotion, the reason is shown to the member
+func (s *walletService) TopUp(adminID, userID string, req dto.WalletTopUpRequest) (*dto.WalletTransactionResponse, error) {
+	user, err := s.user.GetUserByID(userID)
+	if err != nil || user == nil {
+		return nil, customErr.NewNotFound("user not found")
+	}
+	if user.Role != "customer" {
+		return nil, customErr.NewBadRequest("only customers have a wallet")
+	}
+
+	amount := money.FromMajor(req.Amount, money.DefaultCurrency())
+	if !amount.IsPositive() {
+		return nil, customErr.NewBadRequest("top up amount is too small")
+	}
+
+	admin := uuid.MustParse(adminID)
+	txn := models.WalletTransaction{
+		UserID:    user.ID,
+		Type:      "top_up",
+		Amount:    amount.Amount,
+		Currency:  amount.Currency,
+		Reason:    req.Reason,
+		CreatedBy: &admin,
+	}
+	if err := s.wallet.ApplyTransaction(&txn); err != nil {
+		return nil, customErr.NewInternal("failed to top up wallet", err)
+	}
+
+	payload := dto.NotificationEvent{
+		UserID:  user.ID.String(),
+		Type:    "system_message",
+		Title:   "Wallet Credit Added",
+		Message: fmt.Sprintf("%s was added to your wallet: %s. Your balance is now %s.", amount, req.Reason, money.New(txn.BalanceAfter, txn.Currency)),
+	}
+	if err := s.notif.SendToUser(payload); err != nil {
+		log.Printf("failed sending wallet top up notification to user %s: %v\n", payload.UserID, err)
+	}
+
+	resp := toWalletTransactionResponse(txn)
+	return &resp, nil
+}
+
+// ReleasePayment returns the wallet credit spent on a payment once it failed, releasing twice is a no-op
+func (s *walletService) ReleasePayment(paymentID uuid.UUID) error {
+	released, err := s.wallet.ReleasePaymentHold(paymentID)
+	if err != nil {
+		return fmt.Errorf("failed to release wallet credit of payment %s: %w", paymentID, err)
+	}
+	if released {
+		log.Printf("wallet credit of payment %s returned\n", paymentID)
+	}
+	return nil
+}
+
+// ReleaseStaleHolds returns the wallet credit of failed payments that was not given back yet, like the checkouts
+// expired in bulk by the cron job
+func (s *walletService) ReleaseStaleHolds() (int, error) {
+	ids, err := s.wallet.GetUnreleasedPaymentHolds()
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, id := range ids {
+		ok, err := s.wallet.ReleasePaymentHold(id)
+		if err != nil {
+			log.Printf("failed releasing wallet credit of payment %s: %v\n", id, err)
+			continue
+		}
+		if ok {
+			released++
+		}
+	}
+	return released, nil
+}
+
+// CoversPayment tells whether the wallet share of a payment can still be taken, either it is still held or the
+// member has the balance to pay it again after it was given back
+func (s *walletService) CoversPayment(payment *models.Payment) (bool, error) {
+	held, err := s.wallet.GetPaymentHold(payment.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch wallet hold of payment %s: %w", payment.ID, err)
+	}
+	if held >= payment.WalletAmount {
+		return true, nil
+	}
+
+	user, err := s.user.GetUserByID(payment.UserID.String())
+	if err != nil || user == nil {
+		return false, fmt.Errorf("user %s not found: %w", payment.UserID, err)
+	}
+	return user.WalletBalance >= payment.WalletAmount-held, nil
+}
+
+func toWalletTransactionResponse(txn models.WalletTransaction) dto.WalletTransactionResponse {
+	resp := dto.WalletTransactionResponse{
+		ID:           txn.ID.String(),
+		Type:         txn.Type,
+		Amount:       majorUnits(txn.Amount, txn.Currency),
+		BalanceAfter: majorUnits(txn.BalanceAfter, txn.Currency),
+		Currency:     txn.Currency,
+		Reason:       txn.Reason,
+		CreatedAt:    txn.CreatedAt.Format(time.RFC3339),
+	}
+	if txn.PaymentID != nil {
+		resp.PaymentID = txn.PaymentID.String()
+	}
+	return resp
+}
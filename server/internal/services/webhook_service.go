@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"server/internal/dto"
+	"server/internal/models"
+	"server/internal/repositories"
+	customErr "server/pkg/errors"
+	"server/pkg/gateway"
+	"server/pkg/utils"
+	"time"
+)
+
+type WebhookService interface {
+	// gateway
+	Receive(gatewayName string, body []byte, header http.Header) error
+
+	// admin
+	GetEvents(params dto.WebhookEventQueryParam) ([]dto.WebhookEventResponse, *dto.PaginationResponse, error)
+	GetEvent(id string) (*dto.WebhookEventResponse, error)
+	Replay(id string) (*dto.WebhookEventResponse, error)
+}
+
+type webhookService struct {
+	events   repositories.WebhookEventRepository
+	payment  PaymentService
+	gateways *gateway.Registry
+}
+
+func NewWebhookService(events repositories.WebhookEventRepository, payment PaymentService, gateways *gateway.Registry) WebhookService {
+	return &webhookService{
+		events:   events,
+		payment:  payment,
+		gateways: gateways,
+	}
+}
+
+// Receive verifies the notification and records it before fulfilling it, a redelivered event that was
+// processed already is acknowledged without running again
+func (s *webhookService) Receive(gatewayName string, body []byte, header http.Header) error {
+	gw, err := s.gateways.Get(gatewayName)
+	if err != nil {
+		return customErr.NewNotFound(err.Error())
+	}
+
+	event, err := gw.ParseWebhook(body, header)
+	if err != nil {
+		log.Printf("webhook from %s rejected: %v\n", gatewayName, err)
+		return customErr.NewBadRequest(fmt.Sprintf("invalid webhook: %v", err))
+	}
+
+	record := models.WebhookEvent{
+		Gateway:   gw.Name(),
+		EventID:   event.ID,
+		Type:      event.Type,
+		Reference: event.Reference,
+		Payload:   string(body),
+		Status:    "received",
+	}
+	created, err := s.events.RecordEvent(&record)
+	if err != nil {
+		return customErr.NewInternal("failed to record webhook event", err)
+	}
+	if !created && (record.Status == "processed" || record.Status == "ignored") {
+		log.Printf("webhook %s/%s (%s) was processed already, acknowledging redelivery\n", record.Gateway, record.EventID, record.Type)
+		return nil
+	}
+	log.Printf("webhook %s/%s (%s) received for %q\n", record.Gateway, record.EventID, record.Type, record.Reference)
+
+	return s.process(&record, event)
+}
+
+// process runs the event and stores the outcome, a failure is returned so the gateway delivers the event again
+func (s *webhookService) process(record *models.WebhookEvent, event *gateway.Event) error {
+	started := time.Now()
+	record.Attempts++
+	err := s.payment.ProcessWebhookEvent(event)
+
+	now := time.Now().UTC()
+	if errors.Is(err, ErrUnhandledEvent) {
+		record.Status = "ignored"
+		record.LastError = err.Error()
+		record.ProcessedAt = &now
+		log.Printf("webhook %s/%s (%s) acknowledged without action: %v\n", record.Gateway, record.EventID, record.Type, err)
+		err = nil
+	} else if err != nil {
+		record.Status = "failed"
+		record.LastError = err.Error()
+		log.Printf("webhook %s/%s (%s) failed on attempt %d: %v\n", record.Gateway, record.EventID, record.Type, record.Attempts, err)
+	} else {
+		record.Status = "processed"
+		record.LastError = ""
+		record.ProcessedAt = &now
+		log.Printf("webhook %s/%s (%s) processed in %s\n", record.Gateway, record.EventID, record.Type, time.Since(started).Round(time.Millisecond))
+	}
+
+	if updateErr := s.events.UpdateEvent(record); updateErr != nil {
+		log.Printf("failed saving outcome of webhook %s/%s: %v\n", record.Gateway, record.EventID, updateErr)
+	}
+	if err != nil {
+		return customErr.NewInternal("failed to process webhook event", err)
+	}
+	return nil
+}
+
+func (s *webhookService) GetEvents(params dto.WebhookEventQueryParam) ([]dto.WebhookEventResponse, *dto.PaginationResponse, error) {
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.Limit <= 0 {
+		params.Limit = 10
+	}
+
+	events, total, err := s.events.GetEvents(params)
+	if err != nil {
+		return nil, nil, customErr.NewInternal("failed to fetch webhook events", err)
+	}
+
+	result := make([]dto.WebhookEventResponse, 0, len(events))
+	for _, e := range events {
+		result = append(result, toWebhookEventResponse(e, false))
+	}
+
+	pagination := utils.Paginate(total, params.Page, params.Limit)
+	return result, pagination, nil
+}
+
+func (s *webhookService) GetEvent(id string) (*dto.WebhookEventResponse, error) {
+	event, err := s.events.GetEventByID(id)
+	if err != nil || event == nil {
+		return nil, customErr.NewNotFound("webhook event not found")
+	}
+
+	resp := toWebhookEventResponse(*event, true)
+	return &resp, nil
+}
+
+// Replay runs a stored event again, its signature was checked when it was received
+func (s *webhookService) Replay(id string) (*dto.WebhookEventResponse, error) {
+	record, err := s.events.GetEventByID(id)
+	if err != nil || record == nil {
+		return nil, customErr.NewNotFound("webhook event not found")
+	}
+	if record.Status == "processed" || record.Status == "ignored" {
+		return nil, customErr.NewBadRequest(fmt.Sprintf("webhook event was %s already", record.Status))
+	}
+
+	gw, err := s.gateways.Get(record.Gateway)
+	if err != nil {
+		return nil, customErr.NewBadRequest(err.Error())
+	}
+	event, err := gw.DecodeEvent([]byte(record.Payload))
+	if err != nil {
+		return nil, customErr.NewBadRequest(fmt.Sprintf("stored payload cannot be read: %v", err))
+	}
+
+	log.Printf("webhook %s/%s (%s) replayed by admin\n", record.Gateway, record.EventID, record.Type)
+	if err := s.process(record, event); err != nil {
+		return nil, customErr.NewBadRequest(fmt.Sprintf("replay failed: %s", record.LastError))
+	}
+
+	resp := toWebhookEventResponse(*record, false)
+	return &resp, nil
+}
+
+func toWebhookEventResponse(e models.WebhookEvent, withPayload bool) dto.WebhookEventResponse {
+	resp := dto.WebhookEventResponse{
+		ID:         e.ID.String(),
+		Gateway:    e.Gateway,
+		EventID:    e.EventID,
+		Type:       e.Type,
+		Reference:  e.Reference,
+		Status:     e.Status,
+		Attempts:   e.Attempts,
+		LastError:  e.LastError,
+		ReceivedAt: e.CreatedAt.Format(time.RFC3339),
+	}
+	if e.ProcessedAt != nil {
+		resp.ProcessedAt = e.ProcessedAt.Format(time.RFC3339)
+	}
+	if withPayload {
+		resp.Payload = e.Payload
+	}
+	return resp
+}
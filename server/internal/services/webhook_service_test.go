@@ -0,0 +1,207 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"server/internal/models"
+	customErr "server/pkg/errors"
+	"server/pkg/gateway"
+
+	"github.com/google/uuid"
+)
+
+func TestWebhookRedeliveryIsProcessedOnce(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	payments := newTestPaymentService(db, gw)
+	svc := NewWebhookService(&fakeWebhookEventRepo{db: db}, payments, gateway.NewRegistry(gw))
+	user := db.addUser(50_000)
+	payment := db.addPayment(t, gw, user, db.addPackage(10), 100_000, 30_000)
+
+	body, err := gw.Settle(*payment.ProviderSessionID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := svc.Receive(gw.Name(), body, http.Header{}); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+
+	if len(db.webhooks) != 1 || db.webhooks[0].Status != "processed" || db.webhooks[0].Attempts != 1 {
+		t.Fatalf("webhooks = %+v, want one processed on the first attempt", db.webhooks)
+	}
+	if len(db.userPackages) != 1 {
+		t.Fatalf("granted %d packages, want 1", len(db.userPackages))
+	}
+	if user.WalletBalance != 20_000 {
+		t.Fatalf("wallet = %d, want the 30000 share taken once", user.WalletBalance)
+	}
+
+	// the payment service itself ignores a success for a settled payment, as a replay or a reconciliation sends it
+	if err := deliver(t, payments, gw, body); err != nil {
+		t.Fatalf("repeated success: %v", err)
+	}
+	if len(db.userPackages) != 1 || user.WalletBalance != 20_000 {
+		t.Fatalf("repeated success granted the package again")
+	}
+}
+
+func TestWebhookFailureThenSuccess(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	payments := newTestPaymentService(db, gw)
+	svc := NewWebhookService(&fakeWebhookEventRepo{db: db}, payments, gateway.NewRegistry(gw))
+	user := db.addUser(50_000)
+	payment := db.addPayment(t, gw, user, db.addPackage(10), 100_000, 30_000)
+
+	failed, err := gw.Settle(*payment.ProviderSessionID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paid, err := gw.Settle(*payment.ProviderSessionID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.Receive(gw.Name(), failed, http.Header{}); err != nil {
+		t.Fatalf("failure: %v", err)
+	}
+	if got := db.payments[payment.ID].Status; got != "failed" {
+		t.Fatalf("status after failure = %s, want failed", got)
+	}
+	if user.WalletBalance != 50_000 {
+		t.Fatalf("wallet after failure = %d, want the hold released", user.WalletBalance)
+	}
+
+	if err := svc.Receive(gw.Name(), paid, http.Header{}); err != nil {
+		t.Fatalf("success: %v", err)
+	}
+	settled := db.payments[payment.ID]
+	if settled.Status != "success" || settled.UserPackageID == nil {
+		t.Fatalf("status after success = %s, want success with the package granted", settled.Status)
+	}
+	if user.WalletBalance != 20_000 {
+		t.Fatalf("wallet after success = %d, want the share taken again", user.WalletBalance)
+	}
+
+	// the failure delivered again after the success does not undo it
+	if err := deliver(t, payments, gw, failed); err != nil {
+		t.Fatalf("stale failure: %v", err)
+	}
+	if got := db.payments[payment.ID].Status; got != "success" || user.WalletBalance != 20_000 {
+		t.Fatalf("stale failure moved the payment to %s with wallet %d", got, user.WalletBalance)
+	}
+	if len(db.webhooks) != 2 {
+		t.Fatalf("recorded %d webhooks, want 2", len(db.webhooks))
+	}
+}
+
+func TestWebhookRedeliveryDoesNotFulfilAgain(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	payments := newTestPaymentService(db, gw)
+	svc := NewWebhookService(&fakeWebhookEventRepo{db: db}, payments, gateway.NewRegistry(gw))
+	payment := db.addPayment(t, gw, db.addUser(0), db.addPackage(10), 100_000, 0)
+	payment.Purpose = "gift"
+	fulfilled := 0
+	payments.OnPaymentSucceeded("gift", func(p *models.Payment) error {
+		fulfilled++
+		return nil
+	})
+
+	body, err := gw.Settle(*payment.ProviderSessionID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := svc.Receive(gw.Name(), body, http.Header{}); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+	if fulfilled != 1 {
+		t.Fatalf("fulfilled %d times, want the redeliveries acknowledged without running it again", fulfilled)
+	}
+
+	// a processed event is not replayed either
+	if _, err := svc.Replay(db.webhooks[0].ID.String()); err == nil || !strings.Contains(err.Error(), "processed already") {
+		t.Fatalf("replay of a processed event = %v, want it rejected", err)
+	}
+	if fulfilled != 1 {
+		t.Fatalf("fulfilled %d times after a rejected replay, want 1", fulfilled)
+	}
+}
+
+func TestWebhookReplayRunsAFailedEventAgain(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	payments := newTestPaymentService(db, gw)
+	svc := NewWebhookService(&fakeWebhookEventRepo{db: db}, payments, gateway.NewRegistry(gw))
+	payment := db.addPayment(t, gw, db.addUser(0), db.addPackage(10), 100_000, 0)
+	payment.Purpose = "gift"
+	fulfilled := 0
+	failing := true
+	payments.OnPaymentSucceeded("gift", func(p *models.Payment) error {
+		if failing {
+			return errors.New("mail server down")
+		}
+		fulfilled++
+		return nil
+	})
+
+	body, err := gw.Settle(*payment.ProviderSessionID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Receive(gw.Name(), body, http.Header{}); err == nil {
+		t.Fatalf("a failed fulfilment was acknowledged")
+	}
+	event := db.webhooks[0]
+	if event.Status != "failed" || !strings.Contains(event.LastError, "mail server down") {
+		t.Fatalf("event = %s %q, want failed with the error kept", event.Status, event.LastError)
+	}
+
+	// replaying while it still fails keeps the event failed for another try
+	if _, err := svc.Replay(event.ID.String()); err == nil || !strings.Contains(err.Error(), "mail server down") {
+		t.Fatalf("replay = %v, want the failure reported", err)
+	}
+
+	failing = false
+	resp, err := svc.Replay(event.ID.String())
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if resp.Status != "processed" || resp.Attempts != 3 || fulfilled != 1 {
+		t.Fatalf("replay = %s after %d attempts with %d fulfilments, want processed on the third attempt once", resp.Status, resp.Attempts, fulfilled)
+	}
+	if db.webhooks[0].Status != "processed" || db.webhooks[0].LastError != "" {
+		t.Fatalf("stored event = %s %q, want processed without an error", db.webhooks[0].Status, db.webhooks[0].LastError)
+	}
+
+	if _, err := svc.Replay(uuid.NewString()); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("replay of an unknown event = %v, want not found", err)
+	}
+}
+
+func TestWebhookFromAnUnknownGatewayIsNotFound(t *testing.T) {
+	db := newStore()
+	gw := gateway.NewFakeGateway("")
+	svc := NewWebhookService(&fakeWebhookEventRepo{db: db}, newTestPaymentService(db, gw), gateway.NewRegistry(gw))
+	payment := db.addPayment(t, gw, db.addUser(0), db.addPackage(10), 100_000, 0)
+	body, err := gw.Settle(*payment.ProviderSessionID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Receive("stripe", body, http.Header{})
+	var appErr *customErr.AppError
+	if !errors.As(err, &appErr) || appErr.Code != http.StatusNotFound {
+		t.Fatalf("err = %v, want not found for a gateway that is not configured", err)
+	}
+	if len(db.webhooks) != 0 || db.payments[payment.ID].Status != "pending" {
+		t.Fatalf("a notification for an unknown gateway was recorded or settled the payment")
+	}
+}
@@ -0,0 +1,249 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"server/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// FakeGateway keeps checkouts in memory so local setups and tests can take any payment method and bill
+// subscriptions, checkouts are settled by posting a FakeWebhook to the notification endpoint
+type FakeGateway struct {
+	secret   string
+	mu       sync.Mutex
+	sessions map[string]*fakeSession
+}
+
+type fakeSession struct {
+	reference        string
+	method           string
+	amount           money.Money
+	refunded         money.Money
+	state            string
+	paymentReference string
+	subscriptionID   string
+}
+
+// FakeWebhook is the notification body the fake gateway accepts, Type is one of the Event* constants
+// and Amount is in minor units of Currency, the invoice fields describe a renewal of SubscriptionID
+type FakeWebhook struct {
+	ID             string     `json:"id"`
+	Type           string     `json:"type"`
+	SessionID      string     `json:"sessionId"`
+	Reference      string     `json:"reference"`
+	Method         string     `json:"method"`
+	Amount         int64      `json:"amount,omitempty"`
+	Currency       string     `json:"currency,omitempty"`
+	SubscriptionID string     `json:"subscriptionId,omitempty"`
+	InvoiceID      string     `json:"invoiceId,omitempty"`
+	AttemptCount   int        `json:"attemptCount,omitempty"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+}
+
+// FakeSignatureHeader carries the shared secret when FAKE_GATEWAY_SECRET is set
+const FakeSignatureHeader = "X-Fake-Signature"
+
+func NewFakeGateway(secret string) *FakeGateway {
+	return &FakeGateway{secret: secret, sessions: map[string]*fakeSession{}}
+}
+
+func (g *FakeGateway) Name() string {
+	return "fake"
+}
+
+func (g *FakeGateway) CreateCheckout(req CheckoutRequest) (*Checkout, error) {
+	if len(req.LineItems) == 0 {
+		return nil, fmt.Errorf("checkout has no line items")
+	}
+	total := money.Zero(req.LineItems[0].Amount.Currency)
+	for _, item := range req.LineItems {
+		total = total.Add(money.New(item.Amount.Amount*item.Quantity, item.Amount.Currency))
+	}
+
+	sessionID := "fake_cs_" + uuid.New().String()
+	g.mu.Lock()
+	g.sessions[sessionID] = &fakeSession{
+		reference: req.Reference,
+		method:    req.Method,
+		amount:    total,
+		refunded:  money.Zero(total.Currency),
+		state:     StatePending,
+	}
+	g.mu.Unlock()
+
+	return &Checkout{
+		SessionID: sessionID,
+		URL:       fmt.Sprintf("%s?session_id=%s&reference=%s", req.SuccessURL, sessionID, url.QueryEscape(req.Reference)),
+	}, nil
+}
+
+// CreateSubscriptionCheckout opens a checkout for the first period, the subscription is reported with its payment
+func (g *FakeGateway) CreateSubscriptionCheckout(req SubscriptionRequest) (*Checkout, error) {
+	checkout, err := g.CreateCheckout(CheckoutRequest{
+		Reference:     req.Reference,
+		CustomerEmail: req.CustomerEmail,
+		Method:        MethodCard,
+		LineItems:     []LineItem{{Name: req.ProductName, Amount: req.Amount, Quantity: 1}},
+		SuccessURL:    req.SuccessURL,
+		CancelURL:     req.CancelURL,
+		Metadata:      req.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	g.sessions[checkout.SessionID].subscriptionID = "fake_sub_" + uuid.New().String()
+	g.mu.Unlock()
+	return checkout, nil
+}
+
+// CancelAtPeriodEnd has nothing to stop, the fake gateway only renews when a renewal is posted to it
+func (g *FakeGateway) CancelAtPeriodEnd(subscriptionID string) error {
+	return nil
+}
+
+func (g *FakeGateway) CancelNow(subscriptionID string) error {
+	return nil
+}
+
+func (g *FakeGateway) ParseWebhook(body []byte, header http.Header) (*Event, error) {
+	if g.secret != "" && header.Get(FakeSignatureHeader) != g.secret {
+		return nil, fmt.Errorf("invalid fake gateway signature")
+	}
+	return g.DecodeEvent(body)
+}
+
+func (g *FakeGateway) DecodeEvent(payload []byte) (*Event, error) {
+	var hook FakeWebhook
+	if err := json.Unmarshal(payload, &hook); err != nil {
+		return nil, fmt.Errorf("invalid fake gateway payload")
+	}
+	if hook.ID == "" {
+		// keeps redelivered notifications without an id recognisable as the same event
+		hook.ID = fmt.Sprintf("fake_evt_%s_%s%s", hook.Type, hook.SessionID, hook.Reference)
+	}
+
+	event := &Event{
+		ID:             hook.ID,
+		Type:           hook.Type,
+		Reference:      hook.Reference,
+		SessionID:      hook.SessionID,
+		SubscriptionID: hook.SubscriptionID,
+		Method:         hook.Method,
+		Amount:         money.New(hook.Amount, hook.Currency),
+		Raw:            hook,
+	}
+	if hook.Type == EventInvoicePaid || hook.Type == EventInvoicePaymentFailed {
+		event.Invoice = &Invoice{
+			ID:             hook.InvoiceID,
+			SubscriptionID: hook.SubscriptionID,
+			Reason:         InvoiceReasonCycle,
+			AmountPaid:     event.Amount,
+			AttemptCount:   hook.AttemptCount,
+			NextAttemptAt:  hook.NextAttemptAt,
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if sess, ok := g.sessions[hook.SessionID]; ok {
+		if event.Reference == "" {
+			event.Reference = sess.reference
+		}
+		if event.Method == "" {
+			event.Method = sess.method
+		}
+		if hook.Currency == "" {
+			event.Amount.Currency = sess.amount.Currency
+		}
+		switch hook.Type {
+		case EventPaymentSucceeded:
+			sess.state = StatePaid
+			sess.paymentReference = "fake_pay_" + hook.SessionID
+		case EventPaymentFailed:
+			sess.state = StateFailed
+		case EventCheckoutExpired:
+			sess.state = StateExpired
+		}
+		event.PaymentReference = sess.paymentReference
+		if event.SubscriptionID == "" {
+			event.SubscriptionID = sess.subscriptionID
+		}
+	}
+	return event, nil
+}
+
+// Settle builds the webhook body that reports the checkout as paid or failed
+func (g *FakeGateway) Settle(sessionID string, paid bool) ([]byte, error) {
+	g.mu.Lock()
+	sess, ok := g.sessions[sessionID]
+	g.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("checkout %s not found", sessionID)
+	}
+
+	hook := FakeWebhook{
+		ID:        "fake_evt_" + uuid.New().String(),
+		Type:      EventPaymentFailed,
+		SessionID: sessionID,
+		Reference: sess.reference,
+		Method:    sess.method,
+	}
+	if paid {
+		hook.Type = EventPaymentSucceeded
+	}
+	return json.Marshal(hook)
+}
+
+func (g *FakeGateway) Refund(req RefundRequest) (*Refund, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, sess := range g.sessions {
+		if sess.paymentReference == "" || sess.paymentReference != req.PaymentReference {
+			continue
+		}
+		if req.Amount.Currency != sess.amount.Currency || sess.refunded.Add(req.Amount).Amount > sess.amount.Amount {
+			return &Refund{ID: "fake_re_" + uuid.New().String(), Status: RefundStatusFailed}, nil
+		}
+		sess.refunded = sess.refunded.Add(req.Amount)
+		break
+	}
+	// payments settled before a restart are no longer known, their refunds are accepted as is
+	return &Refund{ID: "fake_re_" + uuid.New().String(), Status: RefundStatusSucceeded}, nil
+}
+
+func (g *FakeGateway) QueryStatus(sessionID string) (*Status, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sess, ok := g.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("checkout %s not found", sessionID)
+	}
+	return &Status{State: sess.state, PaymentReference: sess.paymentReference, Method: sess.method, Amount: sess.amount}, nil
+}
+
+// SetState changes a checkout without sending a notification, it plays a webhook that never arrived
+func (g *FakeGateway) SetState(sessionID, state string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sess, ok := g.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("checkout %s not found", sessionID)
+	}
+	sess.state = state
+	if state == StatePaid && sess.paymentReference == "" {
+		sess.paymentReference = "fake_pay_" + sessionID
+	}
+	return nil
+}
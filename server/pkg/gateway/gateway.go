@@ -0,0 +1,214 @@
+package gateway
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"server/pkg/money"
+)
+
+// PaymentGateway takes one-off payments through a hosted checkout and reports them back by webhook,
+// ParseWebhook verifies a notification while DecodeEvent reads a payload that was verified before
+type PaymentGateway interface {
+	Name() string
+	CreateCheckout(req CheckoutRequest) (*Checkout, error)
+	ParseWebhook(body []byte, header http.Header) (*Event, error)
+	DecodeEvent(payload []byte) (*Event, error)
+	Refund(req RefundRequest) (*Refund, error)
+	QueryStatus(sessionID string) (*Status, error)
+}
+
+const (
+	MethodCard           = "card"
+	MethodQRIS           = "qris"
+	MethodVirtualAccount = "virtual_account"
+	MethodEWallet        = "ewallet"
+)
+
+// LineItem is charged Quantity times at Amount, the currency of the first item is the checkout's currency
+type LineItem struct {
+	Name     string
+	Amount   money.Money
+	Quantity int64
+}
+
+// CheckoutRequest opens a checkout for the payment identified by Reference
+type CheckoutRequest struct {
+	Reference     string
+	CustomerEmail string
+	Method        string
+	LineItems     []LineItem
+	SuccessURL    string
+	CancelURL     string
+	Metadata      map[string]string
+}
+
+type Checkout struct {
+	SessionID string
+	URL       string
+}
+
+// normalized event types, a processing payment was completed by the customer but the money has not arrived yet,
+// the invoice events report subscription renewals
+const (
+	EventPaymentSucceeded     = "payment.succeeded"
+	EventPaymentProcessing    = "payment.processing"
+	EventPaymentFailed        = "payment.failed"
+	EventCheckoutExpired      = "checkout.expired"
+	EventPaymentRefunded      = "payment.refunded"
+	EventPaymentDisputed      = "payment.disputed"
+	EventInvoicePaid          = "invoice.paid"
+	EventInvoicePaymentFailed = "invoice.payment_failed"
+	EventSubscriptionEnded    = "subscription.ended"
+)
+
+// Event is a verified webhook, events the gateway does not map keep the provider's type and payload in Raw,
+// Amount is the total refunded for EventPaymentRefunded and the disputed amount for EventPaymentDisputed and
+// Invoice is set for the invoice events
+type Event struct {
+	ID               string
+	Type             string
+	Reference        string
+	SessionID        string
+	PaymentReference string
+	SubscriptionID   string
+	Method           string
+	Amount           money.Money
+	Reason           string
+	Invoice          *Invoice
+	Raw              any
+}
+
+// RefundRequest returns Amount of the captured payment identified by PaymentReference
+type RefundRequest struct {
+	PaymentReference string
+	Amount           money.Money
+	Reason           string
+	Metadata         map[string]string
+}
+
+type Refund struct {
+	ID     string
+	Status string
+}
+
+const (
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusPending   = "pending"
+	RefundStatusFailed    = "failed"
+)
+
+const (
+	StatePending = "pending"
+	StatePaid    = "paid"
+	StateFailed  = "failed"
+	StateExpired = "expired"
+)
+
+// Status is the gateway's view of a checkout, Amount is what the customer is charged and zero when the gateway
+// does not report it
+type Status struct {
+	State            string
+	PaymentReference string
+	Method           string
+	Amount           money.Money
+}
+
+// Registry picks the gateway that handles each payment method and the one that bills subscriptions
+type Registry struct {
+	gateways      map[string]PaymentGateway
+	methods       map[string]string
+	subscriptions string
+}
+
+func NewRegistry(gateways ...PaymentGateway) *Registry {
+	r := &Registry{gateways: map[string]PaymentGateway{}, methods: map[string]string{}}
+	for _, g := range gateways {
+		r.gateways[g.Name()] = g
+	}
+	return r
+}
+
+// Route sends payments made with method to the named gateway
+func (r *Registry) Route(method, gateway string) {
+	r.methods[method] = gateway
+}
+
+func (r *Registry) Get(name string) (PaymentGateway, error) {
+	g, ok := r.gateways[name]
+	if !ok {
+		return nil, fmt.Errorf("payment gateway %q is not configured", name)
+	}
+	return g, nil
+}
+
+func (r *Registry) ForMethod(method string) (PaymentGateway, error) {
+	name, ok := r.methods[method]
+	if !ok {
+		return nil, fmt.Errorf("payment method %q is not available", method)
+	}
+	return r.Get(name)
+}
+
+// RouteSubscriptions bills auto-renewing memberships through the named gateway
+func (r *Registry) RouteSubscriptions(gateway string) {
+	r.subscriptions = gateway
+}
+
+// Subscriptions is the named gateway when it can bill subscriptions
+func (r *Registry) Subscriptions(name string) (SubscriptionGateway, error) {
+	g, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	sg, ok := g.(SubscriptionGateway)
+	if !ok {
+		return nil, fmt.Errorf("payment gateway %q does not bill subscriptions", name)
+	}
+	return sg, nil
+}
+
+// ForSubscriptions is the gateway new subscriptions are billed through
+func (r *Registry) ForSubscriptions() (SubscriptionGateway, error) {
+	if r.subscriptions == "" {
+		return nil, fmt.Errorf("no payment gateway bills subscriptions")
+	}
+	return r.Subscriptions(r.subscriptions)
+}
+
+// NewRegistryFromEnv routes methods as listed in PAYMENT_GATEWAYS, e.g. "card=stripe,qris=fake",
+// cards go through Stripe unless configured otherwise, subscriptions go through BILLING_PROVIDER
+func NewRegistryFromEnv() *Registry {
+	r := NewRegistry(NewStripeGateway(), NewFakeGateway(os.Getenv("FAKE_GATEWAY_SECRET")))
+	r.Route(MethodCard, "stripe")
+
+	for _, pair := range strings.Split(os.Getenv("PAYMENT_GATEWAYS"), ",") {
+		method, name, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		if _, err := r.Get(name); err != nil {
+			log.Printf("%v, %s payments stay disabled\n", err, method)
+			continue
+		}
+		r.Route(method, name)
+	}
+
+	// "local" is what development setups used before the fake gateway billed subscriptions
+	billing := strings.TrimSpace(os.Getenv("BILLING_PROVIDER"))
+	switch billing {
+	case "":
+		billing = "stripe"
+	case "local":
+		billing = "fake"
+	}
+	if _, err := r.Subscriptions(billing); err != nil {
+		log.Printf("%v, subscriptions go through stripe\n", err)
+		billing = "stripe"
+	}
+	r.RouteSubscriptions(billing)
+	return r
+}
@@ -0,0 +1,64 @@
+package gateway
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryPicksTheGatewayForEachMethod(t *testing.T) {
+	r := NewRegistry(NewStripeGateway(), NewFakeGateway(""))
+	r.Route(MethodCard, "stripe")
+	r.Route(MethodQRIS, "fake")
+
+	tests := []struct {
+		method  string
+		want    string
+		wantErr string
+	}{
+		{method: MethodCard, want: "stripe"},
+		{method: MethodQRIS, want: "fake"},
+		{method: MethodEWallet, wantErr: "not available"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			g, err := r.ForMethod(tt.method)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || g.Name() != tt.want {
+				t.Fatalf("gateway = %v, %v, want %s", g, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := r.Get("paypal"); err == nil || !strings.Contains(err.Error(), "not configured") {
+		t.Fatalf("err = %v, want an unknown gateway rejected", err)
+	}
+	// a method routed to a gateway that is not registered is not available either
+	r.Route(MethodVirtualAccount, "paypal")
+	if _, err := r.ForMethod(MethodVirtualAccount); err == nil {
+		t.Fatalf("a method routed to an unknown gateway was accepted")
+	}
+}
+
+func TestRegistryFromEnvSkipsUnknownGateways(t *testing.T) {
+	t.Setenv("PAYMENT_GATEWAYS", "qris=fake, ewallet=paypal, broken")
+	t.Setenv("BILLING_PROVIDER", "local")
+	r := NewRegistryFromEnv()
+
+	if g, err := r.ForMethod(MethodCard); err != nil || g.Name() != "stripe" {
+		t.Fatalf("card = %v, %v, want stripe", g, err)
+	}
+	if g, err := r.ForMethod(MethodQRIS); err != nil || g.Name() != "fake" {
+		t.Fatalf("qris = %v, %v, want fake", g, err)
+	}
+	if _, err := r.ForMethod(MethodEWallet); err == nil {
+		t.Fatalf("ewallet was routed to a gateway that is not configured")
+	}
+	if g, err := r.ForSubscriptions(); err != nil || g.Name() != "fake" {
+		t.Fatalf("subscriptions = %v, %v, want the fake gateway for BILLING_PROVIDER=local", g, err)
+	}
+}
@@ -0,0 +1,302 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"server/pkg/money"
+
+	"github.com/stripe/stripe-go/v75"
+	"github.com/stripe/stripe-go/v75/checkout/session"
+	"github.com/stripe/stripe-go/v75/refund"
+	"github.com/stripe/stripe-go/v75/subscription"
+	"github.com/stripe/stripe-go/v75/webhook"
+)
+
+// StripeGateway takes card payments through Stripe Checkout and bills memberships as Stripe subscriptions,
+// Stripe amounts are in minor units like ours
+type StripeGateway struct {
+	webhookSecret string
+}
+
+func NewStripeGateway() *StripeGateway {
+	return &StripeGateway{
+		webhookSecret: os.Getenv("STRIPE_WEBHOOK_SECRET"),
+	}
+}
+
+func (g *StripeGateway) Name() string {
+	return "stripe"
+}
+
+func (g *StripeGateway) CreateCheckout(req CheckoutRequest) (*Checkout, error) {
+	if req.Method != MethodCard {
+		return nil, fmt.Errorf("stripe gateway does not take %s payments", req.Method)
+	}
+
+	lineItems := make([]*stripe.CheckoutSessionLineItemParams, 0, len(req.LineItems))
+	for _, item := range req.LineItems {
+		lineItems = append(lineItems, &stripe.CheckoutSessionLineItemParams{
+			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+				Currency: stripe.String(strings.ToLower(item.Amount.Currency)),
+				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+					Name: stripe.String(item.Name),
+				},
+				UnitAmount: stripe.Int64(item.Amount.Amount),
+			},
+			Quantity: stripe.Int64(item.Quantity),
+		})
+	}
+
+	params := &stripe.CheckoutSessionParams{
+		PaymentMethodTypes: stripe.StringSlice([]string{"card"}),
+		Mode:               stripe.String(string(stripe.CheckoutSessionModePayment)),
+		CustomerEmail:      stripe.String(req.CustomerEmail),
+		SuccessURL:         stripe.String(req.SuccessURL),
+		CancelURL:          stripe.String(req.CancelURL),
+		ClientReferenceID:  stripe.String(req.Reference),
+		LineItems:          lineItems,
+		Metadata:           req.Metadata,
+	}
+
+	sess, err := session.New(params)
+	if err != nil {
+		return nil, err
+	}
+	return &Checkout{SessionID: sess.ID, URL: sess.URL}, nil
+}
+
+func (g *StripeGateway) CreateSubscriptionCheckout(req SubscriptionRequest) (*Checkout, error) {
+	params := &stripe.CheckoutSessionParams{
+		PaymentMethodTypes: stripe.StringSlice([]string{"card"}),
+		Mode:               stripe.String(string(stripe.CheckoutSessionModeSubscription)),
+		CustomerEmail:      stripe.String(req.CustomerEmail),
+		SuccessURL:         stripe.String(req.SuccessURL),
+		CancelURL:          stripe.String(req.CancelURL),
+		ClientReferenceID:  stripe.String(req.Reference),
+		Metadata:           req.Metadata,
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+					Currency: stripe.String(strings.ToLower(req.Amount.Currency)),
+					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+						Name: stripe.String(req.ProductName),
+					},
+					UnitAmount: stripe.Int64(req.Amount.Amount),
+					Recurring: &stripe.CheckoutSessionLineItemPriceDataRecurringParams{
+						Interval:      stripe.String(string(stripe.PriceRecurringIntervalDay)),
+						IntervalCount: stripe.Int64(int64(req.IntervalDays)),
+					},
+				},
+				Quantity: stripe.Int64(1),
+			},
+		},
+		SubscriptionData: &stripe.CheckoutSessionSubscriptionDataParams{
+			Metadata: req.Metadata,
+		},
+	}
+
+	sess, err := session.New(params)
+	if err != nil {
+		return nil, err
+	}
+	return &Checkout{SessionID: sess.ID, URL: sess.URL}, nil
+}
+
+func (g *StripeGateway) CancelAtPeriodEnd(subscriptionID string) error {
+	_, err := subscription.Update(subscriptionID, &stripe.SubscriptionParams{
+		CancelAtPeriodEnd: stripe.Bool(true),
+	})
+	return err
+}
+
+func (g *StripeGateway) CancelNow(subscriptionID string) error {
+	_, err := subscription.Cancel(subscriptionID, &stripe.SubscriptionCancelParams{})
+	return err
+}
+
+// ParseWebhook verifies the Stripe-Signature header, checkout, charge, invoice and subscription events are
+// mapped to the normalized types and everything else is passed on as the raw stripe.Event
+func (g *StripeGateway) ParseWebhook(body []byte, header http.Header) (*Event, error) {
+	if _, err := webhook.ConstructEventWithOptions(body, header.Get("Stripe-Signature"), g.webhookSecret,
+		webhook.ConstructEventOptions{IgnoreAPIVersionMismatch: true}); err != nil {
+		return nil, err
+	}
+	return g.DecodeEvent(body)
+}
+
+func (g *StripeGateway) DecodeEvent(payload []byte) (*Event, error) {
+	var event stripe.Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid event payload")
+	}
+
+	result := &Event{ID: event.ID, Type: string(event.Type), Raw: event}
+	switch event.Type {
+	case "checkout.session.completed", "checkout.session.async_payment_succeeded",
+		"checkout.session.async_payment_failed", "checkout.session.expired":
+		var sess stripe.CheckoutSession
+		if err := json.Unmarshal(event.Data.Raw, &sess); err != nil {
+			return nil, fmt.Errorf("invalid session data")
+		}
+		result.Reference = sess.Metadata["order_id"]
+		result.SessionID = sess.ID
+		result.Method = MethodCard
+		if len(sess.PaymentMethodTypes) == 1 {
+			result.Method = sess.PaymentMethodTypes[0]
+		}
+		if sess.PaymentIntent != nil {
+			result.PaymentReference = sess.PaymentIntent.ID
+		}
+		if sess.Subscription != nil {
+			result.SubscriptionID = sess.Subscription.ID
+		}
+
+		switch event.Type {
+		case "checkout.session.completed":
+			// delayed methods complete the checkout unpaid and report the outcome as an async event later
+			result.Type = EventPaymentSucceeded
+			if sess.PaymentStatus == stripe.CheckoutSessionPaymentStatusUnpaid {
+				result.Type = EventPaymentProcessing
+			}
+		case "checkout.session.async_payment_succeeded":
+			result.Type = EventPaymentSucceeded
+		case "checkout.session.async_payment_failed":
+			result.Type = EventPaymentFailed
+		default:
+			result.Type = EventCheckoutExpired
+		}
+
+	case "charge.refunded":
+		var charge stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &charge); err != nil {
+			return nil, fmt.Errorf("invalid charge data")
+		}
+		result.Type = EventPaymentRefunded
+		result.Amount = money.New(charge.AmountRefunded, string(charge.Currency))
+		if charge.PaymentIntent != nil {
+			result.PaymentReference = charge.PaymentIntent.ID
+		}
+
+	case "charge.dispute.created":
+		var dispute stripe.Dispute
+		if err := json.Unmarshal(event.Data.Raw, &dispute); err != nil {
+			return nil, fmt.Errorf("invalid dispute data")
+		}
+		result.Type = EventPaymentDisputed
+		result.Amount = money.New(dispute.Amount, string(dispute.Currency))
+		result.Reason = string(dispute.Reason)
+		if dispute.PaymentIntent != nil {
+			result.PaymentReference = dispute.PaymentIntent.ID
+		}
+
+	case "invoice.paid", "invoice.payment_failed":
+		invoice, err := decodeStripeInvoice(event.Data.Raw)
+		if err != nil {
+			return nil, err
+		}
+		result.Type = EventInvoicePaid
+		if event.Type == "invoice.payment_failed" {
+			result.Type = EventInvoicePaymentFailed
+		}
+		result.Invoice = invoice
+		result.SubscriptionID = invoice.SubscriptionID
+
+	case "customer.subscription.deleted":
+		var sub stripe.Subscription
+		if err := json.Unmarshal(event.Data.Raw, &sub); err != nil || sub.ID == "" {
+			return nil, fmt.Errorf("invalid subscription data")
+		}
+		result.Type = EventSubscriptionEnded
+		result.SubscriptionID = sub.ID
+	}
+	return result, nil
+}
+
+func decodeStripeInvoice(raw json.RawMessage) (*Invoice, error) {
+	var inv stripe.Invoice
+	if err := json.Unmarshal(raw, &inv); err != nil {
+		return nil, fmt.Errorf("invalid invoice data")
+	}
+	if inv.Subscription == nil || inv.Subscription.ID == "" {
+		return nil, fmt.Errorf("invoice %s is not linked to a subscription", inv.ID)
+	}
+
+	result := &Invoice{
+		ID:             inv.ID,
+		SubscriptionID: inv.Subscription.ID,
+		AmountPaid:     money.New(inv.AmountPaid, string(inv.Currency)),
+		AttemptCount:   int(inv.AttemptCount),
+		HostedURL:      inv.HostedInvoiceURL,
+	}
+	if inv.PaymentIntent != nil {
+		result.PaymentReference = inv.PaymentIntent.ID
+	}
+	switch inv.BillingReason {
+	case stripe.InvoiceBillingReasonSubscriptionCreate:
+		result.Reason = InvoiceReasonCreate
+	case stripe.InvoiceBillingReasonSubscriptionCycle:
+		result.Reason = InvoiceReasonCycle
+	default:
+		result.Reason = string(inv.BillingReason)
+	}
+	if inv.NextPaymentAttempt > 0 {
+		next := time.Unix(inv.NextPaymentAttempt, 0).UTC()
+		result.NextAttemptAt = &next
+	}
+	return result, nil
+}
+
+// Refund refunds the payment intent of a checkout, Stripe only accepts its fixed reasons so ours goes in the metadata
+func (g *StripeGateway) Refund(req RefundRequest) (*Refund, error) {
+	if req.PaymentReference == "" {
+		return nil, fmt.Errorf("payment has no stripe payment intent to refund")
+	}
+
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(req.PaymentReference),
+		Amount:        stripe.Int64(req.Amount.Amount),
+		Reason:        stripe.String(string(stripe.RefundReasonRequestedByCustomer)),
+	}
+	for key, value := range req.Metadata {
+		params.AddMetadata(key, value)
+	}
+	params.AddMetadata("reason", req.Reason)
+
+	re, err := refund.New(params)
+	if err != nil {
+		return nil, err
+	}
+
+	status := RefundStatusPending
+	switch re.Status {
+	case stripe.RefundStatusSucceeded:
+		status = RefundStatusSucceeded
+	case stripe.RefundStatusFailed, stripe.RefundStatusCanceled:
+		status = RefundStatusFailed
+	}
+	return &Refund{ID: re.ID, Status: status}, nil
+}
+
+func (g *StripeGateway) QueryStatus(sessionID string) (*Status, error) {
+	sess, err := session.Get(sessionID, &stripe.CheckoutSessionParams{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Status{State: StatePending, Method: MethodCard, Amount: money.New(sess.AmountTotal, string(sess.Currency))}
+	if sess.PaymentIntent != nil {
+		result.PaymentReference = sess.PaymentIntent.ID
+	}
+	switch {
+	case sess.Status == stripe.CheckoutSessionStatusExpired:
+		result.State = StateExpired
+	case sess.Status == stripe.CheckoutSessionStatusComplete && sess.PaymentStatus != stripe.CheckoutSessionPaymentStatusUnpaid:
+		result.State = StatePaid
+	}
+	return result, nil
+}
@@ -0,0 +1,50 @@
+package gateway
+
+import (
+	"testing"
+)
+
+func TestStripeInvoiceEventsAreNormalized(t *testing.T) {
+	payload := []byte(`{
+		"id": "evt_1",
+		"type": "invoice.payment_failed",
+		"data": {"object": {
+			"id": "in_1",
+			"object": "invoice",
+			"subscription": "sub_1",
+			"payment_intent": "pi_1",
+			"amount_paid": 0,
+			"currency": "idr",
+			"attempt_count": 2,
+			"billing_reason": "subscription_cycle",
+			"next_payment_attempt": 1767225600
+		}}
+	}`)
+
+	event, err := NewStripeGateway().DecodeEvent(payload)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if event.Type != EventInvoicePaymentFailed || event.SubscriptionID != "sub_1" || event.Invoice == nil {
+		t.Fatalf("event = %s/%s/%v, want a failed invoice of sub_1", event.Type, event.SubscriptionID, event.Invoice)
+	}
+	invoice := event.Invoice
+	if invoice.ID != "in_1" || invoice.Reason != InvoiceReasonCycle || invoice.AttemptCount != 2 || invoice.PaymentReference != "pi_1" {
+		t.Fatalf("invoice = %+v", invoice)
+	}
+	if invoice.NextAttemptAt == nil || invoice.NextAttemptAt.Unix() != 1767225600 {
+		t.Fatalf("next attempt = %v", invoice.NextAttemptAt)
+	}
+}
+
+func TestStripeSubscriptionDeletedIsNormalized(t *testing.T) {
+	payload := []byte(`{"id": "evt_2", "type": "customer.subscription.deleted", "data": {"object": {"id": "sub_1", "object": "subscription"}}}`)
+
+	event, err := NewStripeGateway().DecodeEvent(payload)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if event.Type != EventSubscriptionEnded || event.SubscriptionID != "sub_1" {
+		t.Fatalf("event = %s/%s, want subscription.ended of sub_1", event.Type, event.SubscriptionID)
+	}
+}
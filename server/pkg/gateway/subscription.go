@@ -0,0 +1,46 @@
+package gateway
+
+import (
+	"time"
+
+	"server/pkg/money"
+)
+
+// SubscriptionGateway is a gateway that also bills auto-renewing memberships, renewals are reported through the
+// same webhook as EventInvoicePaid and EventInvoicePaymentFailed and the end of a subscription as
+// EventSubscriptionEnded
+type SubscriptionGateway interface {
+	PaymentGateway
+	CreateSubscriptionCheckout(req SubscriptionRequest) (*Checkout, error)
+	CancelAtPeriodEnd(subscriptionID string) error
+	CancelNow(subscriptionID string) error
+}
+
+// SubscriptionRequest opens a checkout that charges Amount every IntervalDays until canceled
+type SubscriptionRequest struct {
+	Reference     string
+	CustomerEmail string
+	ProductName   string
+	Amount        money.Money
+	IntervalDays  int
+	SuccessURL    string
+	CancelURL     string
+	Metadata      map[string]string
+}
+
+// Invoice is a renewal attempt reported back by the gateway
+type Invoice struct {
+	ID               string
+	SubscriptionID   string
+	PaymentReference string
+	Reason           string
+	AmountPaid       money.Money
+	AttemptCount     int
+	NextAttemptAt    *time.Time
+	HostedURL        string
+}
+
+const (
+	InvoiceReasonCreate = "create"
+	InvoiceReasonCycle  = "cycle"
+)
@@ -0,0 +1,43 @@
+package meeting
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const defaultLocalBaseURL = "http://localhost:5173/meet"
+
+// LocalProvider builds links without calling any external service, for development and tests
+type LocalProvider struct {
+	baseURL string
+}
+
+func NewLocalProvider(baseURL string) *LocalProvider {
+	if baseURL == "" {
+		baseURL = defaultLocalBaseURL
+	}
+	return &LocalProvider{baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (p *LocalProvider) Name() string {
+	return "local"
+}
+
+func (p *LocalProvider) CreateMeeting(req Request) (*Meeting, error) {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate passcode: %w", err)
+	}
+
+	id := uuid.New().String()
+	passcode := hex.EncodeToString(buf)
+	return &Meeting{
+		ID:       id,
+		JoinURL:  fmt.Sprintf("%s/%s?pwd=%s", p.baseURL, id, passcode),
+		Passcode: passcode,
+	}, nil
+}
@@ -0,0 +1,37 @@
+package meeting
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// Provider creates the online room for an online or hybrid class schedule
+type Provider interface {
+	Name() string
+	CreateMeeting(req Request) (*Meeting, error)
+}
+
+type Request struct {
+	ScheduleID string
+	Title      string
+	StartAt    time.Time
+	Duration   int
+}
+
+type Meeting struct {
+	ID       string
+	JoinURL  string
+	Passcode string
+}
+
+// NewProvider picks the provider from MEETING_PROVIDER, falling back to the local stub
+func NewProvider() Provider {
+	switch os.Getenv("MEETING_PROVIDER") {
+	case "", "local":
+		return NewLocalProvider(os.Getenv("MEETING_BASE_URL"))
+	default:
+		log.Printf("unknown meeting provider %q, using local provider\n", os.Getenv("MEETING_PROVIDER"))
+		return NewLocalProvider(os.Getenv("MEETING_BASE_URL"))
+	}
+}
@@ -30,6 +30,20 @@ func AuthRequired() gin.HandlerFunc {
 	}
 }
 
+// AuthOptional identifies the user when a valid token is sent, and lets anonymous requests through
+func AuthOptional() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, err := c.Cookie("accessToken")
+		if err == nil && tokenString != "" {
+			if claims, err := utils.DecodeAccessToken(tokenString); err == nil {
+				c.Set("role", claims.Role)
+				c.Set("userID", claims.UserID)
+			}
+		}
+		c.Next()
+	}
+}
+
 func RoleOnly(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role := utils.MustGetRole(c)
@@ -0,0 +1,187 @@
+package money
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in the smallest unit of its currency, e.g. cents, so sums and comparisons stay exact,
+// anything that scales an amount rounds half away from zero to the minor unit
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// zeroDecimal lists the ISO 4217 currencies without a minor unit, every other currency has two decimals
+var zeroDecimal = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "ISK": true, "JPY": true, "KMF": true, "KRW": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// DefaultCurrency is the currency prices are charged in, set with PAYMENT_CURRENCY
+func DefaultCurrency() string {
+	currency := strings.ToUpper(strings.TrimSpace(os.Getenv("PAYMENT_CURRENCY")))
+	if currency == "" {
+		return "IDR"
+	}
+	return currency
+}
+
+// Exponent is the number of decimals between the major and the minor unit of the currency
+func Exponent(currency string) int {
+	if zeroDecimal[strings.ToUpper(currency)] {
+		return 0
+	}
+	return 2
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+func Zero(currency string) Money {
+	return New(0, currency)
+}
+
+// FromMajor converts an amount given in major units, like 1500.50, rounding to the nearest minor unit
+func FromMajor(amount float64, currency string) Money {
+	return New(0, currency).addScaled(decimal(amount), pow10(Exponent(currency)))
+}
+
+// Major is the amount in major units, only meant for display and API responses
+func (m Money) Major() float64 {
+	return float64(m.Amount) / math.Pow10(Exponent(m.Currency))
+}
+
+func (m Money) Add(o Money) Money {
+	m.mustMatch(o)
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}
+}
+
+func (m Money) Sub(o Money) Money {
+	m.mustMatch(o)
+	return Money{Amount: m.Amount - o.Amount, Currency: m.Currency}
+}
+
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// MulRate multiplies by rate, e.g. 0.11 for a tax of 11%
+func (m Money) MulRate(rate float64) Money {
+	return Zero(m.Currency).addScaled(decimal(rate), big.NewInt(m.Amount))
+}
+
+// Percent is pct percent of the amount, e.g. Percent(15) for 15% off
+func (m Money) Percent(pct float64) Money {
+	rate := new(big.Rat).Quo(decimal(pct), big.NewRat(100, 1))
+	return Zero(m.Currency).addScaled(rate, big.NewInt(m.Amount))
+}
+
+// WithoutRate is the amount before rate was added on top of it, e.g. the net of a price that includes tax
+func (m Money) WithoutRate(rate float64) Money {
+	factor := new(big.Rat).Add(big.NewRat(1, 1), decimal(rate))
+	return Zero(m.Currency).addScaled(factor.Inv(factor), big.NewInt(m.Amount))
+}
+
+// Ratio scales the amount by num/den, it is used to prorate an amount by days or credits left
+func (m Money) Ratio(num, den int64) Money {
+	if den == 0 {
+		return Zero(m.Currency)
+	}
+	return Zero(m.Currency).addScaled(big.NewRat(num, den), big.NewInt(m.Amount))
+}
+
+func (m Money) Min(o Money) Money {
+	m.mustMatch(o)
+	if o.Amount < m.Amount {
+		return o
+	}
+	return m
+}
+
+func (m Money) Max(o Money) Money {
+	m.mustMatch(o)
+	if o.Amount > m.Amount {
+		return o
+	}
+	return m
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// String formats the amount with its currency, e.g. "IDR 150,000.00"
+func (m Money) String() string {
+	exp := Exponent(m.Currency)
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	unit := int64(math.Pow10(exp))
+	digits := strconv.FormatInt(amount/unit, 10)
+	var grouped strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(d)
+	}
+	if exp > 0 {
+		return fmt.Sprintf("%s %s%s.%0*d", m.Currency, sign, grouped.String(), exp, amount%unit)
+	}
+	return fmt.Sprintf("%s %s%s", m.Currency, sign, grouped.String())
+}
+
+// mustMatch guards against adding up amounts in different currencies, which is always a programming error
+func (m Money) mustMatch(o Money) {
+	if m.Currency != o.Currency {
+		panic(fmt.Sprintf("money: currency mismatch %s and %s", m.Currency, o.Currency))
+	}
+}
+
+// addScaled adds factor*scale rounded half away from zero to the minor unit
+func (m Money) addScaled(factor *big.Rat, scale *big.Int) Money {
+	product := new(big.Rat).Mul(factor, new(big.Rat).SetInt(scale))
+	return Money{Amount: m.Amount + roundHalfUp(product), Currency: m.Currency}
+}
+
+func roundHalfUp(r *big.Rat) int64 {
+	num, den := new(big.Int).Abs(r.Num()), r.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		quo.Neg(quo)
+	}
+	return quo.Int64()
+}
+
+// decimal reads a float as the shortest decimal that prints as it, so 0.1 is one tenth and not its binary neighbour
+func decimal(f float64) *big.Rat {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+func pow10(exp int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)
+}
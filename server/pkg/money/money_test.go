@@ -0,0 +1,158 @@
+package money
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoundingIsHalfUp(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Money
+		want int64
+	}{
+		{name: "half rounds up", got: New(5, "USD").Percent(50), want: 3},
+		{name: "below half rounds down", got: New(149, "USD").Percent(1), want: 1},
+		{name: "half of a rate", got: New(250, "USD").MulRate(0.11), want: 28},
+		{name: "tenth is exact", got: New(1000, "USD").MulRate(0.1), want: 100},
+		{name: "major half cent", got: FromMajor(1.005, "USD"), want: 101},
+		{name: "major without minor unit", got: FromMajor(1500.5, "JPY"), want: 1501},
+		{name: "net of an inclusive price", got: New(111, "IDR").WithoutRate(0.11), want: 100},
+		{name: "prorated by days", got: New(1000, "USD").Ratio(1, 8), want: 125},
+		{name: "ratio over nothing", got: New(1000, "USD").Ratio(1, 0), want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got.Amount != tt.want {
+				t.Fatalf("amount = %d, want %d", tt.got.Amount, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegativeAmountsRoundAwayFromZero(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Money
+		want int64
+	}{
+		{name: "half", got: New(-5, "USD").Percent(50), want: -3},
+		{name: "below half", got: New(-149, "USD").Percent(1), want: -1},
+		{name: "rate", got: New(-250, "USD").MulRate(0.11), want: -28},
+		{name: "major", got: FromMajor(-1.005, "USD"), want: -101},
+		{name: "negated", got: New(250, "USD").Neg(), want: -250},
+		{name: "sub below zero", got: New(100, "USD").Sub(New(250, "USD")), want: -150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got.Amount != tt.want {
+				t.Fatalf("amount = %d, want %d", tt.got.Amount, tt.want)
+			}
+		})
+	}
+	if !New(-1, "USD").IsNegative() || New(-1, "USD").IsPositive() {
+		t.Fatalf("a negative amount is not reported as negative")
+	}
+}
+
+func TestMinorUnitsPerCurrency(t *testing.T) {
+	tests := []struct {
+		currency string
+		major    float64
+		exponent int
+		amount   int64
+		text     string
+	}{
+		{currency: "IDR", major: 150000, exponent: 2, amount: 15000000, text: "IDR 150,000.00"},
+		{currency: "usd", major: 12.34, exponent: 2, amount: 1234, text: "USD 12.34"},
+		{currency: "JPY", major: 1500, exponent: 0, amount: 1500, text: "JPY 1,500"},
+		{currency: "krw", major: 1234567, exponent: 0, amount: 1234567, text: "KRW 1,234,567"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			if got := Exponent(tt.currency); got != tt.exponent {
+				t.Fatalf("exponent = %d, want %d", got, tt.exponent)
+			}
+			m := FromMajor(tt.major, tt.currency)
+			if m.Amount != tt.amount || m.Currency != strings.ToUpper(tt.currency) {
+				t.Fatalf("FromMajor = %d %s, want %d", m.Amount, m.Currency, tt.amount)
+			}
+			if m.Major() != tt.major {
+				t.Fatalf("Major = %v, want %v", m.Major(), tt.major)
+			}
+			if m.String() != tt.text {
+				t.Fatalf("String = %q, want %q", m.String(), tt.text)
+			}
+		})
+	}
+}
+
+// shares are rounded one by one, so a split takes the last share as what is left to keep the total exact
+func TestSplitKeepsTheRemainder(t *testing.T) {
+	tests := []struct {
+		name   string
+		total  Money
+		weight []int64
+		want   []int64
+	}{
+		{name: "thirds", total: New(10000, "USD"), weight: []int64{1, 1, 1}, want: []int64{3333, 3333, 3334}},
+		{name: "uneven", total: New(100, "IDR"), weight: []int64{1, 2}, want: []int64{33, 67}},
+		{name: "rounded up share", total: New(1001, "USD"), weight: []int64{1, 1}, want: []int64{501, 500}},
+		{name: "negative", total: New(-100, "USD"), weight: []int64{1, 1, 1}, want: []int64{-33, -33, -34}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sum int64
+			for _, w := range tt.weight {
+				sum += w
+			}
+
+			left := tt.total
+			for i, w := range tt.weight {
+				share := left
+				if i < len(tt.weight)-1 {
+					share = tt.total.Ratio(w, sum)
+				}
+				if share.Amount != tt.want[i] {
+					t.Fatalf("share %d = %d, want %d", i, share.Amount, tt.want[i])
+				}
+				left = left.Sub(share)
+			}
+			if !left.IsZero() {
+				t.Fatalf("%d left over after the split", left.Amount)
+			}
+		})
+	}
+
+	// an inclusive price splits into net and tax without losing the rounding
+	total := New(9999, "IDR")
+	net := total.WithoutRate(0.11)
+	if tax := total.Sub(net); net.Add(tax) != total || net.Amount != 9008 || tax.Amount != 991 {
+		t.Fatalf("net %d + tax %d, want 9008 + 991", net.Amount, tax.Amount)
+	}
+}
+
+func TestCurrencyMismatchPanics(t *testing.T) {
+	ops := map[string]func(a, b Money){
+		"Add": func(a, b Money) { a.Add(b) },
+		"Sub": func(a, b Money) { a.Sub(b) },
+		"Min": func(a, b Money) { a.Min(b) },
+		"Max": func(a, b Money) { a.Max(b) },
+	}
+	for name, op := range ops {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if r == nil || !strings.Contains(r.(string), "currency mismatch IDR and USD") {
+					t.Fatalf("recovered %v, want a currency mismatch panic", r)
+				}
+			}()
+			op(New(100, "IDR"), New(100, "USD"))
+		})
+	}
+
+	// the currency is normalized, so the same currency in another case matches
+	if got := New(100, "idr").Add(New(1, "IDR")); got.Amount != 101 {
+		t.Fatalf("amount = %d, want 101", got.Amount)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/datatypes"
@@ -84,3 +86,73 @@ func GetTaxRate() float64 {
 	}
 	return rate
 }
+
+// ScheduleStartTime returns the start of a schedule, schedules are stored as wall-clock time of the studio
+func ScheduleStartTime(date time.Time, hour, minute int) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, GetStudioLocation())
+}
+
+// GetMeetingLinkLeadTime is how long before class start online attendees can see the meeting link
+func GetMeetingLinkLeadTime() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("MEETING_LINK_LEAD_MINUTES"))
+	if err != nil || minutes < 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// WeekRange returns the Monday that starts the week of date and the Monday after it
+func WeekRange(date time.Time) (time.Time, time.Time) {
+	offset := (int(date.Weekday()) + 6) % 7
+	start := time.Date(date.Year(), date.Month(), date.Day()-offset, 0, 0, 0, 0, date.Location())
+	return start, start.AddDate(0, 0, 7)
+}
+
+// GetExpiryReminderDays lists how many days before expiry members are reminded, largest first
+func GetExpiryReminderDays() []int {
+	val := os.Getenv("PACKAGE_EXPIRY_REMINDER_DAYS")
+	if val == "" {
+		return []int{7, 3, 1}
+	}
+
+	var days []int
+	for _, part := range strings.Split(val, ",") {
+		d, err := strconv.Atoi(strings.TrimSpace(part))
+		if err == nil && d > 0 {
+			days = append(days, d)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(days)))
+	return days
+}
+
+// GetReconcileAfter is how long a checkout stays pending before reconciliation asks its gateway about it
+func GetReconcileAfter() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("PAYMENT_RECONCILE_AFTER_MINUTES"))
+	if err != nil || minutes <= 0 {
+		return 30 * time.Minute
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GetSubscriptionMaxRetries is how many failed renewal attempts are tolerated before a subscription is canceled
+func GetSubscriptionMaxRetries() int {
+	val, err := strconv.Atoi(os.Getenv("SUBSCRIPTION_MAX_RETRIES"))
+	if err != nil || val <= 0 {
+		return 3
+	}
+	return val
+}
+
+// GetStudioLocation is the time zone of the studio's calendar, STUDIO_TIMEZONE takes an IANA name like Asia/Jakarta
+func GetStudioLocation() *time.Location {
+	name := strings.TrimSpace(os.Getenv("STUDIO_TIMEZONE"))
+	if name == "" {
+		name = "Asia/Jakarta"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -1,8 +1,11 @@
 package utils
 
 import (
+	crand "crypto/rand"
 	"fmt"
+	"log"
 	"math/rand"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -26,6 +29,19 @@ func GenerateOTP(length int) string {
 	return sb.String()
 }
 
+// GenerateGiftCode returns a code such as GIFT-7KQ2M9XA, ambiguous characters are left out
+func GenerateGiftCode() string {
+	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	b := make([]byte, 8)
+	if _, err := crand.Read(b); err != nil {
+		return "GIFT-" + strings.ToUpper(uuid.New().String()[:8])
+	}
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return "GIFT-" + string(b)
+}
+
 func GenerateSlug(input string) string {
 
 	slug := strings.ToLower(input)
@@ -76,10 +92,44 @@ func ParsePlatform(ua string) string {
 	}
 }
 
-func GenerateInvoiceNumber(paymentID uuid.UUID) string {
-	timestamp := time.Now().Format("20060102")
-	shortID := paymentID.String()[:8]
-	return fmt.Sprintf("INV/%s/%s", timestamp, shortID)
+const defaultInvoiceFormat = "INV/{YYYY}/{MM}/{SEQ:5}"
+
+var invoiceSeqToken = regexp.MustCompile(`\{SEQ(?::(\d))?\}`)
+
+// InvoiceNumberFormat is the pattern of invoice numbers, set with INVOICE_NUMBER_FORMAT. {YYYY}, {YY} and {MM}
+// are the year and month the payment was made, {LOC} the code of its location and {SEQ:n} the sequence number
+// padded to n digits. The numbering restarts whenever the rest of the number changes, so a format with {MM}
+// resets every month, one with only {YYYY} every year and one with {LOC} counts every location on its own
+func InvoiceNumberFormat() string {
+	format := strings.TrimSpace(os.Getenv("INVOICE_NUMBER_FORMAT"))
+	if format == "" {
+		return defaultInvoiceFormat
+	}
+	if len(invoiceSeqToken.FindAllString(format, -1)) != 1 {
+		log.Printf("INVOICE_NUMBER_FORMAT %q needs exactly one {SEQ} token, using %q\n", format, defaultInvoiceFormat)
+		return defaultInvoiceFormat
+	}
+	return format
+}
+
+// InvoiceSeries fills in every token of the format except the sequence, payments of the same series are
+// numbered one after the other. Periods follow the studio's calendar, see GetStudioLocation
+func InvoiceSeries(format string, paidAt time.Time, location string) string {
+	paidAt = paidAt.In(GetStudioLocation())
+	return strings.NewReplacer(
+		"{YYYY}", paidAt.Format("2006"),
+		"{YY}", paidAt.Format("06"),
+		"{MM}", paidAt.Format("01"),
+		"{LOC}", location,
+	).Replace(format)
+}
+
+// FormatInvoiceNumber puts the sequence number into its series
+func FormatInvoiceNumber(series string, seq int64) string {
+	return invoiceSeqToken.ReplaceAllStringFunc(series, func(token string) string {
+		digits, _ := strconv.Atoi(invoiceSeqToken.FindStringSubmatch(token)[1])
+		return fmt.Sprintf("%0*d", digits, seq)
+	})
 }
 
 func GenerateTimeLocal(date time.Time, hour, minute int) time.Time {
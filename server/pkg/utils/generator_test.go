@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvoiceNumbersFollowTheirSeries(t *testing.T) {
+	t.Setenv("STUDIO_TIMEZONE", "Asia/Jakarta")
+	paidAt := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		format   string
+		location string
+		seq      int64
+		want     string
+	}{
+		{name: "default format", format: defaultInvoiceFormat, seq: 42, want: "INV/2026/10/00042"},
+		{name: "per location", format: "{LOC}-{YY}{MM}-{SEQ:4}", location: "JKT", seq: 7, want: "JKT-2610-0007"},
+		{name: "unpadded", format: "R{YYYY}.{SEQ}", seq: 1234, want: "R2026.1234"},
+		{name: "sequence wider than the padding", format: "{SEQ:3}", seq: 12345, want: "12345"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatInvoiceNumber(InvoiceSeries(tt.format, paidAt, tt.location), tt.seq)
+			if got != tt.want {
+				t.Fatalf("number = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// the sequence is kept per series, payments of different series never share or skip numbers of each other
+func TestInvoiceSeriesSeparatesLocationsAndPeriods(t *testing.T) {
+	t.Setenv("STUDIO_TIMEZONE", "Asia/Jakarta")
+	format := "{LOC}/{YYYY}/{MM}/{SEQ:5}"
+	paidAt := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+
+	jakarta := InvoiceSeries(format, paidAt, "JKT")
+	if jakarta != InvoiceSeries(format, paidAt.Add(48*time.Hour), "JKT") {
+		t.Fatalf("payments of the same month and location are in different series")
+	}
+	if jakarta == InvoiceSeries(format, paidAt, "BDG") {
+		t.Fatalf("two locations share series %s", jakarta)
+	}
+	if jakarta == InvoiceSeries(format, paidAt.AddDate(0, 1, 0), "JKT") {
+		t.Fatalf("the next month continues series %s", jakarta)
+	}
+	for seq, want := range []string{"JKT/2026/10/00001", "JKT/2026/10/00002", "JKT/2026/10/00003"} {
+		if got := FormatInvoiceNumber(jakarta, int64(seq+1)); got != want {
+			t.Fatalf("number %d = %s, want %s", seq+1, got, want)
+		}
+	}
+}
+
+func TestInvoicePeriodsFollowTheStudioCalendar(t *testing.T) {
+	// 31 Oct 18:00 UTC is already 1 Nov in Jakarta and still 31 Oct in New York
+	paidAt := time.Date(2026, 10, 31, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		timezone string
+		want     string
+	}{
+		{timezone: "Asia/Jakarta", want: "INV/2026/11/"},
+		{timezone: "America/New_York", want: "INV/2026/10/"},
+		{timezone: "UTC", want: "INV/2026/10/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.timezone, func(t *testing.T) {
+			t.Setenv("STUDIO_TIMEZONE", tt.timezone)
+			if got := InvoiceSeries("INV/{YYYY}/{MM}/", paidAt, ""); got != tt.want {
+				t.Fatalf("series = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// the last evening of the year opens the next year's series in the studio
+	t.Setenv("STUDIO_TIMEZONE", "Asia/Jakarta")
+	if got := InvoiceSeries(defaultInvoiceFormat, time.Date(2026, 12, 31, 20, 0, 0, 0, time.UTC), ""); got != "INV/2027/01/{SEQ:5}" {
+		t.Fatalf("series = %s, want the January 2027 series", got)
+	}
+}
+
+func TestInvoiceNumberFormatNeedsOneSequence(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "", want: defaultInvoiceFormat},
+		{format: " {LOC}-{SEQ:6} ", want: "{LOC}-{SEQ:6}"},
+		{format: "INV/{YYYY}", want: defaultInvoiceFormat},
+		{format: "{SEQ}-{SEQ:2}", want: defaultInvoiceFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			t.Setenv("INVOICE_NUMBER_FORMAT", tt.format)
+			if got := InvoiceNumberFormat(); got != tt.want {
+				t.Fatalf("format = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"server/internal/config"
@@ -16,7 +17,14 @@ func SendNotificationEmail(to string, title string, message string) error {
 	return SendEmail(title, to, plainText, html)
 }
 
-func SendEmail(subject, toEmail, plainTextBody, htmlBody string) error {
+// Attachment is a file sent along with an email, e.g. a PDF receipt
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+func SendEmail(subject, toEmail, plainTextBody, htmlBody string, attachments ...Attachment) error {
 	m := gomail.NewMessage()
 	from := os.Getenv("USER_EMAIL")
 
@@ -25,6 +33,16 @@ func SendEmail(subject, toEmail, plainTextBody, htmlBody string) error {
 	m.SetHeader("Subject", subject)
 	m.SetBody("text/plain", plainTextBody)
 	m.AddAlternative("text/html", htmlBody)
+	for _, a := range attachments {
+		content := a.Content
+		m.Attach(a.Filename,
+			gomail.SetHeader(map[string][]string{"Content-Type": {a.ContentType}}),
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(content)
+				return err
+			}),
+		)
+	}
 
 	if err := config.MailDialer.DialAndSend(m); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"server/internal/dto"
+	customErr "server/pkg/errors"
+)
+
+const MaxImportFileSize = 1 * 1024 * 1024
+
+var scheduleCSVColumns = []string{"class", "instructor", "date", "time", "capacity"}
+
+// ParseScheduleImportFile reads an uploaded .csv or .ics file into import rows
+func ParseScheduleImportFile(fileHeader *multipart.FileHeader) ([]dto.ScheduleImportRow, error) {
+	if fileHeader.Size > MaxImportFileSize {
+		return nil, customErr.NewBadRequest("import file size exceeds 1MB limit")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, customErr.NewBadRequest("failed to open import file")
+	}
+	defer file.Close()
+
+	var rows []dto.ScheduleImportRow
+	switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+	case ".csv":
+		rows, err = ParseScheduleCSV(file)
+	case ".ics", ".ical":
+		rows, err = ParseScheduleICS(file)
+	default:
+		return nil, customErr.NewBadRequest("unsupported file type, only .csv and .ics are allowed")
+	}
+	if err != nil {
+		return nil, customErr.NewBadRequest(err.Error())
+	}
+	if len(rows) == 0 {
+		return nil, customErr.NewBadRequest("import file contains no schedule")
+	}
+	return rows, nil
+}
+
+// ParseScheduleCSV expects a header row with class, instructor, date, time, capacity and an optional color column
+func ParseScheduleCSV(r io.Reader) ([]dto.ScheduleImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, col := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(col, "\ufeff")))] = i
+	}
+	for _, col := range scheduleCSVColumns {
+		if _, ok := index[col]; !ok {
+			return nil, fmt.Errorf("missing required csv column %q", col)
+		}
+	}
+
+	field := func(record []string, col string) string {
+		i, ok := index[col]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []dto.ScheduleImportRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv line %d: %w", line, err)
+		}
+
+		row := dto.ScheduleImportRow{
+			Row:        line,
+			Class:      field(record, "class"),
+			Instructor: field(record, "instructor"),
+			Date:       field(record, "date"),
+			Time:       field(record, "time"),
+			Color:      field(record, "color"),
+		}
+
+		capacity, err := strconv.Atoi(field(record, "capacity"))
+		if err != nil {
+			row.Error = "capacity must be a number"
+		}
+		row.Capacity = capacity
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// ParseScheduleICS maps every VEVENT to a row: SUMMARY is the class, DTSTART the date and time,
+// X-INSTRUCTOR (or ORGANIZER;CN=) the instructor, X-CAPACITY the capacity and COLOR the color
+func ParseScheduleICS(r io.Reader) ([]dto.ScheduleImportRow, error) {
+	lines, err := unfoldICSLines(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ics file: %w", err)
+	}
+
+	var rows []dto.ScheduleImportRow
+	var current *dto.ScheduleImportRow
+
+	for _, line := range lines {
+		name, params, value := splitICSProperty(line)
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current = &dto.ScheduleImportRow{Row: len(rows) + 1}
+			continue
+		case name == "END" && value == "VEVENT":
+			if current != nil {
+				if current.Capacity <= 0 && current.Error == "" {
+					current.Error = "missing X-CAPACITY"
+				}
+				rows = append(rows, *current)
+			}
+			current = nil
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		switch name {
+		case "SUMMARY":
+			current.Class = value
+		case "X-INSTRUCTOR":
+			current.Instructor = value
+		case "ORGANIZER":
+			if current.Instructor == "" {
+				current.Instructor = params["CN"]
+			}
+		case "X-CAPACITY":
+			capacity, err := strconv.Atoi(value)
+			if err != nil {
+				current.Error = "X-CAPACITY must be a number"
+			}
+			current.Capacity = capacity
+		case "COLOR", "X-COLOR":
+			current.Color = value
+		case "DTSTART":
+			start, err := parseICSDateTime(value, params["TZID"])
+			if err != nil {
+				current.Error = err.Error()
+				continue
+			}
+			current.Date = start.Format("2006-01-02")
+			current.Time = start.Format("15:04")
+		}
+	}
+
+	return rows, nil
+}
+
+// ParseClockTime parses "HH:MM" into hour and minute
+func ParseClockTime(value string) (int, int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time %q, format must be HH:MM", value)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+func unfoldICSLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func splitICSProperty(line string) (string, map[string]string, string) {
+	params := map[string]string{}
+	head, value, found := strings.Cut(line, ":")
+	if !found {
+		return strings.ToUpper(line), params, ""
+	}
+
+	parts := strings.Split(head, ";")
+	for _, p := range parts[1:] {
+		if key, val, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+		}
+	}
+
+	value = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\\`, `\`).Replace(value)
+	return strings.ToUpper(parts[0]), params, strings.TrimSpace(value)
+}
+
+// schedules are stored as Asia/Jakarta wall-clock time, UTC and TZID values are converted to it
+func parseICSDateTime(value, tzid string) (time.Time, error) {
+	jakarta, _ := time.LoadLocation("Asia/Jakarta")
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid DTSTART %q", value)
+		}
+		return t.In(jakarta), nil
+	}
+
+	loc := time.UTC
+	if tzid != "" {
+		tz, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unknown TZID %q", tzid)
+		}
+		loc = tz
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid DTSTART %q, a date with time is required", value)
+	}
+	if tzid != "" {
+		return t.In(jakarta), nil
+	}
+	return t, nil
+}